@@ -0,0 +1,229 @@
+package once_cache
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// accessFlushInterval is how often an AccessRecorder flushes its buffered records.
+	accessFlushInterval = time.Second
+	// accessFlushSize is the buffered size at which an AccessRecorder flushes its records.
+	accessFlushSize = 4096
+)
+
+// WithAccessRecorder records the hits and misses of the cache to r, for example to replay them with
+// the cachesim package.
+func WithAccessRecorder(r *AccessRecorder) Option {
+	return func(o *OnceCache) {
+		o.recorder = r
+	}
+}
+
+type accessRecord struct {
+	at   int64
+	hash uint64
+	hit  bool
+}
+
+// AccessRecorder writes a sample of cache accesses as CSV lines of Unix nanoseconds, key hash and
+// "hit" or "miss". Keys are sampled by hash, so a sampled key has all of its accesses recorded and
+// hit ratios simulated on the sample stay representative. Only hashes are written, never keys.
+//
+// Recording never blocks the cache: records are queued in a bounded buffer and dropped when it is full.
+type AccessRecorder struct {
+	threshold uint64
+	records   chan accessRecord
+	dropped   atomic.Uint64
+	w         io.Writer
+	closed    atomic.Bool
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	err       error
+}
+
+// record queues an access if its key is sampled.
+func (r *AccessRecorder) record(key string, hit bool) {
+	if r == nil || r.closed.Load() {
+		return
+	}
+	hash := fnv1a(key)
+	if hash > r.threshold {
+		return
+	}
+	select {
+	case r.records <- accessRecord{at: time.Now().UnixNano(), hash: hash, hit: hit}:
+	default:
+		r.dropped.Add(1)
+	}
+}
+
+// Dropped returns how many sampled accesses were dropped because the buffer was full.
+func (r *AccessRecorder) Dropped() uint64 {
+	return r.dropped.Load()
+}
+
+// Close stops recording, flushes the queued records, closes the writer if it is an io.Closer and returns
+// the first write error. Accesses are no longer recorded afterwards.
+func (r *AccessRecorder) Close() error {
+	r.closeOnce.Do(func() {
+		r.closed.Store(true)
+		close(r.stop)
+		<-r.done
+		if c, ok := r.w.(io.Closer); ok {
+			if err := c.Close(); r.err == nil {
+				r.err = err
+			}
+		}
+	})
+	return r.err
+}
+
+func (r *AccessRecorder) run() {
+	defer close(r.done)
+	ticker := time.NewTicker(accessFlushInterval)
+	defer ticker.Stop()
+	// Buffer whole lines only, so that a RotatingFile never splits a line between files.
+	buf := make([]byte, 0, 2*accessFlushSize)
+	flush := func() {
+		if len(buf) > 0 {
+			_, err := r.w.Write(buf)
+			r.fail(err)
+			buf = buf[:0]
+		}
+	}
+	write := func(rec accessRecord) {
+		buf = strconv.AppendInt(buf, rec.at, 10)
+		buf = append(buf, ',')
+		buf = strconv.AppendUint(buf, rec.hash, 16)
+		if rec.hit {
+			buf = append(buf, ",hit\n"...)
+		} else {
+			buf = append(buf, ",miss\n"...)
+		}
+		if len(buf) >= accessFlushSize {
+			flush()
+		}
+	}
+	for {
+		select {
+		case rec := <-r.records:
+			write(rec)
+		case <-ticker.C:
+			flush()
+		case <-r.stop:
+			// Drain what was queued before Close.
+			for {
+				select {
+				case rec := <-r.records:
+					write(rec)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+func (r *AccessRecorder) fail(err error) {
+	if err != nil && r.err == nil {
+		r.err = err
+	}
+}
+
+// NewAccessRecorder creates a new instance of AccessRecorder writing to w the accesses of a fraction
+// sampleRate of the keys, between 0 and 1, with up to buffer records queued.
+func NewAccessRecorder(w io.Writer, sampleRate float64, buffer int) *AccessRecorder {
+	threshold := uint64(math.MaxUint64)
+	if sampleRate < 1 {
+		threshold = uint64(max(0, sampleRate) * math.MaxUint64)
+	}
+	r := &AccessRecorder{
+		threshold: threshold,
+		records:   make(chan accessRecord, buffer),
+		w:         w,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go r.run()
+	return r
+}
+
+// RotatingFile is an io.WriteCloser writing to a file that is rotated once it reaches a size,
+// keeping a bounded number of older files named with suffixes .1, .2 and so on.
+type RotatingFile struct {
+	mu       sync.Mutex
+	path     string
+	maxBytes int64
+	keep     int
+	f        *os.File
+	size     int64
+}
+
+// Write appends p to the current file, rotating it first if p would make it exceed the maximum size.
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.size > 0 && f.size+int64(len(p)) > f.maxBytes {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.f.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Close closes the current file.
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.f.Close()
+}
+
+func (f *RotatingFile) rotate() error {
+	if err := f.f.Close(); err != nil {
+		return err
+	}
+	if f.keep > 0 {
+		for i := f.keep - 1; i > 0; i-- {
+			_ = os.Rename(fmt.Sprintf("%s.%d", f.path, i), fmt.Sprintf("%s.%d", f.path, i+1))
+		}
+		if err := os.Rename(f.path, f.path+".1"); err != nil {
+			return err
+		}
+	}
+	return f.open(os.O_TRUNC)
+}
+
+func (f *RotatingFile) open(flag int) error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|flag, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.f, f.size = file, info.Size()
+	return nil
+}
+
+// NewRotatingFile creates a new instance of RotatingFile appending to path, rotating it at maxBytes
+// and keeping keep older files.
+func NewRotatingFile(path string, maxBytes int64, keep int) (*RotatingFile, error) {
+	f := &RotatingFile{path: path, maxBytes: maxBytes, keep: keep}
+	if err := f.open(os.O_APPEND); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
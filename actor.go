@@ -0,0 +1,149 @@
+package once_cache
+
+import (
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultActorIdleTimeout is how long an idle actor goroutine lives by default.
+	defaultActorIdleTimeout = time.Second
+	// actorQueueSize bounds the mutations waiting for an actor; submitters block beyond it.
+	actorQueueSize = 64
+)
+
+// WithActorIdleTimeout sets how long the goroutine of an Actor lives without mutations before it exits.
+// It defaults to one second; the next mutation of the key starts a new one.
+func WithActorIdleTimeout(d time.Duration) Option {
+	return func(o *OnceCache) {
+		o.actors.idle = d
+	}
+}
+
+// KeyActor funnels the mutations of one key through a single goroutine, so that they are applied one at a
+// time in the order they were submitted, see OnceCache.Actor.
+type KeyActor struct {
+	o   *OnceCache
+	key string
+}
+
+// Actor returns the actor of key. Mutations made through actors of the same key, from any goroutine, are
+// applied strictly in order by a goroutine started on the first of them and stopped once the key has been
+// idle for WithActorIdleTimeout. Writes made directly to the cache are not ordered with them.
+func (o *OnceCache) Actor(key string) *KeyActor {
+	return &KeyActor{o: o, key: key}
+}
+
+// Set stores the value with the specified time to live once the mutations submitted before it are applied.
+func (a *KeyActor) Set(value any, d time.Duration) {
+	a.o.actors.submit(a.o, a.key, func() {
+		a.o.Set(a.key, value, d)
+	})
+}
+
+// Delete removes the key once the mutations submitted before it are applied.
+func (a *KeyActor) Delete() {
+	a.o.actors.submit(a.o, a.key, func() {
+		a.o.Delete(a.key)
+	})
+}
+
+// Update replaces the value with the one f returns for the current value, with the specified time to live,
+// once the mutations submitted before it are applied. f returns false to delete the key instead. No other
+// mutation of the actor runs between the read and the write, so concurrent updates are never lost.
+// It returns the stored value and whether the key exists. If f panics, the key is left unchanged and the
+// panic is reported to the handler of WithBackgroundErrorHandler, or to DefaultSupervisor without one.
+func (a *KeyActor) Update(f func(old any, ok bool) (any, bool), d time.Duration) (value any, ok bool) {
+	a.o.actors.submit(a.o, a.key, func() {
+		value, ok = a.o.Get(a.key)
+		next, keep := f(value, ok)
+		if !keep {
+			a.o.Delete(a.key)
+			value, ok = nil, false
+			return
+		}
+		a.o.Set(a.key, next, d)
+		value, ok = next, true
+	})
+	return value, ok
+}
+
+// actorSet holds the goroutines of the keys with pending mutations.
+type actorSet struct {
+	idle    time.Duration
+	mu      sync.Mutex
+	workers map[string]*actorWorker
+}
+
+// actorWorker applies the mutations of a key in order.
+type actorWorker struct {
+	queue chan func()
+	// pending counts the mutations submitted but not applied yet. It is guarded by actorSet.mu.
+	pending int
+}
+
+// submit queues op for key, starting the key's goroutine if needed, and waits until it is applied.
+func (s *actorSet) submit(o *OnceCache, key string, op func()) {
+	s.mu.Lock()
+	w, ok := s.workers[key]
+	if !ok {
+		if s.workers == nil {
+			s.workers = make(map[string]*actorWorker)
+		}
+		w = &actorWorker{queue: make(chan func(), actorQueueSize)}
+		s.workers[key] = w
+		go s.run(o, key, w)
+	}
+	w.pending++
+	s.mu.Unlock()
+	done := make(chan struct{})
+	w.queue <- func() {
+		defer close(done)
+		op()
+	}
+	<-done
+}
+
+// run applies the mutations of key until it has been idle for s.idle.
+func (s *actorSet) run(o *OnceCache, key string, w *actorWorker) {
+	idle := s.idle
+	if idle <= 0 {
+		idle = defaultActorIdleTimeout
+	}
+	timer := time.NewTimer(idle)
+	defer timer.Stop()
+	for {
+		select {
+		case op := <-w.queue:
+			o.applyActorOp(key, op)
+			s.mu.Lock()
+			w.pending--
+			s.mu.Unlock()
+			if !timer.Stop() {
+				<-timer.C
+			}
+			timer.Reset(idle)
+		case <-timer.C:
+			s.mu.Lock()
+			if w.pending == 0 {
+				// Submitters find no worker from now on and start a new one.
+				delete(s.workers, key)
+				s.mu.Unlock()
+				return
+			}
+			s.mu.Unlock()
+			timer.Reset(idle)
+		}
+	}
+}
+
+// applyActorOp applies a mutation, reporting a panic instead of stopping the actor.
+func (o *OnceCache) applyActorOp(key string, op func()) {
+	defer func() {
+		if v := recover(); v != nil {
+			o.crashed(key, &PanicError{Name: "actor of " + key, Value: v, Stack: debug.Stack()})
+		}
+	}()
+	op()
+}
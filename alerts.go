@@ -0,0 +1,208 @@
+package once_cache
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// alertSampleSize is how many entries are measured to estimate the memory of the store, see EstimatedBytes.
+const alertSampleSize = 1000
+
+// AlertKind identifies the limit an Alert is about.
+type AlertKind int
+
+const (
+	// AlertEntries is raised when the store holds more entries than AlertLimits.MaxEntries.
+	AlertEntries AlertKind = iota
+	// AlertMemory is raised when the estimated memory of the store exceeds AlertLimits.MaxBytes.
+	AlertMemory
+	// AlertMissRate is raised when the miss rate over a window exceeds AlertLimits.MaxMissRate.
+	AlertMissRate
+	// AlertLoadErrorRate is raised when the rate of failed loads over a window exceeds AlertLimits.MaxLoadErrorRate.
+	AlertLoadErrorRate
+
+	alertKinds = iota
+)
+
+// String returns the name of the alert kind.
+func (k AlertKind) String() string {
+	switch k {
+	case AlertEntries:
+		return "entries"
+	case AlertMemory:
+		return "memory"
+	case AlertMissRate:
+		return "miss_rate"
+	case AlertLoadErrorRate:
+		return "load_error_rate"
+	}
+	return fmt.Sprintf("AlertKind(%d)", int(k))
+}
+
+// Alert reports a cache crossing one of its limits, or going back under it.
+type Alert struct {
+	Kind AlertKind
+	// Label is the label of the cache, see WithLabel.
+	Label string
+	// Value is the measured entry count, bytes or rate, and Limit the limit it is compared with.
+	Value float64
+	Limit float64
+	// Resolved reports that the value went back under the limit after an alert.
+	Resolved bool
+	Time     time.Time
+}
+
+// AlertLimits are the soft limits watched by WithAlerts. Zero limits are not watched.
+type AlertLimits struct {
+	// MaxEntries bounds the number of entries of the store, which must have a Len method, as MemoryCache does.
+	MaxEntries int
+	// MaxBytes bounds the estimated memory of the store, which must have an EstimatedBytes method, as
+	// MemoryCache does.
+	MaxBytes int64
+	// MaxMissRate bounds the fraction of lookups that miss over a window, in [0, 1].
+	MaxMissRate float64
+	// MaxLoadErrorRate bounds the fraction of loads that fail over a window, in [0, 1].
+	MaxLoadErrorRate float64
+	// Window is how often the limits are checked and over which the rates are measured. It defaults to one minute.
+	Window time.Duration
+	// MinSamples is how many lookups or loads a window needs before its rate is compared with the limit,
+	// so that a few misses of a quiet cache do not raise alerts. It defaults to 100.
+	MinSamples uint64
+}
+
+// WithAlerts watches soft limits of the cache and calls notify when one is crossed, and again when the value
+// goes back under it, so operators get early warning before a cache-related incident. The limits are checked
+// at the end of each window by the call that ends it, or by CheckAlerts; notify runs on that goroutine and
+// should return quickly, see AlertChannel.
+func WithAlerts(limits AlertLimits, notify func(Alert)) Option {
+	return func(o *OnceCache) {
+		if limits.Window <= 0 {
+			limits.Window = time.Minute
+		}
+		if limits.MinSamples == 0 {
+			limits.MinSamples = 100
+		}
+		a := &alerter{limits: limits, notify: notify}
+		for _, s := range []any{o.store, o.ICache} {
+			if l, ok := s.(interface{ Len() int }); ok && a.len == nil {
+				a.len = l.Len
+			}
+			if b, ok := s.(interface{ EstimatedBytes(sampleSize int) int64 }); ok && a.bytes == nil {
+				a.bytes = b.EstimatedBytes
+			}
+		}
+		a.windowStart.Store(time.Now().UnixNano())
+		o.alerts = a
+	}
+}
+
+// AlertChannel returns a notify function for WithAlerts sending alerts to ch, dropping them when ch is full.
+func AlertChannel(ch chan<- Alert) func(Alert) {
+	return func(alert Alert) {
+		select {
+		case ch <- alert:
+		default:
+		}
+	}
+}
+
+// CheckAlerts checks the limits set with WithAlerts now, ending the current window.
+func (o *OnceCache) CheckAlerts() {
+	if o.alerts != nil {
+		o.alerts.check(time.Now())
+	}
+}
+
+// alerter holds the counters of the current window and the state of each alert.
+type alerter struct {
+	limits AlertLimits
+	notify func(Alert)
+	label  string
+	len    func() int
+	bytes  func(sampleSize int) int64
+
+	windowStart                     atomic.Int64
+	hits, misses, loads, loadErrors atomic.Uint64
+
+	mu     sync.Mutex
+	firing [alertKinds]bool
+}
+
+func (a *alerter) recordLookup(hit bool) {
+	if a == nil {
+		return
+	}
+	if hit {
+		a.hits.Add(1)
+	} else {
+		a.misses.Add(1)
+	}
+	a.maybeCheck()
+}
+
+func (a *alerter) recordLoad(err error) {
+	if a == nil {
+		return
+	}
+	a.loads.Add(1)
+	if err != nil {
+		a.loadErrors.Add(1)
+	}
+	a.maybeCheck()
+}
+
+// maybeCheck checks the limits if the window is over, once for all the callers noticing it.
+func (a *alerter) maybeCheck() {
+	start := a.windowStart.Load()
+	now := time.Now()
+	if now.UnixNano()-start < int64(a.limits.Window) {
+		return
+	}
+	if a.windowStart.CompareAndSwap(start, now.UnixNano()) {
+		a.evaluate(now)
+	}
+}
+
+// check ends the current window and checks the limits.
+func (a *alerter) check(now time.Time) {
+	a.windowStart.Store(now.UnixNano())
+	a.evaluate(now)
+}
+
+func (a *alerter) evaluate(now time.Time) {
+	hits, misses := a.hits.Swap(0), a.misses.Swap(0)
+	loads, loadErrors := a.loads.Swap(0), a.loadErrors.Swap(0)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	l := a.limits
+	if l.MaxEntries > 0 && a.len != nil {
+		n := a.len()
+		a.set(now, AlertEntries, float64(n), float64(l.MaxEntries), n > l.MaxEntries)
+	}
+	if l.MaxBytes > 0 && a.bytes != nil {
+		n := a.bytes(alertSampleSize)
+		a.set(now, AlertMemory, float64(n), float64(l.MaxBytes), n > l.MaxBytes)
+	}
+	if lookups := hits + misses; l.MaxMissRate > 0 && lookups >= l.MinSamples {
+		rate := float64(misses) / float64(lookups)
+		a.set(now, AlertMissRate, rate, l.MaxMissRate, rate > l.MaxMissRate)
+	}
+	if l.MaxLoadErrorRate > 0 && loads >= l.MinSamples {
+		rate := float64(loadErrors) / float64(loads)
+		a.set(now, AlertLoadErrorRate, rate, l.MaxLoadErrorRate, rate > l.MaxLoadErrorRate)
+	}
+}
+
+// set notifies when the alert of kind starts or stops firing. a.mu must be held.
+func (a *alerter) set(now time.Time, kind AlertKind, value, limit float64, over bool) {
+	if a.firing[kind] == over {
+		return
+	}
+	a.firing[kind] = over
+	if a.notify != nil {
+		a.notify(Alert{Kind: kind, Label: a.label, Value: value, Limit: limit, Resolved: !over, Time: now})
+	}
+}
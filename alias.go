@@ -0,0 +1,33 @@
+package once_cache
+
+import (
+	"time"
+)
+
+// aliasPointer is stored under an alias key in place of a value, see Alias.
+type aliasPointer struct {
+	canonical string
+}
+
+// Alias makes alias another spelling of the canonical key for d, such as "user:by-email:x@y.com" for "user:42",
+// so that both share one flight and one entry. The alias entry only stores the canonical key.
+// A zero duration uses the cache's default TTL. Aliases are only stored by stores keeping live values.
+func (o *OnceCache) Alias(alias, canonical string, d time.Duration) {
+	if alias == canonical {
+		return
+	}
+	o.aliasing.Store(true)
+	o.store.Set(alias, aliasPointer{canonical: o.canonical(canonical)}, o.ttl(d))
+}
+
+// canonical returns the key key is an alias of, or key itself.
+func (o *OnceCache) canonical(key string) string {
+	value, ok, err := o.store.Get(key)
+	if err != nil || !ok {
+		return key
+	}
+	if p, ok := value.(aliasPointer); ok {
+		return p.canonical
+	}
+	return key
+}
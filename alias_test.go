@@ -0,0 +1,27 @@
+package once_cache
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+func TestAliasSharesTheCanonicalEntry(t *testing.T) {
+	c := NewOnceCache(&singleflight.Group{}, NewMemoryCache())
+	c.Alias("user:by-email:a@b.c", "user:42", time.Minute)
+	loads := 0
+	load := func() (any, error) {
+		loads++
+		return "user 42", nil
+	}
+	if res := c.GetResult("user:by-email:a@b.c", load, WithTTL(time.Minute)); res.Value != "user 42" {
+		t.Fatalf("GetResult of the alias = %v, want user 42", res.Value)
+	}
+	if res := c.GetResult("user:42", load, WithTTL(time.Minute)); !res.Hit || res.Value != "user 42" {
+		t.Fatalf("GetResult of the canonical key = %+v, want a hit", res)
+	}
+	if loads != 1 {
+		t.Fatalf("loader ran %d times, want once for both keys", loads)
+	}
+}
@@ -0,0 +1,44 @@
+package once_cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+func TestHitsDoNotAllocate(t *testing.T) {
+	cache := NewOnceCache(&singleflight.Group{}, NewMemoryCache())
+	f := func() (any, error) { return 1, nil }
+	keyed := func(context.Context, string) (any, error) { return 1, nil }
+	opts := []CallOption{WithTTL(time.Minute), WithErrorHandler(func(ICache, string, error) any { return nil })}
+	if _, ok := cache.GetWithOptions("k", f, opts...); !ok {
+		t.Fatal("GetWithOptions failed")
+	}
+	for name, call := range map[string]func(){
+		"Get":                     func() { cache.Get("k") },
+		"GetWithSingleFunc":       func() { cache.GetWithSingleFunc("k", f, time.Minute, nil) },
+		"GetWithOptions":          func() { cache.GetWithOptions("k", f) },
+		"GetWithOptions and opts": func() { cache.GetWithOptions("k", f, opts...) },
+		"GetResult":               func() { cache.GetResult("k", f, opts...) },
+		"GetWithContext":          func() { cache.GetWithContext(context.Background(), "k", keyed, opts...) },
+	} {
+		if allocs := testing.AllocsPerRun(100, call); allocs != 0 {
+			t.Errorf("%s hit: %v allocs, want 0", name, allocs)
+		}
+	}
+}
+
+func TestPooledCallConfigsStartEmpty(t *testing.T) {
+	cache := NewOnceCache(&singleflight.Group{}, NewMemoryCache())
+	cache.GetWithOptions("a", func() (any, error) { return 1, nil }, WithTTL(time.Nanosecond), WithForceRefresh())
+	// A config reused from the pool must not carry the previous call's options.
+	res := cache.GetResult("b", func() (any, error) { return 2, nil })
+	if _, info, ok := cache.infoGetter.GetWithInfo("b"); !ok || !info.ExpiresAt.IsZero() {
+		t.Fatalf("b stored with expiry %v, %v, want none", info.ExpiresAt, ok)
+	}
+	if res = cache.GetResult("b", func() (any, error) { return 3, nil }); !res.Hit || res.Value != 2 {
+		t.Fatalf("GetResult = %+v, want a hit on 2", res)
+	}
+}
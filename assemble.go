@@ -0,0 +1,101 @@
+package once_cache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// CombineFunc builds a composite value from its fragments, given by key.
+type CombineFunc func(fragments map[string]any) (any, error)
+
+// AssembleOption configures Assemble.
+type AssembleOption func(*assembleConfig)
+
+type assembleConfig struct {
+	compositeKey string
+	compositeTTL time.Duration
+	fragmentOpts []CallOption
+	concurrency  int
+}
+
+// WithComposite caches the composite under key for ttl, declared as depending on its fragments with
+// WithDependsOn, so that deleting a fragment also deletes the composite. Without it the composite is
+// assembled on every call, from cached fragments.
+func WithComposite(key string, ttl time.Duration) AssembleOption {
+	return func(c *assembleConfig) {
+		c.compositeKey, c.compositeTTL = key, ttl
+	}
+}
+
+// WithFragmentOptions applies opts to the lookup of every fragment, such as WithTTL.
+func WithFragmentOptions(opts ...CallOption) AssembleOption {
+	return func(c *assembleConfig) {
+		c.fragmentOpts = opts
+	}
+}
+
+// WithAssembleConcurrency bounds how many fragments are fetched at once. Zero, the default, means unbounded.
+func WithAssembleConcurrency(n int) AssembleOption {
+	return func(c *assembleConfig) {
+		c.concurrency = n
+	}
+}
+
+// Assemble fetches the fragments keys from the cache in parallel, loading missing ones with f through the
+// cache's singleflight, and combines them with combine. The first fragment that cannot be served fails the
+// call, cancelling the context of the remaining fetches, and its error is returned. Loads are shared with
+// other callers, so f receives ctx without its cancellation, as with GetWithContext.
+func Assemble(ctx context.Context, cache IResultCache, keys []string, f KeyedFunc, combine CombineFunc, opts ...AssembleOption) (any, error) {
+	var c assembleConfig
+	for _, opt := range opts {
+		opt(&c)
+	}
+	if c.compositeKey == "" {
+		return assemble(ctx, cache, keys, f, combine, &c)
+	}
+	res := cache.GetResult(c.compositeKey, func() (any, error) {
+		// The assembly is shared with other callers of the composite, so it does not stop with ctx.
+		return assemble(context.WithoutCancel(ctx), cache, keys, f, combine, &c)
+	}, WithTTL(c.compositeTTL), WithDependsOn(keys...))
+	if !res.OK() {
+		return nil, res.Err
+	}
+	return res.Value, nil
+}
+
+// assemble fetches the fragments and combines them.
+func assemble(ctx context.Context, cache IResultCache, keys []string, f KeyedFunc, combine CombineFunc, c *assembleConfig) (any, error) {
+	g, gctx := errgroup.WithContext(ctx)
+	if c.concurrency > 0 {
+		g.SetLimit(c.concurrency)
+	}
+	loadCtx := context.WithoutCancel(ctx)
+	var mu sync.Mutex
+	fragments := make(map[string]any, len(keys))
+	for _, key := range keys {
+		key := key
+		g.Go(func() error {
+			if err := gctx.Err(); err != nil {
+				return err
+			}
+			res := cache.GetResult(key, func() (any, error) {
+				return f(loadCtx, key)
+			}, c.fragmentOpts...)
+			if !res.OK() {
+				return fmt.Errorf("once_cache: fragment %q: %w", key, res.Err)
+			}
+			mu.Lock()
+			fragments[key] = res.Value
+			mu.Unlock()
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return combine(fragments)
+}
@@ -0,0 +1,153 @@
+package once_cache
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// AuditOp is the kind of an AuditRecord.
+type AuditOp int
+
+const (
+	// AuditSet records a value being stored, by Set or by a load.
+	AuditSet AuditOp = iota
+	// AuditDelete records a key being deleted.
+	AuditDelete
+	// AuditInvalidate records entries being deleted on behalf of another change: a tag, or a dependency
+	// declared with DependsOn.
+	AuditInvalidate
+)
+
+// String returns the name of the operation.
+func (op AuditOp) String() string {
+	switch op {
+	case AuditSet:
+		return "set"
+	case AuditDelete:
+		return "delete"
+	case AuditInvalidate:
+		return "invalidate"
+	}
+	return "unknown"
+}
+
+// MarshalText encodes the operation by name.
+func (op AuditOp) MarshalText() ([]byte, error) {
+	return []byte(op.String()), nil
+}
+
+// AuditRecord explains a change of the cache contents.
+type AuditRecord struct {
+	Op  AuditOp       `json:"op"`
+	Key string        `json:"key,omitempty"`
+	Tag string        `json:"tag,omitempty"`
+	TTL time.Duration `json:"ttl,omitempty"`
+	// Reason is the reason given by the caller, such as "user updated profile", "load" for values
+	// stored by loads, or the key a dependent entry was invalidated for.
+	Reason string    `json:"reason,omitempty"`
+	Time   time.Time `json:"time"`
+	// Label is the cache's label, see WithLabel.
+	Label string `json:"label,omitempty"`
+}
+
+// AuditSink receives an AuditRecord for every change of the cache contents. Audit is called synchronously
+// by the operation making the change, so it should be fast or buffer records itself.
+type AuditSink interface {
+	Audit(rec AuditRecord)
+}
+
+// AuditSinkFunc is a function that implements the AuditSink interface.
+type AuditSinkFunc func(rec AuditRecord)
+
+// Audit calls f.
+func (f AuditSinkFunc) Audit(rec AuditRecord) {
+	f(rec)
+}
+
+// WithAuditSink sends an AuditRecord to sink for every Set, Delete and invalidation of the cache, and for
+// every value stored by a load, so that systems holding personal data can explain why an entry existed and
+// when it was purged. Use SetWithReason and DeleteWithReason to record why a change was made.
+func WithAuditSink(sink AuditSink) Option {
+	return func(o *OnceCache) {
+		o.auditSink = sink
+	}
+}
+
+func (o *OnceCache) audit(op AuditOp, key, tag, reason string, d time.Duration) {
+	if o.auditSink != nil {
+		o.auditSink.Audit(AuditRecord{Op: op, Key: key, Tag: tag, TTL: d, Reason: reason, Time: time.Now(), Label: o.label})
+	}
+}
+
+// Set stores the value in the store, see SetWithReason.
+func (o *OnceCache) Set(key string, value any, d time.Duration) {
+	o.SetWithReason(key, value, d, "")
+}
+
+// SetWithReason stores the value in the store, recording reason in the audit log. A zero duration uses
+// the cache's default TTL, as loads do.
+func (o *OnceCache) SetWithReason(key string, value any, d time.Duration, reason string) {
+	d = o.keyTTL(key, d)
+	if o.strict {
+		if err := o.checkCall(key, d, true); err != nil {
+			if handler := o.handler(nil); handler != nil {
+				handler(o, key, err)
+			}
+			return
+		}
+	}
+	o.ICache.Set(key, wrapNil(value), d)
+	o.decide(DecisionSet, key, reason, nil, 0)
+	o.audit(AuditSet, key, "", reason, d)
+}
+
+// DeleteWithReason is Delete recording reason in the audit log.
+func (o *OnceCache) DeleteWithReason(key string, reason string) {
+	o.ICache.Delete(key)
+	o.decide(DecisionDelete, key, reason, nil, 0)
+	o.audit(AuditDelete, key, "", reason, 0)
+	o.deleteDependents(key)
+}
+
+// DeleteTagWithReason is DeleteTag recording reason in the audit log.
+func (o *OnceCache) DeleteTagWithReason(tag string, reason string) int {
+	n, staggered := o.deleteTagStaggered(tag)
+	if !staggered {
+		if o.tagInvalidator == nil {
+			return 0
+		}
+		n = o.tagInvalidator.DeleteTag(tag)
+	}
+	o.audit(AuditInvalidate, "", tag, reason, 0)
+	return n
+}
+
+// JSONAuditSink is a struct that implements the AuditSink interface by writing records as JSON lines.
+type JSONAuditSink struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	err error
+}
+
+// Audit writes the record. Write errors are kept for Err.
+func (s *JSONAuditSink) Audit(rec AuditRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.enc.Encode(rec); err != nil && s.err == nil {
+		s.err = err
+	}
+}
+
+// Err returns the first write error.
+func (s *JSONAuditSink) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// NewJSONAuditSink creates a new instance of JSONAuditSink writing to w.
+func NewJSONAuditSink(w io.Writer) *JSONAuditSink {
+	return &JSONAuditSink{enc: json.NewEncoder(w)}
+}
@@ -0,0 +1,200 @@
+package once_cache
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// autoWarmBuckets is how many steps the sliding window of WithAutoWarm is divided into.
+	autoWarmBuckets = 4
+	// defaultAutoWarmPrefixes bounds the prefixes tracked by WithAutoWarm by default.
+	defaultAutoWarmPrefixes = 1024
+)
+
+// AutoWarmConfig configures WithAutoWarm.
+type AutoWarmConfig struct {
+	// Keys returns the keys to warm for a prefix, such as its most popular keys. It is required.
+	Keys func(ctx context.Context, prefix string) ([]string, error)
+	// Loader loads the warmed keys, and TTL is their time to live, the cache's default if zero.
+	Loader KeyedFunc
+	TTL    time.Duration
+	// PrefixOf returns the prefix of a key. It defaults to the key up to and including its first colon,
+	// as built by Key, or the whole key if it has none.
+	PrefixOf func(key string) string
+	// Window is the sliding window over which miss rates are measured. It defaults to one minute.
+	Window time.Duration
+	// MissRate is the miss rate at or above which a prefix is warmed. It defaults to 0.5.
+	MissRate float64
+	// MinLookups is how many lookups of a prefix the window needs before its miss rate counts, so that
+	// rarely used prefixes are not warmed. It defaults to 100.
+	MinLookups uint64
+	// Cooldown is how long a warmed prefix is left alone before it may be warmed again. It defaults to
+	// ten windows.
+	Cooldown time.Duration
+	// Concurrency bounds how many prefixes are warmed at once. It defaults to one. Within a prefix, at
+	// most WithPrefetchConcurrency keys load at once.
+	Concurrency int
+	// MaxPrefixes bounds the number of prefixes tracked at once. It defaults to 1024.
+	MaxPrefixes int
+}
+
+// WithAutoWarm measures the miss rate of every key prefix over a sliding window and warms the prefixes whose
+// miss rate rises to cfg.MissRate, loading the keys cfg.Keys returns as Prefetch does, so that the cache
+// recovers on its own from a deployment or a Clear instead of waiting for every key to be missed. Failures
+// to list or load keys are reported to the handler of WithBackgroundErrorHandler.
+func WithAutoWarm(cfg AutoWarmConfig) Option {
+	return func(o *OnceCache) {
+		if cfg.PrefixOf == nil {
+			cfg.PrefixOf = defaultPrefixOf
+		}
+		if cfg.Window <= 0 {
+			cfg.Window = time.Minute
+		}
+		if cfg.MissRate <= 0 {
+			cfg.MissRate = 0.5
+		}
+		if cfg.MinLookups == 0 {
+			cfg.MinLookups = 100
+		}
+		if cfg.Cooldown <= 0 {
+			cfg.Cooldown = 10 * cfg.Window
+		}
+		if cfg.MaxPrefixes <= 0 {
+			cfg.MaxPrefixes = defaultAutoWarmPrefixes
+		}
+		o.autoWarm = &autoWarmer{
+			cfg:  cfg,
+			step: int64(cfg.Window) / autoWarmBuckets,
+			sem:  make(chan struct{}, max(1, cfg.Concurrency)),
+		}
+	}
+}
+
+// defaultPrefixOf returns key up to and including its first colon.
+func defaultPrefixOf(key string) string {
+	if i := strings.IndexByte(key, ':'); i >= 0 {
+		return key[:i+1]
+	}
+	return key
+}
+
+// autoWarmer holds the windowed counters of the tracked prefixes.
+type autoWarmer struct {
+	cfg      AutoWarmConfig
+	step     int64
+	sem      chan struct{}
+	prefixes sync.Map
+	count    atomic.Int64
+	// evaluated is the last step at which the prefixes were evaluated.
+	evaluated atomic.Int64
+}
+
+// prefixWindow counts the lookups of a prefix in the buckets of the sliding window.
+type prefixWindow struct {
+	buckets [autoWarmBuckets]struct {
+		step         atomic.Int64
+		hits, misses atomic.Uint64
+	}
+	warmedAt atomic.Int64
+}
+
+// add counts a lookup at step.
+func (w *prefixWindow) add(step int64, hit bool) {
+	b := &w.buckets[step%autoWarmBuckets]
+	if old := b.step.Load(); old != step && b.step.CompareAndSwap(old, step) {
+		b.hits.Store(0)
+		b.misses.Store(0)
+	}
+	if hit {
+		b.hits.Add(1)
+	} else {
+		b.misses.Add(1)
+	}
+}
+
+// sum returns the lookups of the window ending at step.
+func (w *prefixWindow) sum(step int64) (hits, misses uint64) {
+	for i := range w.buckets {
+		b := &w.buckets[i]
+		if s := b.step.Load(); s > step-autoWarmBuckets && s <= step {
+			hits += b.hits.Load()
+			misses += b.misses.Load()
+		}
+	}
+	return hits, misses
+}
+
+// recordWarmLookup counts a lookup of key for WithAutoWarm, evaluating the prefixes once per step.
+func (o *OnceCache) recordWarmLookup(key string, hit bool) {
+	a := o.autoWarm
+	if a == nil {
+		return
+	}
+	now := time.Now().UnixNano()
+	step := now / max(1, a.step)
+	prefix := a.cfg.PrefixOf(key)
+	v, ok := a.prefixes.Load(prefix)
+	if !ok {
+		if a.count.Load() >= int64(a.cfg.MaxPrefixes) {
+			v = nil
+		} else if v, ok = a.prefixes.LoadOrStore(prefix, new(prefixWindow)); !ok {
+			a.count.Add(1)
+		}
+	}
+	if v != nil {
+		v.(*prefixWindow).add(step, hit)
+	}
+	if last := a.evaluated.Load(); step > last && a.evaluated.CompareAndSwap(last, step) {
+		o.evaluateWarm(step, now)
+	}
+}
+
+// evaluateWarm warms the prefixes whose miss rate is too high and forgets those without lookups.
+func (o *OnceCache) evaluateWarm(step, now int64) {
+	a := o.autoWarm
+	a.prefixes.Range(func(key, v any) bool {
+		prefix, w := key.(string), v.(*prefixWindow)
+		hits, misses := w.sum(step)
+		if hits+misses == 0 {
+			if a.prefixes.CompareAndDelete(prefix, w) {
+				a.count.Add(-1)
+			}
+			return true
+		}
+		if hits+misses < a.cfg.MinLookups || float64(misses)/float64(hits+misses) < a.cfg.MissRate {
+			return true
+		}
+		if warmed := w.warmedAt.Load(); warmed != 0 && now-warmed < int64(a.cfg.Cooldown) {
+			return true
+		}
+		select {
+		case a.sem <- struct{}{}:
+		default:
+			// Every warm-up slot is busy; the prefix is considered again at the next step.
+			return true
+		}
+		w.warmedAt.Store(now)
+		go func() {
+			defer func() { <-a.sem }()
+			defer o.recoverBackground(prefix)
+			o.warm(prefix)
+		}()
+		return true
+	})
+}
+
+// warm loads the keys of prefix.
+func (o *OnceCache) warm(prefix string) {
+	a := o.autoWarm
+	ctx := context.Background()
+	keys, err := a.cfg.Keys(ctx, prefix)
+	if err != nil {
+		o.backgroundError(prefix, err)
+		return
+	}
+	o.prefetchKeys(ctx, keys, a.cfg.Loader, a.cfg.TTL)
+}
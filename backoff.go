@@ -0,0 +1,103 @@
+package once_cache
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrLoadBackoff is reported when a load is refused because the key's loader failed recently,
+// see WithFailureBackoff. It wraps the error of the last failure.
+var ErrLoadBackoff = errors.New("once_cache: load backing off after failures")
+
+// backoffSweepEvery is how many checks pass between sweeps of idle failure records.
+const backoffSweepEvery = 1024
+
+// WithFailureBackoff refuses to run the loader of a key for a while after it fails, doubling the wait from
+// initial up to maxDelay with each consecutive failure, and resetting it once a load succeeds, so a permanently
+// broken key does not keep the loader busy. Unlike WithMissingTTL, nothing is stored: refused loads fail
+// with ErrLoadBackoff, and callers are served the stale value if the store retains one. Batches leave out the
+// keys backing off, and a failed batch starts the wait of each of its keys.
+func WithFailureBackoff(initial, maxDelay time.Duration) Option {
+	return func(o *OnceCache) {
+		o.backoff = &failureBackoff{initial: initial, max: maxDelay}
+	}
+}
+
+// failureBackoff holds the consecutive failures of the keys whose last load failed.
+type failureBackoff struct {
+	initial, max time.Duration
+	keys         sync.Map
+	checks       atomic.Int64
+}
+
+type backoffState struct {
+	mu       sync.Mutex
+	failures int
+	until    time.Time
+	err      error
+}
+
+// allow returns ErrLoadBackoff if key is waiting out a failure.
+func (b *failureBackoff) allow(key string) error {
+	now := time.Now()
+	if b.checks.Add(1)%backoffSweepEvery == 0 {
+		b.sweep(now)
+	}
+	v, ok := b.keys.Load(key)
+	if !ok {
+		return nil
+	}
+	s := v.(*backoffState)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.Before(s.until) {
+		return fmt.Errorf("%w: %w", ErrLoadBackoff, s.err)
+	}
+	return nil
+}
+
+// failure records a failed load of key and starts its wait.
+func (b *failureBackoff) failure(key string, err error) {
+	v, _ := b.keys.LoadOrStore(key, &backoffState{})
+	s := v.(*backoffState)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures++
+	s.until = time.Now().Add(b.delay(s.failures))
+	s.err = err
+}
+
+// success forgets the failures of key.
+func (b *failureBackoff) success(key string) {
+	b.keys.Delete(key)
+}
+
+// delay returns the wait after the given number of consecutive failures.
+func (b *failureBackoff) delay(failures int) time.Duration {
+	d := b.initial
+	for i := 1; i < failures && (b.max <= 0 || d < b.max); i++ {
+		d *= 2
+	}
+	if b.max > 0 && d > b.max {
+		d = b.max
+	}
+	return d
+}
+
+// sweep forgets keys that were not loaded again within max of the end of their wait, so keys that were
+// abandoned start over rather than being remembered forever.
+func (b *failureBackoff) sweep(now time.Time) {
+	idle := max(b.max, b.initial)
+	b.keys.Range(func(key, v any) bool {
+		s := v.(*backoffState)
+		s.mu.Lock()
+		if now.Sub(s.until) > idle {
+			b.keys.CompareAndDelete(key, v)
+		}
+		s.mu.Unlock()
+		return true
+	})
+}
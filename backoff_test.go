@@ -0,0 +1,50 @@
+package once_cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+func TestFailureBackoffRefusesLoadsAfterAFailure(t *testing.T) {
+	c := NewOnceCache(&singleflight.Group{}, NewMemoryCache(), WithFailureBackoff(20*time.Millisecond, time.Second))
+	loads := 0
+	failing := errors.New("down")
+	load := func() (any, error) {
+		loads++
+		if loads == 1 {
+			return nil, failing
+		}
+		return "v", nil
+	}
+	if res := c.GetResult("a", load); !errors.Is(res.Err, failing) {
+		t.Fatalf("first load = %v, want %v", res.Err, failing)
+	}
+	res := c.GetResult("a", load)
+	if !errors.Is(res.Err, ErrLoadBackoff) || !errors.Is(res.Err, failing) {
+		t.Fatalf("load during the backoff = %v, want ErrLoadBackoff wrapping %v", res.Err, failing)
+	}
+	if loads != 1 {
+		t.Fatalf("loader ran %d times, want once", loads)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if res := c.GetResult("a", load); res.Err != nil || res.Value != "v" {
+		t.Fatalf("load after the backoff = %v, %v, want v", res.Value, res.Err)
+	}
+}
+
+func TestFailureBackoffDoublesUpToTheMaximum(t *testing.T) {
+	b := &failureBackoff{initial: time.Second, max: 5 * time.Second}
+	for failures, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 5 * time.Second, 10: 5 * time.Second} {
+		if got := b.delay(failures); got != want {
+			t.Errorf("delay after %d failures = %v, want %v", failures, got, want)
+		}
+	}
+	b.failure("a", errors.New("down"))
+	b.success("a")
+	if err := b.allow("a"); err != nil {
+		t.Fatalf("allow after a success = %v, want nil", err)
+	}
+}
@@ -0,0 +1,388 @@
+package once_cache
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sort"
+	"strings"
+	"time"
+)
+
+// BatchFunc loads the values for several keys at once. Keys missing from the returned map are not cached.
+type BatchFunc func(keys []string) (map[string]any, error)
+
+// missingMarker is cached for keys the batch function reported as missing, see WithMissingTTL.
+type missingMarker struct{}
+
+// WithMissingTTL caches a marker for d for keys requested from a BatchFunc but absent from its result,
+// so that repeated batches do not ask the origin again for records that do not exist. Marked keys are
+// absent from results until the marker expires. Markers are only stored by stores keeping live values,
+// not by those encoding values, such as MemoryCache with WithByteValues.
+func WithMissingTTL(d time.Duration) Option {
+	return func(o *OnceCache) {
+		o.missingTTL = d
+	}
+}
+
+// GetManyWithSingleFunc retrieves the values associated with keys, loading all missing keys with a single
+// call to f. Concurrent calls for the same set of missing keys share one load.
+// Keys that were neither cached nor returned by f are absent from the result. A nil catchError uses the
+// handler set with WithDefaultErrorHandler.
+func (o *OnceCache) GetManyWithSingleFunc(keys []string, f BatchFunc, d time.Duration, catchError *CatchErrorFunc) map[string]any {
+	var handler CatchErrorFunc
+	if catchError != nil {
+		handler = *catchError
+	}
+	return o.getManyWithFunc(keys, f, d, handler)
+}
+
+// GetManyWithOptions is GetManyWithSingleFunc configured with WithTTL and WithErrorHandler instead of
+// positional parameters. Other options have no effect on batches.
+func (o *OnceCache) GetManyWithOptions(keys []string, f BatchFunc, opts ...CallOption) map[string]any {
+	c := acquireCallConfig(opts)
+	defer releaseCallConfig(c)
+	return o.getManyWithFunc(keys, f, c.ttl, c.errorHandler)
+}
+
+func (o *OnceCache) getManyWithFunc(keys []string, f BatchFunc, d time.Duration, handler CatchErrorFunc) map[string]any {
+	if o.strict {
+		keys = o.checkBatch(keys, o.ttl(d), f != nil, o.handler(handler))
+	}
+	values := o.getMany(keys)
+	var known map[string]struct{}
+	for key, value := range values {
+		if p, ok := value.(aliasPointer); ok {
+			// Serve aliases from their canonical entry, or load them under the alias if it is gone.
+			if value, ok := o.lookup(p.canonical); ok {
+				values[key] = value
+			} else {
+				delete(values, key)
+			}
+			continue
+		}
+		if _, ok := value.(missingMarker); ok {
+			if known == nil {
+				known = make(map[string]struct{})
+			}
+			known[key] = struct{}{}
+			delete(values, key)
+		}
+	}
+	if o.missingFilter != nil {
+		for _, key := range keys {
+			if _, ok := values[key]; !ok && o.missingFilter.MayContain(key) {
+				if known == nil {
+					known = make(map[string]struct{})
+				}
+				known[key] = struct{}{}
+			}
+		}
+	}
+	missing := missingKeys(keys, values, known)
+	if o.events != nil || o.keyStats != nil || o.recorder != nil || o.experiment != nil || o.alerts != nil || o.autoWarm != nil {
+		for key := range values {
+			o.emit(EventHit, key, nil, 0)
+			o.recordHit(key)
+		}
+		for _, key := range missing {
+			o.emit(EventMiss, key, nil, 0)
+			o.recordMiss(key)
+		}
+	}
+	if len(missing) == 0 {
+		return values
+	}
+
+	flightKey := batchFlightKey(missing)
+	defer o.group.Forget(flightKey)
+	defer o.flightMetrics.join(missing...)()
+	loaded, err, _ := o.group.Do(flightKey, func() (any, error) {
+		if o.flights != nil {
+			return o.flights.batch(missing, func(keys []string) (any, error) {
+				return o.loadMany(keys, f, o.ttl(d))
+			})
+		}
+		return o.loadMany(missing, f, o.ttl(d))
+	})
+
+	if err != nil {
+		if handler := o.handler(handler); handler != nil {
+			for _, key := range missing {
+				handler(o, key, err)
+			}
+		}
+		o.fallBackMany(values, missing)
+		return values
+	}
+	var refused []string
+	for key, value := range loaded.(map[string]any) {
+		if r, ok := value.(refusedLoad); ok {
+			if handler := o.handler(handler); handler != nil {
+				handler(o, key, r.err)
+			}
+			refused = append(refused, key)
+			continue
+		}
+		values[key] = value
+	}
+	o.fallBackMany(values, refused)
+	return values
+}
+
+// fallBackMany adds to values what the error fallback allows to serve for keys whose load failed, see
+// WithErrorFallback.
+func (o *OnceCache) fallBackMany(values map[string]any, keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+	switch o.errorFallback {
+	case FallbackRecheck:
+		for key, value := range o.getMany(keys) {
+			if !internalValue(value) {
+				values[key] = value
+			}
+		}
+	case FallbackStale:
+		for _, key := range keys {
+			if value, ok := o.stale(key); ok {
+				values[key] = value
+			}
+		}
+	}
+}
+
+// getMany retrieves several keys, including missing markers, using the store's multi-get if available.
+func (o *OnceCache) getMany(keys []string) map[string]any {
+	if o.multiGetter != nil {
+		values := o.multiGetter.GetMulti(keys)
+		if values == nil {
+			values = make(map[string]any, len(keys))
+		}
+		for key, value := range values {
+			values[key] = unwrapNil(value)
+		}
+		return values
+	}
+	values := make(map[string]any, len(keys))
+	for _, key := range keys {
+		// Read the store directly, since lookup hides missing markers.
+		if value, ok, err := o.store.Get(key); err == nil && ok {
+			values[key] = unwrapNil(value)
+		}
+	}
+	return values
+}
+
+// refusedLoad is returned by loadMany in place of the value of a key whose load was refused by
+// WithFailureBackoff or WithLoadRateLimit. It is never stored.
+type refusedLoad struct {
+	err error
+}
+
+// loadMany runs the batch function for the keys whose loads are allowed and stores its results, using the
+// store's multi-set if available. Refused keys are returned as refusedLoad.
+func (o *OnceCache) loadMany(keys []string, f BatchFunc, d time.Duration) (any, error) {
+	keys, refused := o.admitBatch(keys)
+	if len(keys) == 0 {
+		return withRefused(map[string]any{}, refused), nil
+	}
+	start := time.Now()
+	done := o.flightMetrics.start(keys...)
+	loaded, err := o.runBatch(keys, f)
+	done()
+	for range keys {
+		o.alerts.recordLoad(err)
+	}
+	if o.experiment != nil {
+		// Share the time of the batch between its keys.
+		per := time.Since(start) / time.Duration(len(keys))
+		for _, key := range keys {
+			o.experiment.recordLoad(key, per, err)
+		}
+	}
+	if o.backoff != nil {
+		for _, key := range keys {
+			if err != nil {
+				o.backoff.failure(key, err)
+			} else {
+				o.backoff.success(key)
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	// The map belongs to the batch function, which may keep or reuse it.
+	loaded = maps.Clone(loaded)
+	if loaded == nil {
+		loaded = map[string]any{}
+	}
+	if o.missingTTL > 0 {
+		o.markMissing(keys, loaded)
+	}
+	if o.missingFilter != nil {
+		for _, key := range keys {
+			if _, ok := loaded[key]; !ok {
+				o.missingFilter.Add(key)
+			}
+		}
+	}
+	// Invalid values are dropped after marking missing keys, since their records do exist.
+	if o.validate != nil {
+		for key, value := range loaded {
+			if o.validate(value) != nil {
+				delete(loaded, key)
+			}
+		}
+	}
+	if o.transform != nil {
+		for key, value := range loaded {
+			if value, err := o.transform(key, value); err == nil {
+				loaded[key] = value
+			} else {
+				delete(loaded, key)
+			}
+		}
+	}
+	// Values of keys deleted with a tombstone during the load are returned but not stored.
+	toStore := loaded
+	for key := range loaded {
+		if o.tombstoned(key, start) {
+			if len(toStore) == len(loaded) {
+				toStore = maps.Clone(loaded)
+			}
+			delete(toStore, key)
+		}
+	}
+	if o.multiSetter != nil {
+		entries := make(map[string]Entry, len(toStore))
+		for key, value := range toStore {
+			entries[key] = Entry{Value: wrapNil(value), TTL: o.keyTTL(key, d)}
+		}
+		o.multiSetter.SetMulti(entries)
+		for key := range toStore {
+			o.emit(EventSet, key, nil, 0)
+			o.audit(AuditSet, key, "", "load", o.keyTTL(key, d))
+		}
+		return withRefused(loaded, refused), nil
+	}
+	for key, value := range toStore {
+		d := o.keyTTL(key, d)
+		if err := o.store.Set(key, wrapNil(value), d); err != nil {
+			if o.onSetError != nil {
+				if err := o.onSetError(o.store, key, value, d, err); err != nil {
+					delete(loaded, key)
+				}
+			}
+			continue
+		}
+		o.emit(EventSet, key, nil, 0)
+		o.audit(AuditSet, key, "", "load", d)
+	}
+	return withRefused(loaded, refused), nil
+}
+
+// admitBatch splits keys into those whose loads may run and those refused by WithFailureBackoff or
+// WithLoadRateLimit, with the error of each, checking every key as a single-key load would.
+func (o *OnceCache) admitBatch(keys []string) ([]string, map[string]error) {
+	if o.backoff == nil && o.limiter == nil {
+		return keys, nil
+	}
+	admitted := make([]string, 0, len(keys))
+	var refused map[string]error
+	for _, key := range keys {
+		var err error
+		if o.backoff != nil {
+			err = o.backoff.allow(key)
+		}
+		if err == nil && o.limiter != nil && !o.limiter.allow(key) {
+			err = ErrLoadRateLimited
+		}
+		if err == nil {
+			admitted = append(admitted, key)
+			continue
+		}
+		if refused == nil {
+			refused = make(map[string]error)
+		}
+		refused[key] = err
+	}
+	return admitted, refused
+}
+
+// withRefused adds the refused keys to loaded as refusedLoad and returns it.
+func withRefused(loaded map[string]any, refused map[string]error) map[string]any {
+	for key, err := range refused {
+		loaded[key] = refusedLoad{err: err}
+	}
+	return loaded
+}
+
+// runBatch calls f for keys through the loader middleware, as one load whose key is the keys joined by
+// commas.
+func (o *OnceCache) runBatch(keys []string, f BatchFunc) (map[string]any, error) {
+	if o.wrappedLoader == nil {
+		return f(keys)
+	}
+	load := KeyedFunc(func(context.Context, string) (any, error) {
+		return f(keys)
+	})
+	ctx := context.WithValue(context.Background(), loaderContextKey{}, load)
+	v, err := o.wrappedLoader(ctx, strings.Join(keys, ","))
+	if err != nil {
+		return nil, err
+	}
+	loaded, ok := v.(map[string]any)
+	if !ok && v != nil {
+		return nil, fmt.Errorf("%w: loader middleware returned %T for a batch", ErrInvalidValue, v)
+	}
+	return loaded, nil
+}
+
+// markMissing caches missing markers for the keys absent from loaded.
+func (o *OnceCache) markMissing(keys []string, loaded map[string]any) {
+	var entries map[string]Entry
+	for _, key := range keys {
+		if _, ok := loaded[key]; ok {
+			continue
+		}
+		if o.multiSetter == nil {
+			o.store.Set(key, missingMarker{}, o.missingTTL)
+			continue
+		}
+		if entries == nil {
+			entries = make(map[string]Entry)
+		}
+		entries[key] = Entry{Value: missingMarker{}, TTL: o.missingTTL}
+	}
+	if entries != nil {
+		o.multiSetter.SetMulti(entries)
+	}
+}
+
+// missingKeys returns the sorted, deduplicated keys that are absent from values and not known to be missing.
+func missingKeys(keys []string, values map[string]any, known map[string]struct{}) []string {
+	seen := make(map[string]struct{}, len(keys))
+	var missing []string
+	for _, key := range keys {
+		if _, ok := values[key]; ok {
+			continue
+		}
+		if _, ok := known[key]; ok {
+			continue
+		}
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		missing = append(missing, key)
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// batchFlightKey returns the singleflight key for loading a sorted set of keys.
+func batchFlightKey(keys []string) string {
+	return "\x00batch\x00" + strings.Join(keys, "\x00")
+}
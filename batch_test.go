@@ -0,0 +1,103 @@
+package once_cache
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+func TestBatchLoadsLeaveTheBatchFunctionsMapAlone(t *testing.T) {
+	c := NewOnceCache(&singleflight.Group{}, NewMemoryCache(),
+		WithValidate(func(value any) error {
+			if value == "bad" {
+				return errors.New("bad")
+			}
+			return nil
+		}),
+		WithTransform(func(key string, value any) (any, error) { return value.(string) + "!", nil }),
+	)
+	result := map[string]any{"a": "ok", "b": "bad"}
+	values := c.GetManyWithOptions([]string{"a", "b"}, func([]string) (map[string]any, error) {
+		return result, nil
+	})
+	if want := map[string]any{"a": "ok!"}; !reflect.DeepEqual(values, want) {
+		t.Fatalf("GetMany = %v, want %v", values, want)
+	}
+	if want := map[string]any{"a": "ok", "b": "bad"}; !reflect.DeepEqual(result, want) {
+		t.Fatalf("the batch function's map became %v, want it unchanged", result)
+	}
+}
+
+func TestBatchLoadsRunThroughLoaderMiddleware(t *testing.T) {
+	var loads []string
+	mw := func(next KeyedFunc) KeyedFunc {
+		return func(ctx context.Context, key string) (any, error) {
+			loads = append(loads, key)
+			return next(ctx, key)
+		}
+	}
+	c := NewOnceCache(&singleflight.Group{}, NewMemoryCache(), WithLoaderMiddleware(mw))
+	values := c.GetManyWithOptions([]string{"b", "a"}, func(keys []string) (map[string]any, error) {
+		return map[string]any{"a": 1, "b": 2}, nil
+	})
+	if len(values) != 2 {
+		t.Fatalf("GetMany = %v, want a and b", values)
+	}
+	if !reflect.DeepEqual(loads, []string{"a,b"}) {
+		t.Fatalf("middleware loads = %q, want the batch as one load", loads)
+	}
+}
+
+func TestBatchLoadsAreRateLimitedPerKey(t *testing.T) {
+	c := NewOnceCache(&singleflight.Group{}, NewMemoryCache(), WithLoadRateLimit(time.Hour, 1))
+	var batches [][]string
+	load := func(keys []string) (map[string]any, error) {
+		batches = append(batches, keys)
+		values := make(map[string]any, len(keys))
+		for _, key := range keys {
+			values[key] = key
+		}
+		return values, nil
+	}
+	c.GetManyWithOptions([]string{"a"}, load, WithTTL(time.Nanosecond))
+	time.Sleep(time.Millisecond)
+
+	var refused []string
+	handler := func(_ ICache, key string, err error) any {
+		if errors.Is(err, ErrLoadRateLimited) {
+			refused = append(refused, key)
+		}
+		return nil
+	}
+	values := c.GetManyWithOptions([]string{"a", "b"}, load, WithErrorHandler(handler))
+	if want := map[string]any{"b": "b"}; !reflect.DeepEqual(values, want) {
+		t.Fatalf("GetMany = %v, want %v", values, want)
+	}
+	if !reflect.DeepEqual(batches, [][]string{{"a"}, {"b"}}) {
+		t.Fatalf("batches = %q, want a left out of the second", batches)
+	}
+	if !reflect.DeepEqual(refused, []string{"a"}) {
+		t.Fatalf("refused keys = %q, want [a]", refused)
+	}
+}
+
+func TestBatchFailuresBackOffPerKey(t *testing.T) {
+	c := NewOnceCache(&singleflight.Group{}, NewMemoryCache(), WithFailureBackoff(time.Hour, time.Hour))
+	calls := 0
+	failing := func([]string) (map[string]any, error) {
+		calls++
+		return nil, errors.New("origin down")
+	}
+	c.GetManyWithOptions([]string{"a"}, failing)
+	c.GetManyWithOptions([]string{"a"}, failing)
+	if calls != 1 {
+		t.Fatalf("batch function called %d times, want the retry refused", calls)
+	}
+	if _, ok := c.GetWithOptions("a", func() (any, error) { return 1, nil }); ok {
+		t.Fatal("a single-key load ran while the key backs off")
+	}
+}
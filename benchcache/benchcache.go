@@ -0,0 +1,214 @@
+// Package benchcache runs synthetic workloads against once_cache.ICache implementations and reports
+// their throughput, latency percentiles and hit ratio, so that backends and policies can be compared.
+package benchcache
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	once_cache "github.com/phongthien99/once-cache"
+)
+
+// latencySamples is the number of latencies each worker keeps for the percentiles.
+const latencySamples = 1 << 16
+
+// Workload describes the operations issued against a cache.
+type Workload struct {
+	// Duration is how long the workload runs. It defaults to 10 seconds.
+	Duration time.Duration
+	// Workers is the number of concurrent goroutines issuing operations. It defaults to 8.
+	Workers int
+	// Keys is the number of distinct keys read and written. It defaults to 100000.
+	Keys int
+	// ReadRatio is the fraction of operations that are reads, the others being writes. It defaults to 0.9.
+	ReadRatio float64
+	// MissRatio is the fraction of reads for keys that are never written.
+	MissRatio float64
+	// Zipf skews key popularity with a Zipf distribution of exponent Zipf when greater than 1,
+	// keys are uniformly distributed otherwise.
+	Zipf float64
+	// ValueSize is the size in bytes of written values. It defaults to 64.
+	ValueSize int
+	// TTL is the time to live of written values.
+	TTL time.Duration
+	// Warm writes every key before the measurement starts.
+	Warm bool
+	// Seed seeds the random generators, for repeatable runs.
+	Seed int64
+}
+
+// Report summarizes a run.
+type Report struct {
+	Name       string
+	Ops        int64
+	Reads      int64
+	Hits       int64
+	Writes     int64
+	Elapsed    time.Duration
+	Throughput float64
+	P50        time.Duration
+	P90        time.Duration
+	P99        time.Duration
+	P999       time.Duration
+	Max        time.Duration
+}
+
+// HitRatio returns the fraction of reads that were hits.
+func (r Report) HitRatio() float64 {
+	if r.Reads == 0 {
+		return 0
+	}
+	return float64(r.Hits) / float64(r.Reads)
+}
+
+// String formats the report on one line.
+func (r Report) String() string {
+	return fmt.Sprintf("%s: %d ops in %v, %.0f ops/s, hit ratio %.2f%%, p50 %v p90 %v p99 %v p99.9 %v max %v",
+		r.Name, r.Ops, r.Elapsed.Round(time.Millisecond), r.Throughput, 100*r.HitRatio(),
+		r.P50, r.P90, r.P99, r.P999, r.Max)
+}
+
+// Fprint writes the reports as an aligned table.
+func Fprint(w io.Writer, reports ...Report) error {
+	if _, err := fmt.Fprintf(w, "%-16s %12s %12s %8s %10s %10s %10s %10s %10s\n",
+		"cache", "ops", "ops/s", "hit%", "p50", "p90", "p99", "p99.9", "max"); err != nil {
+		return err
+	}
+	for _, r := range reports {
+		if _, err := fmt.Fprintf(w, "%-16s %12d %12.0f %8.2f %10v %10v %10v %10v %10v\n",
+			r.Name, r.Ops, r.Throughput, 100*r.HitRatio(), r.P50, r.P90, r.P99, r.P999, r.Max); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type workerResult struct {
+	ops, reads, hits, writes int64
+	latencies                []time.Duration
+}
+
+// Run runs the workload against cache and reports the results under name.
+func Run(name string, cache once_cache.ICache, w Workload) Report {
+	w = w.withDefaults()
+	value := make([]byte, w.ValueSize)
+	if w.Warm {
+		for i := 0; i < w.Keys; i++ {
+			cache.Set(key(i), value, w.TTL)
+		}
+	}
+
+	results := make([]workerResult, w.Workers)
+	var wg sync.WaitGroup
+	start := time.Now()
+	deadline := start.Add(w.Duration)
+	for i := 0; i < w.Workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = w.work(cache, value, rand.New(rand.NewSource(w.Seed+int64(i))), deadline)
+		}(i)
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	r := Report{Name: name, Elapsed: elapsed}
+	var latencies []time.Duration
+	for _, res := range results {
+		r.Ops += res.ops
+		r.Reads += res.reads
+		r.Hits += res.hits
+		r.Writes += res.writes
+		latencies = append(latencies, res.latencies...)
+	}
+	r.Throughput = float64(r.Ops) / elapsed.Seconds()
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	r.P50 = percentile(latencies, 0.5)
+	r.P90 = percentile(latencies, 0.9)
+	r.P99 = percentile(latencies, 0.99)
+	r.P999 = percentile(latencies, 0.999)
+	if len(latencies) > 0 {
+		r.Max = latencies[len(latencies)-1]
+	}
+	return r
+}
+
+// work issues operations until the deadline, keeping a reservoir sample of their latencies.
+func (w Workload) work(cache once_cache.ICache, value []byte, rng *rand.Rand, deadline time.Time) workerResult {
+	var zipf *rand.Zipf
+	if w.Zipf > 1 {
+		zipf = rand.NewZipf(rng, w.Zipf, 1, uint64(w.Keys-1))
+	}
+	next := func() int {
+		if zipf != nil {
+			return int(zipf.Uint64())
+		}
+		return rng.Intn(w.Keys)
+	}
+	res := workerResult{latencies: make([]time.Duration, 0, latencySamples)}
+	for {
+		// Check the clock every few operations only, it would dominate fast caches otherwise.
+		if res.ops%64 == 0 && time.Now().After(deadline) {
+			return res
+		}
+		var k string
+		read := rng.Float64() < w.ReadRatio
+		if read && rng.Float64() < w.MissRatio {
+			k = "cold:" + strconv.Itoa(next())
+		} else {
+			k = key(next())
+		}
+		start := time.Now()
+		if read {
+			if _, ok := cache.Get(k); ok {
+				res.hits++
+			}
+			res.reads++
+		} else {
+			cache.Set(k, value, w.TTL)
+			res.writes++
+		}
+		d := time.Since(start)
+		res.ops++
+		if len(res.latencies) < latencySamples {
+			res.latencies = append(res.latencies, d)
+		} else if j := rng.Int63n(res.ops); j < latencySamples {
+			res.latencies[j] = d
+		}
+	}
+}
+
+func (w Workload) withDefaults() Workload {
+	if w.Duration <= 0 {
+		w.Duration = 10 * time.Second
+	}
+	if w.Workers <= 0 {
+		w.Workers = 8
+	}
+	if w.Keys <= 0 {
+		w.Keys = 100000
+	}
+	if w.ReadRatio == 0 {
+		w.ReadRatio = 0.9
+	}
+	if w.ValueSize <= 0 {
+		w.ValueSize = 64
+	}
+	return w
+}
+
+func key(i int) string {
+	return "key:" + strconv.Itoa(i)
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	return sorted[min(len(sorted)-1, int(p*float64(len(sorted))))]
+}
@@ -0,0 +1,161 @@
+package once_cache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+)
+
+// ErrBlobNotFound is returned by a BlobClient when the object does not exist.
+var ErrBlobNotFound = errors.New("once_cache: blob not found")
+
+// blobExpiresAtKey is the object metadata key holding the expiry as Unix nanoseconds.
+const blobExpiresAtKey = "once-cache-expires-at"
+
+// BlobClient is the minimal object storage API used by BlobCache. It can be implemented
+// over any S3-compatible SDK by mapping objects to PutObject/GetObject/DeleteObject calls
+// and metadata to the object's user metadata.
+type BlobClient interface {
+	// PutObject stores data and metadata under the object key
+	PutObject(ctx context.Context, key string, data []byte, metadata map[string]string) error
+
+	// GetObject retrieves the data and metadata of an object, returning ErrBlobNotFound if it does not exist
+	GetObject(ctx context.Context, key string) ([]byte, map[string]string, error)
+
+	// DeleteObject removes an object
+	DeleteObject(ctx context.Context, key string) error
+}
+
+// BlobStreamClient is an optional interface for BlobClients that can stream objects, such as S3 uploads
+// through a multipart uploader and downloads of the response body, used by BlobCache.GetReader and SetReader.
+type BlobStreamClient interface {
+	// PutObjectStream stores the data read from r and metadata under the object key
+	PutObjectStream(ctx context.Context, key string, r io.Reader, metadata map[string]string) error
+
+	// GetObjectStream opens the data of an object and retrieves its metadata, returning ErrBlobNotFound
+	// if it does not exist
+	GetObjectStream(ctx context.Context, key string) (io.ReadCloser, map[string]string, error)
+}
+
+// BlobCache is a struct that implements the ICacheWithError interface on top of an object store,
+// for large values such as rendered reports. The expiry is kept in object metadata and enforced on reads:
+// expired objects are reported as misses but left in place, since deleting one could remove the newer
+// object another writer just replaced it with. Bucket lifecycle rules reclaim them.
+type BlobCache struct {
+	client BlobClient
+	prefix string
+	codec  Codec
+}
+
+// Set uploads the value with the specified time to live. A non-positive duration never expires.
+func (c *BlobCache) Set(key string, value any, d time.Duration) error {
+	data, err := marshalValue(c.codec, value, d)
+	if err != nil {
+		return err
+	}
+	metadata := map[string]string{}
+	if d > 0 {
+		metadata[blobExpiresAtKey] = strconv.FormatInt(time.Now().Add(d).UnixNano(), 10)
+	}
+	return c.client.PutObject(context.Background(), c.prefix+key, data, metadata)
+}
+
+// Get downloads the value if the object exists and has not expired.
+func (c *BlobCache) Get(key string) (any, bool, error) {
+	ctx := context.Background()
+	data, metadata, err := c.client.GetObject(ctx, c.prefix+key)
+	if errors.Is(err, ErrBlobNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if blobExpired(metadata) {
+		return nil, false, nil
+	}
+	value, err := c.codec.Unmarshal(data)
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// SetReader uploads the raw value read from r, bypassing the codec. The value is streamed if the client
+// implements BlobStreamClient, and read into memory first otherwise.
+func (c *BlobCache) SetReader(key string, r io.Reader, d time.Duration) error {
+	metadata := map[string]string{}
+	if d > 0 {
+		metadata[blobExpiresAtKey] = strconv.FormatInt(time.Now().Add(d).UnixNano(), 10)
+	}
+	ctx := context.Background()
+	if sc, ok := c.client.(BlobStreamClient); ok {
+		return sc.PutObjectStream(ctx, c.prefix+key, r, metadata)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return c.client.PutObject(ctx, c.prefix+key, data, metadata)
+}
+
+// GetReader opens the raw value for reading, bypassing the codec, if the object exists and has not expired.
+// The value is streamed if the client implements BlobStreamClient, and downloaded into memory first otherwise.
+func (c *BlobCache) GetReader(key string) (io.ReadCloser, bool, error) {
+	ctx := context.Background()
+	var body io.ReadCloser
+	var metadata map[string]string
+	var err error
+	if sc, ok := c.client.(BlobStreamClient); ok {
+		body, metadata, err = sc.GetObjectStream(ctx, c.prefix+key)
+	} else {
+		var data []byte
+		data, metadata, err = c.client.GetObject(ctx, c.prefix+key)
+		body = io.NopCloser(bytes.NewReader(data))
+	}
+	if errors.Is(err, ErrBlobNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if blobExpired(metadata) {
+		body.Close()
+		return nil, false, nil
+	}
+	return body, true, nil
+}
+
+// blobExpired reports whether object metadata holds an expiry that has passed or cannot be read.
+func blobExpired(metadata map[string]string) bool {
+	raw, ok := metadata[blobExpiresAtKey]
+	if !ok {
+		return false
+	}
+	expiresAt, err := strconv.ParseInt(raw, 10, 64)
+	return err != nil || time.Now().UnixNano() >= expiresAt
+}
+
+// Delete removes the object for the key.
+func (c *BlobCache) Delete(key string) error {
+	err := c.client.DeleteObject(context.Background(), c.prefix+key)
+	if errors.Is(err, ErrBlobNotFound) {
+		return nil
+	}
+	return err
+}
+
+// NewBlobCache creates a new instance of BlobCache storing objects under the specified key prefix.
+// Values are serialized with codec, or GobCodec wrapped in an EnvelopeCodec if codec is nil.
+func NewBlobCache(client BlobClient, prefix string, codec Codec) *BlobCache {
+	if codec == nil {
+		codec = defaultCodec()
+	}
+	return &BlobCache{
+		client: client,
+		prefix: prefix,
+		codec:  codec,
+	}
+}
@@ -0,0 +1,81 @@
+package once_cache
+
+import (
+	"context"
+	"io"
+	"maps"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryBlobClient is a BlobClient keeping objects in memory and counting the deletes it is sent.
+type memoryBlobClient struct {
+	mu       sync.Mutex
+	data     map[string][]byte
+	metadata map[string]map[string]string
+	deletes  int
+}
+
+func newMemoryBlobClient() *memoryBlobClient {
+	return &memoryBlobClient{data: map[string][]byte{}, metadata: map[string]map[string]string{}}
+}
+
+func (c *memoryBlobClient) PutObject(ctx context.Context, key string, data []byte, metadata map[string]string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.data[key], c.metadata[key] = data, maps.Clone(metadata)
+	return nil
+}
+
+func (c *memoryBlobClient) GetObject(ctx context.Context, key string) ([]byte, map[string]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data, ok := c.data[key]
+	if !ok {
+		return nil, nil, ErrBlobNotFound
+	}
+	return data, maps.Clone(c.metadata[key]), nil
+}
+
+func (c *memoryBlobClient) DeleteObject(ctx context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deletes++
+	delete(c.data, key)
+	delete(c.metadata, key)
+	return nil
+}
+
+func TestBlobCacheReadsOfExpiredObjectsDeleteNothing(t *testing.T) {
+	client := newMemoryBlobClient()
+	c := NewBlobCache(client, "p/", nil)
+	if err := c.Set("k", "v", time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	if v, ok, err := c.Get("k"); ok || err != nil {
+		t.Fatalf("Get of an expired object = %v, %v, %v, want a miss", v, ok, err)
+	}
+	if _, ok, err := c.GetReader("k"); ok || err != nil {
+		t.Fatalf("GetReader of an expired object = %v, %v, want a miss", ok, err)
+	}
+	if client.deletes != 0 {
+		t.Fatalf("reads sent %d deletes, want none", client.deletes)
+	}
+
+	// Another writer replacing the object is served.
+	if err := c.SetReader("k", strings.NewReader("new"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	r, ok, err := c.GetReader("k")
+	if !ok || err != nil {
+		t.Fatalf("GetReader = %v, %v, want a hit", ok, err)
+	}
+	defer r.Close()
+	if data, _ := io.ReadAll(r); string(data) != "new" {
+		t.Fatalf("GetReader read %q, want new", data)
+	}
+}
@@ -0,0 +1,38 @@
+package once_cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBreakerOpensAfterThresholdAndProbesOnce(t *testing.T) {
+	b := NewBreaker(2, 20*time.Millisecond)
+	b.Failure()
+	if b.Open() || !b.Allow() {
+		t.Fatal("breaker opened before reaching the threshold")
+	}
+	b.Failure()
+	if !b.Open() || b.Allow() {
+		t.Fatal("breaker did not open at the threshold")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if !b.Allow() {
+		t.Fatal("breaker refused the probe after the cooldown")
+	}
+	if b.Allow() {
+		t.Fatal("breaker let a second call through while probing")
+	}
+	// A failed probe reopens the breaker for another cooldown.
+	b.Failure()
+	if b.Allow() {
+		t.Fatal("breaker allowed a call right after a failed probe")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if !b.Allow() {
+		t.Fatal("breaker refused the second probe")
+	}
+	b.Success()
+	if b.Open() || !b.Allow() || !b.Allow() {
+		t.Fatal("breaker stayed open after a successful probe")
+	}
+}
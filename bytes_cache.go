@@ -0,0 +1,186 @@
+package once_cache
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// IBytesStore is a store of raw byte values, implemented by BytesMemoryStore and, bypassing their codecs,
+// by FileCache, SQLCache, DynamoCache and HTTPKVCache.
+type IBytesStore interface {
+	// GetBytes retrieves the value for key
+	GetBytes(key string) ([]byte, bool, error)
+
+	// SetBytes stores the value for key with the specified time to live
+	SetBytes(key string, data []byte, d time.Duration) error
+
+	// Delete removes key
+	Delete(key string) error
+}
+
+// OnceBytesCache is OnceCache specialized for byte values, for proxies and asset servers. Hits go straight
+// to the store without boxing values in interfaces, and concurrent misses for a key share one load.
+// Returned slices are shared between callers and with the store, and must not be modified.
+type OnceBytesCache struct {
+	group *singleflight.Group
+	store IBytesStore
+	ttl   time.Duration
+}
+
+// bytesFlight carries the result of a load shared by the callers of a flight.
+type bytesFlight struct {
+	data []byte
+}
+
+// GetWithSingleFunc retrieves the value for key, loading it with f and storing it for d if it is missing.
+// A zero duration uses the cache's default TTL. Store errors are treated as misses on reads and ignored
+// on writes, so the loaded value is still returned.
+func (c *OnceBytesCache) GetWithSingleFunc(key string, f func() ([]byte, error), d time.Duration) ([]byte, error) {
+	if data, ok, err := c.store.GetBytes(key); err == nil && ok {
+		return data, nil
+	}
+	if d == 0 {
+		d = c.ttl
+	}
+	v, err, _ := c.group.Do(key, func() (any, error) {
+		data, err := f()
+		if err != nil {
+			return nil, err
+		}
+		_ = c.store.SetBytes(key, data, d)
+		return &bytesFlight{data: data}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*bytesFlight).data, nil
+}
+
+// Get retrieves the value for key.
+func (c *OnceBytesCache) Get(key string) ([]byte, bool, error) {
+	return c.store.GetBytes(key)
+}
+
+// Set stores the value for key with the specified time to live, or the default TTL if d is zero.
+func (c *OnceBytesCache) Set(key string, data []byte, d time.Duration) error {
+	if d == 0 {
+		d = c.ttl
+	}
+	return c.store.SetBytes(key, data, d)
+}
+
+// Delete removes key.
+func (c *OnceBytesCache) Delete(key string) error {
+	return c.store.Delete(key)
+}
+
+// NewOnceBytesCache creates a new instance of OnceBytesCache over store, storing loaded values for
+// defaultTTL unless the call specifies a time to live.
+func NewOnceBytesCache(group *singleflight.Group, store IBytesStore, defaultTTL time.Duration) *OnceBytesCache {
+	return &OnceBytesCache{group: group, store: store, ttl: defaultTTL}
+}
+
+// BytesMemoryStore is a struct that implements the IBytesStore interface with in-process maps of byte
+// slices, which hold no interfaces for the garbage collector to scan through. Expired entries are removed
+// when read or by DeleteExpired.
+type BytesMemoryStore struct {
+	shards []bytesShard
+}
+
+type bytesShard struct {
+	mu    sync.RWMutex
+	items map[string]bytesEntry
+}
+
+type bytesEntry struct {
+	data      []byte
+	expiresAt int64 // Unix nanoseconds, zero if the entry never expires
+}
+
+func (s *BytesMemoryStore) shard(key string) *bytesShard {
+	return &s.shards[fnv1a(key)%uint64(len(s.shards))]
+}
+
+// GetBytes retrieves the value for key if it exists and has not expired. It never fails.
+func (s *BytesMemoryStore) GetBytes(key string) ([]byte, bool, error) {
+	sh := s.shard(key)
+	sh.mu.RLock()
+	e, ok := sh.items[key]
+	sh.mu.RUnlock()
+	if !ok {
+		return nil, false, nil
+	}
+	if now := time.Now().UnixNano(); e.expiresAt != 0 && now >= e.expiresAt {
+		sh.mu.Lock()
+		if cur, ok := sh.items[key]; ok && cur.expiresAt != 0 && now >= cur.expiresAt {
+			delete(sh.items, key)
+		}
+		sh.mu.Unlock()
+		return nil, false, nil
+	}
+	return e.data, true, nil
+}
+
+// SetBytes stores the value with the specified time to live. A non-positive duration never expires.
+// The slice is kept as is and must not be modified afterwards. It never fails.
+func (s *BytesMemoryStore) SetBytes(key string, data []byte, d time.Duration) error {
+	e := bytesEntry{data: data}
+	if d > 0 {
+		e.expiresAt = time.Now().Add(d).UnixNano()
+	}
+	sh := s.shard(key)
+	sh.mu.Lock()
+	sh.items[key] = e
+	sh.mu.Unlock()
+	return nil
+}
+
+// Delete removes key. It never fails.
+func (s *BytesMemoryStore) Delete(key string) error {
+	sh := s.shard(key)
+	sh.mu.Lock()
+	delete(sh.items, key)
+	sh.mu.Unlock()
+	return nil
+}
+
+// DeleteExpired removes all expired entries and returns how many were removed.
+func (s *BytesMemoryStore) DeleteExpired() int {
+	now := time.Now().UnixNano()
+	removed := 0
+	for i := range s.shards {
+		sh := &s.shards[i]
+		sh.mu.Lock()
+		for key, e := range sh.items {
+			if e.expiresAt != 0 && now >= e.expiresAt {
+				delete(sh.items, key)
+				removed++
+			}
+		}
+		sh.mu.Unlock()
+	}
+	return removed
+}
+
+// Len returns the number of stored entries, including expired entries not yet removed.
+func (s *BytesMemoryStore) Len() int {
+	n := 0
+	for i := range s.shards {
+		sh := &s.shards[i]
+		sh.mu.RLock()
+		n += len(sh.items)
+		sh.mu.RUnlock()
+	}
+	return n
+}
+
+// NewBytesMemoryStore creates a new instance of BytesMemoryStore with the default number of shards.
+func NewBytesMemoryStore() *BytesMemoryStore {
+	s := &BytesMemoryStore{shards: make([]bytesShard, defaultShards)}
+	for i := range s.shards {
+		s.shards[i].items = make(map[string]bytesEntry)
+	}
+	return s
+}
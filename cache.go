@@ -1,6 +1,9 @@
 package once_cache
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 type ICache interface {
 	// Set a value in the cache with an associated key
@@ -18,3 +21,130 @@ type ICache interface {
 	// // Get the number of items in the cache
 	// Size() int
 }
+
+// ICacheWithError is a cache store contract for backends that can fail, such as remote stores.
+// Use NewCacheWithError and NewCacheIgnoringErrors to convert between ICache and ICacheWithError.
+type ICacheWithError interface {
+	// Set a value in the cache with an associated key
+	Set(key string, value any, d time.Duration) error
+
+	// Get a value from the cache with a given key
+	Get(key string) (any, bool, error)
+
+	// Delete a value from the cache with a given key
+	Delete(key string) error
+}
+
+// Entry is a value with its time to live, as written by multi-set operations.
+type Entry struct {
+	Value any
+	TTL   time.Duration
+}
+
+// IMultiGetter is an optional interface for stores that can fetch several keys in one round trip,
+// such as Redis MGET or memcached multi-get. Missing keys are absent from the returned map.
+type IMultiGetter interface {
+	GetMulti(keys []string) map[string]any
+}
+
+// IMultiSetter is an optional interface for stores that can write several entries in one round trip,
+// such as a Redis pipeline.
+type IMultiSetter interface {
+	SetMulti(entries map[string]Entry)
+}
+
+// Priority orders entries for eviction: under pressure, lower priority entries are evicted first.
+type Priority int8
+
+const (
+	// PriorityLow is for entries that are cheap to lose, such as speculative prefetches.
+	PriorityLow Priority = -1
+	// PriorityNormal is the priority of entries stored with Set.
+	PriorityNormal Priority = 0
+	// PriorityHigh is for entries that should be evicted last, such as auth tokens.
+	PriorityHigh Priority = 1
+)
+
+// IPrioritySetter is an optional interface for stores that support eviction priorities.
+type IPrioritySetter interface {
+	SetWithPriority(key string, value any, d time.Duration, priority Priority)
+}
+
+// NoExpiration is a time to live for entries that never expire. Stores treat any non-positive
+// duration as never expiring, but OnceCache replaces a zero duration with its default TTL.
+const NoExpiration time.Duration = -1
+
+// IStaleGetter is an optional interface for stores that retain expired entries for a while,
+// so that they can be served when a fresh value cannot be loaded.
+type IStaleGetter interface {
+	// GetStale retrieves the value for key even if it has expired, along with its expiry time
+	GetStale(key string) (value any, expiresAt time.Time, ok bool)
+}
+
+// EntryInfo is the metadata of a cache entry.
+type EntryInfo struct {
+	// CreatedAt is when the entry was written.
+	CreatedAt time.Time
+	// ExpiresAt is when the entry expires, zero if it never expires.
+	ExpiresAt time.Time
+	// LastAccess is approximately when the entry was last read or written.
+	LastAccess time.Time
+	// Priority is the eviction priority of the entry.
+	Priority Priority
+	// Version increases with every write of the entry, zero if the store does not track versions.
+	Version uint64
+}
+
+// IEntryInfoGetter is an optional interface for stores that expose entry metadata.
+type IEntryInfoGetter interface {
+	// GetWithInfo retrieves the value for key along with its metadata
+	GetWithInfo(key string) (any, EntryInfo, bool)
+}
+
+// IContextGetter is an optional interface for stores whose lookups can be bounded by a context, such as
+// TieredCache with WithDeadlineRatios. OnceCache uses it for calls made with GetWithContext.
+type IContextGetter interface {
+	// GetContext retrieves the value for key, giving up as ctx allows
+	GetContext(ctx context.Context, key string) (any, bool)
+}
+
+// IVersionedSetter is an optional interface for stores supporting optimistic concurrency on entry versions.
+type IVersionedSetter interface {
+	// SetIfVersion stores the value only if the entry's current version is version, zero for a missing entry
+	SetIfVersion(key string, value any, version uint64, d time.Duration) (uint64, bool)
+}
+
+// IConditionalDeleter is an optional interface for stores that can delete an entry only if it still holds
+// a given value.
+type IConditionalDeleter interface {
+	// DeleteIfEquals removes the key only if its value equals expected and reports whether it did
+	DeleteIfEquals(key string, expected any) bool
+}
+
+// ITagInvalidator is an optional interface for stores that can delete all entries stored with a tag.
+type ITagInvalidator interface {
+	// DeleteTag removes every entry stored with tag and returns how many were removed
+	DeleteTag(tag string) int
+}
+
+// IStaggeredInvalidator is an optional interface for stores that can expire the entries of a tag
+// gradually instead of deleting them at once, see WithStaggeredInvalidation.
+type IStaggeredInvalidator interface {
+	// ExpireTag schedules the entries stored with tag to expire at random times within window and
+	// returns how many were scheduled
+	ExpireTag(tag string, window time.Duration) int
+}
+
+// IPurger is an optional interface for stores that can delete every entry matching a predicate.
+type IPurger interface {
+	// PurgeWhere removes every entry for which predicate returns true, including expired entries still
+	// retained, and returns the removed keys
+	PurgeWhere(predicate func(key string, meta EntryInfo) bool) []string
+}
+
+// IPurgerWithError is the form of IPurger for stores that report errors, such as SQLCache.
+type IPurgerWithError interface {
+	// PurgeWhere removes every entry for which predicate returns true and returns the removed keys.
+	// The keys removed before an error are returned along with it
+	PurgeWhere(predicate func(key string, meta EntryInfo) bool) ([]string, error)
+}
@@ -0,0 +1,77 @@
+package once_cache
+
+import "time"
+
+// legacyCache adapts an ICache to the ICacheWithError interface. It never reports errors.
+type legacyCache struct {
+	ICache
+}
+
+func (c legacyCache) Set(key string, value any, d time.Duration) error {
+	c.ICache.Set(key, value, d)
+	return nil
+}
+
+func (c legacyCache) Get(key string) (any, bool, error) {
+	value, ok := c.ICache.Get(key)
+	return value, ok, nil
+}
+
+func (c legacyCache) Delete(key string) error {
+	c.ICache.Delete(key)
+	return nil
+}
+
+// errorlessCache adapts an ICacheWithError to the ICache interface. Errors are treated as misses.
+type errorlessCache struct {
+	ICacheWithError
+}
+
+func (c errorlessCache) Set(key string, value any, d time.Duration) {
+	_ = c.ICacheWithError.Set(key, value, d)
+}
+
+func (c errorlessCache) Get(key string) (any, bool) {
+	value, ok, err := c.ICacheWithError.Get(key)
+	if err != nil {
+		return nil, false
+	}
+	return value, ok
+}
+
+func (c errorlessCache) Delete(key string) {
+	_ = c.ICacheWithError.Delete(key)
+}
+
+// CacheWithError returns the wrapped store so that OnceCache can use its errors.
+func (c errorlessCache) CacheWithError() ICacheWithError {
+	return c.ICacheWithError
+}
+
+// cacheWithErrorProvider is implemented by ICache values that wrap an ICacheWithError.
+type cacheWithErrorProvider interface {
+	CacheWithError() ICacheWithError
+}
+
+// asCacheWithError returns the error-returning form of cacheStore, detecting ICache values
+// that wrap an ICacheWithError and adapting legacy stores otherwise.
+func asCacheWithError(cacheStore ICache) ICacheWithError {
+	if p, ok := cacheStore.(cacheWithErrorProvider); ok {
+		return p.CacheWithError()
+	}
+	return legacyCache{cacheStore}
+}
+
+// NewCacheWithError adapts a legacy ICache to the ICacheWithError interface. The adapter never reports errors.
+func NewCacheWithError(cacheStore ICache) ICacheWithError {
+	return asCacheWithError(cacheStore)
+}
+
+// NewCacheIgnoringErrors adapts an ICacheWithError to the ICache interface, treating errors as misses.
+// OnceCache detects stores wrapped this way and keeps using their errors.
+func NewCacheIgnoringErrors(store ICacheWithError) ICache {
+	if l, ok := store.(legacyCache); ok {
+		return l.ICache
+	}
+	return errorlessCache{store}
+}
@@ -0,0 +1,174 @@
+package once_cache
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// WithLabel names the cache in the events it emits, to tell apart caches sharing a consumer.
+func WithLabel(label string) Option {
+	return func(o *OnceCache) {
+		o.label = label
+	}
+}
+
+// NewCacheGroup creates an OnceCache over a shared store with its own singleflight group, storing its
+// entries under the key prefix "name:" and labelled name, so that several logical caches with their own
+// default TTLs and options can share one store instance without their keys or flights colliding.
+// Options for the group, such as WithDefaultTTL, apply to it alone.
+func NewCacheGroup(store ICache, name string, opts ...Option) *OnceCache {
+	opts = append([]Option{WithLabel(name)}, opts...)
+	return NewOnceCache(&singleflight.Group{}, NewPrefixedCache(store, name+":"), opts...)
+}
+
+// NewPrefixedCache creates a view of store storing every key under prefix. It keeps the multi-key
+// operations, priorities, stale reads, entry metadata, context-bounded reads, conditional deletes and tag
+// invalidations of store for OnceCache to use. Tags are not prefixed: they name the entries of store tagged
+// with them, whichever view stored them.
+func NewPrefixedCache(store ICache, prefix string) ICache {
+	return &prefixedCache{store: store, prefix: prefix}
+}
+
+// prefixedCache is a struct that implements the ICache interface by prefixing the keys of another cache.
+type prefixedCache struct {
+	store  ICache
+	prefix string
+}
+
+func (p *prefixedCache) Set(key string, value any, d time.Duration) {
+	p.store.Set(p.prefix+key, value, d)
+}
+
+func (p *prefixedCache) Get(key string) (any, bool) {
+	return p.store.Get(p.prefix + key)
+}
+
+func (p *prefixedCache) Delete(key string) {
+	p.store.Delete(p.prefix + key)
+}
+
+// adapt sets the optional store interfaces of o to prefixing adapters of those of the underlying store.
+func (p *prefixedCache) adapt(o *OnceCache) {
+	if g, ok := p.store.(IMultiGetter); ok {
+		o.multiGetter = prefixedMultiGetter{p, g}
+	}
+	if m, ok := p.store.(IMultiSetter); ok {
+		o.multiSetter = prefixedMultiSetter{p, m}
+	}
+	if s, ok := p.store.(IPrioritySetter); ok {
+		o.prioritySetter = prefixedPrioritySetter{p, s}
+	}
+	if g, ok := p.store.(IStaleGetter); ok {
+		o.staleGetter = prefixedStaleGetter{p, g}
+	}
+	if g, ok := p.store.(IEntryInfoGetter); ok {
+		o.infoGetter = prefixedInfoGetter{p, g}
+	}
+	if n, ok := p.store.(IEvictionNotifier); ok {
+		o.evictionNotifier = prefixedEvictionNotifier{p, n}
+	}
+	if g, ok := p.store.(IContextGetter); ok {
+		o.contextGetter = prefixedContextGetter{p, g}
+	}
+	if d, ok := p.store.(IConditionalDeleter); ok {
+		o.condDeleter = prefixedConditionalDeleter{p, d}
+	}
+	// Tags are shared by every view of the store, so their invalidations are passed on as they are.
+	if t, ok := p.store.(ITagInvalidator); ok {
+		o.tagInvalidator = t
+	}
+	if t, ok := p.store.(IStaggeredInvalidator); ok {
+		o.staggerer = t
+	}
+}
+
+type prefixedMultiGetter struct {
+	p *prefixedCache
+	g IMultiGetter
+}
+
+func (a prefixedMultiGetter) GetMulti(keys []string) map[string]any {
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = a.p.prefix + key
+	}
+	values := make(map[string]any, len(keys))
+	for key, value := range a.g.GetMulti(prefixed) {
+		values[strings.TrimPrefix(key, a.p.prefix)] = value
+	}
+	return values
+}
+
+type prefixedMultiSetter struct {
+	p *prefixedCache
+	s IMultiSetter
+}
+
+func (a prefixedMultiSetter) SetMulti(entries map[string]Entry) {
+	prefixed := make(map[string]Entry, len(entries))
+	for key, e := range entries {
+		prefixed[a.p.prefix+key] = e
+	}
+	a.s.SetMulti(prefixed)
+}
+
+type prefixedPrioritySetter struct {
+	p *prefixedCache
+	s IPrioritySetter
+}
+
+func (a prefixedPrioritySetter) SetWithPriority(key string, value any, d time.Duration, priority Priority) {
+	a.s.SetWithPriority(a.p.prefix+key, value, d, priority)
+}
+
+type prefixedStaleGetter struct {
+	p *prefixedCache
+	g IStaleGetter
+}
+
+func (a prefixedStaleGetter) GetStale(key string) (any, time.Time, bool) {
+	return a.g.GetStale(a.p.prefix + key)
+}
+
+type prefixedInfoGetter struct {
+	p *prefixedCache
+	g IEntryInfoGetter
+}
+
+func (a prefixedInfoGetter) GetWithInfo(key string) (any, EntryInfo, bool) {
+	return a.g.GetWithInfo(a.p.prefix + key)
+}
+
+type prefixedEvictionNotifier struct {
+	p *prefixedCache
+	n IEvictionNotifier
+}
+
+func (a prefixedEvictionNotifier) OnEvict(f func(key string, reason EvictionReason)) {
+	a.n.OnEvict(func(key string, reason EvictionReason) {
+		if strings.HasPrefix(key, a.p.prefix) {
+			f(strings.TrimPrefix(key, a.p.prefix), reason)
+		}
+	})
+}
+
+type prefixedContextGetter struct {
+	p *prefixedCache
+	g IContextGetter
+}
+
+func (a prefixedContextGetter) GetContext(ctx context.Context, key string) (any, bool) {
+	return a.g.GetContext(ctx, a.p.prefix+key)
+}
+
+type prefixedConditionalDeleter struct {
+	p *prefixedCache
+	d IConditionalDeleter
+}
+
+func (a prefixedConditionalDeleter) DeleteIfEquals(key string, expected any) bool {
+	return a.d.DeleteIfEquals(a.p.prefix+key, expected)
+}
@@ -0,0 +1,90 @@
+package once_cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// contextStore is a MemoryCache recording the keys read with GetContext.
+type contextStore struct {
+	*MemoryCache
+	keys []string
+}
+
+func (s *contextStore) GetContext(ctx context.Context, key string) (any, bool) {
+	s.keys = append(s.keys, key)
+	return s.Get(key)
+}
+
+func TestCacheGroupGetWithContextUsesTheStoresContextReads(t *testing.T) {
+	store := &contextStore{MemoryCache: NewMemoryCache()}
+	defer store.Close()
+	users := NewCacheGroup(store, "users")
+	users.Set("a", 1, time.Minute)
+
+	v, ok := users.GetWithContext(context.Background(), "a", func(context.Context, string) (any, error) {
+		return nil, errors.New("loaded a cached key")
+	})
+	if !ok || v != 1 {
+		t.Fatalf("GetWithContext = %v, %v, want 1, true", v, ok)
+	}
+	if len(store.keys) != 1 || store.keys[0] != "users:a" {
+		t.Fatalf("context reads = %v, want [users:a]", store.keys)
+	}
+}
+
+func TestCacheGroupDeleteIfEqualsUsesItsPrefix(t *testing.T) {
+	store := NewMemoryCache()
+	defer store.Close()
+	users := NewCacheGroup(store, "users")
+	users.Set("a", 1, time.Minute)
+	store.Set("a", 1, time.Minute)
+
+	if ok, err := users.DeleteIfEquals("a", 2); ok || err != nil {
+		t.Fatalf("DeleteIfEquals of another value = %v, %v, want false, nil", ok, err)
+	}
+	if ok, err := users.DeleteIfEquals("a", 1); !ok || err != nil {
+		t.Fatalf("DeleteIfEquals = %v, %v, want true, nil", ok, err)
+	}
+	if v, ok := store.Get("users:a"); ok {
+		t.Fatalf("the store holds %v under the group's key, want nothing", v)
+	}
+	if _, ok := store.Get("a"); !ok {
+		t.Fatal("DeleteIfEquals removed the unprefixed key")
+	}
+}
+
+func TestDeleteIfEqualsReportsUnsupportedStores(t *testing.T) {
+	store := NewPipelinedCache(newRecordingPipelineStore(), 100, 0)
+	defer store.Close()
+	c := NewCacheGroup(store, "users")
+	c.Set("a", 1, time.Minute)
+	if ok, err := c.DeleteIfEquals("a", 1); ok || !errors.Is(err, ErrConditionalDeleteUnsupported) {
+		t.Fatalf("DeleteIfEquals = %v, %v, want false, ErrConditionalDeleteUnsupported", ok, err)
+	}
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("DeleteIfEquals removed the key from a store without conditional deletes")
+	}
+}
+
+func TestCacheGroupStaggersTagInvalidations(t *testing.T) {
+	store := NewMemoryCache()
+	defer store.Close()
+	users := NewCacheGroup(store, "users", WithStaggeredInvalidation(20*time.Millisecond))
+	store.SetWithTags("users:a", 1, time.Hour, "t")
+	store.SetWithTags("users:b", 2, time.Hour, "other")
+
+	if n := users.DeleteTag("t"); n != 1 {
+		t.Fatalf("DeleteTag = %d, want 1 entry scheduled", n)
+	}
+	// The entry is scheduled to expire within the window rather than deleted.
+	time.Sleep(30 * time.Millisecond)
+	if v, ok := users.Get("a"); ok {
+		t.Fatalf("Get after the window = %v, want a miss", v)
+	}
+	if _, ok := users.Get("b"); !ok {
+		t.Fatal("DeleteTag expired an entry without the tag")
+	}
+}
@@ -0,0 +1,175 @@
+// Package cachesim replays recorded key-access traces against eviction policies of various sizes offline
+// and reports their hit ratios, for capacity planning of bounded caches.
+package cachesim
+
+import (
+	"container/list"
+	"fmt"
+	"io"
+	"sort"
+
+	once_cache "github.com/phongthien99/once-cache"
+)
+
+// Policy is a bounded cache under simulation.
+type Policy interface {
+	// Access looks the key up, admitting it on a miss, and reports whether it was a hit
+	Access(key string) bool
+}
+
+// PolicyFactory creates a policy holding up to size keys.
+type PolicyFactory func(size int) Policy
+
+// Policies are the built-in policies by name: lru, fifo and memory, the approximated LRU of
+// once_cache.MemoryCache with WithMaxEntries.
+var Policies = map[string]PolicyFactory{
+	"lru":    NewLRU,
+	"fifo":   NewFIFO,
+	"memory": NewMemoryPolicy,
+}
+
+// Result is the outcome of replaying a trace against a policy of a given size.
+type Result struct {
+	Policy   string
+	Size     int
+	Accesses int64
+	Hits     int64
+}
+
+// HitRatio returns the fraction of accesses that were hits.
+func (r Result) HitRatio() float64 {
+	if r.Accesses == 0 {
+		return 0
+	}
+	return float64(r.Hits) / float64(r.Accesses)
+}
+
+// Replay runs the trace against a policy and returns the result.
+func Replay(name string, size int, p Policy, trace []string) Result {
+	r := Result{Policy: name, Size: size, Accesses: int64(len(trace))}
+	for _, key := range trace {
+		if p.Access(key) {
+			r.Hits++
+		}
+	}
+	return r
+}
+
+// Simulate replays the trace against every policy at every size, sorted by policy name and size.
+func Simulate(trace []string, policies map[string]PolicyFactory, sizes []int) []Result {
+	names := make([]string, 0, len(policies))
+	for name := range policies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	sizes = append([]int(nil), sizes...)
+	sort.Ints(sizes)
+	var results []Result
+	for _, name := range names {
+		for _, size := range sizes {
+			results = append(results, Replay(name, size, policies[name](size), trace))
+		}
+	}
+	return results
+}
+
+// Fprint writes the results as a table of hit ratios, one row per size and one column per policy.
+func Fprint(w io.Writer, results []Result) error {
+	var names []string
+	var sizes []int
+	ratios := make(map[string]map[int]float64)
+	for _, r := range results {
+		if _, ok := ratios[r.Policy]; !ok {
+			names = append(names, r.Policy)
+			ratios[r.Policy] = make(map[int]float64)
+		}
+		if len(names) == 1 {
+			sizes = append(sizes, r.Size)
+		}
+		ratios[r.Policy][r.Size] = r.HitRatio()
+	}
+	if _, err := fmt.Fprintf(w, "%12s", "size"); err != nil {
+		return err
+	}
+	for _, name := range names {
+		fmt.Fprintf(w, " %10s", name)
+	}
+	fmt.Fprintln(w)
+	for _, size := range sizes {
+		fmt.Fprintf(w, "%12d", size)
+		for _, name := range names {
+			fmt.Fprintf(w, " %9.2f%%", 100*ratios[name][size])
+		}
+		if _, err := fmt.Fprintln(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lru is an exact least recently used policy.
+type lru struct {
+	size  int
+	order *list.List
+	items map[string]*list.Element
+}
+
+// NewLRU creates an exact least recently used policy.
+func NewLRU(size int) Policy {
+	return &lru{size: size, order: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (p *lru) Access(key string) bool {
+	if el, ok := p.items[key]; ok {
+		p.order.MoveToFront(el)
+		return true
+	}
+	p.items[key] = p.order.PushFront(key)
+	if p.order.Len() > p.size {
+		delete(p.items, p.order.Remove(p.order.Back()).(string))
+	}
+	return false
+}
+
+// fifo evicts keys in admission order, regardless of hits.
+type fifo struct {
+	size  int
+	order *list.List
+	items map[string]struct{}
+}
+
+// NewFIFO creates a first in, first out policy.
+func NewFIFO(size int) Policy {
+	return &fifo{size: size, order: list.New(), items: make(map[string]struct{})}
+}
+
+func (p *fifo) Access(key string) bool {
+	if _, ok := p.items[key]; ok {
+		return true
+	}
+	p.items[key] = struct{}{}
+	p.order.PushBack(key)
+	if p.order.Len() > p.size {
+		delete(p.items, p.order.Remove(p.order.Front()).(string))
+	}
+	return false
+}
+
+// memoryPolicy replays accesses against a real bounded MemoryCache.
+type memoryPolicy struct {
+	cache *once_cache.MemoryCache
+}
+
+// NewMemoryPolicy creates a policy backed by once_cache.MemoryCache bounded with WithMaxEntries, so that
+// the simulation reflects its approximated LRU.
+func NewMemoryPolicy(size int) Policy {
+	return memoryPolicy{cache: once_cache.NewMemoryCache(once_cache.WithMaxEntries(size))}
+}
+
+func (p memoryPolicy) Access(key string) bool {
+	if _, ok := p.cache.Get(key); ok {
+		return true
+	}
+	p.cache.Set(key, struct{}{}, once_cache.NoExpiration)
+	return false
+}
@@ -0,0 +1,63 @@
+package cachesim
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ReadCSV reads a trace with one access per record, the key being in the column at index column.
+// Empty lines and lines starting with # are skipped.
+func ReadCSV(r io.Reader, column int) ([]string, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.Comment = '#'
+	cr.ReuseRecord = true
+	var trace []string
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return trace, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if column >= len(record) {
+			line, _ := cr.FieldPos(0)
+			return nil, fmt.Errorf("cachesim: line %d has no column %d", line, column)
+		}
+		trace = append(trace, record[column])
+	}
+}
+
+// ReadARC reads a trace in the format of the ARC paper traces, where each line holds a starting block,
+// a number of blocks and two ignored fields, and stands for accesses to each of those blocks in turn.
+func ReadARC(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	var trace []string
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("cachesim: line %d: want at least 2 fields, got %d", line, len(fields))
+		}
+		start, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("cachesim: line %d: %w", line, err)
+		}
+		count, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("cachesim: line %d: %w", line, err)
+		}
+		for i := uint64(0); i < count; i++ {
+			trace = append(trace, strconv.FormatUint(start+i, 10))
+		}
+	}
+	return trace, scanner.Err()
+}
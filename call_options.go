@@ -0,0 +1,101 @@
+package once_cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrLoadTimeout is reported when a load does not finish within the time given by WithTimeout.
+var ErrLoadTimeout = errors.New("once_cache: load timed out")
+
+// CallOption configures a single call to GetWithOptions. Hits do not allocate when the options are built
+// once and passed as a slice, as in GetWithOptions(key, f, opts...).
+type CallOption func(*callConfig)
+
+type callConfig struct {
+	ttl          time.Duration
+	errorHandler CatchErrorFunc
+	forceRefresh bool
+	timeout      time.Duration
+	staleOK      bool
+	budget       time.Duration
+	waitTimeout  time.Duration
+	dependsOn    []string
+	session      SessionToken
+	ctx          context.Context
+}
+
+// callConfigs pools the configs of calls with options, which move to the heap since options take their address.
+var callConfigs = sync.Pool{New: func() any { return new(callConfig) }}
+
+// acquireCallConfig returns a pooled config applying opts, to be released with releaseCallConfig once
+// the call returns.
+func acquireCallConfig(opts []CallOption) *callConfig {
+	c := callConfigs.Get().(*callConfig)
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func releaseCallConfig(c *callConfig) {
+	*c = callConfig{}
+	callConfigs.Put(c)
+}
+
+// WithTTL sets the time to live of the loaded value. Without it, the cache's default TTL is used.
+func WithTTL(d time.Duration) CallOption {
+	return func(c *callConfig) {
+		c.ttl = d
+	}
+}
+
+// WithErrorHandler sets the function called when the load fails.
+func WithErrorHandler(handler CatchErrorFunc) CallOption {
+	return func(c *callConfig) {
+		c.errorHandler = handler
+	}
+}
+
+// WithForceRefresh skips the cached value and loads a fresh one.
+func WithForceRefresh() CallOption {
+	return func(c *callConfig) {
+		c.forceRefresh = true
+	}
+}
+
+// WithTimeout bounds how long the call waits for the load. When it is exceeded the call fails with
+// ErrLoadTimeout, while the load keeps running and stores its result for later callers.
+func WithTimeout(d time.Duration) CallOption {
+	return func(c *callConfig) {
+		c.timeout = d
+	}
+}
+
+// WithStaleOK serves an expired value retained by the store when the load fails.
+// The store must implement IStaleGetter, as MemoryCache does with WithStaleRetention.
+func WithStaleOK() CallOption {
+	return func(c *callConfig) {
+		c.staleOK = true
+	}
+}
+
+// WithBudget sets the response-time budget of the call, overriding WithResponseBudget.
+// A negative budget disables it for the call.
+func WithBudget(d time.Duration) CallOption {
+	return func(c *callConfig) {
+		c.budget = d
+	}
+}
+
+// WithWaitTimeout bounds how long the call waits for the load, whether it runs the load or joins a flight
+// started by another caller, independently of any loader timeout such as TimeoutLoader. When it is exceeded,
+// the call is served the stale value if the store retains one and fails with ErrLoadTimeout otherwise,
+// while the load carries on for the other waiters.
+func WithWaitTimeout(d time.Duration) CallOption {
+	return func(c *callConfig) {
+		c.waitTimeout = d
+	}
+}
@@ -0,0 +1,30 @@
+package once_cache
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrInjectedFault is the error of the faults injected by WithChaos, NewChaosStore and NewChaosTransport.
+var ErrInjectedFault = errors.New("once_cache: injected fault")
+
+// ChaosEnabled reports whether fault injection is compiled in. Faults are only injected in binaries built
+// with the chaos build tag, go build -tags chaos; in other builds WithChaos, NewChaosStore and
+// NewChaosTransport have no effect, so chaos settings can stay in code shipped to production.
+const ChaosEnabled = chaosEnabled
+
+// ChaosConfig describes the faults to inject. Rates are fractions in [0, 1] of the operations affected.
+type ChaosConfig struct {
+	// StoreErrorRate is the rate of store Get, Set and Delete calls failing with ErrInjectedFault.
+	StoreErrorRate float64
+	// LoaderErrorRate is the rate of loads failing with ErrInjectedFault without calling the loader.
+	LoaderErrorRate float64
+	// LoaderLatency is added to loads, at LoaderLatencyRate.
+	LoaderLatency     time.Duration
+	LoaderLatencyRate float64
+	// DropInvalidationRate is the rate of invalidations published or delivered by a transport silently lost.
+	DropInvalidationRate float64
+	// Random is the source of randomness deciding which operations fail, the shared source of math/rand
+	// if nil. A seeded source, see the testutil package, makes the faults reproducible.
+	Random Random
+}
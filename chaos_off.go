@@ -0,0 +1,23 @@
+//go:build !chaos
+
+package once_cache
+
+const chaosEnabled = false
+
+// WithChaos injects the faults of cfg into the store and the loads of the cache.
+// It has no effect unless the binary is built with the chaos build tag, see ChaosEnabled.
+func WithChaos(cfg ChaosConfig) Option {
+	return func(o *OnceCache) {}
+}
+
+// NewChaosStore returns store failing operations as cfg.StoreErrorRate sets.
+// It returns store itself unless the binary is built with the chaos build tag, see ChaosEnabled.
+func NewChaosStore(store ICacheWithError, cfg ChaosConfig) ICacheWithError {
+	return store
+}
+
+// NewChaosTransport returns transport losing invalidations as cfg.DropInvalidationRate sets.
+// It returns transport itself unless the binary is built with the chaos build tag, see ChaosEnabled.
+func NewChaosTransport(transport InvalidationTransport, cfg ChaosConfig) InvalidationTransport {
+	return transport
+}
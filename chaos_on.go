@@ -0,0 +1,120 @@
+//go:build chaos
+
+package once_cache
+
+import (
+	"context"
+	"time"
+)
+
+const chaosEnabled = true
+
+// WithChaos injects the faults of cfg into the store and the loads of the cache.
+// It has no effect unless the binary is built with the chaos build tag, see ChaosEnabled.
+func WithChaos(cfg ChaosConfig) Option {
+	return func(o *OnceCache) {
+		c := newChaos(cfg)
+		if cfg.StoreErrorRate > 0 {
+			o.store = &chaosStore{store: o.store, chaos: c}
+			o.ICache = NewCacheIgnoringErrors(o.store)
+		}
+		if cfg.LoaderErrorRate > 0 || (cfg.LoaderLatency > 0 && cfg.LoaderLatencyRate > 0) {
+			o.loaderMiddleware = append(o.loaderMiddleware, c.loader)
+		}
+	}
+}
+
+// NewChaosStore returns store failing operations as cfg.StoreErrorRate sets.
+// It returns store itself unless the binary is built with the chaos build tag, see ChaosEnabled.
+func NewChaosStore(store ICacheWithError, cfg ChaosConfig) ICacheWithError {
+	return &chaosStore{store: store, chaos: newChaos(cfg)}
+}
+
+// NewChaosTransport returns transport losing invalidations as cfg.DropInvalidationRate sets.
+// It returns transport itself unless the binary is built with the chaos build tag, see ChaosEnabled.
+func NewChaosTransport(transport InvalidationTransport, cfg ChaosConfig) InvalidationTransport {
+	return &chaosTransport{transport: transport, chaos: newChaos(cfg)}
+}
+
+// chaos decides which operations fail.
+type chaos struct {
+	cfg    ChaosConfig
+	random Random
+}
+
+func newChaos(cfg ChaosConfig) *chaos {
+	return &chaos{cfg: cfg, random: randomOrDefault(cfg.Random)}
+}
+
+// hit reports whether an operation is affected at rate.
+func (c *chaos) hit(rate float64) bool {
+	return rate > 0 && c.random.Float64() < rate
+}
+
+// loader is a LoaderMiddleware delaying and failing loads.
+func (c *chaos) loader(next KeyedFunc) KeyedFunc {
+	return func(ctx context.Context, key string) (any, error) {
+		if c.cfg.LoaderLatency > 0 && c.hit(c.cfg.LoaderLatencyRate) {
+			timer := time.NewTimer(c.cfg.LoaderLatency)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, ctx.Err()
+			}
+		}
+		if c.hit(c.cfg.LoaderErrorRate) {
+			return nil, ErrInjectedFault
+		}
+		return next(ctx, key)
+	}
+}
+
+// chaosStore is an ICacheWithError failing a share of the operations of a store.
+type chaosStore struct {
+	store ICacheWithError
+	chaos *chaos
+}
+
+func (s *chaosStore) Set(key string, value any, d time.Duration) error {
+	if s.chaos.hit(s.chaos.cfg.StoreErrorRate) {
+		return ErrInjectedFault
+	}
+	return s.store.Set(key, value, d)
+}
+
+func (s *chaosStore) Get(key string) (any, bool, error) {
+	if s.chaos.hit(s.chaos.cfg.StoreErrorRate) {
+		return nil, false, ErrInjectedFault
+	}
+	return s.store.Get(key)
+}
+
+func (s *chaosStore) Delete(key string) error {
+	if s.chaos.hit(s.chaos.cfg.StoreErrorRate) {
+		return ErrInjectedFault
+	}
+	return s.store.Delete(key)
+}
+
+// chaosTransport is an InvalidationTransport losing a share of the invalidations.
+type chaosTransport struct {
+	transport InvalidationTransport
+	chaos     *chaos
+}
+
+func (t *chaosTransport) Publish(ctx context.Context, inv Invalidation) error {
+	if t.chaos.hit(t.chaos.cfg.DropInvalidationRate) {
+		return nil
+	}
+	return t.transport.Publish(ctx, inv)
+}
+
+func (t *chaosTransport) Subscribe(ctx context.Context, handler func(ctx context.Context, inv Invalidation) error) error {
+	return t.transport.Subscribe(ctx, func(ctx context.Context, inv Invalidation) error {
+		if t.chaos.hit(t.chaos.cfg.DropInvalidationRate) {
+			return nil
+		}
+		return handler(ctx, inv)
+	})
+}
@@ -0,0 +1,51 @@
+// Command benchcache generates load against the in-process cache backends and prints a comparison
+// of their throughput, latency percentiles and hit ratio.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	once_cache "github.com/phongthien99/once-cache"
+	"github.com/phongthien99/once-cache/benchcache"
+)
+
+func main() {
+	var w benchcache.Workload
+	flag.DurationVar(&w.Duration, "duration", 0, "duration of each run (default 10s)")
+	flag.IntVar(&w.Workers, "workers", 0, "concurrent workers (default 8)")
+	flag.IntVar(&w.Keys, "keys", 0, "distinct keys (default 100000)")
+	flag.Float64Var(&w.ReadRatio, "reads", 0, "fraction of reads (default 0.9)")
+	flag.Float64Var(&w.MissRatio, "misses", 0, "fraction of reads for keys never written")
+	flag.Float64Var(&w.Zipf, "zipf", 0, "Zipf exponent of key popularity, uniform if at most 1")
+	flag.IntVar(&w.ValueSize, "value-size", 0, "size of written values in bytes (default 64)")
+	flag.DurationVar(&w.TTL, "ttl", 0, "time to live of written values")
+	flag.BoolVar(&w.Warm, "warm", true, "write every key before measuring")
+	flag.Int64Var(&w.Seed, "seed", 1, "random seed")
+	backends := flag.String("backends", "sharded,syncmap,bounded", "comma separated backends: sharded, syncmap, bounded")
+	maxEntries := flag.Int("max-entries", 10000, "capacity of the bounded backend")
+	flag.Parse()
+
+	var reports []benchcache.Report
+	for _, name := range strings.Split(*backends, ",") {
+		var cache once_cache.ICache
+		switch name {
+		case "sharded":
+			cache = once_cache.NewMemoryCache()
+		case "syncmap":
+			cache = once_cache.NewMemoryCache(once_cache.WithSyncMap())
+		case "bounded":
+			cache = once_cache.NewMemoryCache(once_cache.WithMaxEntries(*maxEntries))
+		default:
+			fmt.Fprintf(os.Stderr, "benchcache: unknown backend %q\n", name)
+			os.Exit(2)
+		}
+		reports = append(reports, benchcache.Run(name, cache, w))
+	}
+	if err := benchcache.Fprint(os.Stdout, reports...); err != nil {
+		fmt.Fprintln(os.Stderr, "benchcache:", err)
+		os.Exit(1)
+	}
+}
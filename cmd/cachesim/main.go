@@ -0,0 +1,73 @@
+// Command cachesim replays a key-access trace against eviction policies of several sizes and prints
+// their hit ratios.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/phongthien99/once-cache/cachesim"
+)
+
+func main() {
+	format := flag.String("format", "csv", "trace format: csv or arc")
+	column := flag.Int("column", 0, "index of the key column in csv traces")
+	sizesFlag := flag.String("sizes", "1000,10000,100000", "comma separated cache sizes")
+	policiesFlag := flag.String("policies", "lru,fifo,memory", "comma separated policies: lru, fifo, memory")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: cachesim [flags] trace")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var sizes []int
+	for _, s := range strings.Split(*sizesFlag, ",") {
+		size, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil || size <= 0 {
+			fail(fmt.Errorf("invalid size %q", s))
+		}
+		sizes = append(sizes, size)
+	}
+	policies := make(map[string]cachesim.PolicyFactory)
+	for _, name := range strings.Split(*policiesFlag, ",") {
+		factory, ok := cachesim.Policies[name]
+		if !ok {
+			fail(fmt.Errorf("unknown policy %q", name))
+		}
+		policies[name] = factory
+	}
+
+	f, err := os.Open(flag.Arg(0))
+	if err != nil {
+		fail(err)
+	}
+	var trace []string
+	switch *format {
+	case "csv":
+		trace, err = cachesim.ReadCSV(f, *column)
+	case "arc":
+		trace, err = cachesim.ReadARC(f)
+	default:
+		err = fmt.Errorf("unknown format %q", *format)
+	}
+	f.Close()
+	if err != nil {
+		fail(err)
+	}
+	fmt.Printf("%d accesses\n", len(trace))
+	if err := cachesim.Fprint(os.Stdout, cachesim.Simulate(trace, policies, sizes)); err != nil {
+		fail(err)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "cachesim:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,282 @@
+// Command once-cached serves a named in-memory OnceCache over HTTP, for local development, prototyping
+// clients and trying the cache out:
+//
+//	GET    /keys/{key}            the value, or 404 Not Found; X-Cache tells a hit from a load
+//	PUT    /keys/{key}?ttl=30s    stores the request body, with the default TTL if ttl is absent
+//	DELETE /keys/{key}            removes the key
+//	GET    /stats                 counters of the cache as JSON
+//
+// With -origin, misses are loaded from the origin URL followed by the escaped key, with concurrent misses
+// of a key sharing one request. The server speaks the protocol of HTTPKVCache, which can use it as a store.
+//
+// With -handoff, the server waits on startup for the instance it replaces to hand its entries over on the
+// unix socket, and hands its own entries over on the socket when it receives SIGTERM or SIGINT, so that
+// restarts keep the cache warm. The handoff is experimental.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	once_cache "github.com/phongthien99/once-cache"
+	"golang.org/x/sync/singleflight"
+)
+
+// maxValueSize bounds the size of stored values.
+const maxValueSize = 32 << 20
+
+// server exposes a cache over HTTP.
+type server struct {
+	name   string
+	memory *once_cache.MemoryCache
+	cache  *once_cache.OnceCache
+	origin string
+	client *http.Client
+
+	hits, misses, loads, sets, deletes atomic.Uint64
+}
+
+// stats is the body of GET /stats.
+type stats struct {
+	Name           string                     `json:"name"`
+	Entries        int                        `json:"entries"`
+	EstimatedBytes int64                      `json:"estimated_bytes"`
+	DefaultTTL     string                     `json:"default_ttl"`
+	Hits           uint64                     `json:"hits"`
+	Misses         uint64                     `json:"misses"`
+	Loads          uint64                     `json:"loads"`
+	Sets           uint64                     `json:"sets"`
+	Deletes        uint64                     `json:"deletes"`
+	Coalescing     once_cache.CoalescingStats `json:"coalescing"`
+}
+
+func (s *server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/keys/", s.handleKey)
+	mux.HandleFunc("/stats", s.handleStats)
+	return mux
+}
+
+func (s *server) handleKey(w http.ResponseWriter, r *http.Request) {
+	key, err := url.PathUnescape(strings.TrimPrefix(r.URL.EscapedPath(), "/keys/"))
+	if err != nil || key == "" {
+		http.Error(w, "missing or malformed key", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("X-Cache-Name", s.name)
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		s.get(w, r, key)
+	case http.MethodPut:
+		s.put(w, r, key)
+	case http.MethodDelete:
+		s.cache.Delete(key)
+		s.deletes.Add(1)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *server) get(w http.ResponseWriter, r *http.Request, key string) {
+	var res once_cache.Result
+	if s.origin != "" {
+		res = s.cache.GetResult(key, func() (any, error) {
+			return s.load(key)
+		})
+	} else {
+		value, ok := s.cache.Get(key)
+		res = once_cache.Result{Value: value, Hit: ok}
+		if !ok {
+			res.Err = once_cache.ErrRecordNotFound
+		}
+	}
+	switch {
+	case res.Hit:
+		s.hits.Add(1)
+		w.Header().Set("X-Cache", "hit")
+	case res.Stale:
+		w.Header().Set("X-Cache", "stale")
+	default:
+		s.misses.Add(1)
+		w.Header().Set("X-Cache", "miss")
+	}
+	if !res.OK() {
+		if errors.Is(res.Err, once_cache.ErrRecordNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, res.Err.Error(), http.StatusBadGateway)
+		return
+	}
+	data, _ := res.Value.([]byte)
+	if _, info, ok := s.memory.GetWithInfo(key); ok && !info.ExpiresAt.IsZero() {
+		w.Header().Set("Expires", info.ExpiresAt.UTC().Format(http.TimeFormat))
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Write(data)
+}
+
+// load fetches key from the origin. The load is shared by concurrent requests, so it does not use the
+// context of the request that started it.
+func (s *server) load(key string) (any, error) {
+	s.loads.Add(1)
+	resp, err := s.client.Get(s.origin + url.PathEscape(key))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, once_cache.ErrRecordNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("origin responded %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxValueSize))
+}
+
+func (s *server) put(w http.ResponseWriter, r *http.Request, key string) {
+	ttl, err := parseTTL(r.URL.Query().Get("ttl"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxValueSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	s.cache.SetWithReason(key, data, ttl, "http")
+	s.sets.Add(1)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parseTTL parses a time to live given as a duration, such as "30s", or as whole seconds, such as "30".
+// An empty string is the default TTL.
+func parseTTL(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		if n <= 0 {
+			return once_cache.NoExpiration, nil
+		}
+		return time.Duration(n) * time.Second, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid ttl %q", s)
+	}
+	if d <= 0 {
+		return once_cache.NoExpiration, nil
+	}
+	return d, nil
+}
+
+func (s *server) handleStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats{
+		Name:           s.name,
+		Entries:        s.memory.Len(),
+		EstimatedBytes: s.memory.EstimatedBytes(1000),
+		DefaultTTL:     s.cache.DefaultTTL().String(),
+		Hits:           s.hits.Load(),
+		Misses:         s.misses.Load(),
+		Loads:          s.loads.Load(),
+		Sets:           s.sets.Load(),
+		Deletes:        s.deletes.Load(),
+		Coalescing:     s.cache.CoalescingStats(),
+	})
+}
+
+func main() {
+	addr := flag.String("addr", "localhost:7070", "address to listen on")
+	name := flag.String("name", "default", "name of the cache")
+	ttl := flag.Duration("ttl", 5*time.Minute, "default time to live")
+	maxEntries := flag.Int("max-entries", 0, "bound on the number of entries, zero for none")
+	origin := flag.String("origin", "", "base URL misses are loaded from, followed by the key")
+	cleanup := flag.Duration("cleanup", time.Minute, "interval of the removal of expired entries")
+	handoff := flag.String("handoff", "", "unix socket the entries are handed over on across restarts")
+	handoffWait := flag.Duration("handoff-wait", 30*time.Second, "how long to wait on startup for a handoff")
+	flag.Parse()
+
+	memory := once_cache.NewMemoryCache(once_cache.WithMaxEntries(*maxEntries), once_cache.WithCleanupInterval(*cleanup))
+	s := &server{
+		name:   *name,
+		memory: memory,
+		cache:  once_cache.NewOnceCache(&singleflight.Group{}, memory, once_cache.WithDefaultTTL(*ttl), once_cache.WithLabel(*name)),
+		origin: *origin,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+	if *handoff != "" {
+		go receiveHandoff(memory, *handoff, *handoffWait)
+	}
+	srv := &http.Server{Addr: *addr, Handler: s.routes()}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, syscall.SIGTERM, os.Interrupt)
+		<-stop
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			log.Print("once-cached: shutdown: ", err)
+		}
+		if *handoff != "" {
+			sendHandoff(ctx, memory, *handoff)
+		}
+	}()
+	log.Printf("once-cached: serving cache %q on %s", *name, *addr)
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal("once-cached: ", err)
+	}
+	<-done
+}
+
+// receiveHandoff restores the entries handed over on path by the instance being replaced, if it does so
+// within wait. Requests are served meanwhile.
+func receiveHandoff(memory *once_cache.MemoryCache, path string, wait time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), wait)
+	defer cancel()
+	n, err := memory.ReceiveHandoff(ctx, path)
+	switch {
+	case errors.Is(err, context.DeadlineExceeded) && n == 0:
+		log.Printf("once-cached: no handoff on %s, starting cold", path)
+	case err != nil:
+		log.Printf("once-cached: handoff: restored %d entries before failing: %v", n, err)
+	default:
+		log.Printf("once-cached: handoff: restored %d entries", n)
+	}
+}
+
+// sendHandoff hands the entries over to the instance waiting on path, if any.
+func sendHandoff(ctx context.Context, memory *once_cache.MemoryCache, path string) {
+	n, err := memory.SendHandoff(ctx, path)
+	if err != nil {
+		log.Printf("once-cached: handoff: sent %d entries before failing: %v", n, err)
+		return
+	}
+	log.Printf("once-cached: handoff: sent %d entries", n)
+}
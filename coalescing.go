@@ -0,0 +1,167 @@
+package once_cache
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// CoalescingStats counts the loads saved by coalescing the single and batch call paths, see
+// WithCrossPathCoalescing.
+type CoalescingStats struct {
+	// SinglesFromFlights is the number of single-key loads served by a batch already loading the key.
+	SinglesFromFlights uint64
+	// BatchKeysFromFlights is the number of keys a batch left out because a single-key load or another
+	// batch was already loading them.
+	BatchKeysFromFlights uint64
+}
+
+// WithCrossPathCoalescing sets whether GetWithSingleFunc and GetManyWithSingleFunc share their loads of the
+// same key. It is enabled by default: a single-key load of a key that a batch is loading waits for the
+// batch instead of loading the key again, and a batch leaves out the keys already being loaded and waits
+// for them. Counts are reported by CoalescingStats. Loaders must then not read the keys they are loading
+// through the cache, which would wait for themselves.
+func WithCrossPathCoalescing(enabled bool) Option {
+	return func(o *OnceCache) {
+		if enabled {
+			o.flights = &flightRegistry{}
+		} else {
+			o.flights = nil
+		}
+	}
+}
+
+// CoalescingStats returns the counts of loads saved by cross-path coalescing.
+func (o *OnceCache) CoalescingStats() CoalescingStats {
+	if o.flights == nil {
+		return CoalescingStats{}
+	}
+	return CoalescingStats{
+		SinglesFromFlights:   o.flights.singles.Load(),
+		BatchKeysFromFlights: o.flights.batchKeys.Load(),
+	}
+}
+
+// keyFlight is the load of one key by either call path.
+type keyFlight struct {
+	done  chan struct{}
+	value any
+	ok    bool
+}
+
+// flightRegistry tracks the keys being loaded by either call path. A flight only waits for flights
+// registered before it, so waits never form a cycle.
+type flightRegistry struct {
+	mu      sync.Mutex
+	flights map[string]*keyFlight
+
+	singles   atomic.Uint64
+	batchKeys atomic.Uint64
+}
+
+// single runs load for key unless a flight of key is running, in which case its value is used.
+// If that flight fails, panics or leaves the key out, load runs after all.
+func (r *flightRegistry) single(key string, load func() (any, error)) (value any, err error) {
+	r.mu.Lock()
+	if fl, ok := r.flights[key]; ok {
+		r.mu.Unlock()
+		<-fl.done
+		if fl.ok {
+			r.singles.Add(1)
+			return fl.value, nil
+		}
+		return load()
+	}
+	fl := r.register(key)
+	r.mu.Unlock()
+	// Finish the flight even if load panics, so that later loads of key do not wait for it forever.
+	ok := false
+	defer func() {
+		r.finish(key, fl, value, ok)
+	}()
+	value, err = load()
+	ok = err == nil
+	return value, err
+}
+
+// batch runs load for the keys no flight is loading and waits for the others. Keys whose flight failed are
+// left out of the result.
+func (r *flightRegistry) batch(keys []string, load func(keys []string) (any, error)) (any, error) {
+	r.mu.Lock()
+	own := make(map[string]*keyFlight, len(keys))
+	var ownKeys []string
+	var joined map[string]*keyFlight
+	for _, key := range keys {
+		if fl, ok := r.flights[key]; ok {
+			if joined == nil {
+				joined = make(map[string]*keyFlight)
+			}
+			joined[key] = fl
+			continue
+		}
+		own[key] = r.register(key)
+		ownKeys = append(ownKeys, key)
+	}
+	r.mu.Unlock()
+
+	loaded, err := r.loadOwn(own, ownKeys, load)
+	if err != nil {
+		return nil, err
+	}
+	for key, fl := range joined {
+		<-fl.done
+		if fl.ok {
+			loaded[key] = fl.value
+			r.batchKeys.Add(1)
+		}
+	}
+	return loaded, nil
+}
+
+// loadOwn runs load for the keys of the flights own and finishes them, even if load panics.
+func (r *flightRegistry) loadOwn(own map[string]*keyFlight, keys []string, load func(keys []string) (any, error)) (loaded map[string]any, err error) {
+	loaded = map[string]any{}
+	defer func() {
+		for key, fl := range own {
+			value, ok := loaded[key]
+			if _, refused := value.(refusedLoad); refused {
+				ok = false
+			}
+			r.finish(key, fl, value, ok)
+		}
+	}()
+	if len(keys) == 0 {
+		return loaded, nil
+	}
+	v, err := load(keys)
+	if err == nil {
+		loaded = v.(map[string]any)
+	}
+	return loaded, err
+}
+
+// register adds a flight for key. r.mu must be held.
+func (r *flightRegistry) register(key string) *keyFlight {
+	if r.flights == nil {
+		r.flights = make(map[string]*keyFlight)
+	}
+	fl := &keyFlight{done: make(chan struct{})}
+	r.flights[key] = fl
+	return fl
+}
+
+func (r *flightRegistry) finish(key string, fl *keyFlight, value any, ok bool) {
+	fl.value, fl.ok = value, ok
+	r.mu.Lock()
+	if r.flights[key] == fl {
+		delete(r.flights, key)
+	}
+	r.mu.Unlock()
+	close(fl.done)
+}
+
+// forget keeps new loads of key from waiting for the running flight, see OnceCache.Forget.
+func (r *flightRegistry) forget(key string) {
+	r.mu.Lock()
+	delete(r.flights, key)
+	r.mu.Unlock()
+}
@@ -0,0 +1,65 @@
+package once_cache
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// returnsWithin fails the test if f does not return within a second.
+func returnsWithin(t *testing.T, what string, f func()) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f()
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("%s blocked", what)
+	}
+}
+
+// recovered runs f and reports whether it panicked.
+func recovered(f func()) (panicked bool) {
+	defer func() {
+		panicked = recover() != nil
+	}()
+	f()
+	return false
+}
+
+func TestCoalescingSurvivesPanickingLoaders(t *testing.T) {
+	boom := func() (any, error) { panic("boom") }
+	batchBoom := func([]string) (map[string]any, error) { panic("boom") }
+	ok := func() (any, error) { return "v", nil }
+	batchOK := func(keys []string) (map[string]any, error) {
+		values := map[string]any{}
+		for _, key := range keys {
+			values[key] = "v"
+		}
+		return values, nil
+	}
+
+	for name, panicking := range map[string]func(c *OnceCache){
+		"single": func(c *OnceCache) { c.GetWithSingleFunc("k", boom, time.Minute, nil) },
+		"batch":  func(c *OnceCache) { c.GetManyWithSingleFunc([]string{"k", "other"}, batchBoom, time.Minute, nil) },
+	} {
+		c := NewOnceCache(&singleflight.Group{}, NewMemoryCache())
+		if !recovered(func() { panicking(c) }) {
+			t.Fatalf("%s: the loader's panic did not reach the caller", name)
+		}
+		returnsWithin(t, name+": single load after a panic", func() {
+			if v, found := c.GetWithSingleFunc("k", ok, time.Minute, nil); !found || v != "v" {
+				t.Errorf("%s: GetWithSingleFunc = %v, %v, want v, true", name, v, found)
+			}
+		})
+		returnsWithin(t, name+": batch load after a panic", func() {
+			if values := c.GetManyWithSingleFunc([]string{"other"}, batchOK, time.Minute, nil); values["other"] != "v" {
+				t.Errorf("%s: GetManyWithSingleFunc = %v, want other: v", name, values)
+			}
+		})
+	}
+}
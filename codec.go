@@ -0,0 +1,58 @@
+package once_cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+)
+
+// Codec serializes cache values for stores that keep bytes, such as persistent or remote backends.
+type Codec interface {
+	// Marshal encodes a value
+	Marshal(value any) ([]byte, error)
+
+	// Unmarshal decodes a value produced by Marshal
+	Unmarshal(data []byte) (any, error)
+}
+
+// GobCodec is a Codec using encoding/gob. Concrete types stored in the cache must be registered
+// with gob.Register, except for the basic types gob registers itself. Nil values are supported.
+type GobCodec struct{}
+
+// Marshal encodes the value with gob.
+func (GobCodec) Marshal(value any) ([]byte, error) {
+	// gob cannot encode nil, so encode the sentinel OnceCache stores for it instead.
+	value = wrapNil(value)
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decodes a gob encoded value.
+func (GobCodec) Unmarshal(data []byte) (any, error) {
+	var value any
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&value); err != nil {
+		return nil, err
+	}
+	return unwrapNil(value), nil
+}
+
+// JSONCodec is a Codec using encoding/json. Values are decoded into the generic JSON types
+// (map[string]any, []any, float64, string, bool and nil).
+type JSONCodec struct{}
+
+// Marshal encodes the value as JSON.
+func (JSONCodec) Marshal(value any) ([]byte, error) {
+	return json.Marshal(unwrapNil(value))
+}
+
+// Unmarshal decodes a JSON encoded value.
+func (JSONCodec) Unmarshal(data []byte) (any, error) {
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
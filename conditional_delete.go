@@ -0,0 +1,61 @@
+package once_cache
+
+import (
+	"errors"
+	"reflect"
+	"time"
+)
+
+// ErrConditionalDeleteUnsupported is returned by DeleteIfEquals when the store does not implement
+// IConditionalDeleter.
+var ErrConditionalDeleteUnsupported = errors.New("once_cache: store does not support DeleteIfEquals")
+
+// WithEquality sets how DeleteIfEquals compares values. It defaults to reflect.DeepEqual.
+func WithEquality(equal func(stored, expected any) bool) MemoryOption {
+	return func(c *MemoryCache) {
+		c.equal = equal
+	}
+}
+
+// DeleteIfEquals removes the key only if its value equals expected, as compared by WithEquality, so that
+// an invalidation racing with a writer does not delete the newer value the writer just stored.
+// Expired entries never match. It reports whether the key was removed.
+func (c *MemoryCache) DeleteIfEquals(key string, expected any) bool {
+	equal := c.equal
+	if equal == nil {
+		equal = reflect.DeepEqual
+	}
+	now := time.Now().UnixNano()
+	return c.storage.deleteIf(key, func(e memoryEntry) bool {
+		if e.expired(now) {
+			return false
+		}
+		value, ok := c.decode(e)
+		// Cached nils are compared as nil, whether the codec kept the sentinel of OnceCache or not.
+		return ok && equal(unwrapNil(value), unwrapNil(expected))
+	})
+}
+
+// DeleteIfVersion removes the key only if its entry's version, as reported by GetWithInfo, is version.
+// It reports whether the key was removed.
+func (c *MemoryCache) DeleteIfVersion(key string, version uint64) bool {
+	return c.storage.deleteIf(key, func(e memoryEntry) bool {
+		return e.version == version
+	})
+}
+
+// DeleteIfEquals removes the key only if its value equals expected, see MemoryCache.DeleteIfEquals, and
+// then the entries depending on it. It reports whether the key was removed. Stores that do not implement
+// IConditionalDeleter remove nothing and return ErrConditionalDeleteUnsupported.
+func (o *OnceCache) DeleteIfEquals(key string, expected any) (bool, error) {
+	if o.condDeleter == nil {
+		return false, ErrConditionalDeleteUnsupported
+	}
+	if !o.condDeleter.DeleteIfEquals(key, expected) {
+		return false, nil
+	}
+	o.decide(DecisionDelete, key, "equal", nil, 0)
+	o.audit(AuditDelete, key, "", "", 0)
+	o.deleteDependents(key)
+	return true, nil
+}
@@ -0,0 +1,341 @@
+package once_cache
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Duration is a time.Duration that is written in configuration as a string such as "5m" or "1h30m".
+type Duration time.Duration
+
+// UnmarshalText parses a duration string. It also makes Duration work with YAML decoders.
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// MarshalText formats the duration as a string.
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// Config describes a set of named caches, so cache topology can live in deployment configuration.
+// It is written in JSON or YAML, see ParseConfig.
+type Config struct {
+	Caches map[string]CacheConfig `json:"caches" yaml:"caches"`
+}
+
+// CacheConfig describes one named OnceCache.
+type CacheConfig struct {
+	Store               StoreConfig `json:"store" yaml:"store"`
+	DefaultTTL          Duration    `json:"default_ttl" yaml:"default_ttl"`
+	PrefetchConcurrency int         `json:"prefetch_concurrency" yaml:"prefetch_concurrency"`
+	// Label names the cache in its events, see WithLabel. It defaults to the name of the cache.
+	Label   string        `json:"label" yaml:"label"`
+	Metrics MetricsConfig `json:"metrics" yaml:"metrics"`
+}
+
+// MetricsConfig describes the metrics a cache records.
+type MetricsConfig struct {
+	// Flights enables WithFlightMetrics, with FlightBounds as histogram bounds if given.
+	Flights      bool       `json:"flights" yaml:"flights"`
+	FlightBounds []Duration `json:"flight_bounds" yaml:"flight_bounds"`
+	// KeyStats enables WithKeyStats, tracking at most KeyStatsMaxKeys keys if positive.
+	KeyStats        bool `json:"key_stats" yaml:"key_stats"`
+	KeyStatsMaxKeys int  `json:"key_stats_max_keys" yaml:"key_stats_max_keys"`
+}
+
+// StoreConfig describes the store behind a cache.
+type StoreConfig struct {
+	// Type is "memory" (the default), "file" or "tiered".
+	Type string `json:"type" yaml:"type"`
+	// Codec is "gob" (the default) or "json". It is used by file stores and by memory stores with ByteValues.
+	Codec string `json:"codec" yaml:"codec"`
+
+	// Memory store settings.
+	Shards         int      `json:"shards" yaml:"shards"`
+	SyncMap        bool     `json:"sync_map" yaml:"sync_map"`
+	MaxEntries     int      `json:"max_entries" yaml:"max_entries"`
+	ByteValues     bool     `json:"byte_values" yaml:"byte_values"`
+	StaleRetention Duration `json:"stale_retention" yaml:"stale_retention"`
+	// Expiry is "lazy" (the default), "periodic" or "sampled".
+	Expiry         string   `json:"expiry" yaml:"expiry"`
+	ExpiryInterval Duration `json:"expiry_interval" yaml:"expiry_interval"`
+	ExpirySample   int      `json:"expiry_sample" yaml:"expiry_sample"`
+
+	// File store settings.
+	Dir string `json:"dir" yaml:"dir"`
+
+	// Tiered store settings, see TieredCache. L1 and L2 are required.
+	L1 *StoreConfig `json:"l1" yaml:"l1"`
+	L2 *StoreConfig `json:"l2" yaml:"l2"`
+	// Promotion is "always" (the default), "on_hit", promoting on the PromotionHits-th L2 hit, or "never".
+	Promotion      string   `json:"promotion" yaml:"promotion"`
+	PromotionHits  int      `json:"promotion_hits" yaml:"promotion_hits"`
+	PromotionTTL   Duration `json:"promotion_ttl" yaml:"promotion_ttl"`
+	AsyncPromotion bool     `json:"async_promotion" yaml:"async_promotion"`
+	L1TTL          Duration `json:"l1_ttl" yaml:"l1_ttl"`
+	L2TTL          Duration `json:"l2_ttl" yaml:"l2_ttl"`
+	// L1WritePolicy and L2WritePolicy are "through" (the default) or "back".
+	L1WritePolicy string  `json:"l1_write_policy" yaml:"l1_write_policy"`
+	L2WritePolicy string  `json:"l2_write_policy" yaml:"l2_write_policy"`
+	ReadRepair    float64 `json:"read_repair" yaml:"read_repair"`
+}
+
+// ParseConfig decodes a configuration document. A document starting with "{" is decoded as JSON, any other
+// as YAML, of which block mappings, block and flow sequences of scalars, quoted scalars and comments are
+// supported; anchors, multi-line scalars and multiple documents are not.
+func ParseConfig(data []byte) (Config, error) {
+	var cfg Config
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		err := json.Unmarshal(trimmed, &cfg)
+		return cfg, err
+	}
+	err := unmarshalYAML(data, &cfg)
+	return cfg, err
+}
+
+// Caches is a set of named caches built from a Config.
+type Caches struct {
+	caches  map[string]*OnceCache
+	closers []func()
+}
+
+// Get returns the cache with the specified name.
+func (c *Caches) Get(name string) (*OnceCache, bool) {
+	cache, ok := c.caches[name]
+	return cache, ok
+}
+
+// Names returns the names of all caches, sorted.
+func (c *Caches) Names() []string {
+	names := make([]string, 0, len(c.caches))
+	for name := range c.caches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Close stops the background work of all stores.
+func (c *Caches) Close() {
+	for _, closer := range c.closers {
+		closer()
+	}
+}
+
+// NewFromConfig builds the caches described by cfg, each with its own singleflight.Group.
+func NewFromConfig(cfg Config) (*Caches, error) {
+	caches := &Caches{caches: make(map[string]*OnceCache, len(cfg.Caches))}
+	for _, name := range sortedKeys(cfg.Caches) {
+		cacheCfg := cfg.Caches[name]
+		cache, closer, err := newCacheFromConfig(name, cacheCfg)
+		if err != nil {
+			caches.Close()
+			return nil, fmt.Errorf("once_cache: cache %q: %w", name, err)
+		}
+		caches.caches[name] = cache
+		if closer != nil {
+			caches.closers = append(caches.closers, closer)
+		}
+	}
+	return caches, nil
+}
+
+func newCacheFromConfig(name string, cfg CacheConfig) (*OnceCache, func(), error) {
+	label := cfg.Label
+	if label == "" {
+		label = name
+	}
+	opts := []Option{WithLabel(label)}
+	if cfg.DefaultTTL != 0 {
+		opts = append(opts, WithDefaultTTL(time.Duration(cfg.DefaultTTL)))
+	}
+	if cfg.PrefetchConcurrency > 0 {
+		opts = append(opts, WithPrefetchConcurrency(cfg.PrefetchConcurrency))
+	}
+	if cfg.Metrics.Flights {
+		bounds := make([]time.Duration, len(cfg.Metrics.FlightBounds))
+		for i, b := range cfg.Metrics.FlightBounds {
+			bounds[i] = time.Duration(b)
+		}
+		opts = append(opts, WithFlightMetrics(bounds...))
+	}
+	if cfg.Metrics.KeyStats {
+		opts = append(opts, WithKeyStats(cfg.Metrics.KeyStatsMaxKeys))
+	}
+	store, closer, err := storeFromConfig(cfg.Store)
+	if err != nil {
+		return nil, nil, err
+	}
+	// OnceCache keeps using the errors of file stores, which storeFromConfig wraps with NewCacheIgnoringErrors.
+	return NewOnceCache(&singleflight.Group{}, store, opts...), closer, nil
+}
+
+// storeFromConfig builds a store and the function stopping its background work, if any.
+func storeFromConfig(cfg StoreConfig) (ICache, func(), error) {
+	codec, err := codecFromConfig(cfg.Codec)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch cfg.Type {
+	case "", "memory":
+		memOpts, err := memoryOptionsFromConfig(cfg, codec)
+		if err != nil {
+			return nil, nil, err
+		}
+		store := NewMemoryCache(memOpts...)
+		return store, store.Close, nil
+	case "file":
+		if cfg.Dir == "" {
+			return nil, nil, fmt.Errorf("file store requires dir")
+		}
+		store, err := NewFileCache(cfg.Dir, codec)
+		if err != nil {
+			return nil, nil, err
+		}
+		return NewCacheIgnoringErrors(store), nil, nil
+	case "tiered":
+		return tieredStoreFromConfig(cfg)
+	}
+	return nil, nil, fmt.Errorf("unknown store type %q", cfg.Type)
+}
+
+func tieredStoreFromConfig(cfg StoreConfig) (ICache, func(), error) {
+	if cfg.L1 == nil || cfg.L2 == nil {
+		return nil, nil, fmt.Errorf("tiered store requires l1 and l2")
+	}
+	opts, err := tieredOptionsFromConfig(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	l1, closeL1, err := storeFromConfig(*cfg.L1)
+	if err != nil {
+		return nil, nil, fmt.Errorf("l1: %w", err)
+	}
+	l2, closeL2, err := storeFromConfig(*cfg.L2)
+	if err != nil {
+		if closeL1 != nil {
+			closeL1()
+		}
+		return nil, nil, fmt.Errorf("l2: %w", err)
+	}
+	store := NewTieredCache(l1, l2, opts...)
+	return store, func() {
+		store.Close()
+		for _, closer := range []func(){closeL1, closeL2} {
+			if closer != nil {
+				closer()
+			}
+		}
+	}, nil
+}
+
+func tieredOptionsFromConfig(cfg StoreConfig) ([]TieredOption, error) {
+	var opts []TieredOption
+	switch cfg.Promotion {
+	case "", "always":
+	case "on_hit":
+		opts = append(opts, WithPromotion(PromoteOnHit(cfg.PromotionHits)))
+	case "never":
+		opts = append(opts, WithPromotion(PromoteNever()))
+	default:
+		return nil, fmt.Errorf("unknown promotion policy %q", cfg.Promotion)
+	}
+	if cfg.PromotionTTL > 0 {
+		opts = append(opts, WithPromotionTTL(time.Duration(cfg.PromotionTTL)))
+	}
+	if cfg.AsyncPromotion {
+		opts = append(opts, WithAsyncPromotion())
+	}
+	if cfg.L1TTL != 0 {
+		opts = append(opts, WithL1TTL(time.Duration(cfg.L1TTL)))
+	}
+	if cfg.L2TTL != 0 {
+		opts = append(opts, WithL2TTL(time.Duration(cfg.L2TTL)))
+	}
+	l1Policy, err := writePolicyFromConfig(cfg.L1WritePolicy)
+	if err != nil {
+		return nil, err
+	}
+	l2Policy, err := writePolicyFromConfig(cfg.L2WritePolicy)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, WithL1WritePolicy(l1Policy), WithL2WritePolicy(l2Policy))
+	if cfg.ReadRepair > 0 {
+		opts = append(opts, WithReadRepair(cfg.ReadRepair))
+	}
+	return opts, nil
+}
+
+func writePolicyFromConfig(name string) (WritePolicy, error) {
+	switch name {
+	case "", "through":
+		return WriteThrough, nil
+	case "back":
+		return WriteBack, nil
+	}
+	return 0, fmt.Errorf("unknown write policy %q", name)
+}
+
+func memoryOptionsFromConfig(cfg StoreConfig, codec Codec) ([]MemoryOption, error) {
+	var opts []MemoryOption
+	if cfg.Shards > 0 {
+		opts = append(opts, WithShards(cfg.Shards))
+	}
+	if cfg.SyncMap {
+		opts = append(opts, WithSyncMap())
+	}
+	if cfg.MaxEntries > 0 {
+		opts = append(opts, WithMaxEntries(cfg.MaxEntries))
+	}
+	if cfg.ByteValues {
+		opts = append(opts, WithByteValues(codec))
+	}
+	if cfg.StaleRetention > 0 {
+		opts = append(opts, WithStaleRetention(time.Duration(cfg.StaleRetention)))
+	}
+	if (cfg.Expiry == "periodic" || cfg.Expiry == "sampled") && cfg.ExpiryInterval <= 0 {
+		// The strategies fall back to lazy expiry without an interval, which the config did not ask for.
+		return nil, fmt.Errorf("%s expiry requires expiry_interval", cfg.Expiry)
+	}
+	switch cfg.Expiry {
+	case "", "lazy":
+	case "periodic":
+		opts = append(opts, WithExpiryStrategy(PeriodicExpiry(time.Duration(cfg.ExpiryInterval))))
+	case "sampled":
+		opts = append(opts, WithExpiryStrategy(SampledExpiry(time.Duration(cfg.ExpiryInterval), cfg.ExpirySample)))
+	default:
+		return nil, fmt.Errorf("unknown expiry strategy %q", cfg.Expiry)
+	}
+	return opts, nil
+}
+
+func codecFromConfig(name string) (Codec, error) {
+	switch name {
+	case "", "gob":
+		return GobCodec{}, nil
+	case "json":
+		return JSONCodec{}, nil
+	}
+	return nil, fmt.Errorf("unknown codec %q", name)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
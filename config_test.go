@@ -0,0 +1,284 @@
+package once_cache
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testConfigYAML = `
+# Caches of the service.
+caches:
+  users:
+    label: user-cache
+    default_ttl: 5m
+    prefetch_concurrency: 8
+    metrics:
+      flights: true
+      flight_bounds: [10ms, "1s"]
+      key_stats: true
+      key_stats_max_keys: 100
+    store:
+      type: tiered
+      promotion: on_hit
+      promotion_hits: 3
+      promotion_ttl: 30s
+      async_promotion: true
+      l1_ttl: 10s
+      l2_ttl: 1h
+      l1_write_policy: through
+      l2_write_policy: back
+      read_repair: 0.25
+      l1:
+        shards: 4
+        max_entries: 1000
+        byte_values: true
+        codec: json
+        stale_retention: 1m
+        expiry: sampled
+        expiry_interval: 1s
+        expiry_sample: 50
+      l2:
+        type: file
+        codec: gob
+        dir: 'DIR'
+  flags:
+    store:
+      sync_map: true
+      expiry: periodic # swept in the background
+      expiry_interval: 2s
+    metrics:
+      flight_bounds:
+        - 1ms
+        - 5ms
+`
+
+const testConfigJSON = `{
+  "caches": {
+    "users": {
+      "label": "user-cache",
+      "default_ttl": "5m",
+      "prefetch_concurrency": 8,
+      "metrics": {"flights": true, "flight_bounds": ["10ms", "1s"], "key_stats": true, "key_stats_max_keys": 100},
+      "store": {
+        "type": "tiered",
+        "promotion": "on_hit",
+        "promotion_hits": 3,
+        "promotion_ttl": "30s",
+        "async_promotion": true,
+        "l1_ttl": "10s",
+        "l2_ttl": "1h",
+        "l1_write_policy": "through",
+        "l2_write_policy": "back",
+        "read_repair": 0.25,
+        "l1": {
+          "shards": 4,
+          "max_entries": 1000,
+          "byte_values": true,
+          "codec": "json",
+          "stale_retention": "1m",
+          "expiry": "sampled",
+          "expiry_interval": "1s",
+          "expiry_sample": 50
+        },
+        "l2": {"type": "file", "codec": "gob", "dir": "DIR"}
+      }
+    },
+    "flags": {
+      "store": {"sync_map": true, "expiry": "periodic", "expiry_interval": "2s"},
+      "metrics": {"flight_bounds": ["1ms", "5ms"]}
+    }
+  }
+}`
+
+func wantTestConfig(dir string) Config {
+	return Config{Caches: map[string]CacheConfig{
+		"users": {
+			Label:               "user-cache",
+			DefaultTTL:          Duration(5 * time.Minute),
+			PrefetchConcurrency: 8,
+			Metrics: MetricsConfig{
+				Flights:         true,
+				FlightBounds:    []Duration{Duration(10 * time.Millisecond), Duration(time.Second)},
+				KeyStats:        true,
+				KeyStatsMaxKeys: 100,
+			},
+			Store: StoreConfig{
+				Type:           "tiered",
+				Promotion:      "on_hit",
+				PromotionHits:  3,
+				PromotionTTL:   Duration(30 * time.Second),
+				AsyncPromotion: true,
+				L1TTL:          Duration(10 * time.Second),
+				L2TTL:          Duration(time.Hour),
+				L1WritePolicy:  "through",
+				L2WritePolicy:  "back",
+				ReadRepair:     0.25,
+				L1: &StoreConfig{
+					Shards:         4,
+					MaxEntries:     1000,
+					ByteValues:     true,
+					Codec:          "json",
+					StaleRetention: Duration(time.Minute),
+					Expiry:         "sampled",
+					ExpiryInterval: Duration(time.Second),
+					ExpirySample:   50,
+				},
+				L2: &StoreConfig{Type: "file", Codec: "gob", Dir: dir},
+			},
+		},
+		"flags": {
+			Store: StoreConfig{
+				SyncMap:        true,
+				Expiry:         "periodic",
+				ExpiryInterval: Duration(2 * time.Second),
+			},
+			Metrics: MetricsConfig{FlightBounds: []Duration{Duration(time.Millisecond), Duration(5 * time.Millisecond)}},
+		},
+	}}
+}
+
+func TestParseConfigDecodesEveryField(t *testing.T) {
+	dir := t.TempDir()
+	want := wantTestConfig(dir)
+	for name, doc := range map[string]string{"yaml": testConfigYAML, "json": testConfigJSON} {
+		cfg, err := ParseConfig([]byte(strings.ReplaceAll(doc, "DIR", dir)))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(cfg, want) {
+			t.Errorf("%s: ParseConfig =\n%+v\nwant\n%+v", name, cfg, want)
+		}
+	}
+}
+
+func TestParseConfigRejectsInvalidYAML(t *testing.T) {
+	for _, doc := range []string{
+		"caches:\n  a:\n    default_ttl: soon\n",
+		"caches:\n  a:\n    prefetch_concurrency: many\n",
+		"caches:\n  a:\n    store:\n      shards: 4\n     sync_map: true\n",
+		"caches:\n  a: 1\n  a: 2\n",
+		"caches:\n  - a\n",
+		"caches:\n  a:\n    metrics:\n      flight_bounds:\n        - a: 1\n",
+		"caches:\n  a: {b: 1}\n",
+		"caches:\n\ta: {}\n",
+		"caches: [1ms\n",
+		"caches\n",
+	} {
+		if _, err := ParseConfig([]byte(doc)); err == nil {
+			t.Errorf("ParseConfig(%q) succeeded, want an error", doc)
+		}
+	}
+}
+
+func TestNewFromConfigAppliesEveryField(t *testing.T) {
+	dir := t.TempDir()
+	caches, err := NewFromConfig(wantTestConfig(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer caches.Close()
+	if names := caches.Names(); !reflect.DeepEqual(names, []string{"flags", "users"}) {
+		t.Fatalf("Names = %v", names)
+	}
+
+	users, _ := caches.Get("users")
+	if users.label != "user-cache" || users.DefaultTTL() != 5*time.Minute || users.prefetchConcurrency != 8 {
+		t.Errorf("users: label %q, default TTL %v, prefetch concurrency %d", users.label, users.DefaultTTL(), users.prefetchConcurrency)
+	}
+	if users.flightMetrics == nil || !reflect.DeepEqual(users.flightMetrics.bounds, []time.Duration{10 * time.Millisecond, time.Second}) {
+		t.Errorf("users: flight metrics %+v", users.flightMetrics)
+	}
+	if users.keyStats == nil || users.keyStats.max != 100 {
+		t.Errorf("users: key stats %+v", users.keyStats)
+	}
+	tiered, ok := users.ICache.(*TieredCache)
+	if !ok {
+		t.Fatalf("users: store %T, want *TieredCache", users.ICache)
+	}
+	if tiered.promotion.hits != 3 || tiered.promotionTTL != 30*time.Second || tiered.promotions == nil {
+		t.Errorf("users: promotion %+v, TTL %v, async %v", tiered.promotion, tiered.promotionTTL, tiered.promotions != nil)
+	}
+	if tiered.l1TTL != 10*time.Second || tiered.l2TTL != time.Hour || tiered.readRepair != 0.25 {
+		t.Errorf("users: L1 TTL %v, L2 TTL %v, read repair %v", tiered.l1TTL, tiered.l2TTL, tiered.readRepair)
+	}
+	if tiered.l1Policy != WriteThrough || tiered.l2Policy != WriteBack {
+		t.Errorf("users: write policies %v, %v", tiered.l1Policy, tiered.l2Policy)
+	}
+	l1, ok := tiered.l1.(*MemoryCache)
+	if !ok {
+		t.Fatalf("users: L1 %T, want *MemoryCache", tiered.l1)
+	}
+	if l1.shards != 4 || l1.useSyncMap || l1.maxEntries.Load() != 1000 || l1.staleRetention != time.Minute {
+		t.Errorf("users: L1 shards %d, sync map %v, max entries %d, stale retention %v", l1.shards, l1.useSyncMap, l1.maxEntries.Load(), l1.staleRetention)
+	}
+	if _, ok := l1.codec.(JSONCodec); !ok {
+		t.Errorf("users: L1 codec %T, want JSONCodec", l1.codec)
+	}
+	if l1.expiry != SampledExpiry(time.Second, 50) {
+		t.Errorf("users: L1 expiry %+v", l1.expiry)
+	}
+	l2, ok := tiered.l2.(errorlessCache)
+	if !ok {
+		t.Fatalf("users: L2 %T, want a FileCache ignoring errors", tiered.l2)
+	}
+	if file := l2.ICacheWithError.(*FileCache); file.dir != dir {
+		t.Errorf("users: L2 dir %q, want %q", file.dir, dir)
+	}
+	if _, ok := l2.ICacheWithError.(*FileCache).codec.(GobCodec); !ok {
+		t.Errorf("users: L2 codec %T, want GobCodec", l2.ICacheWithError.(*FileCache).codec)
+	}
+
+	flags, _ := caches.Get("flags")
+	if flags.label != "flags" || flags.DefaultTTL() != 0 || flags.keyStats != nil {
+		t.Errorf("flags: label %q, default TTL %v, key stats %v", flags.label, flags.DefaultTTL(), flags.keyStats != nil)
+	}
+	// Bounds alone do not enable flight metrics.
+	if flags.flightMetrics != nil {
+		t.Error("flags: flight metrics enabled without flights")
+	}
+	memory := flags.ICache.(*MemoryCache)
+	if !memory.useSyncMap || memory.expiry != PeriodicExpiry(2*time.Second) || memory.codec != nil {
+		t.Errorf("flags: sync map %v, expiry %+v, codec %T", memory.useSyncMap, memory.expiry, memory.codec)
+	}
+
+	tiered.Set("k", "v", time.Minute)
+	tiered.Flush()
+	if v, ok, err := (&FileCache{dir: dir, codec: GobCodec{}}).Get("k"); err != nil || !ok || v != "v" {
+		t.Errorf("L2 Get = %v, %v, %v, want v, true, nil", v, ok, err)
+	}
+}
+
+func TestNewFromConfigRejectsInvalidStores(t *testing.T) {
+	for name, store := range map[string]StoreConfig{
+		"unknown type":                     {Type: "redis"},
+		"unknown codec":                    {Codec: "xml"},
+		"file without dir":                 {Type: "file"},
+		"unknown expiry":                   {Expiry: "eager"},
+		"periodic expiry without interval": {Expiry: "periodic"},
+		"sampled expiry without interval":  {Expiry: "sampled", ExpirySample: 20},
+		"tiered without l2":                {Type: "tiered", L1: &StoreConfig{}},
+		"unknown promotion":                {Type: "tiered", L1: &StoreConfig{}, L2: &StoreConfig{}, Promotion: "sometimes"},
+		"unknown write mode":               {Type: "tiered", L1: &StoreConfig{}, L2: &StoreConfig{}, L2WritePolicy: "around"},
+		"invalid level":                    {Type: "tiered", L1: &StoreConfig{}, L2: &StoreConfig{Type: "file"}},
+	} {
+		if _, err := NewFromConfig(Config{Caches: map[string]CacheConfig{"a": {Store: store}}}); err == nil {
+			t.Errorf("%s: NewFromConfig succeeded, want an error", name)
+		}
+	}
+}
+
+func TestFileStoreFromConfigKeepsErrors(t *testing.T) {
+	caches, err := NewFromConfig(Config{Caches: map[string]CacheConfig{
+		"a": {Store: StoreConfig{Type: "file", Dir: filepath.Join(t.TempDir(), "a")}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache, _ := caches.Get("a")
+	if _, ok := cache.store.(*FileCache); !ok {
+		t.Errorf("store %T, want *FileCache", cache.store)
+	}
+}
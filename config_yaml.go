@@ -0,0 +1,416 @@
+package once_cache
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// yamlKind is the kind of a yamlNode.
+type yamlKind int
+
+const (
+	yamlNull yamlKind = iota
+	yamlScalar
+	yamlMapping
+	yamlSequence
+)
+
+// yamlNode is a parsed YAML value. Mappings keep their keys in document order.
+type yamlNode struct {
+	kind   yamlKind
+	line   int
+	scalar string
+	keys   []string
+	values []*yamlNode
+	items  []*yamlNode
+}
+
+// yamlLine is a line of a YAML document without its indentation and comment.
+type yamlLine struct {
+	num    int
+	indent int
+	text   string
+}
+
+// unmarshalYAML decodes the subset of YAML described by ParseConfig into v, a pointer, matching mapping keys
+// with the yaml tags of struct fields. Like encoding/json, keys without a matching field are ignored.
+func unmarshalYAML(data []byte, v any) error {
+	lines, err := yamlLines(string(data))
+	if err != nil {
+		return err
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	node, next, err := parseYAMLBlock(lines, 0, lines[0].indent)
+	if err != nil {
+		return err
+	}
+	if next < len(lines) {
+		return fmt.Errorf("yaml: line %d: unexpected indentation", lines[next].num)
+	}
+	return decodeYAML(node, reflect.ValueOf(v).Elem())
+}
+
+// yamlLines splits a document into its non-empty lines, dropping comments and a leading document marker.
+func yamlLines(doc string) ([]yamlLine, error) {
+	var lines []yamlLine
+	for i, raw := range strings.Split(doc, "\n") {
+		text := strings.TrimRight(stripYAMLComment(strings.TrimSuffix(raw, "\r")), " \t")
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, fmt.Errorf("yaml: line %d: tabs cannot indent", i+1)
+		}
+		if trimmed == "---" && len(lines) == 0 {
+			continue
+		}
+		lines = append(lines, yamlLine{num: i + 1, indent: len(text) - len(trimmed), text: trimmed})
+	}
+	return lines, nil
+}
+
+// stripYAMLComment removes a comment, which starts with a # outside quotes at the start of the line or
+// after whitespace.
+func stripYAMLComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// parseYAMLBlock parses the mapping or sequence whose lines start at lines[i] with the indentation indent,
+// and returns it with the index of the line following it.
+func parseYAMLBlock(lines []yamlLine, i, indent int) (*yamlNode, int, error) {
+	if isYAMLItem(lines[i].text) {
+		return parseYAMLSequence(lines, i, indent)
+	}
+	return parseYAMLMapping(lines, i, indent)
+}
+
+func isYAMLItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+func parseYAMLMapping(lines []yamlLine, i, indent int) (*yamlNode, int, error) {
+	node := &yamlNode{kind: yamlMapping, line: lines[i].num}
+	for i < len(lines) && lines[i].indent == indent {
+		line := lines[i]
+		if isYAMLItem(line.text) {
+			return nil, 0, fmt.Errorf("yaml: line %d: sequence item in a mapping", line.num)
+		}
+		key, value, err := splitYAMLEntry(line)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, k := range node.keys {
+			if k == key {
+				return nil, 0, fmt.Errorf("yaml: line %d: duplicate key %q", line.num, key)
+			}
+		}
+		i++
+		var child *yamlNode
+		switch {
+		case value != "":
+			child, err = parseYAMLValue(value, line.num)
+			if err != nil {
+				return nil, 0, err
+			}
+		case i < len(lines) && (lines[i].indent > indent || lines[i].indent == indent && isYAMLItem(lines[i].text)):
+			// A block value, or a sequence written at the indentation of its key.
+			child, i, err = parseYAMLBlock(lines, i, lines[i].indent)
+			if err != nil {
+				return nil, 0, err
+			}
+		default:
+			child = &yamlNode{kind: yamlNull, line: line.num}
+		}
+		node.keys = append(node.keys, key)
+		node.values = append(node.values, child)
+	}
+	if i < len(lines) && lines[i].indent > indent {
+		return nil, 0, fmt.Errorf("yaml: line %d: unexpected indentation", lines[i].num)
+	}
+	return node, i, nil
+}
+
+func parseYAMLSequence(lines []yamlLine, i, indent int) (*yamlNode, int, error) {
+	node := &yamlNode{kind: yamlSequence, line: lines[i].num}
+	for i < len(lines) && lines[i].indent == indent && isYAMLItem(lines[i].text) {
+		line := lines[i]
+		value := strings.TrimSpace(strings.TrimPrefix(line.text, "-"))
+		i++
+		if value == "" {
+			if i < len(lines) && lines[i].indent > indent {
+				return nil, 0, fmt.Errorf("yaml: line %d: only scalars are supported in sequences", line.num)
+			}
+			node.items = append(node.items, &yamlNode{kind: yamlNull, line: line.num})
+			continue
+		}
+		if _, _, err := splitYAMLEntry(yamlLine{num: line.num, text: value}); err == nil {
+			return nil, 0, fmt.Errorf("yaml: line %d: only scalars are supported in sequences", line.num)
+		}
+		item, err := parseYAMLValue(value, line.num)
+		if err != nil {
+			return nil, 0, err
+		}
+		if item.kind != yamlScalar && item.kind != yamlNull {
+			return nil, 0, fmt.Errorf("yaml: line %d: only scalars are supported in sequences", line.num)
+		}
+		node.items = append(node.items, item)
+	}
+	if i < len(lines) && lines[i].indent > indent {
+		return nil, 0, fmt.Errorf("yaml: line %d: unexpected indentation", lines[i].num)
+	}
+	return node, i, nil
+}
+
+// splitYAMLEntry splits a mapping entry into its key and its value, empty for block values.
+func splitYAMLEntry(line yamlLine) (string, string, error) {
+	var quote byte
+	for i := 0; i < len(line.text); i++ {
+		c := line.text[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && i == 0:
+			quote = c
+		case c == ':' && (i == len(line.text)-1 || line.text[i+1] == ' '):
+			key, err := parseYAMLScalar(strings.TrimSpace(line.text[:i]), line.num)
+			if err != nil {
+				return "", "", err
+			}
+			return key.scalar, strings.TrimSpace(line.text[i+1:]), nil
+		}
+	}
+	return "", "", fmt.Errorf("yaml: line %d: expected a key followed by a colon", line.num)
+}
+
+// parseYAMLValue parses the value following a key or a sequence dash: a scalar, a flow sequence of scalars
+// or an empty flow mapping.
+func parseYAMLValue(value string, num int) (*yamlNode, error) {
+	switch {
+	case value == "{}":
+		return &yamlNode{kind: yamlMapping, line: num}, nil
+	case strings.HasPrefix(value, "{"):
+		return nil, fmt.Errorf("yaml: line %d: flow mappings are not supported", num)
+	case strings.HasPrefix(value, "["):
+		if !strings.HasSuffix(value, "]") {
+			return nil, fmt.Errorf("yaml: line %d: unterminated flow sequence", num)
+		}
+		node := &yamlNode{kind: yamlSequence, line: num}
+		inner := strings.TrimSpace(value[1 : len(value)-1])
+		if inner == "" {
+			return node, nil
+		}
+		for _, part := range splitYAMLFlow(inner) {
+			item, err := parseYAMLScalar(strings.TrimSpace(part), num)
+			if err != nil {
+				return nil, err
+			}
+			node.items = append(node.items, item)
+		}
+		return node, nil
+	}
+	return parseYAMLScalar(value, num)
+}
+
+// splitYAMLFlow splits the items of a flow sequence on the commas outside quotes.
+func splitYAMLFlow(s string) []string {
+	var parts []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ',':
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func parseYAMLScalar(s string, num int) (*yamlNode, error) {
+	switch {
+	case len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"':
+		v, err := strconv.Unquote(s)
+		if err != nil {
+			return nil, fmt.Errorf("yaml: line %d: invalid quoted scalar %s", num, s)
+		}
+		return &yamlNode{kind: yamlScalar, line: num, scalar: v}, nil
+	case len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'':
+		return &yamlNode{kind: yamlScalar, line: num, scalar: strings.ReplaceAll(s[1:len(s)-1], "''", "'")}, nil
+	case s == "" || s == "~" || s == "null" || s == "Null" || s == "NULL":
+		return &yamlNode{kind: yamlNull, line: num}, nil
+	case strings.ContainsAny(s[:1], "\"'&*!|>%@`"):
+		return nil, fmt.Errorf("yaml: line %d: unsupported scalar %s", num, s)
+	}
+	return &yamlNode{kind: yamlScalar, line: num, scalar: s}, nil
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// decodeYAML stores node in v. Null nodes leave v unchanged.
+func decodeYAML(node *yamlNode, v reflect.Value) error {
+	if node.kind == yamlNull {
+		return nil
+	}
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return decodeYAML(node, v.Elem())
+	}
+	if reflect.PointerTo(v.Type()).Implements(textUnmarshalerType) {
+		if node.kind != yamlScalar {
+			return yamlTypeError(node, v)
+		}
+		if err := v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(node.scalar)); err != nil {
+			return fmt.Errorf("yaml: line %d: %w", node.line, err)
+		}
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		if node.kind != yamlMapping {
+			return yamlTypeError(node, v)
+		}
+		fields := yamlFields(v.Type())
+		for i, key := range node.keys {
+			if index, ok := fields[key]; ok {
+				if err := decodeYAML(node.values[i], v.Field(index)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	case reflect.Map:
+		if node.kind != yamlMapping || v.Type().Key().Kind() != reflect.String {
+			return yamlTypeError(node, v)
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMapWithSize(v.Type(), len(node.keys)))
+		}
+		for i, key := range node.keys {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := decodeYAML(node.values[i], elem); err != nil {
+				return err
+			}
+			v.SetMapIndex(reflect.ValueOf(key).Convert(v.Type().Key()), elem)
+		}
+		return nil
+	case reflect.Slice:
+		if node.kind != yamlSequence {
+			return yamlTypeError(node, v)
+		}
+		slice := reflect.MakeSlice(v.Type(), len(node.items), len(node.items))
+		for i, item := range node.items {
+			if err := decodeYAML(item, slice.Index(i)); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+		return nil
+	}
+	if node.kind != yamlScalar {
+		return yamlTypeError(node, v)
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(node.scalar)
+		return nil
+	case reflect.Bool:
+		b, err := strconv.ParseBool(node.scalar)
+		if err != nil {
+			return yamlTypeError(node, v)
+		}
+		v.SetBool(b)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(node.scalar, 0, 64)
+		if err != nil || v.OverflowInt(n) {
+			return yamlTypeError(node, v)
+		}
+		v.SetInt(n)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(node.scalar, 64)
+		if err != nil {
+			return yamlTypeError(node, v)
+		}
+		v.SetFloat(f)
+		return nil
+	}
+	return fmt.Errorf("yaml: line %d: cannot decode into %s", node.line, v.Type())
+}
+
+// yamlFields maps the yaml tag names of the exported fields of a struct type to their index.
+func yamlFields(t reflect.Type) map[string]int {
+	fields := make(map[string]int, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = f.Name
+		}
+		fields[name] = i
+	}
+	return fields
+}
+
+func yamlTypeError(node *yamlNode, v reflect.Value) error {
+	if node.kind == yamlScalar {
+		return fmt.Errorf("yaml: line %d: cannot decode %q into %s", node.line, node.scalar, v.Type())
+	}
+	return fmt.Errorf("yaml: line %d: cannot decode a %s into %s", node.line, node.kind, v.Type())
+}
+
+// String returns the name of the kind, for error messages.
+func (k yamlKind) String() string {
+	switch k {
+	case yamlScalar:
+		return "scalar"
+	case yamlMapping:
+		return "mapping"
+	case yamlSequence:
+		return "sequence"
+	}
+	return "null"
+}
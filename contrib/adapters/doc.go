@@ -0,0 +1,5 @@
+// Package adapters implements once_cache.ICache over popular in-process cache libraries, so that their users
+// can put OnceCache in front of the cache they already run. Each adapter takes the subset of the library's
+// API it uses as an interface matching the library's own method set, so the library's cache is passed as is
+// and this package does not depend on any of the libraries.
+package adapters
@@ -0,0 +1,48 @@
+package adapters
+
+import (
+	"time"
+
+	once_cache "github.com/phongthien99/once-cache"
+)
+
+// goCacheNoExpiration is go-cache's NoExpiration.
+const goCacheNoExpiration time.Duration = -1
+
+// GoCacheClient is the subset of github.com/patrickmn/go-cache's *cache.Cache used by GoCache.
+type GoCacheClient interface {
+	Set(k string, x interface{}, d time.Duration)
+	Get(k string) (interface{}, bool)
+	Delete(k string)
+}
+
+// GoCache is a struct that implements the ICache interface over a github.com/patrickmn/go-cache cache.
+type GoCache struct {
+	client GoCacheClient
+}
+
+var _ once_cache.ICache = (*GoCache)(nil)
+
+// Set stores the value with the specified time to live. A non-positive duration never expires, rather than
+// using the go-cache default expiration.
+func (c *GoCache) Set(key string, value any, d time.Duration) {
+	if d <= 0 {
+		d = goCacheNoExpiration
+	}
+	c.client.Set(key, value, d)
+}
+
+// Get retrieves the value for the key.
+func (c *GoCache) Get(key string) (any, bool) {
+	return c.client.Get(key)
+}
+
+// Delete removes the key.
+func (c *GoCache) Delete(key string) {
+	c.client.Delete(key)
+}
+
+// NewGoCache creates a new instance of GoCache over client, such as cache.New(5*time.Minute, 10*time.Minute).
+func NewGoCache(client GoCacheClient) *GoCache {
+	return &GoCache{client: client}
+}
@@ -0,0 +1,63 @@
+package adapters
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeGoCache is an in-memory GoCacheClient recording the expiration of each Set.
+type fakeGoCache struct {
+	values map[string]interface{}
+	ttls   map[string]time.Duration
+}
+
+func newFakeGoCache() *fakeGoCache {
+	return &fakeGoCache{values: map[string]interface{}{}, ttls: map[string]time.Duration{}}
+}
+
+func (f *fakeGoCache) Set(k string, x interface{}, d time.Duration) {
+	f.values[k] = x
+	f.ttls[k] = d
+}
+
+func (f *fakeGoCache) Get(k string) (interface{}, bool) {
+	v, ok := f.values[k]
+	return v, ok
+}
+
+func (f *fakeGoCache) Delete(k string) {
+	delete(f.values, k)
+	delete(f.ttls, k)
+}
+
+func TestGoCacheMapsMisses(t *testing.T) {
+	c := NewGoCache(newFakeGoCache())
+	if v, ok := c.Get("k"); ok || v != nil {
+		t.Fatalf("Get of a missing key = %v, %v, want nil, false", v, ok)
+	}
+	c.Set("k", "v", time.Minute)
+	if v, ok := c.Get("k"); !ok || v != "v" {
+		t.Fatalf("Get = %v, %v, want v, true", v, ok)
+	}
+	c.Delete("k")
+	if _, ok := c.Get("k"); ok {
+		t.Fatal("Get after Delete hit")
+	}
+}
+
+func TestGoCachePassesTTL(t *testing.T) {
+	client := newFakeGoCache()
+	c := NewGoCache(client)
+	for _, tc := range []struct {
+		d, want time.Duration
+	}{
+		{time.Minute, time.Minute},
+		{0, goCacheNoExpiration},
+		{-time.Second, goCacheNoExpiration},
+	} {
+		c.Set("k", "v", tc.d)
+		if got := client.ttls["k"]; got != tc.want {
+			t.Errorf("Set with %v passed %v, want %v", tc.d, got, tc.want)
+		}
+	}
+}
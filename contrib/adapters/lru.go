@@ -0,0 +1,94 @@
+package adapters
+
+import (
+	"time"
+
+	once_cache "github.com/phongthien99/once-cache"
+)
+
+// LRUClient is the subset of github.com/hashicorp/golang-lru/v2's *lru.Cache[string, any] used by LRU.
+type LRUClient interface {
+	Add(key string, value any) (evicted bool)
+	Get(key string) (value any, ok bool)
+	Remove(key string) (present bool)
+}
+
+// TwoQueueClient is the subset of the 2Q and ARC caches of github.com/hashicorp/golang-lru/v2, such as
+// *lru.TwoQueueCache[string, any] and *arc.ARCCache[string, any], used by LRU. Unlike *lru.Cache, their Add
+// and Remove return nothing.
+type TwoQueueClient interface {
+	Add(key string, value any)
+	Get(key string) (value any, ok bool)
+	Remove(key string)
+}
+
+// twoQueueClient adapts a TwoQueueClient to LRUClient.
+type twoQueueClient struct {
+	TwoQueueClient
+}
+
+func (c twoQueueClient) Add(key string, value any) bool {
+	c.TwoQueueClient.Add(key, value)
+	return false
+}
+
+func (c twoQueueClient) Remove(key string) bool {
+	c.TwoQueueClient.Remove(key)
+	return false
+}
+
+// lruEntry is a value stored in an LRU with its expiry, in Unix nanoseconds, zero if it never expires.
+type lruEntry struct {
+	value     any
+	expiresAt int64
+}
+
+// LRU is a struct that implements the ICache interface over a github.com/hashicorp/golang-lru/v2 cache.
+// golang-lru bounds the number of entries but has no per-entry expiry, so LRU stores each value with its
+// expiry and treats expired entries as misses, left for the LRU policy to evict.
+type LRU struct {
+	client LRUClient
+}
+
+var _ once_cache.ICache = (*LRU)(nil)
+
+// Set stores the value with the specified time to live. A non-positive duration never expires.
+func (c *LRU) Set(key string, value any, d time.Duration) {
+	e := lruEntry{value: value}
+	if d > 0 {
+		e.expiresAt = time.Now().Add(d).UnixNano()
+	}
+	c.client.Add(key, e)
+}
+
+// Get retrieves the value for the key if it exists and has not expired.
+func (c *LRU) Get(key string) (any, bool) {
+	v, ok := c.client.Get(key)
+	if !ok {
+		return nil, false
+	}
+	e, ok := v.(lruEntry)
+	if !ok {
+		// Written to the cache directly rather than through LRU.
+		return v, true
+	}
+	if e.expiresAt != 0 && time.Now().UnixNano() >= e.expiresAt {
+		return nil, false
+	}
+	return e.value, true
+}
+
+// Delete removes the key.
+func (c *LRU) Delete(key string) {
+	c.client.Remove(key)
+}
+
+// NewLRU creates a new instance of LRU over client, such as lru.New[string, any](size).
+func NewLRU(client LRUClient) *LRU {
+	return &LRU{client: client}
+}
+
+// NewTwoQueueLRU creates a new instance of LRU over a 2Q or ARC cache, such as lru.New2Q[string, any](size).
+func NewTwoQueueLRU(client TwoQueueClient) *LRU {
+	return &LRU{client: twoQueueClient{client}}
+}
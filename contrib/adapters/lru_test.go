@@ -0,0 +1,107 @@
+package adapters
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeLRU is an in-memory LRUClient without eviction.
+type fakeLRU struct {
+	values map[string]any
+}
+
+func newFakeLRU() *fakeLRU {
+	return &fakeLRU{values: map[string]any{}}
+}
+
+func (f *fakeLRU) Add(key string, value any) bool {
+	f.values[key] = value
+	return false
+}
+
+func (f *fakeLRU) Get(key string) (any, bool) {
+	v, ok := f.values[key]
+	return v, ok
+}
+
+func (f *fakeLRU) Remove(key string) bool {
+	_, ok := f.values[key]
+	delete(f.values, key)
+	return ok
+}
+
+func TestLRUMapsMisses(t *testing.T) {
+	client := newFakeLRU()
+	c := NewLRU(client)
+	if v, ok := c.Get("k"); ok || v != nil {
+		t.Fatalf("Get of a missing key = %v, %v, want nil, false", v, ok)
+	}
+	c.Set("k", "v", time.Minute)
+	if v, ok := c.Get("k"); !ok || v != "v" {
+		t.Fatalf("Get = %v, %v, want v, true", v, ok)
+	}
+	client.values["expired"] = lruEntry{value: "v", expiresAt: time.Now().Add(-time.Second).UnixNano()}
+	if v, ok := c.Get("expired"); ok || v != nil {
+		t.Fatalf("Get of an expired entry = %v, %v, want nil, false", v, ok)
+	}
+	client.values["raw"] = "v"
+	if v, ok := c.Get("raw"); !ok || v != "v" {
+		t.Fatalf("Get of a value added directly = %v, %v, want v, true", v, ok)
+	}
+	c.Delete("k")
+	if _, ok := c.Get("k"); ok {
+		t.Fatal("Get after Delete hit")
+	}
+}
+
+func TestLRUPassesTTL(t *testing.T) {
+	client := newFakeLRU()
+	c := NewLRU(client)
+	before := time.Now()
+	c.Set("k", "v", time.Minute)
+	e := client.values["k"].(lruEntry)
+	if lo, hi := before.Add(time.Minute).UnixNano(), time.Now().Add(time.Minute).UnixNano(); e.expiresAt < lo || e.expiresAt > hi {
+		t.Fatalf("Set with a minute stored expiry %v, want between %v and %v", e.expiresAt, lo, hi)
+	}
+	for _, d := range []time.Duration{0, -time.Second} {
+		c.Set("k", "v", d)
+		if e := client.values["k"].(lruEntry); e.expiresAt != 0 {
+			t.Errorf("Set with %v stored expiry %v, want none", d, e.expiresAt)
+		}
+	}
+}
+
+// fakeTwoQueue is an in-memory TwoQueueClient without eviction.
+type fakeTwoQueue struct {
+	values map[string]any
+}
+
+func (f *fakeTwoQueue) Add(key string, value any) {
+	f.values[key] = value
+}
+
+func (f *fakeTwoQueue) Get(key string) (any, bool) {
+	v, ok := f.values[key]
+	return v, ok
+}
+
+func (f *fakeTwoQueue) Remove(key string) {
+	delete(f.values, key)
+}
+
+func TestTwoQueueLRU(t *testing.T) {
+	client := &fakeTwoQueue{values: map[string]any{}}
+	c := NewTwoQueueLRU(client)
+	c.Set("k", "v", time.Minute)
+	if v, ok := c.Get("k"); !ok || v != "v" {
+		t.Fatalf("Get = %v, %v, want v, true", v, ok)
+	}
+	client.values["expired"] = lruEntry{value: "v", expiresAt: time.Now().Add(-time.Second).UnixNano()}
+	if v, ok := c.Get("expired"); ok || v != nil {
+		t.Fatalf("Get of an expired entry = %v, %v, want nil, false", v, ok)
+	}
+	c.Delete("k")
+	if _, ok := client.values["k"]; ok {
+		t.Fatal("Delete left the key in the cache")
+	}
+}
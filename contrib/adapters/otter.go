@@ -0,0 +1,76 @@
+package adapters
+
+import (
+	"time"
+
+	once_cache "github.com/phongthien99/once-cache"
+)
+
+// OtterClient is the subset of github.com/maypok86/otter's otter.CacheWithVariableTTL[string, any] used by
+// Otter, built with otter.MustBuilder[string, any](capacity).WithVariableTTL().Build().
+type OtterClient interface {
+	Set(key string, value any, ttl time.Duration) bool
+	Get(key string) (any, bool)
+	Delete(key string)
+}
+
+// OtterNoTTLClient is the subset of otter.Cache[string, any], built without a TTL or with a fixed one,
+// used by Otter.
+type OtterNoTTLClient interface {
+	Set(key string, value any) bool
+	Get(key string) (any, bool)
+	Delete(key string)
+}
+
+// Otter is a struct that implements the ICache interface over a github.com/maypok86/otter cache.
+// Otter rejects values it has no room for, so a Set may not be stored; OnceCache then loads the key again.
+type Otter struct {
+	client OtterClient
+	noTTL  OtterNoTTLClient
+}
+
+var _ once_cache.ICache = (*Otter)(nil)
+
+// otterMaxTTL stands for no expiration, which otter's variable TTL caches do not have.
+const otterMaxTTL = 100 * 365 * 24 * time.Hour
+
+// Set stores the value with the specified time to live. A non-positive duration never expires.
+// Caches built without variable TTL ignore d and apply their own expiration, if any.
+func (c *Otter) Set(key string, value any, d time.Duration) {
+	if c.noTTL != nil {
+		c.noTTL.Set(key, value)
+		return
+	}
+	if d <= 0 {
+		d = otterMaxTTL
+	}
+	c.client.Set(key, value, d)
+}
+
+// Get retrieves the value for the key.
+func (c *Otter) Get(key string) (any, bool) {
+	if c.noTTL != nil {
+		return c.noTTL.Get(key)
+	}
+	return c.client.Get(key)
+}
+
+// Delete removes the key.
+func (c *Otter) Delete(key string) {
+	if c.noTTL != nil {
+		c.noTTL.Delete(key)
+		return
+	}
+	c.client.Delete(key)
+}
+
+// NewOtter creates a new instance of Otter over an otter cache built with variable TTL.
+func NewOtter(client OtterClient) *Otter {
+	return &Otter{client: client}
+}
+
+// NewOtterWithoutTTL creates a new instance of Otter over an otter cache built without variable TTL,
+// whose entries expire as configured when it was built rather than with the TTL of each Set.
+func NewOtterWithoutTTL(client OtterNoTTLClient) *Otter {
+	return &Otter{noTTL: client}
+}
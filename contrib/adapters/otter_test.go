@@ -0,0 +1,89 @@
+package adapters
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeOtter is an in-memory OtterClient and OtterNoTTLClient that, like otter, rejects values once full.
+type fakeOtter struct {
+	capacity int
+	values   map[string]any
+	ttls     map[string]time.Duration
+}
+
+func newFakeOtter(capacity int) *fakeOtter {
+	return &fakeOtter{capacity: capacity, values: map[string]any{}, ttls: map[string]time.Duration{}}
+}
+
+func (f *fakeOtter) set(key string, value any, ttl time.Duration) bool {
+	if _, ok := f.values[key]; !ok && len(f.values) >= f.capacity {
+		return false
+	}
+	f.values[key] = value
+	f.ttls[key] = ttl
+	return true
+}
+
+func (f *fakeOtter) Get(key string) (any, bool) {
+	v, ok := f.values[key]
+	return v, ok
+}
+
+func (f *fakeOtter) Delete(key string) {
+	delete(f.values, key)
+	delete(f.ttls, key)
+}
+
+type fakeVariableTTLOtter struct{ *fakeOtter }
+
+func (f fakeVariableTTLOtter) Set(key string, value any, ttl time.Duration) bool {
+	return f.set(key, value, ttl)
+}
+
+type fakeFixedTTLOtter struct{ *fakeOtter }
+
+func (f fakeFixedTTLOtter) Set(key string, value any) bool {
+	return f.set(key, value, 0)
+}
+
+func TestOtterMapsMisses(t *testing.T) {
+	for name, c := range map[string]*Otter{
+		"variable TTL": NewOtter(fakeVariableTTLOtter{newFakeOtter(1)}),
+		"without TTL":  NewOtterWithoutTTL(fakeFixedTTLOtter{newFakeOtter(1)}),
+	} {
+		if v, ok := c.Get("k"); ok || v != nil {
+			t.Fatalf("%s: Get of a missing key = %v, %v, want nil, false", name, v, ok)
+		}
+		c.Set("k", "v", time.Minute)
+		if v, ok := c.Get("k"); !ok || v != "v" {
+			t.Fatalf("%s: Get = %v, %v, want v, true", name, v, ok)
+		}
+		// The cache is full, so the value is rejected and reads miss.
+		c.Set("other", "v", time.Minute)
+		if _, ok := c.Get("other"); ok {
+			t.Fatalf("%s: Get of a rejected value hit", name)
+		}
+		c.Delete("k")
+		if _, ok := c.Get("k"); ok {
+			t.Fatalf("%s: Get after Delete hit", name)
+		}
+	}
+}
+
+func TestOtterPassesTTL(t *testing.T) {
+	client := newFakeOtter(1)
+	c := NewOtter(fakeVariableTTLOtter{client})
+	for _, tc := range []struct {
+		d, want time.Duration
+	}{
+		{time.Minute, time.Minute},
+		{0, otterMaxTTL},
+		{-time.Second, otterMaxTTL},
+	} {
+		c.Set("k", "v", tc.d)
+		if got := client.ttls["k"]; got != tc.want {
+			t.Errorf("Set with %v passed %v, want %v", tc.d, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package adapters
+
+import (
+	"time"
+
+	once_cache "github.com/phongthien99/once-cache"
+)
+
+// ttlcacheNoTTL is ttlcache's NoTTL.
+const ttlcacheNoTTL time.Duration = -1
+
+// TTLCacheItem is the subset of github.com/jellydator/ttlcache/v3's *ttlcache.Item[string, any] used by TTLCache.
+type TTLCacheItem interface {
+	comparable
+	Value() any
+}
+
+// TTLCacheClient is the subset of github.com/jellydator/ttlcache/v3's *ttlcache.Cache[string, any] used by
+// TTLCache. I is *ttlcache.Item[string, any] and O is ttlcache.Option[string, any].
+type TTLCacheClient[I TTLCacheItem, O any] interface {
+	Set(key string, value any, ttl time.Duration) I
+	Get(key string, opts ...O) I
+	Delete(key string)
+}
+
+// TTLCache is a struct that implements the ICache interface over a github.com/jellydator/ttlcache/v3 cache.
+type TTLCache[I TTLCacheItem, O any] struct {
+	client TTLCacheClient[I, O]
+}
+
+// Set stores the value with the specified time to live. A non-positive duration never expires, rather than
+// using the ttlcache default TTL.
+func (c *TTLCache[I, O]) Set(key string, value any, d time.Duration) {
+	if d <= 0 {
+		d = ttlcacheNoTTL
+	}
+	c.client.Set(key, value, d)
+}
+
+// Get retrieves the value for the key.
+func (c *TTLCache[I, O]) Get(key string) (any, bool) {
+	var missing I
+	item := c.client.Get(key)
+	if item == missing {
+		return nil, false
+	}
+	return item.Value(), true
+}
+
+// Delete removes the key.
+func (c *TTLCache[I, O]) Delete(key string) {
+	c.client.Delete(key)
+}
+
+// NewTTLCache creates a new instance of TTLCache over client. The type parameters cannot be inferred, so
+// they are spelled out:
+//
+//	adapters.NewTTLCache[*ttlcache.Item[string, any], ttlcache.Option[string, any]](ttlcache.New[string, any]())
+func NewTTLCache[I TTLCacheItem, O any](client TTLCacheClient[I, O]) once_cache.ICache {
+	return &TTLCache[I, O]{client: client}
+}
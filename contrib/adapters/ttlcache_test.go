@@ -0,0 +1,79 @@
+package adapters
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeTTLItem stands for *ttlcache.Item[string, any].
+type fakeTTLItem struct {
+	value any
+	ttl   time.Duration
+}
+
+func (i *fakeTTLItem) Value() any {
+	return i.value
+}
+
+// fakeTTLOption stands for ttlcache.Option[string, any].
+type fakeTTLOption struct{}
+
+// fakeTTLCache is an in-memory TTLCacheClient that, like ttlcache, returns a nil item for missing keys.
+type fakeTTLCache struct {
+	items map[string]*fakeTTLItem
+}
+
+func newFakeTTLCache() *fakeTTLCache {
+	return &fakeTTLCache{items: map[string]*fakeTTLItem{}}
+}
+
+func (f *fakeTTLCache) Set(key string, value any, ttl time.Duration) *fakeTTLItem {
+	item := &fakeTTLItem{value: value, ttl: ttl}
+	f.items[key] = item
+	return item
+}
+
+func (f *fakeTTLCache) Get(key string, opts ...fakeTTLOption) *fakeTTLItem {
+	return f.items[key]
+}
+
+func (f *fakeTTLCache) Delete(key string) {
+	delete(f.items, key)
+}
+
+func TestTTLCacheMapsMisses(t *testing.T) {
+	c := NewTTLCache[*fakeTTLItem, fakeTTLOption](newFakeTTLCache())
+	if v, ok := c.Get("k"); ok || v != nil {
+		t.Fatalf("Get of a missing key = %v, %v, want nil, false", v, ok)
+	}
+	// A stored nil is a hit, not a miss.
+	c.Set("k", nil, time.Minute)
+	if v, ok := c.Get("k"); !ok || v != nil {
+		t.Fatalf("Get of a stored nil = %v, %v, want nil, true", v, ok)
+	}
+	c.Set("k", "v", time.Minute)
+	if v, ok := c.Get("k"); !ok || v != "v" {
+		t.Fatalf("Get = %v, %v, want v, true", v, ok)
+	}
+	c.Delete("k")
+	if _, ok := c.Get("k"); ok {
+		t.Fatal("Get after Delete hit")
+	}
+}
+
+func TestTTLCachePassesTTL(t *testing.T) {
+	client := newFakeTTLCache()
+	c := NewTTLCache[*fakeTTLItem, fakeTTLOption](client)
+	for _, tc := range []struct {
+		d, want time.Duration
+	}{
+		{time.Minute, time.Minute},
+		{0, ttlcacheNoTTL},
+		{-time.Second, ttlcacheNoTTL},
+	} {
+		c.Set("k", "v", tc.d)
+		if got := client.items["k"].ttl; got != tc.want {
+			t.Errorf("Set with %v passed %v, want %v", tc.d, got, tc.want)
+		}
+	}
+}
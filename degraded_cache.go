@@ -0,0 +1,56 @@
+package once_cache
+
+import "time"
+
+// DegradedCache is a struct that implements the ICache interface on top of an ICacheWithError.
+// Store errors are treated as misses and dropped writes, so that when the store is down
+// OnceCache still runs the loader through singleflight and serves results without caching them.
+type DegradedCache struct {
+	store   ICacheWithError
+	breaker *Breaker
+}
+
+// Set stores the value unless the store is unavailable, in which case the write is dropped.
+func (c *DegradedCache) Set(key string, value any, d time.Duration) {
+	if !c.breaker.Allow() {
+		return
+	}
+	c.record(c.store.Set(key, value, d))
+}
+
+// Get retrieves the value from the store, reporting a miss if the store is unavailable.
+func (c *DegradedCache) Get(key string) (any, bool) {
+	if !c.breaker.Allow() {
+		return nil, false
+	}
+	value, ok, err := c.store.Get(key)
+	c.record(err)
+	if err != nil {
+		return nil, false
+	}
+	return value, ok
+}
+
+// Delete removes the value from the store unless the store is unavailable.
+func (c *DegradedCache) Delete(key string) {
+	if !c.breaker.Allow() {
+		return
+	}
+	c.record(c.store.Delete(key))
+}
+
+func (c *DegradedCache) record(err error) {
+	if err != nil {
+		c.breaker.Failure()
+		return
+	}
+	c.breaker.Success()
+}
+
+// NewDegradedCache creates a new instance of DegradedCache guarding the store with the specified Breaker.
+func NewDegradedCache(store ICacheWithError, breaker *Breaker) ICache {
+	return &DegradedCache{
+		store:   store,
+		breaker: breaker,
+	}
+}
@@ -0,0 +1,72 @@
+package once_cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// flakyStore is an ICacheWithError over a MemoryCache failing every call while down is set.
+type flakyStore struct {
+	*MemoryCache
+	down  bool
+	calls int
+}
+
+var errStoreDown = errors.New("store down")
+
+func (s *flakyStore) Set(key string, value any, d time.Duration) error {
+	s.calls++
+	if s.down {
+		return errStoreDown
+	}
+	s.MemoryCache.Set(key, value, d)
+	return nil
+}
+
+func (s *flakyStore) Get(key string) (any, bool, error) {
+	s.calls++
+	if s.down {
+		return nil, false, errStoreDown
+	}
+	v, ok := s.MemoryCache.Get(key)
+	return v, ok, nil
+}
+
+func (s *flakyStore) Delete(key string) error {
+	s.calls++
+	if s.down {
+		return errStoreDown
+	}
+	s.MemoryCache.Delete(key)
+	return nil
+}
+
+func TestDegradedCacheTreatsErrorsAsMissesAndStopsCallingADownStore(t *testing.T) {
+	store := &flakyStore{MemoryCache: NewMemoryCache()}
+	c := NewDegradedCache(store, NewBreaker(2, 20*time.Millisecond))
+	c.Set("a", 1, time.Minute)
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Fatalf("Get = %v, %v, want 1, true", v, ok)
+	}
+
+	store.down = true
+	for i := 0; i < 2; i++ {
+		if v, ok := c.Get("a"); ok {
+			t.Fatalf("Get from a down store = %v, want a miss", v)
+		}
+	}
+	calls := store.calls
+	c.Set("a", 2, time.Minute)
+	c.Get("a")
+	c.Delete("a")
+	if store.calls != calls {
+		t.Fatalf("the open breaker let %d calls through to the store", store.calls-calls)
+	}
+
+	store.down = false
+	time.Sleep(30 * time.Millisecond)
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Fatalf("Get after recovery = %v, %v, want 1, true", v, ok)
+	}
+}
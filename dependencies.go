@@ -0,0 +1,115 @@
+package once_cache
+
+import (
+	"sync"
+	"time"
+)
+
+// dependencyGraph records which entries are built from which other entries.
+type dependencyGraph struct {
+	mu sync.Mutex
+	// deps maps a key to the keys it depends on.
+	deps map[string][]string
+	// dependents maps a key to the keys depending on it.
+	dependents map[string]map[string]struct{}
+}
+
+// WithDependsOn declares that the value loaded for the key is built from the entries deps,
+// see DependsOn. The dependencies are declared before the load runs.
+func WithDependsOn(deps ...string) CallOption {
+	return func(c *callConfig) {
+		c.dependsOn = deps
+	}
+}
+
+// DependsOn declares that the entry for key is built from the entries deps, replacing its previous
+// dependencies. Deleting a dependency, directly or through another dependency, also deletes the entry and
+// keeps loads of it that started before from storing their results, as Forget does. Declare dependencies
+// before loading the entry, so that a dependency deleted during the load is not missed.
+// Dependencies are forgotten when the entry is deleted, and are not followed by DeleteTag.
+func (o *OnceCache) DependsOn(key string, deps ...string) {
+	g := &o.dependencies
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.deps == nil {
+		g.deps = make(map[string][]string)
+		g.dependents = make(map[string]map[string]struct{})
+	}
+	g.unlink(key)
+	if len(deps) == 0 {
+		return
+	}
+	g.deps[key] = append([]string(nil), deps...)
+	for _, dep := range deps {
+		set, ok := g.dependents[dep]
+		if !ok {
+			set = make(map[string]struct{})
+			g.dependents[dep] = set
+		}
+		set[key] = struct{}{}
+	}
+	o.hasDependencies.Store(true)
+}
+
+// Delete removes the key and every entry depending on it, see DependsOn.
+func (o *OnceCache) Delete(key string) {
+	o.DeleteWithReason(key, "")
+}
+
+// deleteDependents deletes with a tombstone the entries depending on key, directly or transitively,
+// and forgets the dependencies of key and of the deleted entries.
+func (o *OnceCache) deleteDependents(key string) {
+	if !o.hasDependencies.Load() {
+		return
+	}
+	g := &o.dependencies
+	g.mu.Lock()
+	seen := map[string]struct{}{key: {}}
+	queue := []string{key}
+	for i := 0; i < len(queue); i++ {
+		for dependent := range g.dependents[queue[i]] {
+			if _, ok := seen[dependent]; !ok {
+				seen[dependent] = struct{}{}
+				queue = append(queue, dependent)
+			}
+		}
+	}
+	for _, k := range queue {
+		g.unlink(k)
+		delete(g.dependents, k)
+	}
+	g.mu.Unlock()
+	for _, dependent := range queue[1:] {
+		o.tombstone(dependent, forgetWindow)
+		o.ICache.Delete(dependent)
+		o.audit(AuditInvalidate, dependent, "", key, 0)
+	}
+}
+
+// unlink removes the dependencies of key. g.mu must be held.
+func (g *dependencyGraph) unlink(key string) {
+	for _, dep := range g.deps[key] {
+		if set, ok := g.dependents[dep]; ok {
+			delete(set, key)
+			if len(set) == 0 {
+				delete(g.dependents, dep)
+			}
+		}
+	}
+	delete(g.deps, key)
+}
+
+// tombstone prevents loads of key started before now from storing their results for window,
+// and keeps new callers from joining them.
+func (o *OnceCache) tombstone(key string, window time.Duration) {
+	o.decide(DecisionDelete, key, "", nil, 0)
+	t := &tombstone{deletedAt: time.Now()}
+	o.tombstones.Store(key, t)
+	time.AfterFunc(window, func() {
+		o.tombstones.CompareAndDelete(key, t)
+	})
+	o.group.Forget(key)
+	if o.flights != nil {
+		o.flights.forget(key)
+	}
+}
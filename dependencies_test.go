@@ -0,0 +1,43 @@
+package once_cache
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+func TestDeleteRemovesDependentsTransitively(t *testing.T) {
+	c := NewOnceCache(&singleflight.Group{}, NewMemoryCache())
+	c.Set("user", 1, time.Minute)
+	c.Set("profile", 2, time.Minute)
+	c.Set("page", 3, time.Minute)
+	c.Set("other", 4, time.Minute)
+	c.DependsOn("profile", "user")
+	c.DependsOn("page", "profile")
+
+	c.Delete("user")
+	for _, key := range []string{"user", "profile", "page"} {
+		if v, ok := c.Get(key); ok {
+			t.Errorf("Get(%s) = %v, want it deleted with its dependency", key, v)
+		}
+	}
+	if _, ok := c.Get("other"); !ok {
+		t.Error("Delete removed an entry without dependencies")
+	}
+}
+
+func TestDependsOnReplacesDependencies(t *testing.T) {
+	c := NewOnceCache(&singleflight.Group{}, NewMemoryCache())
+	c.Set("page", 1, time.Minute)
+	c.DependsOn("page", "a")
+	c.DependsOn("page", "b")
+	c.Delete("a")
+	if _, ok := c.Get("page"); !ok {
+		t.Fatal("deleting a replaced dependency removed the entry")
+	}
+	c.Delete("b")
+	if _, ok := c.Get("page"); ok {
+		t.Fatal("deleting the current dependency kept the entry")
+	}
+}
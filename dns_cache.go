@@ -0,0 +1,182 @@
+package once_cache
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+// DNSResolver resolves DNS records along with their time to live.
+type DNSResolver interface {
+	// LookupHost returns the addresses of host and the TTL of the records
+	LookupHost(ctx context.Context, host string) ([]string, time.Duration, error)
+
+	// LookupSRV returns the canonical name and SRV records of the service and the TTL of the records
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, time.Duration, error)
+}
+
+// netResolver adapts a net.Resolver, which does not expose record TTLs, to DNSResolver.
+type netResolver struct {
+	r   *net.Resolver
+	ttl time.Duration
+}
+
+// NewNetResolver creates a DNSResolver over r, or net.DefaultResolver if r is nil. Since the standard
+// resolver hides record TTLs, every record is given ttl.
+func NewNetResolver(r *net.Resolver, ttl time.Duration) DNSResolver {
+	if r == nil {
+		r = net.DefaultResolver
+	}
+	return netResolver{r: r, ttl: ttl}
+}
+
+func (n netResolver) LookupHost(ctx context.Context, host string) ([]string, time.Duration, error) {
+	addrs, err := n.r.LookupHost(ctx, host)
+	return addrs, n.ttl, err
+}
+
+func (n netResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, time.Duration, error) {
+	cname, srvs, err := n.r.LookupSRV(ctx, service, proto, name)
+	return cname, srvs, n.ttl, err
+}
+
+// DNSOption configures a DNSCache.
+type DNSOption func(*DNSCache)
+
+// WithDNSTTLBounds clamps record TTLs between min and max. They default to 5 seconds and an hour.
+func WithDNSTTLBounds(min, max time.Duration) DNSOption {
+	return func(c *DNSCache) {
+		c.minTTL, c.maxTTL = min, max
+	}
+}
+
+// WithDNSRefreshAhead refreshes records in the background once less than fraction of their TTL remains,
+// so that lookups of popular names never wait for the resolver. It defaults to 0.1; zero disables it.
+func WithDNSRefreshAhead(fraction float64) DNSOption {
+	return func(c *DNSCache) {
+		c.refreshAhead = fraction
+	}
+}
+
+// DNSCache caches DNS lookups in an IResultCache for the TTL of their records. Concurrent lookups of a name
+// share one query, records close to expiry are refreshed in the background, and an expired record is still
+// served if refreshing it fails.
+type DNSCache struct {
+	cache        IResultCache
+	resolver     DNSResolver
+	minTTL       time.Duration
+	maxTTL       time.Duration
+	refreshAhead float64
+	refreshing   sync.Map
+}
+
+// dnsRecord is a cached lookup result with its own expiry, which the store's does not follow.
+type dnsRecord struct {
+	value     any
+	ttl       time.Duration
+	expiresAt time.Time
+}
+
+type srvRecord struct {
+	cname string
+	srvs  []*net.SRV
+}
+
+// LookupHost returns the addresses of host. The returned slice is shared and must not be modified.
+func (c *DNSCache) LookupHost(ctx context.Context, host string) ([]string, error) {
+	v, err := c.lookup(ctx, Key("dns", "host", host), func(ctx context.Context) (any, time.Duration, error) {
+		addrs, ttl, err := c.resolver.LookupHost(ctx, host)
+		return addrs, ttl, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]string), nil
+}
+
+// LookupSRV returns the canonical name and SRV records of the service. The returned slice is shared
+// and must not be modified.
+func (c *DNSCache) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	v, err := c.lookup(ctx, Key("dns", "srv", service, proto, name), func(ctx context.Context) (any, time.Duration, error) {
+		cname, srvs, ttl, err := c.resolver.LookupSRV(ctx, service, proto, name)
+		return srvRecord{cname: cname, srvs: srvs}, ttl, err
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	r := v.(srvRecord)
+	return r.cname, r.srvs, nil
+}
+
+// lookup serves key from the cache, resolving it with query when it is missing or expired.
+func (c *DNSCache) lookup(ctx context.Context, key string, query func(ctx context.Context) (any, time.Duration, error)) (any, error) {
+	load := func() (any, error) {
+		// The query is shared by concurrent callers, so it must not end with the caller that started it.
+		v, ttl, err := query(context.WithoutCancel(ctx))
+		if err != nil {
+			return nil, err
+		}
+		ttl = min(max(ttl, c.minTTL), c.maxTTL)
+		return &dnsRecord{value: v, ttl: ttl, expiresAt: time.Now().Add(ttl)}, nil
+	}
+	// Records never outlive maxTTL, so the store may drop them after it.
+	res, err := c.wait(ctx, key, load)
+	if err != nil {
+		return nil, err
+	}
+	rec, ok := res.Value.(*dnsRecord)
+	if !ok {
+		return nil, res.Err
+	}
+	remaining := time.Until(rec.expiresAt)
+	if remaining <= 0 {
+		fresh, err := c.wait(ctx, key, load, WithForceRefresh())
+		if r, ok := fresh.Value.(*dnsRecord); ok && err == nil && fresh.Err == nil {
+			rec = r
+		}
+		// Otherwise serve the expired record rather than failing.
+	} else if c.refreshAhead > 0 && remaining < time.Duration(c.refreshAhead*float64(rec.ttl)) {
+		if _, loaded := c.refreshing.LoadOrStore(key, struct{}{}); !loaded {
+			go func() {
+				defer c.refreshing.Delete(key)
+				c.cache.GetResult(key, load, WithTTL(c.maxTTL), WithForceRefresh())
+			}()
+		}
+	}
+	return rec.value, nil
+}
+
+// wait reads key from the cache, giving up with the error of ctx when it is done first. The query keeps
+// running for the other callers sharing it and is stored when it completes.
+func (c *DNSCache) wait(ctx context.Context, key string, load SingleFunc, opts ...CallOption) (Result, error) {
+	opts = append(opts, WithTTL(c.maxTTL))
+	if ctx.Done() == nil {
+		return c.cache.GetResult(key, load, opts...), nil
+	}
+	done := make(chan Result, 1)
+	go func() {
+		done <- c.cache.GetResult(key, load, opts...)
+	}()
+	select {
+	case res := <-done:
+		return res, nil
+	case <-ctx.Done():
+		return Result{}, ctx.Err()
+	}
+}
+
+// NewDNSCache creates a new instance of DNSCache resolving names with resolver and caching them in cache.
+func NewDNSCache(cache IResultCache, resolver DNSResolver, opts ...DNSOption) *DNSCache {
+	c := &DNSCache{
+		cache:        cache,
+		resolver:     resolver,
+		minTTL:       5 * time.Second,
+		maxTTL:       time.Hour,
+		refreshAhead: 0.1,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
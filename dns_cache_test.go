@@ -0,0 +1,52 @@
+package once_cache
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// blockingResolver resolves hosts once release is closed, counting its queries.
+type blockingResolver struct {
+	release chan struct{}
+	queries atomic.Int32
+}
+
+func (r *blockingResolver) LookupHost(ctx context.Context, host string) ([]string, time.Duration, error) {
+	r.queries.Add(1)
+	<-r.release
+	return []string{"10.0.0.1"}, time.Minute, nil
+}
+
+func (r *blockingResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, time.Duration, error) {
+	return "", nil, 0, errors.New("not supported")
+}
+
+func TestDNSCacheLookupReturnsWhenTheContextIsDone(t *testing.T) {
+	resolver := &blockingResolver{release: make(chan struct{})}
+	cache := NewOnceCache(&singleflight.Group{}, NewMemoryCache())
+	c := NewDNSCache(cache, resolver)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	returnsWithin(t, "LookupHost past its deadline", func() {
+		if _, err := c.LookupHost(ctx, "example.com"); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("LookupHost = %v, want context.DeadlineExceeded", err)
+		}
+	})
+
+	// The shared query keeps running and later lookups get its result.
+	close(resolver.release)
+	addrs, err := c.LookupHost(context.Background(), "example.com")
+	if err != nil || len(addrs) != 1 || addrs[0] != "10.0.0.1" {
+		t.Fatalf("LookupHost = %v, %v, want [10.0.0.1]", addrs, err)
+	}
+	if n := resolver.queries.Load(); n != 1 {
+		t.Fatalf("resolver queried %d times, want 1", n)
+	}
+}
@@ -0,0 +1,182 @@
+package once_cache
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// maxDumpLine bounds the length of a line read by Restore.
+const maxDumpLine = 64 << 20
+
+// DumpRecord is one line of the dump format written by MemoryCache.Dump: a JSON object per entry, with the
+// fields below, and the value encoded by the dump's codec in "payload" as standard base64. The format is
+// stable, so dumps can move cache contents between environments and versions.
+//
+//	{"key":"user:1","created_at":"2024-05-01T10:00:00Z","expires_at":"2024-05-01T11:00:00Z","payload":"..."}
+type DumpRecord struct {
+	Key       string    `json:"key"`
+	CreatedAt time.Time `json:"created_at"`
+	// ExpiresAt is absent for entries that never expire.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Priority  Priority   `json:"priority,omitempty"`
+	Tags      []string   `json:"tags,omitempty"`
+	Payload   []byte     `json:"payload"`
+}
+
+// DumpOption configures Dump and Restore.
+type DumpOption func(*dumpConfig)
+
+type dumpConfig struct {
+	codec     Codec
+	filter    func(key string, info EntryInfo) bool
+	transform func(key string, value any) (any, bool)
+}
+
+// WithDumpCodec sets the codec of the payloads. It defaults to the codec of WithByteValues, or GobCodec.
+// Restore must use the codec the dump was written with.
+func WithDumpCodec(codec Codec) DumpOption {
+	return func(c *dumpConfig) {
+		c.codec = codec
+	}
+}
+
+// WithDumpFilter selects the entries dumped or restored.
+func WithDumpFilter(filter func(key string, info EntryInfo) bool) DumpOption {
+	return func(c *dumpConfig) {
+		c.filter = filter
+	}
+}
+
+// WithDumpTransform rewrites the values dumped or restored, such as to strip personal data before seeding
+// a staging environment with production-shaped data. Values for which it returns false are skipped.
+func WithDumpTransform(transform func(key string, value any) (any, bool)) DumpOption {
+	return func(c *dumpConfig) {
+		c.transform = transform
+	}
+}
+
+func (c *MemoryCache) dumpConfig(opts []DumpOption) dumpConfig {
+	cfg := dumpConfig{codec: c.codec}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.codec == nil {
+		cfg.codec = GobCodec{}
+	}
+	return cfg
+}
+
+// Dump writes the live entries to w as newline-delimited JSON, one DumpRecord per line, and returns how
+// many were written. Values the codec cannot encode are skipped.
+func (c *MemoryCache) Dump(w io.Writer, opts ...DumpOption) (int, error) {
+	cfg := c.dumpConfig(opts)
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	now := time.Now().UnixNano()
+	written := 0
+	var err error
+	c.storage.rangeEntries(func(key string, e memoryEntry) bool {
+		if e.expired(now) {
+			return true
+		}
+		info := e.info()
+		if cfg.filter != nil && !cfg.filter(key, info) {
+			return true
+		}
+		value, ok := c.decode(e)
+		if !ok {
+			return true
+		}
+		if cfg.transform != nil {
+			if value, ok = cfg.transform(key, value); !ok {
+				return true
+			}
+		}
+		payload, merr := cfg.codec.Marshal(value)
+		if merr != nil {
+			return true
+		}
+		rec := DumpRecord{Key: key, CreatedAt: info.CreatedAt, Priority: e.priority, Tags: e.tags, Payload: payload}
+		if !info.ExpiresAt.IsZero() {
+			rec.ExpiresAt = &info.ExpiresAt
+		}
+		if err = enc.Encode(rec); err != nil {
+			return false
+		}
+		written++
+		return true
+	})
+	if err != nil {
+		return written, err
+	}
+	return written, bw.Flush()
+}
+
+// Restore reads entries written by Dump from r and stores them with their remaining time to live, keeping
+// their creation time, priority and tags. Entries that have expired since are skipped. It returns how many
+// entries were stored, and stops at the first malformed line.
+func (c *MemoryCache) Restore(r io.Reader, opts ...DumpOption) (int, error) {
+	cfg := c.dumpConfig(opts)
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, maxDumpLine)
+	restored := 0
+	for sc.Scan() {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var rec DumpRecord
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			return restored, err
+		}
+		info := EntryInfo{CreatedAt: rec.CreatedAt, Priority: rec.Priority}
+		var d time.Duration = NoExpiration
+		if rec.ExpiresAt != nil {
+			info.ExpiresAt = *rec.ExpiresAt
+			if d = time.Until(*rec.ExpiresAt); d <= 0 {
+				continue
+			}
+		}
+		if cfg.filter != nil && !cfg.filter(rec.Key, info) {
+			continue
+		}
+		value, err := cfg.codec.Unmarshal(rec.Payload)
+		if err != nil {
+			return restored, err
+		}
+		if cfg.transform != nil {
+			var ok bool
+			if value, ok = cfg.transform(rec.Key, value); !ok {
+				continue
+			}
+		}
+		if c.restore(rec, value, d) {
+			restored++
+		}
+	}
+	return restored, sc.Err()
+}
+
+// restore stores a restored entry, reporting whether its value could be encoded.
+func (c *MemoryCache) restore(rec DumpRecord, value any, d time.Duration) bool {
+	c.indexValue(rec.Key, value)
+	if c.codec != nil {
+		data, err := c.codec.Marshal(value)
+		if err != nil {
+			return false
+		}
+		value = c.intern(data)
+	}
+	e := c.newEntry(value, d, rec.Priority)
+	if !rec.CreatedAt.IsZero() {
+		e.createdAt = rec.CreatedAt.UnixNano()
+	}
+	e.tags = rec.Tags
+	c.tagMu.Lock()
+	c.storage.store(rec.Key, e)
+	c.indexTags(rec.Key, e.tags)
+	c.tagMu.Unlock()
+	c.enforceMaxEntries()
+	return true
+}
@@ -0,0 +1,174 @@
+package once_cache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrConditionFailed is returned by a DynamoClient when the condition of a write does not hold.
+var ErrConditionFailed = errors.New("once_cache: conditional write failed")
+
+// DynamoItem is a cache entry as stored in a DynamoDB table.
+type DynamoItem struct {
+	// Key is the partition key.
+	Key string
+	// Value is the encoded value.
+	Value []byte
+	// ExpiresAt is the expiry in Unix seconds, zero if the item never expires. Enable DynamoDB TTL on the
+	// attribute holding it, so the table removes expired items itself.
+	ExpiresAt int64
+	// Version increases with every write of the item.
+	Version uint64
+}
+
+// DynamoCondition restricts a write to items in a known state.
+type DynamoCondition struct {
+	// Check enables the condition. Unchecked writes always succeed.
+	Check bool
+	// Version is the version the item must have, zero for an item that must not exist.
+	Version uint64
+}
+
+// DynamoClient is the subset of the DynamoDB API used by DynamoCache. It maps onto the AWS SDK as:
+// GetItem is a consistent GetItem of the partition key, PutItem is an UpdateItem that sets the value and
+// expiry and adds 1 to the version with ReturnValues UPDATED_NEW, and DeleteItem is DeleteItem.
+// A checked condition maps to attribute_not_exists on the key for version zero and to an equality on the
+// version attribute otherwise, ConditionalCheckFailedException being returned as ErrConditionFailed.
+type DynamoClient interface {
+	// GetItem retrieves the item stored under key
+	GetItem(ctx context.Context, key string) (DynamoItem, bool, error)
+
+	// PutItem writes the item if the condition holds and returns its new version
+	PutItem(ctx context.Context, item DynamoItem, condition DynamoCondition) (uint64, error)
+
+	// DeleteItem removes the item stored under key
+	DeleteItem(ctx context.Context, key string) error
+}
+
+// DynamoCache is a struct that implements the ICacheWithError interface on top of DynamoDB, for serverless
+// deployments sharing a cache across instances without running Redis. Expiry relies on the table's native
+// TTL, and since DynamoDB removes expired items lazily, items past their expiry are also reported as misses.
+// Conditional writes are exposed through SetIfAbsent and SetIfVersion.
+type DynamoCache struct {
+	client DynamoClient
+	prefix string
+	codec  Codec
+}
+
+// Set stores the value with the specified time to live, rounded up to whole seconds as DynamoDB TTL requires.
+// A non-positive duration never expires.
+func (c *DynamoCache) Set(key string, value any, d time.Duration) error {
+	data, err := marshalValue(c.codec, value, d)
+	if err != nil {
+		return err
+	}
+	return c.SetBytes(key, data, d)
+}
+
+// SetBytes stores the raw value, bypassing the codec.
+func (c *DynamoCache) SetBytes(key string, data []byte, d time.Duration) error {
+	_, err := c.putBytes(key, data, d, DynamoCondition{})
+	return err
+}
+
+// SetIfAbsent stores the value only if the key has no live item and reports whether it was stored.
+func (c *DynamoCache) SetIfAbsent(key string, value any, d time.Duration) (bool, error) {
+	ctx := context.Background()
+	item, ok, err := c.client.GetItem(ctx, c.prefix+key)
+	if err != nil {
+		return false, err
+	}
+	condition := DynamoCondition{Check: true}
+	if ok {
+		if !item.expired(time.Now()) {
+			return false, nil
+		}
+		// The table has not removed the expired item yet, replace it.
+		condition.Version = item.Version
+	}
+	_, err = c.put(key, value, d, condition)
+	if errors.Is(err, ErrConditionFailed) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// SetIfVersion stores the value only if the item's current version is version, zero for a missing item.
+// It returns the new version and whether the value was stored.
+func (c *DynamoCache) SetIfVersion(key string, value any, version uint64, d time.Duration) (uint64, bool, error) {
+	v, err := c.put(key, value, d, DynamoCondition{Check: true, Version: version})
+	if errors.Is(err, ErrConditionFailed) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return v, true, nil
+}
+
+func (c *DynamoCache) put(key string, value any, d time.Duration, condition DynamoCondition) (uint64, error) {
+	data, err := marshalValue(c.codec, value, d)
+	if err != nil {
+		return 0, err
+	}
+	return c.putBytes(key, data, d, condition)
+}
+
+func (c *DynamoCache) putBytes(key string, data []byte, d time.Duration, condition DynamoCondition) (uint64, error) {
+	item := DynamoItem{Key: c.prefix + key, Value: data}
+	if d > 0 {
+		item.ExpiresAt = time.Now().Add(d + time.Second - 1).Unix()
+	}
+	return c.client.PutItem(context.Background(), item, condition)
+}
+
+// Get retrieves the value for the key.
+func (c *DynamoCache) Get(key string) (any, bool, error) {
+	value, _, ok, err := c.GetWithVersion(key)
+	return value, ok, err
+}
+
+// GetWithVersion retrieves the value for the key along with its version, for use with SetIfVersion.
+func (c *DynamoCache) GetWithVersion(key string) (any, uint64, bool, error) {
+	item, ok, err := c.client.GetItem(context.Background(), c.prefix+key)
+	if err != nil || !ok || item.expired(time.Now()) {
+		return nil, 0, false, err
+	}
+	value, err := c.codec.Unmarshal(item.Value)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	return value, item.Version, true, nil
+}
+
+// GetBytes retrieves the raw value, bypassing the codec.
+func (c *DynamoCache) GetBytes(key string) ([]byte, bool, error) {
+	item, ok, err := c.client.GetItem(context.Background(), c.prefix+key)
+	if err != nil || !ok || item.expired(time.Now()) {
+		return nil, false, err
+	}
+	return item.Value, true, nil
+}
+
+// Delete removes the key.
+func (c *DynamoCache) Delete(key string) error {
+	return c.client.DeleteItem(context.Background(), c.prefix+key)
+}
+
+func (i DynamoItem) expired(now time.Time) bool {
+	return i.ExpiresAt != 0 && now.Unix() >= i.ExpiresAt
+}
+
+// NewDynamoCache creates a new instance of DynamoCache storing items under the specified key prefix.
+// Values are serialized with codec, or GobCodec wrapped in an EnvelopeCodec if codec is nil.
+func NewDynamoCache(client DynamoClient, prefix string, codec Codec) *DynamoCache {
+	if codec == nil {
+		codec = defaultCodec()
+	}
+	return &DynamoCache{
+		client: client,
+		prefix: prefix,
+		codec:  codec,
+	}
+}
@@ -0,0 +1,277 @@
+package once_cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// envelopeMagic starts every envelope. Gob encodes lengths below 128 in one byte, so no gob stream starts
+// with 0xff followed by 'O', and JSON never starts with 0xff.
+var envelopeMagic = []byte{0xff, 'O', 'C'}
+
+const (
+	// EnvelopeVersion is the schema version of the envelopes written by this package.
+	EnvelopeVersion = 1
+	// envelopeHeaderSize is the size of the magic, version, codec, compression, flags and timestamps.
+	envelopeHeaderSize = 3 + 4 + 16
+	// defaultCompressionThreshold is the payload size from which EnvelopeCodec compresses, if enabled.
+	defaultCompressionThreshold = 1024
+)
+
+var (
+	// ErrNotEnvelope is returned by ParseEnvelope for data that is not an envelope.
+	ErrNotEnvelope = errors.New("once_cache: not an envelope")
+	// ErrEnvelopeVersion is returned for envelopes written with a newer schema version than this package reads.
+	ErrEnvelopeVersion = errors.New("once_cache: unsupported envelope version")
+)
+
+// CodecID identifies the codec of an envelope payload. IDs below 128 are reserved for this package;
+// applications register their own codecs from 128 on with RegisterCodec.
+type CodecID uint8
+
+const (
+	// CodecUnknown marks payloads decoded with the codec the EnvelopeCodec was created with.
+	CodecUnknown CodecID = 0
+	// CodecGob is GobCodec.
+	CodecGob CodecID = 1
+	// CodecJSON is JSONCodec.
+	CodecJSON CodecID = 2
+)
+
+// CompressionID identifies the compression of an envelope payload.
+type CompressionID uint8
+
+const (
+	// CompressionNone stores the payload as encoded.
+	CompressionNone CompressionID = 0
+	// CompressionGzip compresses the payload with gzip.
+	CompressionGzip CompressionID = 1
+)
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = map[CodecID]Codec{CodecGob: GobCodec{}, CodecJSON: JSONCodec{}}
+)
+
+// RegisterCodec makes codec available under id for decoding envelopes, so that entries written with it
+// stay readable after an application switches to another codec.
+func RegisterCodec(id CodecID, codec Codec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	codecs[id] = codec
+}
+
+func registeredCodec(id CodecID) (Codec, bool) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	codec, ok := codecs[id]
+	return codec, ok
+}
+
+// Envelope is the stable serialized form of the entries of remote and persistent stores: a header naming
+// the schema version, codec and compression of the payload, along with the entry's timestamps, so that
+// entries written by one version of an application remain readable by later versions. The header is
+// the magic bytes 0xff 'O' 'C', then one byte each for the version, codec, compression and flags, then
+// the creation and expiry times as big-endian Unix nanoseconds, zero if the entry never expires.
+type Envelope struct {
+	Version     uint8
+	Codec       CodecID
+	Compression CompressionID
+	// Flags are reserved for future schema versions and preserved as read.
+	Flags     uint8
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	// Payload is the value as encoded by the codec and compressed.
+	Payload []byte
+}
+
+// MarshalBinary encodes the envelope.
+func (e Envelope) MarshalBinary() ([]byte, error) {
+	buf := make([]byte, envelopeHeaderSize, envelopeHeaderSize+len(e.Payload))
+	copy(buf, envelopeMagic)
+	buf[3], buf[4], buf[5], buf[6] = e.Version, byte(e.Codec), byte(e.Compression), e.Flags
+	binary.BigEndian.PutUint64(buf[7:], uint64(unixNanos(e.CreatedAt)))
+	binary.BigEndian.PutUint64(buf[15:], uint64(unixNanos(e.ExpiresAt)))
+	return append(buf, e.Payload...), nil
+}
+
+// ParseEnvelope decodes an envelope. Payload aliases data.
+func ParseEnvelope(data []byte) (Envelope, error) {
+	if len(data) < envelopeHeaderSize || !bytes.HasPrefix(data, envelopeMagic) {
+		return Envelope{}, ErrNotEnvelope
+	}
+	e := Envelope{
+		Version:     data[3],
+		Codec:       CodecID(data[4]),
+		Compression: CompressionID(data[5]),
+		Flags:       data[6],
+		CreatedAt:   fromUnixNanos(int64(binary.BigEndian.Uint64(data[7:]))),
+		ExpiresAt:   fromUnixNanos(int64(binary.BigEndian.Uint64(data[15:]))),
+		Payload:     data[envelopeHeaderSize:],
+	}
+	if e.Version == 0 || e.Version > EnvelopeVersion {
+		return Envelope{}, fmt.Errorf("%w: %d", ErrEnvelopeVersion, e.Version)
+	}
+	return e, nil
+}
+
+// EnvelopeOption configures an EnvelopeCodec.
+type EnvelopeOption func(*EnvelopeCodec)
+
+// WithEnvelopeCodecID sets the ID recorded for the wrapped codec, for codecs registered with RegisterCodec.
+// GobCodec and JSONCodec are recognized without it.
+func WithEnvelopeCodecID(id CodecID) EnvelopeOption {
+	return func(c *EnvelopeCodec) {
+		c.id = id
+	}
+}
+
+// WithEnvelopeCompression compresses payloads of at least threshold bytes, 1024 if threshold is not positive.
+func WithEnvelopeCompression(compression CompressionID, threshold int) EnvelopeOption {
+	return func(c *EnvelopeCodec) {
+		c.compression = compression
+		c.threshold = threshold
+		if c.threshold <= 0 {
+			c.threshold = defaultCompressionThreshold
+		}
+	}
+}
+
+// EnvelopeCodec is a struct that implements the Codec interface by wrapping the values encoded by another
+// codec in an Envelope. It decodes envelopes written with any registered codec and compression, and data
+// written without an envelope with the wrapped codec, so existing entries stay readable when it is adopted.
+// Remote and persistent stores record the expiry of their entries in the envelope; it is informational,
+// since those stores expire entries themselves.
+type EnvelopeCodec struct {
+	codec       Codec
+	id          CodecID
+	compression CompressionID
+	threshold   int
+}
+
+// Marshal encodes the value in an envelope that never expires.
+func (c *EnvelopeCodec) Marshal(value any) ([]byte, error) {
+	return c.MarshalWithExpiry(value, time.Time{})
+}
+
+// MarshalWithExpiry encodes the value in an envelope recording its expiry, zero if it never expires.
+func (c *EnvelopeCodec) MarshalWithExpiry(value any, expiresAt time.Time) ([]byte, error) {
+	payload, err := c.codec.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	e := Envelope{Version: EnvelopeVersion, Codec: c.id, CreatedAt: time.Now(), ExpiresAt: expiresAt, Payload: payload}
+	if c.compression != CompressionNone && len(payload) >= c.threshold {
+		if e.Payload, err = compress(c.compression, payload); err != nil {
+			return nil, err
+		}
+		e.Compression = c.compression
+	}
+	return e.MarshalBinary()
+}
+
+// Unmarshal decodes a value encoded by any EnvelopeCodec, or by the wrapped codec without an envelope.
+func (c *EnvelopeCodec) Unmarshal(data []byte) (any, error) {
+	e, err := ParseEnvelope(data)
+	if errors.Is(err, ErrNotEnvelope) {
+		return c.codec.Unmarshal(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	codec := c.codec
+	if e.Codec != CodecUnknown && e.Codec != c.id {
+		var ok bool
+		if codec, ok = registeredCodec(e.Codec); !ok {
+			return nil, fmt.Errorf("once_cache: unknown envelope codec %d", e.Codec)
+		}
+	}
+	payload, err := decompress(e.Compression, e.Payload)
+	if err != nil {
+		return nil, err
+	}
+	return codec.Unmarshal(payload)
+}
+
+func compress(compression CompressionID, data []byte) ([]byte, error) {
+	if compression != CompressionGzip {
+		return nil, fmt.Errorf("once_cache: unknown envelope compression %d", compression)
+	}
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decompress(compression CompressionID, data []byte) ([]byte, error) {
+	switch compression {
+	case CompressionNone:
+		return data, nil
+	case CompressionGzip:
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return io.ReadAll(r)
+	}
+	return nil, fmt.Errorf("once_cache: unknown envelope compression %d", compression)
+}
+
+// NewEnvelopeCodec creates a new instance of EnvelopeCodec wrapping codec.
+func NewEnvelopeCodec(codec Codec, opts ...EnvelopeOption) *EnvelopeCodec {
+	c := &EnvelopeCodec{codec: codec}
+	switch codec.(type) {
+	case GobCodec:
+		c.id = CodecGob
+	case JSONCodec:
+		c.id = CodecJSON
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// defaultCodec is the codec of remote and persistent stores created without one.
+func defaultCodec() Codec {
+	return NewEnvelopeCodec(GobCodec{})
+}
+
+// marshalValue encodes the value of an entry with the specified time to live, recording its expiry
+// for codecs writing envelopes.
+func marshalValue(codec Codec, value any, d time.Duration) ([]byte, error) {
+	if e, ok := codec.(*EnvelopeCodec); ok {
+		var expiresAt time.Time
+		if d > 0 {
+			expiresAt = time.Now().Add(d)
+		}
+		return e.MarshalWithExpiry(value, expiresAt)
+	}
+	return codec.Marshal(value)
+}
+
+func unixNanos(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func fromUnixNanos(n int64) time.Time {
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
+}
@@ -0,0 +1,54 @@
+package once_cache
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEnvelopeCodecRoundTripsAndCompresses(t *testing.T) {
+	c := NewEnvelopeCodec(GobCodec{}, WithEnvelopeCompression(CompressionGzip, 64))
+	long := strings.Repeat("a", 1000)
+	expiresAt := time.Now().Add(time.Hour)
+	data, err := c.MarshalWithExpiry(long, expiresAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := ParseEnvelope(data)
+	if err != nil || e.Compression != CompressionGzip || e.Codec != CodecGob || !e.ExpiresAt.Equal(expiresAt.Round(0)) {
+		t.Fatalf("ParseEnvelope = %+v, %v, want a gzipped gob envelope with the expiry", e, err)
+	}
+	if len(data) >= len(long) {
+		t.Fatalf("the envelope holds %d bytes, want the payload compressed", len(data))
+	}
+	if v, err := c.Unmarshal(data); err != nil || v != long {
+		t.Fatalf("Unmarshal = %v, want the value back", err)
+	}
+}
+
+func TestEnvelopeCodecReadsOtherCodecsAndBareData(t *testing.T) {
+	gob := NewEnvelopeCodec(GobCodec{})
+	fromJSON, err := NewEnvelopeCodec(JSONCodec{}).Marshal("v")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := gob.Unmarshal(fromJSON); err != nil || v != "v" {
+		t.Fatalf("Unmarshal of a JSON envelope = %v, %v, want v", v, err)
+	}
+	bare, _ := GobCodec{}.Marshal("bare")
+	if v, err := gob.Unmarshal(bare); err != nil || v != "bare" {
+		t.Fatalf("Unmarshal without envelope = %v, %v, want bare", v, err)
+	}
+}
+
+func TestParseEnvelopeRejectsNewerVersions(t *testing.T) {
+	data, _ := Envelope{Version: EnvelopeVersion + 1, Payload: []byte("x")}.MarshalBinary()
+	if _, err := ParseEnvelope(data); !errors.Is(err, ErrEnvelopeVersion) {
+		t.Fatalf("ParseEnvelope = %v, want ErrEnvelopeVersion", err)
+	}
+	if _, err := ParseEnvelope(bytes.Repeat([]byte{1}, 40)); !errors.Is(err, ErrNotEnvelope) {
+		t.Fatalf("ParseEnvelope of other data = %v, want ErrNotEnvelope", err)
+	}
+}
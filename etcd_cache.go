@@ -0,0 +1,148 @@
+package once_cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// EtcdKV is the subset of the etcd v3 client used by EtcdCache. It maps directly onto clientv3:
+// Grant is Lease.Grant returning the lease ID, Revoke is Lease.Revoke, Put is KV.Put with
+// clientv3.WithLease when leaseID is not zero, Get is a linearizable KV.Get of a single key and
+// Delete is KV.Delete.
+type EtcdKV interface {
+	// Grant creates a lease that expires after ttl seconds
+	Grant(ctx context.Context, ttl int64) (leaseID int64, err error)
+
+	// Revoke ends a lease before it expires, removing the keys attached to it
+	Revoke(ctx context.Context, leaseID int64) error
+
+	// Put stores value under key, attached to the lease unless leaseID is zero
+	Put(ctx context.Context, key string, value []byte, leaseID int64) error
+
+	// Get retrieves the value stored under key
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+
+	// Delete removes key
+	Delete(ctx context.Context, key string) error
+}
+
+// EtcdCache is a struct that implements the ICacheWithError interface on top of etcd.
+// Each entry with a time to live is attached to its own lease, so etcd removes it on expiry
+// and all members of the cluster observe the same, consistent entries. The lease an entry
+// replaced is revoked when it was granted by the same EtcdCache, so that frequent Sets with
+// long TTLs do not pile up leases; those of other writers expire on their own.
+type EtcdCache struct {
+	kv     EtcdKV
+	prefix string
+	codec  Codec
+
+	mu     sync.Mutex
+	leases map[string]etcdLease
+	// sweepAt is the number of tracked leases at which the expired ones are forgotten.
+	sweepAt int
+}
+
+// etcdLease is a lease granted for an entry, tracked until it expires.
+type etcdLease struct {
+	id        int64
+	expiresAt time.Time
+}
+
+// minEtcdLeaseSweep is the number of tracked leases below which expired ones are kept.
+const minEtcdLeaseSweep = 1024
+
+// Set stores the value with the specified time to live, rounded up to whole seconds as leases require.
+// A non-positive duration never expires.
+func (c *EtcdCache) Set(key string, value any, d time.Duration) error {
+	data, err := marshalValue(c.codec, value, d)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	var lease etcdLease
+	if d > 0 {
+		ttl := int64((d + time.Second - 1) / time.Second)
+		if lease.id, err = c.kv.Grant(ctx, ttl); err != nil {
+			return err
+		}
+		lease.expiresAt = time.Now().Add(time.Duration(ttl) * time.Second)
+	}
+	if err := c.kv.Put(ctx, c.prefix+key, data, lease.id); err != nil {
+		if lease.id != 0 {
+			c.kv.Revoke(ctx, lease.id)
+		}
+		return err
+	}
+	if prev := c.swapLease(key, lease); prev != 0 {
+		// The entry no longer uses the lease, so revoking it removes nothing; it expires anyway if this fails.
+		c.kv.Revoke(ctx, prev)
+	}
+	return nil
+}
+
+// swapLease records the lease of the entry under key and returns the unexpired lease it replaced, if any.
+func (c *EtcdCache) swapLease(key string, lease etcdLease) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	prev, ok := c.leases[key]
+	if lease.id == 0 {
+		delete(c.leases, key)
+	} else {
+		if c.leases == nil {
+			c.leases = make(map[string]etcdLease)
+		}
+		c.leases[key] = lease
+	}
+	if len(c.leases) >= max(c.sweepAt, minEtcdLeaseSweep) {
+		for k, l := range c.leases {
+			if !now.Before(l.expiresAt) {
+				delete(c.leases, k)
+			}
+		}
+		c.sweepAt = 2 * len(c.leases)
+	}
+	if ok && now.Before(prev.expiresAt) {
+		return prev.id
+	}
+	return 0
+}
+
+// Get retrieves the value for the key.
+func (c *EtcdCache) Get(key string) (any, bool, error) {
+	data, ok, err := c.kv.Get(context.Background(), c.prefix+key)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	value, err := c.codec.Unmarshal(data)
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// Delete removes the key, revoking its lease when this EtcdCache granted it.
+func (c *EtcdCache) Delete(key string) error {
+	ctx := context.Background()
+	if err := c.kv.Delete(ctx, c.prefix+key); err != nil {
+		return err
+	}
+	if prev := c.swapLease(key, etcdLease{}); prev != 0 {
+		c.kv.Revoke(ctx, prev)
+	}
+	return nil
+}
+
+// NewEtcdCache creates a new instance of EtcdCache storing entries under the specified key prefix.
+// Values are serialized with codec, or GobCodec wrapped in an EnvelopeCodec if codec is nil.
+func NewEtcdCache(kv EtcdKV, prefix string, codec Codec) ICacheWithError {
+	if codec == nil {
+		codec = defaultCodec()
+	}
+	return &EtcdCache{
+		kv:     kv,
+		prefix: prefix,
+		codec:  codec,
+	}
+}
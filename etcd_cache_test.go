@@ -0,0 +1,114 @@
+package once_cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeEtcd is an in-memory EtcdKV tracking live leases, whose Puts fail while failPuts is set.
+type fakeEtcd struct {
+	mu       sync.Mutex
+	next     int64
+	leases   map[int64]bool
+	values   map[string][]byte
+	failPuts bool
+}
+
+func newFakeEtcd() *fakeEtcd {
+	return &fakeEtcd{leases: map[int64]bool{}, values: map[string][]byte{}}
+}
+
+func (f *fakeEtcd) Grant(ctx context.Context, ttl int64) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.next++
+	f.leases[f.next] = true
+	return f.next, nil
+}
+
+func (f *fakeEtcd) Revoke(ctx context.Context, leaseID int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.leases, leaseID)
+	return nil
+}
+
+func (f *fakeEtcd) Put(ctx context.Context, key string, value []byte, leaseID int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failPuts {
+		return errors.New("put failed")
+	}
+	f.values[key] = value
+	return nil
+}
+
+func (f *fakeEtcd) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	v, ok := f.values[key]
+	return v, ok, nil
+}
+
+func (f *fakeEtcd) Delete(ctx context.Context, key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.values, key)
+	return nil
+}
+
+func (f *fakeEtcd) liveLeases() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.leases)
+}
+
+func TestEtcdCacheRevokesReplacedLeases(t *testing.T) {
+	kv := newFakeEtcd()
+	c := NewEtcdCache(kv, "cache/", nil)
+	for i := 0; i < 5; i++ {
+		if err := c.Set("a", i, time.Hour); err != nil {
+			t.Fatalf("Set = %v", err)
+		}
+	}
+	if n := kv.liveLeases(); n != 1 {
+		t.Fatalf("%d leases live after overwriting one key, want 1", n)
+	}
+	if v, ok, err := c.Get("a"); err != nil || !ok || v != 4 {
+		t.Fatalf("Get = %v, %v, %v, want 4", v, ok, err)
+	}
+	if err := c.Set("a", 5, 0); err != nil {
+		t.Fatalf("Set without expiry = %v", err)
+	}
+	if n := kv.liveLeases(); n != 0 {
+		t.Fatalf("%d leases live after storing the key without expiry, want 0", n)
+	}
+	c.Set("b", 1, time.Hour)
+	if err := c.Delete("b"); err != nil {
+		t.Fatalf("Delete = %v", err)
+	}
+	if n := kv.liveLeases(); n != 0 {
+		t.Fatalf("%d leases live after Delete, want 0", n)
+	}
+}
+
+func TestEtcdCacheRevokesTheLeaseOfAFailedPut(t *testing.T) {
+	kv := newFakeEtcd()
+	c := NewEtcdCache(kv, "cache/", nil)
+	c.Set("a", 1, time.Hour)
+	kv.failPuts = true
+	if err := c.Set("a", 2, time.Hour); err == nil {
+		t.Fatal("Set succeeded with a failing Put")
+	}
+	if n := kv.liveLeases(); n != 1 {
+		t.Fatalf("%d leases live after a failed Put, want only the stored entry's", n)
+	}
+	kv.failPuts = false
+	c.Set("a", 3, time.Hour)
+	if n := kv.liveLeases(); n != 1 {
+		t.Fatalf("%d leases live after replacing the entry, want 1", n)
+	}
+}
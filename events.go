@@ -0,0 +1,141 @@
+package once_cache
+
+import (
+	"time"
+)
+
+// EventType is the kind of a CacheEvent.
+type EventType int
+
+const (
+	// EventHit is emitted when a value is served from the cache.
+	EventHit EventType = iota
+	// EventMiss is emitted when a value is not in the cache and has to be loaded.
+	EventMiss
+	// EventLoad is emitted when a loader finishes, with its error and duration.
+	EventLoad
+	// EventSet is emitted when a loaded value is stored.
+	EventSet
+	// EventEvict is emitted when the store evicts an entry to make room.
+	EventEvict
+	// EventExpire is emitted when the store removes an expired entry.
+	EventExpire
+)
+
+// String returns the name of the event type.
+func (t EventType) String() string {
+	switch t {
+	case EventHit:
+		return "hit"
+	case EventMiss:
+		return "miss"
+	case EventLoad:
+		return "load"
+	case EventSet:
+		return "set"
+	case EventEvict:
+		return "evict"
+	case EventExpire:
+		return "expire"
+	}
+	return "unknown"
+}
+
+// CacheEvent is a structured record of cache activity.
+type CacheEvent struct {
+	Type EventType
+	Key  string
+	Time time.Time
+	// Label is the cache's label, see WithLabel.
+	Label string
+	// Err is the loader error of an EventLoad.
+	Err error
+	// Duration is the loader duration of an EventLoad.
+	Duration time.Duration
+}
+
+// BackpressurePolicy decides what happens when the events channel is full.
+type BackpressurePolicy int
+
+const (
+	// DropOldest discards the oldest buffered event to make room, so cache operations never wait.
+	DropOldest BackpressurePolicy = iota
+	// Block makes cache operations wait until the consumer catches up.
+	Block
+)
+
+// EvictionReason is why a store removed an entry on its own.
+type EvictionReason int
+
+const (
+	// EvictionCapacity means the entry was evicted to make room.
+	EvictionCapacity EvictionReason = iota
+	// EvictionExpired means the entry had expired.
+	EvictionExpired
+)
+
+// IEvictionNotifier is an optional interface for stores that report entries they remove on their own.
+type IEvictionNotifier interface {
+	// OnEvict registers a function called for every entry the store evicts or expires
+	OnEvict(f func(key string, reason EvictionReason))
+}
+
+// WithEvents enables the Events channel with the specified buffer size and backpressure policy.
+// Evict and expire events are included when the store implements IEvictionNotifier.
+// Only Block may use an unbuffered channel; other policies buffer at least one event.
+func WithEvents(buffer int, policy BackpressurePolicy) Option {
+	return func(o *OnceCache) {
+		if policy != Block {
+			buffer = max(1, buffer)
+		}
+		o.events = make(chan CacheEvent, buffer)
+		o.eventPolicy = policy
+	}
+}
+
+// Events returns the channel of cache events, or nil if they are not enabled with WithEvents.
+func (o *OnceCache) Events() <-chan CacheEvent {
+	return o.events
+}
+
+// emit sends an event according to the backpressure policy.
+func (o *OnceCache) emit(t EventType, key string, err error, d time.Duration) {
+	o.decideEvent(t, key, err, d)
+	if o.events == nil {
+		return
+	}
+	ev := CacheEvent{Type: t, Key: key, Time: time.Now(), Label: o.label, Err: err, Duration: d}
+	if o.eventPolicy == Block {
+		o.events <- ev
+		return
+	}
+	select {
+	case o.events <- ev:
+		return
+	default:
+	}
+	// Drop the oldest event and try again once. If concurrent emitters took the room first,
+	// the new event is dropped instead, so that emitting never waits.
+	select {
+	case <-o.events:
+	default:
+	}
+	select {
+	case o.events <- ev:
+	default:
+	}
+}
+
+// forwardEvictions subscribes to the store's evictions, if it reports them.
+func (o *OnceCache) forwardEvictions() {
+	if (o.events == nil && o.decisions == nil) || o.evictionNotifier == nil {
+		return
+	}
+	o.evictionNotifier.OnEvict(func(key string, reason EvictionReason) {
+		if reason == EvictionExpired {
+			o.emit(EventExpire, key, nil, 0)
+		} else {
+			o.emit(EventEvict, key, nil, 0)
+		}
+	})
+}
@@ -0,0 +1,33 @@
+package once_cache
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+func TestEventsDropOldestNeverBlocksWithoutReader(t *testing.T) {
+	cache := NewOnceCache(&singleflight.Group{}, NewMemoryCache(), WithEvents(0, DropOldest))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 3; i++ {
+			cache.GetWithSingleFunc("key", func() (any, error) { return 1, nil }, time.Minute, nil)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("GetWithSingleFunc blocked on an unread events channel")
+	}
+	// The channel keeps the newest event.
+	select {
+	case ev := <-cache.Events():
+		if ev.Type != EventHit {
+			t.Fatalf("last event = %v, want hit", ev.Type)
+		}
+	default:
+		t.Fatal("no event buffered")
+	}
+}
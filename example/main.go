@@ -1 +1,67 @@
 package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	once_cache "github.com/phongthien99/once-cache"
+	"golang.org/x/sync/singleflight"
+)
+
+// remoteStore simulates a remote cache store that can go down.
+type remoteStore struct {
+	mu    sync.Mutex
+	items map[string]any
+	down  bool
+}
+
+func (s *remoteStore) Set(key string, value any, d time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.down {
+		return errors.New("store unavailable")
+	}
+	s.items[key] = value
+	return nil
+}
+
+func (s *remoteStore) Get(key string) (any, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.down {
+		return nil, false, errors.New("store unavailable")
+	}
+	value, ok := s.items[key]
+	return value, ok, nil
+}
+
+func (s *remoteStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.down {
+		return errors.New("store unavailable")
+	}
+	delete(s.items, key)
+	return nil
+}
+
+func main() {
+	store := &remoteStore{items: map[string]any{}}
+	cache := once_cache.NewOnceCache(&singleflight.Group{}, once_cache.NewDegradedCache(store, once_cache.NewBreaker(3, time.Second)))
+
+	load := func() (any, error) {
+		return "value from the source of truth", nil
+	}
+
+	value, ok := cache.GetWithSingleFunc("key", load, time.Minute, nil)
+	fmt.Println(value, ok)
+
+	// While the store is down the loader still runs and its result is served without caching.
+	store.mu.Lock()
+	store.down = true
+	store.mu.Unlock()
+	value, ok = cache.GetWithSingleFunc("other", load, time.Minute, nil)
+	fmt.Println(value, ok)
+}
@@ -0,0 +1,154 @@
+package once_cache
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// ExperimentArm is the cache policy applied to the keys of one arm of an Experiment.
+type ExperimentArm struct {
+	// Name labels the arm in its stats.
+	Name string
+	// TTL replaces the time to live of the loads of the arm's keys, if not zero.
+	TTL time.Duration
+	// RefreshAhead replaces the refresh-ahead window of the arm's keys, if not zero, see WithRefreshAhead.
+	RefreshAhead time.Duration
+}
+
+// ArmStats are the counters of one arm of an Experiment.
+type ArmStats struct {
+	Arm        string
+	Hits       uint64
+	Misses     uint64
+	Loads      uint64
+	LoadErrors uint64
+	// LoadTime is the total time spent loading the arm's keys, a proxy for the load put on the origin.
+	LoadTime time.Duration
+}
+
+// HitRatio returns the fraction of lookups that were hits, zero if there were none.
+func (s ArmStats) HitRatio() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+type armCounters struct {
+	hits, misses, loads, loadErrors atomic.Uint64
+	loadTime                        atomic.Int64
+}
+
+// Experiment splits the keys of a cache between a control and a treatment policy, so that a policy change,
+// such as a longer TTL, can be evaluated on real traffic by comparing the hit ratio and origin load of both
+// arms. Keys are assigned to arms by hash, so each key consistently uses one policy.
+type Experiment struct {
+	name               string
+	control, treatment ExperimentArm
+	// threshold is the number of the 10000 hash buckets assigned to the treatment.
+	threshold uint64
+	counters  [2]armCounters
+}
+
+// Arm returns the name of the arm key belongs to.
+func (e *Experiment) Arm(key string) string {
+	return e.arm(key).Name
+}
+
+// Stats returns the counters of the control and treatment arms, in that order.
+func (e *Experiment) Stats() []ArmStats {
+	stats := make([]ArmStats, 2)
+	for i, arm := range []ExperimentArm{e.control, e.treatment} {
+		c := &e.counters[i]
+		stats[i] = ArmStats{
+			Arm:        arm.Name,
+			Hits:       c.hits.Load(),
+			Misses:     c.misses.Load(),
+			Loads:      c.loads.Load(),
+			LoadErrors: c.loadErrors.Load(),
+			LoadTime:   time.Duration(c.loadTime.Load()),
+		}
+	}
+	return stats
+}
+
+func (e *Experiment) index(key string) int {
+	// Hash the experiment name too, so that experiments split keys independently.
+	if fnv1a(e.name+"\x00"+key)%10000 < e.threshold {
+		return 1
+	}
+	return 0
+}
+
+func (e *Experiment) arm(key string) *ExperimentArm {
+	if e.index(key) == 1 {
+		return &e.treatment
+	}
+	return &e.control
+}
+
+func (e *Experiment) recordLookup(key string, hit bool) {
+	if e == nil {
+		return
+	}
+	c := &e.counters[e.index(key)]
+	if hit {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+}
+
+func (e *Experiment) recordLoad(key string, d time.Duration, err error) {
+	if e == nil {
+		return
+	}
+	c := &e.counters[e.index(key)]
+	c.loads.Add(1)
+	c.loadTime.Add(int64(d))
+	if err != nil {
+		c.loadErrors.Add(1)
+	}
+}
+
+// NewExperiment creates a new instance of Experiment assigning percent of the keys, in [0, 100], to the
+// treatment arm and the others to the control arm. Arms without a name are called "control" and "treatment".
+func NewExperiment(name string, control, treatment ExperimentArm, percent float64) *Experiment {
+	if control.Name == "" {
+		control.Name = "control"
+	}
+	if treatment.Name == "" {
+		treatment.Name = "treatment"
+	}
+	percent = min(max(percent, 0), 100)
+	return &Experiment{name: name, control: control, treatment: treatment, threshold: uint64(percent * 100)}
+}
+
+// WithExperiment applies the policies of the experiment's arms to their keys and records their stats.
+// Arm TTLs take precedence over the TTLs passed to calls.
+func WithExperiment(e *Experiment) Option {
+	return func(o *OnceCache) {
+		o.experiment = e
+	}
+}
+
+// keyTTL returns the time to live of a load of key, d or the default TTL unless the key's experiment arm
+// sets one.
+func (o *OnceCache) keyTTL(key string, d time.Duration) time.Duration {
+	if o.experiment != nil {
+		if arm := o.experiment.arm(key); arm.TTL != 0 {
+			return arm.TTL
+		}
+	}
+	return o.ttl(d)
+}
+
+// refreshWindow returns the refresh-ahead window of key.
+func (o *OnceCache) refreshWindow(key string) time.Duration {
+	if o.experiment != nil {
+		if arm := o.experiment.arm(key); arm.RefreshAhead != 0 {
+			return arm.RefreshAhead
+		}
+	}
+	return time.Duration(o.refreshAhead.Load())
+}
@@ -0,0 +1,102 @@
+package once_cache
+
+import "time"
+
+const (
+	// sampledExpiryBudget bounds the time a sampled expiry tick may spend removing entries.
+	sampledExpiryBudget = 25 * time.Millisecond
+	// defaultExpirySample is the number of entries checked per round by SampledExpiry when none is given.
+	defaultExpirySample = 20
+)
+
+type expiryMode int
+
+const (
+	lazyExpiry expiryMode = iota
+	periodicExpiry
+	sampledExpiry
+)
+
+// ExpiryStrategy selects how a MemoryCache removes expired entries. Expired entries are never returned
+// and are always removed when read; the strategy decides what happens to expired entries nobody reads.
+type ExpiryStrategy struct {
+	mode       expiryMode
+	interval   time.Duration
+	sampleSize int
+}
+
+// LazyExpiry removes expired entries only when they are read. It costs nothing in the background
+// and suits small caches, or caches whose keys are read until they are replaced.
+func LazyExpiry() ExpiryStrategy {
+	return ExpiryStrategy{mode: lazyExpiry}
+}
+
+// PeriodicExpiry sweeps the whole cache every interval. Memory is reclaimed promptly,
+// but each sweep visits every entry.
+func PeriodicExpiry(interval time.Duration) ExpiryStrategy {
+	return ExpiryStrategy{mode: periodicExpiry, interval: interval}
+}
+
+// SampledExpiry checks sampleSize random entries every interval and repeats while more than a quarter
+// of them were expired, like Redis' active expiry. The work per tick is bounded regardless of cache size,
+// which suits very large caches.
+func SampledExpiry(interval time.Duration, sampleSize int) ExpiryStrategy {
+	if sampleSize < 1 {
+		sampleSize = defaultExpirySample
+	}
+	return ExpiryStrategy{mode: sampledExpiry, interval: interval, sampleSize: sampleSize}
+}
+
+// background reports whether the strategy needs a janitor goroutine.
+func (s ExpiryStrategy) background() bool {
+	return s.mode != lazyExpiry && s.interval > 0
+}
+
+// WithExpiryStrategy sets how expired entries are removed. It defaults to LazyExpiry.
+func WithExpiryStrategy(strategy ExpiryStrategy) MemoryOption {
+	return func(c *MemoryCache) {
+		c.expiry = strategy
+	}
+}
+
+// deleteExpiredSample runs one tick of sampled expiry and returns the number of removed entries.
+func (c *MemoryCache) deleteExpiredSample(sampleSize int) int {
+	deadline := time.Now().Add(sampledExpiryBudget)
+	removed := 0
+	expired := make([]string, 0, sampleSize)
+	for {
+		now := time.Now().UnixNano()
+		sampled := 0
+		expired = expired[:0]
+		c.storage.sample(sampleSize, func(key string, e memoryEntry) {
+			sampled++
+			if e.removable(now, int64(c.staleRetention)) {
+				expired = append(expired, key)
+			}
+		})
+		for _, key := range expired {
+			c.expire(key, now)
+		}
+		removed += len(expired)
+		if sampled == 0 || 4*len(expired) <= sampled || time.Now().After(deadline) {
+			return removed
+		}
+	}
+}
+
+func (c *MemoryCache) janitor() {
+	ticker := time.NewTicker(c.expiry.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if c.expiry.mode == sampledExpiry {
+				c.deleteExpiredSample(c.expiry.sampleSize)
+			} else {
+				c.DeleteExpired()
+			}
+		case <-c.stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package once_cache
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSampledExpiryDrainsEveryShard(t *testing.T) {
+	c := NewMemoryCache(WithShards(32))
+	for i := 0; i < 3200; i++ {
+		c.Set("live:"+strconv.Itoa(i), i, time.Hour)
+		c.Set("expired:"+strconv.Itoa(i), i, time.Millisecond)
+	}
+	time.Sleep(5 * time.Millisecond)
+	// Samples smaller than the number of shards must still reach all of them over successive ticks.
+	for tick := 0; tick < 10000 && c.Len() > 3200; tick++ {
+		c.deleteExpiredSample(8)
+	}
+	if n := c.Len(); n != 3200 {
+		t.Fatalf("Len = %d after sampled expiry, want 3200", n)
+	}
+	s := c.storage.(*shardedStorage)
+	now := time.Now().UnixNano()
+	for i := range s.shards {
+		for key, p := range s.shards[i].items {
+			if p.entry.removable(now, 0) {
+				t.Fatalf("shard %d still holds expired %s", i, key)
+			}
+		}
+	}
+}
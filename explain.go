@@ -0,0 +1,195 @@
+package once_cache
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DecisionKind is the kind of a Decision.
+type DecisionKind int
+
+const (
+	// DecisionHit means a fresh value was served from the store.
+	DecisionHit DecisionKind = iota
+	// DecisionMiss means no fresh value was found and the call went on to load one.
+	DecisionMiss
+	// DecisionStale means an expired value retained by the store was served.
+	DecisionStale
+	// DecisionStaleRefused means a retained value was not served because of WithMaxStaleness.
+	DecisionStaleRefused
+	// DecisionRefresh means a background reload was started, by refresh-ahead or stale-while-revalidate.
+	DecisionRefresh
+	// DecisionLoad means a loader finished.
+	DecisionLoad
+	// DecisionSet means a loaded value was stored.
+	DecisionSet
+	// DecisionDelete means the key was deleted.
+	DecisionDelete
+	// DecisionEvict means the store evicted the entry to make room.
+	DecisionEvict
+	// DecisionExpire means the store removed the expired entry.
+	DecisionExpire
+)
+
+// String returns the name of the decision kind.
+func (k DecisionKind) String() string {
+	switch k {
+	case DecisionHit:
+		return "hit"
+	case DecisionMiss:
+		return "miss"
+	case DecisionStale:
+		return "stale"
+	case DecisionStaleRefused:
+		return "stale-refused"
+	case DecisionRefresh:
+		return "refresh"
+	case DecisionLoad:
+		return "load"
+	case DecisionSet:
+		return "set"
+	case DecisionDelete:
+		return "delete"
+	case DecisionEvict:
+		return "evict"
+	case DecisionExpire:
+		return "expire"
+	}
+	return "unknown"
+}
+
+// Decision is a record of what the cache did for a key, see Explain.
+type Decision struct {
+	Time time.Time
+	Kind DecisionKind
+	// Detail describes the decision, such as how long ago a stale value expired.
+	Detail string
+	// Err is the loader error of a DecisionLoad.
+	Err error
+	// Duration is the loader duration of a DecisionLoad.
+	Duration time.Duration
+}
+
+// String formats the decision on one line.
+func (d Decision) String() string {
+	s := d.Time.Format(time.RFC3339Nano) + " " + d.Kind.String()
+	if d.Detail != "" {
+		s += " " + d.Detail
+	}
+	if d.Kind == DecisionLoad {
+		s += " in " + d.Duration.String()
+	}
+	if d.Err != nil {
+		s += ": " + d.Err.Error()
+	}
+	return s
+}
+
+// decisionLog keeps the last decisions of the traced keys.
+type decisionLog struct {
+	size int
+	keys sync.Map // key -> *decisionRing
+}
+
+type decisionRing struct {
+	mu      sync.Mutex
+	entries []Decision
+	next    int
+}
+
+func (r *decisionRing) add(d Decision, size int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.entries) < size {
+		r.entries = append(r.entries, d)
+		return
+	}
+	r.entries[r.next] = d
+	r.next = (r.next + 1) % size
+}
+
+func (r *decisionRing) list() []Decision {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]Decision, 0, len(r.entries))
+	out = append(out, r.entries[r.next:]...)
+	return append(out, r.entries[:r.next]...)
+}
+
+// WithDecisionLog enables Explain, keeping the last size decisions of every traced key, such as hits,
+// misses, stale serves, background refreshes and evictions, so that a question like "why did this user see
+// old data at 14:32" can be answered. keys are traced from the start; TraceKey traces more at runtime.
+// Untraced keys cost one map lookup per decision.
+func WithDecisionLog(size int, keys ...string) Option {
+	return func(o *OnceCache) {
+		o.decisions = &decisionLog{size: max(1, size)}
+		for _, key := range keys {
+			o.decisions.keys.Store(key, &decisionRing{})
+		}
+	}
+}
+
+// TraceKey starts recording the decisions of key, if WithDecisionLog is enabled.
+func (o *OnceCache) TraceKey(key string) {
+	if o.decisions != nil {
+		o.decisions.keys.LoadOrStore(key, &decisionRing{})
+	}
+}
+
+// UntraceKey stops recording the decisions of key and drops those recorded.
+func (o *OnceCache) UntraceKey(key string) {
+	if o.decisions != nil {
+		o.decisions.keys.Delete(key)
+	}
+}
+
+// Explain returns the recorded decisions of a traced key, oldest first.
+func (o *OnceCache) Explain(key string) []Decision {
+	if o.decisions == nil {
+		return nil
+	}
+	r, ok := o.decisions.keys.Load(key)
+	if !ok {
+		return nil
+	}
+	return r.(*decisionRing).list()
+}
+
+// decide records a decision for key if it is traced.
+func (o *OnceCache) decide(kind DecisionKind, key, detail string, err error, d time.Duration) {
+	if o.decisions == nil {
+		return
+	}
+	r, ok := o.decisions.keys.Load(key)
+	if !ok {
+		return
+	}
+	r.(*decisionRing).add(Decision{Time: time.Now(), Kind: kind, Detail: detail, Err: err, Duration: d}, o.decisions.size)
+}
+
+// decideEvent records the decision matching an event.
+func (o *OnceCache) decideEvent(t EventType, key string, err error, d time.Duration) {
+	if o.decisions == nil {
+		return
+	}
+	switch t {
+	case EventHit:
+		o.decide(DecisionHit, key, "", nil, 0)
+	case EventMiss:
+		o.decide(DecisionMiss, key, "", nil, 0)
+	case EventLoad:
+		o.decide(DecisionLoad, key, "", err, d)
+	case EventSet:
+		o.decide(DecisionSet, key, "", nil, 0)
+	case EventEvict:
+		o.decide(DecisionEvict, key, "", nil, 0)
+	case EventExpire:
+		o.decide(DecisionExpire, key, "", nil, 0)
+	}
+}
+
+// staleDetail describes a retained value expired at expiresAt.
+func staleDetail(expiresAt time.Time) string {
+	return fmt.Sprintf("expired %s ago", time.Since(expiresAt).Round(time.Millisecond))
+}
@@ -0,0 +1,241 @@
+package once_cache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fileHeaderSize is the size of the header written before each value: the expiry as Unix nanoseconds,
+// or zero if the entry never expires.
+const fileHeaderSize = 8
+
+// fileLockSlots is the number of hashed locks serializing the replacement and removal of files.
+const fileLockSlots = 64
+
+// staleTempFileAge is the age after which Cleanup removes temporary files left by interrupted writes.
+const staleTempFileAge = time.Hour
+
+// FileCache is a struct that implements the ICacheWithError interface with one file per key in a directory,
+// for CLI tools and batch jobs that want persistent caching without a server. File names are hashes of
+// the keys, and each file starts with a header holding the expiry. Expired files read as misses and
+// are removed on access or by Cleanup, unless they were replaced since they were read.
+type FileCache struct {
+	dir   string
+	codec Codec
+	// locks serialize renaming a file into place with removing the expired file it replaces.
+	locks [fileLockSlots]sync.Mutex
+}
+
+// Set writes the value with the specified time to live. A non-positive duration never expires.
+// The file is written atomically, so concurrent readers never see a partial value.
+func (c *FileCache) Set(key string, value any, d time.Duration) error {
+	data, err := marshalValue(c.codec, value, d)
+	if err != nil {
+		return err
+	}
+	return c.SetBytes(key, data, d)
+}
+
+// SetBytes writes the raw value, bypassing the codec.
+func (c *FileCache) SetBytes(key string, data []byte, d time.Duration) error {
+	return c.SetReader(key, bytes.NewReader(data), d)
+}
+
+// SetReader writes the raw value read from r, bypassing the codec, without holding it in memory.
+// The file is only visible once r is fully read.
+func (c *FileCache) SetReader(key string, r io.Reader, d time.Duration) error {
+	var expiresAt int64
+	if d > 0 {
+		expiresAt = time.Now().Add(d).UnixNano()
+	}
+	header := make([]byte, fileHeaderSize)
+	binary.BigEndian.PutUint64(header, uint64(expiresAt))
+
+	tmp, err := os.CreateTemp(c.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(tmp, io.MultiReader(bytes.NewReader(header), r)); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	path := c.path(key)
+	mu := c.lock(path)
+	mu.Lock()
+	err = os.Rename(tmp.Name(), path)
+	mu.Unlock()
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// Get reads the value if the file exists and has not expired.
+func (c *FileCache) Get(key string) (any, bool, error) {
+	data, ok, err := c.GetBytes(key)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	value, err := c.codec.Unmarshal(data)
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// GetBytes reads the raw value, bypassing the codec.
+func (c *FileCache) GetBytes(key string) ([]byte, bool, error) {
+	path := c.path(key)
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	defer f.Close()
+	buf, err := io.ReadAll(f)
+	if err != nil {
+		return nil, false, err
+	}
+	if fileExpired(buf) {
+		c.removeExpired(path, f)
+		return nil, false, nil
+	}
+	return buf[fileHeaderSize:], true, nil
+}
+
+// GetReader opens the raw value for reading, bypassing the codec, if the file exists and has not expired.
+func (c *FileCache) GetReader(key string) (io.ReadCloser, bool, error) {
+	path := c.path(key)
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	header := make([]byte, fileHeaderSize)
+	if _, err := io.ReadFull(f, header); err != nil {
+		f.Close()
+		return nil, false, err
+	}
+	if fileExpired(header) {
+		c.removeExpired(path, f)
+		f.Close()
+		return nil, false, nil
+	}
+	return f, true, nil
+}
+
+// Delete removes the file for the key.
+func (c *FileCache) Delete(key string) error {
+	err := os.Remove(c.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Cleanup removes all expired files, as well as the temporary files of writes interrupted more than an hour
+// ago, and returns how many were removed.
+func (c *FileCache) Cleanup() (int, error) {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		path := filepath.Join(c.dir, entry.Name())
+		if strings.HasPrefix(entry.Name(), ".tmp-") {
+			if info, err := entry.Info(); err == nil && time.Since(info.ModTime()) > staleTempFileAge && os.Remove(path) == nil {
+				removed++
+			}
+			continue
+		}
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		header := make([]byte, fileHeaderSize)
+		_, err = io.ReadFull(f, header)
+		if (err != nil || fileExpired(header)) && c.removeExpired(path, f) {
+			removed++
+		}
+		f.Close()
+	}
+	return removed, nil
+}
+
+// removeExpired removes the file at path if it is still the open file f found expired, and reports whether
+// it did. A file renamed into place since then holds a newer value and is kept.
+func (c *FileCache) removeExpired(path string, f *os.File) bool {
+	opened, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	mu := c.lock(path)
+	mu.Lock()
+	defer mu.Unlock()
+	current, err := os.Lstat(path)
+	if err != nil || !os.SameFile(opened, current) {
+		return false
+	}
+	return os.Remove(path) == nil
+}
+
+// lock returns the lock serializing the replacement and removal of the file at path.
+func (c *FileCache) lock(path string) *sync.Mutex {
+	return &c.locks[fnv1a(filepath.Base(path))%fileLockSlots]
+}
+
+func (c *FileCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:]))
+}
+
+// fileExpired reports whether the file contents are truncated or past their expiry.
+func fileExpired(buf []byte) bool {
+	if len(buf) < fileHeaderSize {
+		return true
+	}
+	expiresAt := int64(binary.BigEndian.Uint64(buf))
+	return expiresAt != 0 && time.Now().UnixNano() >= expiresAt
+}
+
+// NewFileCache creates a new instance of FileCache storing files in dir, creating it if needed.
+// Values are serialized with codec, or GobCodec wrapped in an EnvelopeCodec if codec is nil.
+func NewFileCache(dir string, codec Codec) (*FileCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	if codec == nil {
+		codec = defaultCodec()
+	}
+	return &FileCache{
+		dir:   dir,
+		codec: codec,
+	}, nil
+}
@@ -0,0 +1,93 @@
+package once_cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileCacheKeepsFilesReplacedAfterExpiring(t *testing.T) {
+	c, err := NewFileCache(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetBytes("k", []byte("old"), time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	// A reader found the old file expired, and a writer renames a new one into place before it removes it.
+	f, err := os.Open(c.path("k"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := c.SetBytes("k", []byte("new"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if c.removeExpired(c.path("k"), f) {
+		t.Fatal("removeExpired removed the replacing file")
+	}
+	if data, ok, err := c.GetBytes("k"); !ok || err != nil || string(data) != "new" {
+		t.Fatalf("GetBytes = %q, %v, %v, want new, true, nil", data, ok, err)
+	}
+}
+
+func TestFileCacheReadsRemoveExpiredFiles(t *testing.T) {
+	c, err := NewFileCache(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetBytes("a", []byte("a"), time.Millisecond)
+	c.SetBytes("b", []byte("b"), time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	if _, ok, err := c.GetBytes("a"); ok || err != nil {
+		t.Fatalf("GetBytes of an expired file = %v, %v, want a miss", ok, err)
+	}
+	if _, ok, err := c.GetReader("b"); ok || err != nil {
+		t.Fatalf("GetReader of an expired file = %v, %v, want a miss", ok, err)
+	}
+	for _, key := range []string{"a", "b"} {
+		if _, err := os.Stat(c.path(key)); !os.IsNotExist(err) {
+			t.Errorf("the expired file of %s is still there: %v", key, err)
+		}
+	}
+}
+
+func TestFileCacheCleanupRemovesStaleTempFiles(t *testing.T) {
+	dir := t.TempDir()
+	c, err := NewFileCache(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetBytes("expired", []byte("v"), time.Millisecond)
+	c.SetBytes("live", []byte("v"), time.Minute)
+	stale := filepath.Join(dir, ".tmp-stale")
+	fresh := filepath.Join(dir, ".tmp-fresh")
+	for _, path := range []string{stale, fresh} {
+		if err := os.WriteFile(path, []byte("partial"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := time.Now().Add(-2 * staleTempFileAge)
+	if err := os.Chtimes(stale, old, old); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	removed, err := c.Cleanup()
+	if err != nil || removed != 2 {
+		t.Fatalf("Cleanup = %d, %v, want the expired file and the stale temporary file", removed, err)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("stale temporary file kept: %v", err)
+	}
+	if _, err := os.Stat(fresh); err != nil {
+		t.Errorf("temporary file of a write in progress removed: %v", err)
+	}
+	if _, ok, _ := c.GetBytes("live"); !ok {
+		t.Error("Cleanup removed a live file")
+	}
+}
@@ -0,0 +1,190 @@
+package once_cache
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultFlightBounds are the histogram bounds of flight durations used when WithFlightMetrics is given none.
+var defaultFlightBounds = []time.Duration{10 * time.Millisecond, 100 * time.Millisecond, time.Second, 10 * time.Second, time.Minute}
+
+// WithFlightMetrics tracks the loads in flight, the callers waiting for them and how long they take, see
+// FlightMetrics and Flights, with the specified histogram bounds or 10ms, 100ms, 1s, 10s and 1m.
+func WithFlightMetrics(bounds ...time.Duration) Option {
+	return func(o *OnceCache) {
+		if len(bounds) == 0 {
+			bounds = defaultFlightBounds
+		}
+		bounds = append([]time.Duration(nil), bounds...)
+		sort.Slice(bounds, func(i, j int) bool { return bounds[i] < bounds[j] })
+		o.flightMetrics = &flightTracker{
+			bounds:  bounds,
+			counts:  make([]atomic.Uint64, len(bounds)+1),
+			flights: make(map[string]*flightGauge),
+		}
+	}
+}
+
+// FlightMetrics is a snapshot of the loads of an OnceCache, see WithFlightMetrics.
+type FlightMetrics struct {
+	// InFlight is the number of keys being loaded.
+	InFlight int
+	// Waiters is the number of callers waiting for loads, including the callers running them.
+	Waiters int
+	// MaxWaiters is the largest number of callers waiting for the load of one key.
+	MaxWaiters int
+	// Oldest is when the longest running load started, zero if none is running. A load running far longer
+	// than the durations below usually has a stuck loader; Flights tells which key it loads.
+	Oldest time.Time
+	// Bounds are the sorted upper bounds of the buckets of completed load durations.
+	Bounds []time.Duration
+	// Durations[i] is the number of completed loads that took at most Bounds[i] but more than Bounds[i-1].
+	// The last count is for loads that took longer than the last bound.
+	Durations []uint64
+}
+
+// Flight is a load in flight, see Flights.
+type Flight struct {
+	Key string
+	// Started is when the load started, zero if the callers are waiting for a load yet to start.
+	Started time.Time
+	// Waiters is the number of callers waiting for the load, including the caller running it.
+	Waiters int
+}
+
+// flightTracker holds the gauges of the keys being loaded or waited for.
+type flightTracker struct {
+	bounds []time.Duration
+	counts []atomic.Uint64
+
+	mu      sync.Mutex
+	flights map[string]*flightGauge
+}
+
+type flightGauge struct {
+	started time.Time
+	loads   int
+	waiters int
+}
+
+// gauge returns the gauge of key, creating it. t.mu must be held.
+func (t *flightTracker) gauge(key string) *flightGauge {
+	g, ok := t.flights[key]
+	if !ok {
+		g = &flightGauge{}
+		t.flights[key] = g
+	}
+	return g
+}
+
+// release drops the gauge of key once nothing refers to it. t.mu must be held.
+func (t *flightTracker) release(key string, g *flightGauge) {
+	if g.loads == 0 && g.waiters == 0 {
+		delete(t.flights, key)
+	}
+}
+
+// join records a caller waiting for the loads of keys and returns the function recording that it stopped.
+func (t *flightTracker) join(keys ...string) func() {
+	if t == nil {
+		return func() {}
+	}
+	t.mu.Lock()
+	for _, key := range keys {
+		t.gauge(key).waiters++
+	}
+	t.mu.Unlock()
+	return func() {
+		t.mu.Lock()
+		for _, key := range keys {
+			g := t.flights[key]
+			g.waiters--
+			t.release(key, g)
+		}
+		t.mu.Unlock()
+	}
+}
+
+// start records the load of keys starting and returns the function recording its end. A batch load
+// counts once in the histogram of durations.
+func (t *flightTracker) start(keys ...string) func() {
+	if t == nil {
+		return func() {}
+	}
+	now := time.Now()
+	t.mu.Lock()
+	for _, key := range keys {
+		g := t.gauge(key)
+		if g.loads == 0 {
+			g.started = now
+		}
+		g.loads++
+	}
+	t.mu.Unlock()
+	return func() {
+		d := time.Since(now)
+		t.counts[sort.Search(len(t.bounds), func(i int) bool { return d <= t.bounds[i] })].Add(1)
+		t.mu.Lock()
+		for _, key := range keys {
+			g := t.flights[key]
+			g.loads--
+			t.release(key, g)
+		}
+		t.mu.Unlock()
+	}
+}
+
+// FlightMetrics returns a snapshot of the loads in flight and of the durations of completed loads.
+// It requires WithFlightMetrics; otherwise it is empty.
+func (o *OnceCache) FlightMetrics() FlightMetrics {
+	t := o.flightMetrics
+	if t == nil {
+		return FlightMetrics{}
+	}
+	m := FlightMetrics{Bounds: append([]time.Duration(nil), t.bounds...), Durations: make([]uint64, len(t.counts))}
+	for i := range t.counts {
+		m.Durations[i] = t.counts[i].Load()
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, g := range t.flights {
+		if g.loads > 0 {
+			m.InFlight++
+			if m.Oldest.IsZero() || g.started.Before(m.Oldest) {
+				m.Oldest = g.started
+			}
+		}
+		m.Waiters += g.waiters
+		m.MaxWaiters = max(m.MaxWaiters, g.waiters)
+	}
+	return m
+}
+
+// Flights returns the loads in flight and the callers waiting for each, longest running first.
+// It requires WithFlightMetrics; otherwise it is empty.
+func (o *OnceCache) Flights() []Flight {
+	t := o.flightMetrics
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	flights := make([]Flight, 0, len(t.flights))
+	for key, g := range t.flights {
+		f := Flight{Key: key, Waiters: g.waiters}
+		if g.loads > 0 {
+			f.Started = g.started
+		}
+		flights = append(flights, f)
+	}
+	t.mu.Unlock()
+	sort.Slice(flights, func(i, j int) bool {
+		a, b := flights[i].Started, flights[j].Started
+		if a.IsZero() != b.IsZero() {
+			return !a.IsZero()
+		}
+		return a.Before(b)
+	})
+	return flights
+}
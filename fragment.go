@@ -0,0 +1,29 @@
+package once_cache
+
+import (
+	"bytes"
+	"html/template"
+	"io"
+	"time"
+)
+
+// RenderFunc renders a fragment to w, such as a template's Execute or a templ component's Render.
+type RenderFunc func(w io.Writer) error
+
+// CachedFragment returns the rendered HTML for key, rendering it with render only when it is not cached.
+// Concurrent requests for the same fragment share one render. Render errors are returned and nothing is stored.
+// The rendered HTML is trusted as is, so render must escape its output, as html/template and templ do.
+func CachedFragment(cache IResultCache, key string, ttl time.Duration, render RenderFunc) (template.HTML, error) {
+	res := cache.GetResult(key, func() (any, error) {
+		var buf bytes.Buffer
+		if err := render(&buf); err != nil {
+			return nil, err
+		}
+		return buf.String(), nil
+	}, WithTTL(ttl))
+	if !res.OK() {
+		return "", res.Err
+	}
+	html, _ := res.Value.(string)
+	return template.HTML(html), nil
+}
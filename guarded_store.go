@@ -0,0 +1,137 @@
+package once_cache
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// ErrStoreTimeout is reported when a store operation does not finish within the timeout set with
+// WithStoreTimeout or WithStoreTimeouts.
+var ErrStoreTimeout = errors.New("once_cache: store operation timed out")
+
+// SlowOp describes a store operation that took at least the threshold of WithSlowOps.
+type SlowOp struct {
+	// Op is "get", "set" or "delete".
+	Op  string
+	Key string
+	// Duration is how long the operation took, or the timeout if it timed out.
+	Duration time.Duration
+	Err      error
+	TimedOut bool
+}
+
+// GuardOption configures a GuardedStore.
+type GuardOption func(*GuardedStore)
+
+// WithStoreTimeout bounds every operation of the store by d.
+func WithStoreTimeout(d time.Duration) GuardOption {
+	return WithStoreTimeouts(d, d, d)
+}
+
+// WithStoreTimeouts bounds the Get, Set and Delete operations of the store. A zero timeout leaves the
+// operation unbounded.
+func WithStoreTimeouts(get, set, del time.Duration) GuardOption {
+	return func(g *GuardedStore) {
+		g.getTimeout, g.setTimeout, g.deleteTimeout = get, set, del
+	}
+}
+
+// WithSlowOps calls handler for every operation taking at least threshold, including those timing out.
+func WithSlowOps(threshold time.Duration, handler func(op SlowOp)) GuardOption {
+	return func(g *GuardedStore) {
+		g.slowThreshold, g.onSlow = threshold, handler
+	}
+}
+
+// LogSlowOps returns a handler for WithSlowOps logging slow operations. If logger is nil, slog.Default() is used.
+func LogSlowOps(logger *slog.Logger) func(op SlowOp) {
+	return func(op SlowOp) {
+		l := logger
+		if l == nil {
+			l = slog.Default()
+		}
+		l.Warn("once_cache: slow store operation", "op", op.Op, "key", op.Key, "duration", op.Duration, "timed_out", op.TimedOut, "error", op.Err)
+	}
+}
+
+// GuardedStore is a struct that implements the ICacheWithError interface over a remote store, bounding how
+// long its operations may take and reporting slow ones, so a degraded backend does not stall the goroutines
+// of GetWithSingleFunc. A timed out Get is reported as ErrStoreTimeout, which OnceCache treats as a miss.
+// Timed out operations keep running in the background until the store returns, so the store's client should
+// still have timeouts of its own.
+type GuardedStore struct {
+	store                                 ICacheWithError
+	getTimeout, setTimeout, deleteTimeout time.Duration
+	slowThreshold                         time.Duration
+	onSlow                                func(op SlowOp)
+}
+
+// storeResult is the outcome of a store operation.
+type storeResult struct {
+	value any
+	ok    bool
+	err   error
+}
+
+// Set stores the value in the store within the set timeout.
+func (g *GuardedStore) Set(key string, value any, d time.Duration) error {
+	return g.run("set", key, g.setTimeout, func() storeResult {
+		return storeResult{err: g.store.Set(key, value, d)}
+	}).err
+}
+
+// Get retrieves the value from the store within the get timeout.
+func (g *GuardedStore) Get(key string) (any, bool, error) {
+	r := g.run("get", key, g.getTimeout, func() storeResult {
+		value, ok, err := g.store.Get(key)
+		return storeResult{value: value, ok: ok, err: err}
+	})
+	return r.value, r.ok, r.err
+}
+
+// Delete removes the key from the store within the delete timeout.
+func (g *GuardedStore) Delete(key string) error {
+	return g.run("delete", key, g.deleteTimeout, func() storeResult {
+		return storeResult{err: g.store.Delete(key)}
+	}).err
+}
+
+// run performs the operation, giving up after timeout if it is positive, and reports it if it is slow.
+func (g *GuardedStore) run(op, key string, timeout time.Duration, f func() storeResult) storeResult {
+	start := time.Now()
+	if timeout <= 0 {
+		r := f()
+		g.slow(op, key, time.Since(start), r.err, false)
+		return r
+	}
+	ch := make(chan storeResult, 1)
+	go func() {
+		ch <- f()
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case r := <-ch:
+		g.slow(op, key, time.Since(start), r.err, false)
+		return r
+	case <-timer.C:
+		g.slow(op, key, timeout, ErrStoreTimeout, true)
+		return storeResult{err: ErrStoreTimeout}
+	}
+}
+
+func (g *GuardedStore) slow(op, key string, d time.Duration, err error, timedOut bool) {
+	if g.onSlow != nil && d >= g.slowThreshold {
+		g.onSlow(SlowOp{Op: op, Key: key, Duration: d, Err: err, TimedOut: timedOut})
+	}
+}
+
+// NewGuardedStore creates a new instance of GuardedStore over store, configured with the specified options.
+func NewGuardedStore(store ICacheWithError, opts ...GuardOption) *GuardedStore {
+	g := &GuardedStore{store: store}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
@@ -0,0 +1,46 @@
+package once_cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// slowStore is an ICacheWithError over a MemoryCache whose Gets take delay.
+type slowStore struct {
+	*MemoryCache
+	delay time.Duration
+}
+
+func (s slowStore) Set(key string, value any, d time.Duration) error {
+	s.MemoryCache.Set(key, value, d)
+	return nil
+}
+
+func (s slowStore) Get(key string) (any, bool, error) {
+	time.Sleep(s.delay)
+	v, ok := s.MemoryCache.Get(key)
+	return v, ok, nil
+}
+
+func (s slowStore) Delete(key string) error {
+	s.MemoryCache.Delete(key)
+	return nil
+}
+
+func TestGuardedStoreTimesOutAndReportsSlowOps(t *testing.T) {
+	var slow []SlowOp
+	store := slowStore{MemoryCache: NewMemoryCache(), delay: 50 * time.Millisecond}
+	g := NewGuardedStore(store, WithStoreTimeouts(10*time.Millisecond, 0, 0), WithSlowOps(0, func(op SlowOp) {
+		slow = append(slow, op)
+	}))
+	if err := g.Set("a", 1, time.Minute); err != nil {
+		t.Fatalf("Set = %v", err)
+	}
+	if _, ok, err := g.Get("a"); ok || !errors.Is(err, ErrStoreTimeout) {
+		t.Fatalf("Get = %v, %v, want ErrStoreTimeout", ok, err)
+	}
+	if len(slow) != 2 || slow[0].Op != "set" || slow[1].Op != "get" || !slow[1].TimedOut {
+		t.Fatalf("slow ops = %+v, want the set and the timed out get", slow)
+	}
+}
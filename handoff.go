@@ -0,0 +1,63 @@
+package once_cache
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+)
+
+// ReceiveHandoff listens on the unix socket at path for one process handing its entries over with
+// SendHandoff, and restores them as Restore does, so that a replacement process starts with a warm cache
+// instead of sending every request of a rolling restart to the origin. It waits until the handoff ends or
+// ctx is done, and returns how many entries were restored. Entries received before a failure are kept.
+// A stale socket left at path by a crashed process is replaced.
+//
+// The handoff is experimental: both processes must run on the same host and agree on the dump options.
+func (c *MemoryCache) ReceiveHandoff(ctx context.Context, path string, opts ...DumpOption) (int, error) {
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+	var lc net.ListenConfig
+	ln, err := lc.Listen(ctx, "unix", path)
+	if err != nil {
+		return 0, err
+	}
+	// Closing the listener also removes the socket file.
+	defer ln.Close()
+	stop := context.AfterFunc(ctx, func() { ln.Close() })
+	defer stop()
+	conn, err := ln.Accept()
+	if err != nil {
+		return 0, handoffError(ctx, err)
+	}
+	defer conn.Close()
+	stopConn := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stopConn()
+	n, err := c.Restore(conn, opts...)
+	return n, handoffError(ctx, err)
+}
+
+// SendHandoff streams the live entries to the process waiting in ReceiveHandoff on the unix socket at
+// path, typically while shutting down, and returns how many entries were sent. It fails at once when no
+// process is waiting, so that shutdown is not delayed.
+func (c *MemoryCache) SendHandoff(ctx context.Context, path string, opts ...DumpOption) (int, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", path)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	n, err := c.Dump(conn, opts...)
+	return n, handoffError(ctx, err)
+}
+
+// handoffError reports the end of ctx rather than the error of the connection it closed.
+func handoffError(ctx context.Context, err error) error {
+	if err != nil && ctx.Err() != nil && errors.Is(err, net.ErrClosed) {
+		return ctx.Err()
+	}
+	return err
+}
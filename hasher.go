@@ -0,0 +1,146 @@
+package once_cache
+
+import (
+	"hash/maphash"
+	"math/bits"
+)
+
+// Hasher hashes keys, for example to pick the shard holding a key.
+type Hasher interface {
+	// Sum64 returns the 64-bit hash of key
+	Sum64(key string) uint64
+}
+
+// HasherFunc is a function that implements the Hasher interface.
+type HasherFunc func(key string) uint64
+
+// Sum64 calls f.
+func (f HasherFunc) Sum64(key string) uint64 {
+	return f(key)
+}
+
+// FNVHasher is a Hasher using 64-bit FNV-1a, the default.
+type FNVHasher struct{}
+
+// Sum64 returns the FNV-1a hash of key.
+func (FNVHasher) Sum64(key string) uint64 {
+	return fnv1a(key)
+}
+
+// XXHasher is a Hasher using 64-bit xxHash with seed 0, as used by many Go caches and proxies.
+type XXHasher struct{}
+
+// Sum64 returns the XXH64 hash of key.
+func (XXHasher) Sum64(key string) uint64 {
+	return xxh64(key)
+}
+
+// MapHasher is a Hasher using hash/maphash. It is the fastest, but its hashes differ between processes,
+// so it does not suit schemes that must agree across a cluster.
+type MapHasher struct {
+	seed maphash.Seed
+}
+
+// Sum64 returns the maphash of key.
+func (h MapHasher) Sum64(key string) uint64 {
+	return maphash.String(h.seed, key)
+}
+
+// NewMapHasher creates a new instance of MapHasher with a random seed.
+func NewMapHasher() MapHasher {
+	return MapHasher{seed: maphash.MakeSeed()}
+}
+
+// ShardFunc maps a key hash to one of n shards.
+type ShardFunc func(hash uint64, n int) int
+
+// ModuloShard picks the shard as the hash modulo n, the default.
+func ModuloShard(hash uint64, n int) int {
+	return int(hash % uint64(n))
+}
+
+// JumpShard picks the shard with jump consistent hashing, which moves only 1/n of the keys when
+// a shard is added, where ModuloShard moves almost all of them.
+func JumpShard(hash uint64, n int) int {
+	var b, j int64 = -1, 0
+	for j < int64(n) {
+		b = j
+		hash = hash*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((hash>>33)+1)))
+	}
+	return int(b)
+}
+
+const (
+	xxPrime1 uint64 = 11400714785074694791
+	xxPrime2 uint64 = 14029467366897019727
+	xxPrime3 uint64 = 1609587929392839161
+	xxPrime4 uint64 = 9650029242287828579
+	xxPrime5 uint64 = 2870177450012600261
+)
+
+// xxh64 hashes a key with XXH64 and seed 0 without allocating.
+func xxh64(key string) uint64 {
+	n := len(key)
+	p := 0
+	var seed, h uint64
+	if n >= 32 {
+		v1 := seed + xxPrime1 + xxPrime2
+		v2 := seed + xxPrime2
+		v3 := seed
+		v4 := seed - xxPrime1
+		for ; p+32 <= n; p += 32 {
+			v1 = xxRound(v1, le64(key[p:]))
+			v2 = xxRound(v2, le64(key[p+8:]))
+			v3 = xxRound(v3, le64(key[p+16:]))
+			v4 = xxRound(v4, le64(key[p+24:]))
+		}
+		h = bits.RotateLeft64(v1, 1) + bits.RotateLeft64(v2, 7) + bits.RotateLeft64(v3, 12) + bits.RotateLeft64(v4, 18)
+		h = xxMerge(h, v1)
+		h = xxMerge(h, v2)
+		h = xxMerge(h, v3)
+		h = xxMerge(h, v4)
+	} else {
+		h = seed + xxPrime5
+	}
+	h += uint64(n)
+	for ; p+8 <= n; p += 8 {
+		h ^= xxRound(0, le64(key[p:]))
+		h = bits.RotateLeft64(h, 27)*xxPrime1 + xxPrime4
+	}
+	if p+4 <= n {
+		h ^= uint64(le32(key[p:])) * xxPrime1
+		h = bits.RotateLeft64(h, 23)*xxPrime2 + xxPrime3
+		p += 4
+	}
+	for ; p < n; p++ {
+		h ^= uint64(key[p]) * xxPrime5
+		h = bits.RotateLeft64(h, 11) * xxPrime1
+	}
+	h ^= h >> 33
+	h *= xxPrime2
+	h ^= h >> 29
+	h *= xxPrime3
+	h ^= h >> 32
+	return h
+}
+
+func xxRound(acc, input uint64) uint64 {
+	acc += input * xxPrime2
+	acc = bits.RotateLeft64(acc, 31)
+	return acc * xxPrime1
+}
+
+func xxMerge(acc, v uint64) uint64 {
+	acc ^= xxRound(0, v)
+	return acc*xxPrime1 + xxPrime4
+}
+
+func le64(s string) uint64 {
+	return uint64(s[0]) | uint64(s[1])<<8 | uint64(s[2])<<16 | uint64(s[3])<<24 |
+		uint64(s[4])<<32 | uint64(s[5])<<40 | uint64(s[6])<<48 | uint64(s[7])<<56
+}
+
+func le32(s string) uint32 {
+	return uint32(s[0]) | uint32(s[1])<<8 | uint32(s[2])<<16 | uint32(s[3])<<24
+}
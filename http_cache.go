@@ -0,0 +1,338 @@
+package once_cache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// CachedResponse is an HTTP response stored by HTTPCache.
+type CachedResponse struct {
+	Status int
+	Header http.Header
+	Body   []byte
+	// ETag is the response's entity tag, the handler's own or one derived from the body.
+	ETag string
+	// StoredAt is when the response was produced.
+	StoredAt time.Time
+	// ExpiresAt is when the cached response expires, zero if it never expires.
+	ExpiresAt time.Time
+}
+
+// HTTPOption configures an HTTPCache.
+type HTTPOption func(*HTTPCache)
+
+// WithMethodTTL caches responses to requests with the method for d. Only GET is cached by default,
+// with the cache's default TTL.
+func WithMethodTTL(method string, d time.Duration) HTTPOption {
+	return func(h *HTTPCache) {
+		h.methods[strings.ToUpper(method)] = d
+	}
+}
+
+// WithRouteTTL overrides the time to live of responses for paths starting with prefix.
+// The longest matching prefix wins.
+func WithRouteTTL(prefix string, d time.Duration) HTTPOption {
+	return func(h *HTTPCache) {
+		h.routes = append(h.routes, routeTTL{prefix: prefix, ttl: d})
+		sort.SliceStable(h.routes, func(i, j int) bool {
+			return len(h.routes[i].prefix) > len(h.routes[j].prefix)
+		})
+	}
+}
+
+// WithVaryHeaders caches a response separately for each combination of values of the request headers.
+// Requests with an Authorization header are only cached when Authorization is varied on.
+func WithVaryHeaders(names ...string) HTTPOption {
+	return func(h *HTTPCache) {
+		for _, name := range names {
+			h.varyHeaders = append(h.varyHeaders, http.CanonicalHeaderKey(name))
+		}
+	}
+}
+
+// WithVaryCookies caches a response separately for each combination of values of the cookies.
+func WithVaryCookies(names ...string) HTTPOption {
+	return func(h *HTTPCache) {
+		h.varyCookies = append(h.varyCookies, names...)
+	}
+}
+
+// WithVaryQuery restricts the query parameters that distinguish cached responses to params.
+// By default the whole query is used, independently of the order of parameters.
+func WithVaryQuery(params ...string) HTTPOption {
+	return func(h *HTTPCache) {
+		h.varyQuery = append(h.varyQuery, params...)
+		h.queryRestricted = true
+	}
+}
+
+// WithSkip bypasses the cache for requests matching skip.
+func WithSkip(skip func(r *http.Request) bool) HTTPOption {
+	return func(h *HTTPCache) {
+		h.skips = append(h.skips, skip)
+	}
+}
+
+type routeTTL struct {
+	prefix string
+	ttl    time.Duration
+}
+
+// HTTPCache caches HTTP responses in an IResultCache. Concurrent identical requests are served by one
+// call to the handler. Only successful responses without Cache-Control no-store or private and without
+// cookies are stored; requests that were waiting for a response that is not stored call the handler
+// themselves, since the response may be meant for one user only.
+//
+// Cached responses carry ETag, Age, Vary and, unless the handler set its own, Cache-Control max-age headers
+// matching the cache entry, so that browsers and CDNs share its freshness, and requests with a matching
+// If-None-Match are answered with 304 Not Modified.
+type HTTPCache struct {
+	cache           IResultCache
+	methods         map[string]time.Duration
+	routes          []routeTTL
+	varyHeaders     []string
+	varyCookies     []string
+	varyQuery       []string
+	queryRestricted bool
+	skips           []func(r *http.Request) bool
+}
+
+// Middleware returns a handler serving cacheable requests from the cache and the others with next.
+func (h *HTTPCache) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ttl, ok := h.cacheable(r)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		// own is the response to this request when its handler ran here and the response is not shared.
+		var own atomic.Pointer[CachedResponse]
+		res := h.cache.GetResult(h.key(r), func() (any, error) {
+			rec := newResponseRecorder()
+			next.ServeHTTP(rec, r)
+			resp := rec.response()
+			if !storable(resp) {
+				own.Store(resp)
+				return unstored{}, nil
+			}
+			h.stamp(resp, ttl)
+			return resp, nil
+		}, WithTTL(ttl))
+		if resp := own.Load(); resp != nil {
+			writeResponse(w, resp)
+			return
+		}
+		if resp, ok := res.Value.(*CachedResponse); ok {
+			h.writeCached(w, r, resp)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// cacheable reports whether the request may be served from the cache, and with which time to live.
+func (h *HTTPCache) cacheable(r *http.Request) (time.Duration, bool) {
+	ttl, ok := h.methods[r.Method]
+	if !ok {
+		return 0, false
+	}
+	if r.Header.Get("Authorization") != "" && !h.variesOn("Authorization") {
+		return 0, false
+	}
+	for _, skip := range h.skips {
+		if skip(r) {
+			return 0, false
+		}
+	}
+	for _, route := range h.routes {
+		if strings.HasPrefix(r.URL.Path, route.prefix) {
+			return route.ttl, true
+		}
+	}
+	return ttl, true
+}
+
+func (h *HTTPCache) variesOn(header string) bool {
+	for _, name := range h.varyHeaders {
+		if name == header {
+			return true
+		}
+	}
+	return false
+}
+
+// key builds the cache key of a request from its method, host, path and varied parts.
+func (h *HTTPCache) key(r *http.Request) string {
+	k := NewKeyBuilder("http").String(r.Method).String(r.Host).String(r.URL.Path)
+	query := r.URL.Query()
+	if h.queryRestricted {
+		restricted := url.Values{}
+		for _, param := range h.varyQuery {
+			if values, ok := query[param]; ok {
+				restricted[param] = values
+			}
+		}
+		query = restricted
+	}
+	// Encode sorts the parameters, so their order in the request does not matter.
+	k.String(query.Encode())
+	for _, name := range h.varyHeaders {
+		values := r.Header.Values(name)
+		k.Int(int64(len(values)))
+		for _, v := range values {
+			k.String(v)
+		}
+	}
+	for _, name := range h.varyCookies {
+		if c, err := r.Cookie(name); err == nil {
+			k.String(c.Value)
+		} else {
+			k.Part(nil)
+		}
+	}
+	return k.Key()
+}
+
+// stamp records the freshness metadata of a response about to be stored for ttl.
+func (h *HTTPCache) stamp(resp *CachedResponse, ttl time.Duration) {
+	resp.StoredAt = time.Now()
+	if d, ok := h.cache.(interface{ DefaultTTL() time.Duration }); ok && ttl == 0 {
+		// The cache stores the response for its default TTL, as OnceCache does.
+		ttl = d.DefaultTTL()
+	}
+	if ttl > 0 {
+		resp.ExpiresAt = resp.StoredAt.Add(ttl)
+	}
+	resp.ETag = resp.Header.Get("ETag")
+	if resp.ETag == "" {
+		sum := sha256.Sum256(resp.Body)
+		resp.ETag = `"` + hex.EncodeToString(sum[:16]) + `"`
+	}
+}
+
+// writeCached writes a cached response with its freshness headers, or 304 Not Modified if the client
+// already has it.
+func (h *HTTPCache) writeCached(w http.ResponseWriter, r *http.Request, resp *CachedResponse) {
+	copyHeader(w, resp)
+	header := w.Header()
+	now := time.Now()
+	header.Set("ETag", resp.ETag)
+	header.Set("Age", strconv.FormatInt(int64(now.Sub(resp.StoredAt)/time.Second), 10))
+	if resp.Header.Get("Cache-Control") == "" && !resp.ExpiresAt.IsZero() {
+		maxAge := max(0, int64(resp.ExpiresAt.Sub(now)/time.Second))
+		header.Set("Cache-Control", "max-age="+strconv.FormatInt(maxAge, 10))
+	}
+	if vary := h.vary(); vary != "" {
+		header.Set("Vary", vary)
+	}
+	if etagMatches(r.Header.Get("If-None-Match"), resp.ETag) {
+		header.Del("Content-Length")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.WriteHeader(resp.Status)
+	w.Write(resp.Body)
+}
+
+// vary returns the Vary header of cached responses.
+func (h *HTTPCache) vary() string {
+	vary := append([]string(nil), h.varyHeaders...)
+	if len(h.varyCookies) > 0 {
+		vary = append(vary, "Cookie")
+	}
+	return strings.Join(vary, ", ")
+}
+
+// etagMatches reports whether an If-None-Match header matches etag, using weak comparison.
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// storable reports whether a response may be stored.
+func storable(resp *CachedResponse) bool {
+	if resp.Status != http.StatusOK {
+		return false
+	}
+	if len(resp.Header.Values("Set-Cookie")) > 0 {
+		return false
+	}
+	cc := strings.ToLower(strings.Join(resp.Header.Values("Cache-Control"), ","))
+	return !strings.Contains(cc, "no-store") && !strings.Contains(cc, "private")
+}
+
+func writeResponse(w http.ResponseWriter, resp *CachedResponse) {
+	copyHeader(w, resp)
+	w.WriteHeader(resp.Status)
+	w.Write(resp.Body)
+}
+
+func copyHeader(w http.ResponseWriter, resp *CachedResponse) {
+	header := w.Header()
+	for name, values := range resp.Header {
+		header[name] = append([]string(nil), values...)
+	}
+}
+
+// responseRecorder is a struct that implements the http.ResponseWriter interface by buffering the response.
+type responseRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newResponseRecorder() *responseRecorder {
+	return &responseRecorder{header: make(http.Header)}
+}
+
+func (r *responseRecorder) Header() http.Header {
+	return r.header
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.WriteHeader(http.StatusOK)
+	return r.body.Write(b)
+}
+
+func (r *responseRecorder) response() *CachedResponse {
+	status := r.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	return &CachedResponse{Status: status, Header: r.header.Clone(), Body: r.body.Bytes()}
+}
+
+// NewHTTPCache creates a new instance of HTTPCache storing responses in cache.
+func NewHTTPCache(cache IResultCache, opts ...HTTPOption) *HTTPCache {
+	h := &HTTPCache{
+		cache:   cache,
+		methods: map[string]time.Duration{http.MethodGet: 0},
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
@@ -0,0 +1,53 @@
+package once_cache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+func TestHTTPCacheDoesNotShareUncacheableResponses(t *testing.T) {
+	cache := NewOnceCache(&singleflight.Group{}, NewMemoryCache(), WithDefaultTTL(time.Minute))
+	var calls atomic.Int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := NewHTTPCache(cache).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			close(started)
+			<-release
+		}
+		w.Header().Set("Cache-Control", "private")
+		w.Write([]byte(r.Header.Get("X-User")))
+	}))
+
+	bodies := make([]string, 2)
+	var wg sync.WaitGroup
+	serve := func(i int, user string) {
+		defer wg.Done()
+		req := httptest.NewRequest(http.MethodGet, "/profile", nil)
+		req.Header.Set("X-User", user)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		bodies[i] = rec.Body.String()
+	}
+	wg.Add(2)
+	go serve(0, "alice")
+	<-started
+	go serve(1, "bob")
+	// Let the second request join the flight of the first before the first handler returns.
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if bodies[0] != "alice" || bodies[1] != "bob" {
+		t.Fatalf("bodies = %q, want [alice bob]", bodies)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Fatalf("handler called %d times, want 2", n)
+	}
+}
@@ -0,0 +1,189 @@
+package once_cache
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HTTPKVOption configures an HTTPKVCache.
+type HTTPKVOption func(*HTTPKVCache)
+
+// WithKVClient sends the requests with client instead of http.DefaultClient.
+func WithKVClient(client *http.Client) HTTPKVOption {
+	return func(c *HTTPKVCache) {
+		c.client = client
+	}
+}
+
+// WithKVURLs sets the URL templates of the get, put and delete requests, replacing the base URL
+// followed by the key. In a template, {key} is replaced by the escaped key and {ttl} by the time
+// to live in whole seconds, empty for entries that never expire.
+func WithKVURLs(get, put, del string) HTTPKVOption {
+	return func(c *HTTPKVCache) {
+		c.getURL, c.putURL, c.deleteURL = get, put, del
+	}
+}
+
+// WithKVAuth sends the header with every request, such as "Authorization" with "Bearer <token>".
+func WithKVAuth(name, value string) HTTPKVOption {
+	return func(c *HTTPKVCache) {
+		c.header.Set(name, value)
+	}
+}
+
+// WithKVTTLHeader sends the time to live of written entries in whole seconds in the header.
+func WithKVTTLHeader(name string) HTTPKVOption {
+	return func(c *HTTPKVCache) {
+		c.ttlHeader = name
+	}
+}
+
+// HTTPKVCache is a struct that implements the ICacheWithError interface on top of a REST key-value service,
+// such as Cloudflare Workers KV, reading with GET, writing with PUT and removing with DELETE.
+// A 404 Not Found response to a GET is a miss, and to a DELETE is success.
+type HTTPKVCache struct {
+	client    *http.Client
+	getURL    string
+	putURL    string
+	deleteURL string
+	header    http.Header
+	ttlHeader string
+	codec     Codec
+}
+
+// Set writes the value with the specified time to live, rounded up to whole seconds.
+// A non-positive duration never expires.
+func (c *HTTPKVCache) Set(key string, value any, d time.Duration) error {
+	data, err := marshalValue(c.codec, value, d)
+	if err != nil {
+		return err
+	}
+	return c.SetBytes(key, data, d)
+}
+
+// SetBytes writes the raw value, bypassing the codec.
+func (c *HTTPKVCache) SetBytes(key string, data []byte, d time.Duration) error {
+	ttl := ""
+	if d > 0 {
+		ttl = strconv.FormatInt(int64((d+time.Second-1)/time.Second), 10)
+	}
+	req, err := c.request(http.MethodPut, c.putURL, key, ttl, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	if c.ttlHeader != "" && ttl != "" {
+		req.Header.Set(c.ttlHeader, ttl)
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	if resp == nil {
+		return fmt.Errorf("once_cache: PUT %s: %s", req.URL.Redacted(), http.StatusText(http.StatusNotFound))
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Get reads the value for the key.
+func (c *HTTPKVCache) Get(key string) (any, bool, error) {
+	data, ok, err := c.GetBytes(key)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	value, err := c.codec.Unmarshal(data)
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// GetBytes reads the raw value, bypassing the codec.
+func (c *HTTPKVCache) GetBytes(key string) ([]byte, bool, error) {
+	req, err := c.request(http.MethodGet, c.getURL, key, "", nil)
+	if err != nil {
+		return nil, false, err
+	}
+	resp, err := c.do(req)
+	if err != nil || resp == nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+// Delete removes the key.
+func (c *HTTPKVCache) Delete(key string) error {
+	req, err := c.request(http.MethodDelete, c.deleteURL, key, "", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(req)
+	if err != nil || resp == nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (c *HTTPKVCache) request(method, template, key, ttl string, body io.Reader) (*http.Request, error) {
+	target := strings.NewReplacer("{key}", url.PathEscape(key), "{ttl}", ttl).Replace(template)
+	req, err := http.NewRequestWithContext(context.Background(), method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range c.header {
+		req.Header[name] = values
+	}
+	return req, nil
+}
+
+// do sends the request, returning a nil response for 404 Not Found and an error for other failures.
+func (c *HTTPKVCache) do(req *http.Request) (*http.Response, error) {
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("once_cache: %s %s: %s", req.Method, req.URL.Redacted(), resp.Status)
+	}
+	return resp, nil
+}
+
+// NewHTTPKVCache creates a new instance of HTTPKVCache for the service at baseURL, addressing each key
+// as baseURL followed by the escaped key unless WithKVURLs is used.
+// Values are serialized with codec, or GobCodec wrapped in an EnvelopeCodec if codec is nil.
+func NewHTTPKVCache(baseURL string, codec Codec, opts ...HTTPKVOption) *HTTPKVCache {
+	if codec == nil {
+		codec = defaultCodec()
+	}
+	template := strings.TrimSuffix(baseURL, "/") + "/{key}"
+	c := &HTTPKVCache{
+		client:    http.DefaultClient,
+		getURL:    template,
+		putURL:    template,
+		deleteURL: template,
+		header:    make(http.Header),
+		codec:     codec,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
@@ -0,0 +1,264 @@
+package once_cache
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// transportVaryHeaders are the request headers that distinguish responses cached by CachingTransport.
+var transportVaryHeaders = []string{"Accept", "Accept-Encoding", "Accept-Language"}
+
+// TransportOption configures a CachingTransport.
+type TransportOption func(*CachingTransport)
+
+// WithTransportRetention keeps responses in the cache for d beyond their freshness, so that they can be
+// revalidated with their ETag or Last-Modified instead of being downloaded again. It defaults to an hour.
+func WithTransportRetention(d time.Duration) TransportOption {
+	return func(t *CachingTransport) {
+		t.retention = d
+	}
+}
+
+// WithTransportDefaultTTL sets the freshness of responses that carry neither Cache-Control max-age nor
+// Expires. By default such responses are only cached if they can be revalidated.
+func WithTransportDefaultTTL(d time.Duration) TransportOption {
+	return func(t *CachingTransport) {
+		t.defaultTTL = d
+	}
+}
+
+// CachingTransport is a struct that implements the http.RoundTripper interface by caching the responses
+// to GET requests in an IResultCache, as a shared HTTP cache would: freshness comes from Cache-Control
+// max-age or s-maxage and Expires, no-store and private responses are not stored, and stale responses are
+// revalidated with If-None-Match and If-Modified-Since. Responses are keyed on the Accept, Accept-Encoding
+// and Accept-Language request headers, and those varying on other headers are not stored. Concurrent identical requests share one upstream
+// request, which is not canceled when the request that started it is; responses that are not stored are
+// not shared either. Requests with Authorization or Cookie headers and conditional requests, which carry
+// the validators of the caller's own copy, are not cached.
+type CachingTransport struct {
+	cache      IResultCache
+	base       http.RoundTripper
+	retention  time.Duration
+	defaultTTL time.Duration
+}
+
+// RoundTrip serves the request from the cache when possible and from the base transport otherwise.
+func (t *CachingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !t.cacheable(req) {
+		return t.base.RoundTrip(req)
+	}
+	key := t.key(req)
+	// own is the response fetched for this request when it must be neither stored nor shared.
+	var own atomic.Pointer[CachedResponse]
+	// lifetime is how long to keep the response this request fetched and stored, if it did.
+	var lifetime time.Duration
+	res := t.cache.GetResult(key, func() (any, error) {
+		return t.fetch(req, nil, &own, &lifetime)
+	}, WithTTL(t.retention))
+	t.extend(key, res, lifetime)
+	cached, ok := res.Value.(*CachedResponse)
+	if ok && res.Hit && t.mustRevalidate(req, cached) {
+		stale := cached
+		res = t.cache.GetResult(key, func() (any, error) {
+			return t.fetch(req, stale, &own, &lifetime)
+		}, WithTTL(t.retention), WithForceRefresh())
+		if res.Err != nil {
+			// The origin cannot be reached, serve the stale response rather than failing.
+			return toResponse(req, stale), nil
+		}
+		t.extend(key, res, lifetime)
+		cached, ok = res.Value.(*CachedResponse)
+	}
+	if resp := own.Load(); resp != nil {
+		return toResponse(req, resp), nil
+	}
+	if ok {
+		return toResponse(req, cached), nil
+	}
+	if res.Err != nil {
+		return nil, res.Err
+	}
+	// The shared request got a response that is not stored; make this request on its own.
+	return t.base.RoundTrip(req)
+}
+
+// extend stores again a response the request fetched for lifetime, its freshness plus the retention, since
+// the load stored it for the retention alone.
+func (t *CachingTransport) extend(key string, res Result, lifetime time.Duration) {
+	if res.Err == nil && !res.Hit && lifetime > t.retention {
+		if cached, ok := res.Value.(*CachedResponse); ok {
+			t.cache.Set(key, cached, lifetime)
+		}
+	}
+}
+
+// mustRevalidate reports whether a cached response is stale or the request demands revalidation.
+func (t *CachingTransport) mustRevalidate(req *http.Request, cached *CachedResponse) bool {
+	return !time.Now().Before(cached.ExpiresAt) ||
+		strings.Contains(strings.ToLower(req.Header.Get("Cache-Control")), "no-cache")
+}
+
+// fetch sends the request upstream, conditionally if a stale response is given, and returns the response
+// to store, setting lifetime to how long to keep it, or unstored after keeping in own a response not to
+// store. The request runs without the cancellation of req, since other callers may be waiting for it.
+func (t *CachingTransport) fetch(req *http.Request, stale *CachedResponse, own *atomic.Pointer[CachedResponse], lifetime *time.Duration) (any, error) {
+	out := req.Clone(context.WithoutCancel(req.Context()))
+	if stale != nil {
+		if stale.ETag != "" {
+			out.Header.Set("If-None-Match", stale.ETag)
+		}
+		if lm := stale.Header.Get("Last-Modified"); lm != "" {
+			out.Header.Set("If-Modified-Since", lm)
+		}
+	}
+	resp, err := t.base.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if stale != nil && resp.StatusCode == http.StatusNotModified {
+		// Refresh the stored response with the new freshness metadata.
+		refreshed := *stale
+		refreshed.Header = stale.Header.Clone()
+		for name, values := range resp.Header {
+			refreshed.Header[name] = values
+		}
+		t.stamp(&refreshed)
+		*lifetime = t.lifetime(&refreshed)
+		return &refreshed, nil
+	}
+	cached := &CachedResponse{Status: resp.StatusCode, Header: resp.Header.Clone(), Body: body, ETag: resp.Header.Get("ETag")}
+	if !storable(cached) || !varyCovered(resp.Header) || !t.stamp(cached) {
+		own.Store(cached)
+		return unstored{}, nil
+	}
+	*lifetime = t.lifetime(cached)
+	return cached, nil
+}
+
+// lifetime returns how long to store a stamped response: its freshness plus the retention.
+func (t *CachingTransport) lifetime(resp *CachedResponse) time.Duration {
+	return time.Until(resp.ExpiresAt) + t.retention
+}
+
+// varyCovered reports whether the request headers a response varies on are all part of the cache key.
+func varyCovered(header http.Header) bool {
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if name != "" && !slices.Contains(transportVaryHeaders, name) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// stamp sets the freshness of a response from its headers and reports whether it is worth storing.
+func (t *CachingTransport) stamp(resp *CachedResponse) bool {
+	now := time.Now()
+	resp.StoredAt = now
+	cc := strings.ToLower(strings.Join(resp.Header.Values("Cache-Control"), ","))
+	ttl, ok := maxAge(cc, "s-maxage")
+	if !ok {
+		ttl, ok = maxAge(cc, "max-age")
+	}
+	if !ok {
+		if expires, err := http.ParseTime(resp.Header.Get("Expires")); err == nil {
+			ttl, ok = expires.Sub(now), true
+		}
+	}
+	if !ok {
+		ttl = t.defaultTTL
+	}
+	if strings.Contains(cc, "no-cache") {
+		ttl = 0
+	}
+	resp.ExpiresAt = now.Add(max(0, ttl))
+	revalidatable := resp.ETag != "" || resp.Header.Get("Last-Modified") != ""
+	return ttl > 0 || revalidatable
+}
+
+// maxAge parses a Cache-Control directive holding a number of seconds.
+func maxAge(cc, directive string) (time.Duration, bool) {
+	for _, part := range strings.Split(cc, ",") {
+		name, value, found := strings.Cut(strings.TrimSpace(part), "=")
+		if !found || name != directive {
+			continue
+		}
+		seconds, err := strconv.ParseInt(strings.Trim(value, `"`), 10, 64)
+		if err != nil {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	return 0, false
+}
+
+func (t *CachingTransport) cacheable(req *http.Request) bool {
+	if req.Method != http.MethodGet || req.Header.Get("Range") != "" {
+		return false
+	}
+	if req.Header.Get("Authorization") != "" || req.Header.Get("Cookie") != "" {
+		return false
+	}
+	if req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != "" {
+		return false
+	}
+	return !strings.Contains(strings.ToLower(req.Header.Get("Cache-Control")), "no-store")
+}
+
+// key builds the cache key of a request from its URL and the negotiation headers.
+func (t *CachingTransport) key(req *http.Request) string {
+	k := NewKeyBuilder("transport").String(req.URL.String())
+	for _, name := range transportVaryHeaders {
+		k.String(req.Header.Get(name))
+	}
+	return k.Key()
+}
+
+// toResponse builds the response to a request from a cached response.
+func toResponse(req *http.Request, cached *CachedResponse) *http.Response {
+	header := cached.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	if !cached.StoredAt.IsZero() {
+		header.Set("Age", strconv.FormatInt(int64(time.Since(cached.StoredAt)/time.Second), 10))
+	}
+	return &http.Response{
+		Status:        strconv.Itoa(cached.Status) + " " + http.StatusText(cached.Status),
+		StatusCode:    cached.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(cached.Body)),
+		ContentLength: int64(len(cached.Body)),
+		Request:       req,
+	}
+}
+
+// NewCachingTransport creates a new instance of CachingTransport caching the responses of base, or of
+// http.DefaultTransport if base is nil, in cache.
+func NewCachingTransport(cache IResultCache, base http.RoundTripper, opts ...TransportOption) *CachingTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	t := &CachingTransport{cache: cache, base: base, retention: time.Hour}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
@@ -0,0 +1,195 @@
+package once_cache
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func textResponse(req *http.Request, status int, header http.Header, body string) *http.Response {
+	if header == nil {
+		header = make(http.Header)
+	}
+	return &http.Response{StatusCode: status, Header: header, Body: io.NopCloser(bytes.NewBufferString(body)), Request: req}
+}
+
+func newTestTransport(base http.RoundTripper) *CachingTransport {
+	return NewCachingTransport(NewOnceCache(&singleflight.Group{}, NewMemoryCache()), base)
+}
+
+func readBody(t *testing.T, resp *http.Response) string {
+	t.Helper()
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+// concurrentGets sends two requests for url, the second while the upstream request of the first is running.
+func concurrentGets(t *testing.T, tr http.RoundTripper, started, release chan struct{}, prepare func(i int, req *http.Request)) []*http.Response {
+	t.Helper()
+	resps := make([]*http.Response, 2)
+	errs := make([]error, 2)
+	var wg sync.WaitGroup
+	get := func(i int) {
+		defer wg.Done()
+		req, _ := http.NewRequest(http.MethodGet, "http://example.com/profile", nil)
+		prepare(i, req)
+		resps[i], errs[i] = tr.RoundTrip(req)
+	}
+	wg.Add(2)
+	go get(0)
+	<-started
+	go get(1)
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resps
+}
+
+func TestCachingTransportDoesNotShareUncacheableResponses(t *testing.T) {
+	var calls atomic.Int32
+	started, release := make(chan struct{}), make(chan struct{})
+	tr := newTestTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if calls.Add(1) == 1 {
+			close(started)
+			<-release
+		}
+		return textResponse(req, http.StatusOK, http.Header{"Cache-Control": {"private"}}, req.Header.Get("X-User")), nil
+	}))
+	users := []string{"alice", "bob"}
+	resps := concurrentGets(t, tr, started, release, func(i int, req *http.Request) {
+		req.Header.Set("X-User", users[i])
+	})
+	for i, resp := range resps {
+		if body := readBody(t, resp); body != users[i] {
+			t.Fatalf("response %d = %q, want %q", i, body, users[i])
+		}
+	}
+}
+
+func TestCachingTransportPassesConditionalRequestsThrough(t *testing.T) {
+	var calls atomic.Int32
+	tr := newTestTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		calls.Add(1)
+		if req.Header.Get("If-None-Match") == `"v1"` {
+			return textResponse(req, http.StatusNotModified, nil, ""), nil
+		}
+		return textResponse(req, http.StatusOK, http.Header{"Cache-Control": {"max-age=60"}, "Etag": {`"v1"`}}, "full"), nil
+	}))
+	get := func(ifNoneMatch string) *http.Response {
+		req, _ := http.NewRequest(http.MethodGet, "http://example.com/doc", nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		resp, err := tr.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	if body := readBody(t, get("")); body != "full" {
+		t.Fatalf("first response = %q", body)
+	}
+	if resp := get(`"v1"`); resp.StatusCode != http.StatusNotModified {
+		t.Fatalf("conditional response status = %d, want 304", resp.StatusCode)
+	}
+	if resp := get(""); resp.StatusCode != http.StatusOK || readBody(t, resp) != "full" {
+		t.Fatalf("unconditional response after a conditional one is not the full cached response")
+	}
+	if n := calls.Load(); n != 2 {
+		t.Fatalf("upstream called %d times, want 2", n)
+	}
+}
+
+func TestCachingTransportSharedFetchSurvivesCancellation(t *testing.T) {
+	var calls atomic.Int32
+	started, release := make(chan struct{}), make(chan struct{})
+	tr := newTestTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if calls.Add(1) == 1 {
+			close(started)
+			select {
+			case <-release:
+			case <-req.Context().Done():
+				return nil, req.Context().Err()
+			}
+		}
+		return textResponse(req, http.StatusOK, http.Header{"Cache-Control": {"max-age=60"}}, "shared"), nil
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	resps := concurrentGets(t, tr, started, release, func(i int, req *http.Request) {
+		if i == 0 {
+			*req = *req.WithContext(ctx)
+			go func() {
+				<-started
+				cancel()
+			}()
+		}
+	})
+	if body := readBody(t, resps[1]); body != "shared" {
+		t.Fatalf("second response = %q, want shared", body)
+	}
+}
+
+func TestCachingTransportDoesNotStoreResponsesVaryingOnOtherHeaders(t *testing.T) {
+	var calls atomic.Int32
+	tr := newTestTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		calls.Add(1)
+		header := http.Header{"Cache-Control": {"max-age=60"}, "Vary": {"Accept-Encoding, X-Tenant"}}
+		return textResponse(req, http.StatusOK, header, req.Header.Get("X-Tenant")), nil
+	}))
+	for _, tenant := range []string{"a", "b"} {
+		req, _ := http.NewRequest(http.MethodGet, "http://example.com/config", nil)
+		req.Header.Set("X-Tenant", tenant)
+		resp, err := tr.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body := readBody(t, resp); body != tenant {
+			t.Fatalf("tenant %s got %q", tenant, body)
+		}
+	}
+	if n := calls.Load(); n != 2 {
+		t.Fatalf("upstream calls = %d, want each tenant to reach it", n)
+	}
+}
+
+func TestCachingTransportStoresResponsesForFreshnessPlusRetention(t *testing.T) {
+	store := NewMemoryCache()
+	tr := NewCachingTransport(NewOnceCache(&singleflight.Group{}, store), roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return textResponse(req, http.StatusOK, http.Header{"Cache-Control": {"max-age=86400"}}, "v"), nil
+	}), WithTransportRetention(time.Hour))
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/static", nil)
+	resp, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	readBody(t, resp)
+	_, info, ok := store.GetWithInfo(tr.key(req))
+	if !ok {
+		t.Fatal("the response was not stored")
+	}
+	if d := time.Until(info.ExpiresAt); d < 24*time.Hour || d > 25*time.Hour {
+		t.Fatalf("response stored for %v, want a day of freshness plus an hour of retention", d)
+	}
+}
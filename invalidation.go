@@ -0,0 +1,178 @@
+package once_cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+)
+
+// invalidationDedupSize is how many recent invalidation IDs a bus remembers to skip redelivered messages.
+const invalidationDedupSize = 4096
+
+// Invalidation is a message asking every cache instance to delete keys or tags.
+type Invalidation struct {
+	// ID identifies the message, so redelivered messages are applied once.
+	ID string `json:"id"`
+	// Origin identifies the publishing instance, which already applied the invalidation.
+	Origin string `json:"origin"`
+	// Keys are the keys to delete.
+	Keys []string `json:"keys"`
+	// Tags are the tags whose entries to delete, for caches implementing ITagInvalidator.
+	Tags []string `json:"tags,omitempty"`
+	// Reason is why the keys and tags are invalidated, recorded by caches with an audit sink.
+	Reason string `json:"reason,omitempty"`
+}
+
+// InvalidationTransport carries invalidations between cache instances.
+type InvalidationTransport interface {
+	// Publish sends an invalidation to all instances
+	Publish(ctx context.Context, inv Invalidation) error
+
+	// Subscribe delivers invalidations to handler until ctx is done or the transport fails.
+	// A message is acknowledged once handler returns nil.
+	Subscribe(ctx context.Context, handler func(ctx context.Context, inv Invalidation) error) error
+}
+
+// InvalidationBus deletes keys from a local cache and fans the invalidation out to the other instances
+// through a transport, applying the invalidations they publish in turn.
+type InvalidationBus struct {
+	cache     ICache
+	transport InvalidationTransport
+	origin    string
+
+	mu       sync.Mutex
+	seen     map[string]struct{}
+	seenRing []string
+	next     int
+}
+
+// Invalidate deletes the keys locally and publishes the invalidation to the other instances.
+func (b *InvalidationBus) Invalidate(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		b.cache.Delete(key)
+	}
+	inv := Invalidation{ID: randomID(), Origin: b.origin, Keys: keys}
+	b.markSeen(inv.ID)
+	return b.transport.Publish(ctx, inv)
+}
+
+// InvalidateTags deletes the entries with the tags locally and publishes the invalidation to the other instances.
+// The local cache must implement ITagInvalidator, as MemoryCache and OnceCache do.
+func (b *InvalidationBus) InvalidateTags(ctx context.Context, tags ...string) error {
+	b.deleteTags(tags)
+	inv := Invalidation{ID: randomID(), Origin: b.origin, Tags: tags}
+	b.markSeen(inv.ID)
+	return b.transport.Publish(ctx, inv)
+}
+
+// InvalidateBecause is Invalidate recording reason in the audit log of every instance, see WithAuditSink.
+func (b *InvalidationBus) InvalidateBecause(ctx context.Context, reason string, keys ...string) error {
+	inv := Invalidation{ID: randomID(), Origin: b.origin, Keys: keys, Reason: reason}
+	b.delete(inv)
+	b.markSeen(inv.ID)
+	return b.transport.Publish(ctx, inv)
+}
+
+// InvalidateAfter runs the write fn and, only if it succeeds, invalidates the keys locally and on the other
+// instances. Invalidating after the write, rather than before, keeps a concurrent load from caching the data
+// the write replaces. The error of fn is returned as is.
+func (b *InvalidationBus) InvalidateAfter(ctx context.Context, fn func() error, keys ...string) error {
+	if err := fn(); err != nil {
+		return err
+	}
+	return b.Invalidate(ctx, keys...)
+}
+
+// InvalidateTagsAfter is InvalidateAfter invalidating the entries with the tags.
+func (b *InvalidationBus) InvalidateTagsAfter(ctx context.Context, fn func() error, tags ...string) error {
+	if err := fn(); err != nil {
+		return err
+	}
+	return b.InvalidateTags(ctx, tags...)
+}
+
+func (b *InvalidationBus) deleteTags(tags []string) {
+	if t, ok := b.cache.(ITagInvalidator); ok {
+		for _, tag := range tags {
+			t.DeleteTag(tag)
+		}
+	}
+}
+
+// Run applies invalidations published by other instances until ctx is done or the transport fails.
+func (b *InvalidationBus) Run(ctx context.Context) error {
+	return b.transport.Subscribe(ctx, func(ctx context.Context, inv Invalidation) error {
+		b.apply(inv)
+		return nil
+	})
+}
+
+// apply deletes the keys of an invalidation. Deletes are idempotent, so replays are harmless,
+// but recently seen messages are skipped altogether.
+func (b *InvalidationBus) apply(inv Invalidation) {
+	if inv.Origin == b.origin || !b.markSeen(inv.ID) {
+		return
+	}
+	b.delete(inv)
+}
+
+// reasonDeleter is implemented by caches recording why entries are deleted, such as OnceCache.
+type reasonDeleter interface {
+	DeleteWithReason(key string, reason string)
+	DeleteTagWithReason(tag string, reason string) int
+}
+
+// delete deletes the keys and tags of an invalidation locally.
+func (b *InvalidationBus) delete(inv Invalidation) {
+	if d, ok := b.cache.(reasonDeleter); ok {
+		for _, key := range inv.Keys {
+			d.DeleteWithReason(key, inv.Reason)
+		}
+		for _, tag := range inv.Tags {
+			d.DeleteTagWithReason(tag, inv.Reason)
+		}
+		return
+	}
+	for _, key := range inv.Keys {
+		b.cache.Delete(key)
+	}
+	b.deleteTags(inv.Tags)
+}
+
+// markSeen records id and reports whether it was new.
+func (b *InvalidationBus) markSeen(id string) bool {
+	if id == "" {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.seen[id]; ok {
+		return false
+	}
+	if old := b.seenRing[b.next]; old != "" {
+		delete(b.seen, old)
+	}
+	b.seenRing[b.next] = id
+	b.next = (b.next + 1) % len(b.seenRing)
+	b.seen[id] = struct{}{}
+	return true
+}
+
+// NewInvalidationBus creates a new instance of InvalidationBus over the local cache and transport.
+// Call Run to start applying invalidations from other instances.
+func NewInvalidationBus(cache ICache, transport InvalidationTransport) *InvalidationBus {
+	return &InvalidationBus{
+		cache:     cache,
+		transport: transport,
+		origin:    randomID(),
+		seen:      make(map[string]struct{}, invalidationDedupSize),
+		seenRing:  make([]string, invalidationDedupSize),
+	}
+}
+
+func randomID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,129 @@
+package once_cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryTransport is an InvalidationTransport delivering every published invalidation to its subscribers.
+type memoryTransport struct {
+	mu        sync.Mutex
+	subs      []chan Invalidation
+	published int
+}
+
+func (m *memoryTransport) Publish(ctx context.Context, inv Invalidation) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.published++
+	for _, sub := range m.subs {
+		sub <- inv
+	}
+	return nil
+}
+
+func (m *memoryTransport) Subscribe(ctx context.Context, handler func(ctx context.Context, inv Invalidation) error) error {
+	sub := make(chan Invalidation, 16)
+	m.mu.Lock()
+	m.subs = append(m.subs, sub)
+	m.mu.Unlock()
+	for {
+		select {
+		case inv := <-sub:
+			if err := handler(ctx, inv); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (m *memoryTransport) subscribers() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.subs)
+}
+
+// waitFor fails the test unless cond becomes true within a second.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestInvalidationBusFansOutToOtherInstances(t *testing.T) {
+	transport := &memoryTransport{}
+	local, remote := NewMemoryCache(), NewMemoryCache()
+	defer local.Close()
+	defer remote.Close()
+	localBus, remoteBus := NewInvalidationBus(local, transport), NewInvalidationBus(remote, transport)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go localBus.Run(ctx)
+	go remoteBus.Run(ctx)
+	waitFor(t, "both buses to subscribe", func() bool { return transport.subscribers() == 2 })
+
+	for _, c := range []*MemoryCache{local, remote} {
+		c.Set("a", 1, time.Minute)
+		c.SetWithTags("b", 2, time.Minute, "t")
+	}
+	if err := localBus.Invalidate(ctx, "a"); err != nil {
+		t.Fatalf("Invalidate = %v", err)
+	}
+	if _, ok := local.Get("a"); ok {
+		t.Fatal("Invalidate kept the key locally")
+	}
+	waitFor(t, "the remote instance to delete a", func() bool { _, ok := remote.Get("a"); return !ok })
+
+	if err := localBus.InvalidateTags(ctx, "t"); err != nil {
+		t.Fatalf("InvalidateTags = %v", err)
+	}
+	waitFor(t, "the remote instance to delete the tag", func() bool { _, ok := remote.Get("b"); return !ok })
+}
+
+func TestInvalidationBusSkipsRedeliveredMessages(t *testing.T) {
+	c := NewMemoryCache()
+	defer c.Close()
+	bus := NewInvalidationBus(c, &memoryTransport{})
+	inv := Invalidation{ID: "1", Origin: "other", Keys: []string{"a"}}
+	bus.apply(inv)
+	c.Set("a", 1, time.Minute)
+	bus.apply(inv)
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("a redelivered invalidation was applied again")
+	}
+	bus.apply(Invalidation{ID: "2", Origin: bus.origin, Keys: []string{"a"}})
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("the bus applied its own invalidation")
+	}
+}
+
+func TestInvalidateAfterSkipsFailedWrites(t *testing.T) {
+	transport := &memoryTransport{}
+	c := NewMemoryCache()
+	defer c.Close()
+	bus := NewInvalidationBus(c, transport)
+	c.Set("a", 1, time.Minute)
+	failing := errors.New("write failed")
+	if err := bus.InvalidateAfter(context.Background(), func() error { return failing }, "a"); !errors.Is(err, failing) {
+		t.Fatalf("InvalidateAfter = %v, want the write's error", err)
+	}
+	if _, ok := c.Get("a"); !ok || transport.published != 0 {
+		t.Fatal("InvalidateAfter invalidated after a failed write")
+	}
+	if err := bus.InvalidateAfter(context.Background(), func() error { return nil }, "a"); err != nil {
+		t.Fatalf("InvalidateAfter = %v", err)
+	}
+	if _, ok := c.Get("a"); ok || transport.published != 1 {
+		t.Fatal("InvalidateAfter did not invalidate after the write")
+	}
+}
@@ -0,0 +1,345 @@
+package once_cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrUnknownIssuer is returned by JWKSCache for issuers configured with neither WithJWKSURL nor
+// WithJWKSIssuers.
+var ErrUnknownIssuer = errors.New("once_cache: unknown issuer")
+
+// JWK is a JSON Web Key. Raw holds the whole key, for parsing parameters not decoded here.
+type JWK struct {
+	Kty string          `json:"kty"`
+	Kid string          `json:"kid,omitempty"`
+	Use string          `json:"use,omitempty"`
+	Alg string          `json:"alg,omitempty"`
+	N   string          `json:"n,omitempty"`
+	E   string          `json:"e,omitempty"`
+	Crv string          `json:"crv,omitempty"`
+	X   string          `json:"x,omitempty"`
+	Y   string          `json:"y,omitempty"`
+	Raw json.RawMessage `json:"-"`
+}
+
+// JWKS is a JSON Web Key Set.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// Key returns the key with the key ID kid.
+func (s *JWKS) Key(kid string) (JWK, bool) {
+	for _, k := range s.Keys {
+		if k.Kid == kid {
+			return k, true
+		}
+	}
+	return JWK{}, false
+}
+
+// IntrospectFunc asks the issuer about a token, returning the introspection response as in RFC 7662.
+type IntrospectFunc func(ctx context.Context) (map[string]any, error)
+
+// JWKSOption configures a JWKSCache.
+type JWKSOption func(*JWKSCache)
+
+// WithJWKSURL fetches the key set of issuer from url instead of discovering it from the issuer's
+// OpenID configuration.
+func WithJWKSURL(issuer, url string) JWKSOption {
+	return func(c *JWKSCache) {
+		c.urls[issuer] = url
+	}
+}
+
+// WithJWKSIssuers allows the key sets of issuers to be discovered from their OpenID configuration. Other
+// issuers without a WithJWKSURL are refused with ErrUnknownIssuer, so that the issuer claim of an untrusted
+// token cannot make the cache fetch arbitrary URLs or grow without bound.
+func WithJWKSIssuers(issuers ...string) JWKSOption {
+	return func(c *JWKSCache) {
+		for _, issuer := range issuers {
+			c.discoverable[issuer] = struct{}{}
+		}
+	}
+}
+
+// WithJWKSClient fetches documents with client instead of http.DefaultClient.
+func WithJWKSClient(client *http.Client) JWKSOption {
+	return func(c *JWKSCache) {
+		c.client = client
+	}
+}
+
+// WithJWKSRefresh sets how long key sets are fresh when the response has no Cache-Control max-age,
+// five minutes by default, and how long an expired key set is still served when refreshing it fails,
+// a day by default.
+func WithJWKSRefresh(ttl, maxStale time.Duration) JWKSOption {
+	return func(c *JWKSCache) {
+		c.ttl, c.maxStale = ttl, maxStale
+	}
+}
+
+// WithIntrospectionTTL bounds how long token introspection results are cached. Active tokens are cached
+// until they expire and at most for d, inactive ones for d. It defaults to a minute.
+func WithIntrospectionTTL(d time.Duration) JWKSOption {
+	return func(c *JWKSCache) {
+		c.introspectionTTL = d
+	}
+}
+
+// JWKSCache caches the JSON Web Key Sets of token issuers in an IResultCache, for authentication middlewares.
+// Key sets are refreshed with conditional requests on their ETag, an expired key set is served if the issuer
+// cannot be reached, and a key ID missing from the cached set triggers one refresh at most every minute,
+// so that key rotations are picked up without letting bogus tokens hammer the issuer.
+type JWKSCache struct {
+	cache            IResultCache
+	client           *http.Client
+	urls             map[string]string
+	discoverable     map[string]struct{}
+	ttl              time.Duration
+	maxStale         time.Duration
+	introspectionTTL time.Duration
+
+	mu          sync.Mutex
+	lastRefresh map[string]time.Time
+}
+
+// jwksRefreshInterval is the minimum time between refreshes triggered by unknown key IDs.
+const jwksRefreshInterval = time.Minute
+
+// jwksEntry is a cached key set with its validators.
+type jwksEntry struct {
+	set          *JWKS
+	etag         string
+	lastModified string
+	expiresAt    time.Time
+}
+
+// KeySet returns the key set of issuer. The returned set is shared and must not be modified.
+func (c *JWKSCache) KeySet(ctx context.Context, issuer string) (*JWKS, error) {
+	e, err := c.entry(ctx, issuer, false)
+	if err != nil {
+		return nil, err
+	}
+	return e.set, nil
+}
+
+// Key returns the key of issuer with the key ID kid, refreshing the key set once if the key is unknown.
+func (c *JWKSCache) Key(ctx context.Context, issuer, kid string) (JWK, error) {
+	e, err := c.entry(ctx, issuer, false)
+	if err != nil {
+		return JWK{}, err
+	}
+	if k, ok := e.set.Key(kid); ok {
+		return k, nil
+	}
+	if c.mayRefresh(issuer) {
+		if e, err = c.entry(ctx, issuer, true); err != nil {
+			return JWK{}, err
+		}
+		if k, ok := e.set.Key(kid); ok {
+			return k, nil
+		}
+	}
+	return JWK{}, fmt.Errorf("once_cache: issuer %s has no key %q", issuer, kid)
+}
+
+// mayRefresh reports whether a refresh of issuer for an unknown key ID is allowed now, and records it.
+func (c *JWKSCache) mayRefresh(issuer string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if now.Sub(c.lastRefresh[issuer]) < jwksRefreshInterval {
+		return false
+	}
+	c.lastRefresh[issuer] = now
+	return true
+}
+
+// entry returns the cached key set of issuer, refreshing it if it expired or force is set.
+func (c *JWKSCache) entry(ctx context.Context, issuer string, force bool) (*jwksEntry, error) {
+	if !c.known(issuer) {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownIssuer, issuer)
+	}
+	key := Key("jwks", issuer)
+	store := WithTTL(c.ttl + c.maxStale)
+	res := c.cache.GetResult(key, func() (any, error) {
+		return c.fetch(ctx, issuer, nil)
+	}, store)
+	cached, ok := res.Value.(*jwksEntry)
+	if !ok {
+		return nil, res.Err
+	}
+	if !res.Hit || (!force && time.Now().Before(cached.expiresAt)) {
+		return cached, nil
+	}
+	res = c.cache.GetResult(key, func() (any, error) {
+		return c.fetch(ctx, issuer, cached)
+	}, store, WithForceRefresh())
+	if fresh, ok := res.Value.(*jwksEntry); ok && res.Err == nil {
+		return fresh, nil
+	}
+	// Stale if error: keep serving the key set until it is dropped from the cache.
+	return cached, nil
+}
+
+// fetch downloads the key set of issuer, conditionally if a previous entry is given.
+func (c *JWKSCache) fetch(ctx context.Context, issuer string, prev *jwksEntry) (*jwksEntry, error) {
+	ctx = context.WithoutCancel(ctx)
+	url, err := c.jwksURL(ctx, issuer)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if prev != nil {
+		if prev.etag != "" {
+			req.Header.Set("If-None-Match", prev.etag)
+		}
+		if prev.lastModified != "" {
+			req.Header.Set("If-Modified-Since", prev.lastModified)
+		}
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	ttl := c.ttl
+	if d, ok := maxAge(strings.ToLower(resp.Header.Get("Cache-Control")), "max-age"); ok {
+		ttl = d
+	}
+	if prev != nil && resp.StatusCode == http.StatusNotModified {
+		refreshed := *prev
+		refreshed.expiresAt = time.Now().Add(ttl)
+		return &refreshed, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("once_cache: fetching %s: %s", url, resp.Status)
+	}
+	var doc struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&doc); err != nil {
+		return nil, err
+	}
+	set := &JWKS{Keys: make([]JWK, 0, len(doc.Keys))}
+	for _, raw := range doc.Keys {
+		var k JWK
+		if err := json.Unmarshal(raw, &k); err != nil {
+			return nil, err
+		}
+		k.Raw = raw
+		set.Keys = append(set.Keys, k)
+	}
+	return &jwksEntry{
+		set:          set,
+		etag:         resp.Header.Get("ETag"),
+		lastModified: resp.Header.Get("Last-Modified"),
+		expiresAt:    time.Now().Add(ttl),
+	}, nil
+}
+
+// known reports whether issuer has a key set URL or may be discovered.
+func (c *JWKSCache) known(issuer string) bool {
+	if _, ok := c.urls[issuer]; ok {
+		return true
+	}
+	_, ok := c.discoverable[issuer]
+	return ok
+}
+
+// jwksURL returns the key set URL of issuer, discovering it from the OpenID configuration, which is cached.
+func (c *JWKSCache) jwksURL(ctx context.Context, issuer string) (string, error) {
+	if url, ok := c.urls[issuer]; ok {
+		return url, nil
+	}
+	res := c.cache.GetResult(Key("oidc", issuer), func() (any, error) {
+		url := strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration"
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := c.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("once_cache: fetching %s: %s", url, resp.Status)
+		}
+		var config struct {
+			JWKSURI string `json:"jwks_uri"`
+		}
+		if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&config); err != nil {
+			return nil, err
+		}
+		if config.JWKSURI == "" {
+			return nil, errors.New("once_cache: no jwks_uri in the configuration of " + issuer)
+		}
+		return config.JWKSURI, nil
+	}, WithTTL(c.ttl+c.maxStale))
+	if url, ok := res.Value.(string); ok {
+		return url, nil
+	}
+	return "", res.Err
+}
+
+// Introspect returns the introspection result of token at issuer, calling introspect on a miss. Results are
+// cached by a hash of the token, never the token itself. Errors are not cached. A token reported active but
+// already past its exp is returned as inactive, as in RFC 7662, and not cached.
+func (c *JWKSCache) Introspect(ctx context.Context, issuer, token string, introspect IntrospectFunc) (map[string]any, error) {
+	sum := sha256.Sum256([]byte(token))
+	key := Key("introspect", issuer, hex.EncodeToString(sum[:]))
+	res := c.cache.GetResult(key, func() (any, error) {
+		return introspect(context.WithoutCancel(ctx))
+	}, WithTTL(c.introspectionTTL))
+	result, ok := res.Value.(map[string]any)
+	if !ok {
+		return nil, res.Err
+	}
+	if !res.Hit {
+		// Active tokens must not be cached beyond their expiry.
+		if exp, ok := result["exp"].(float64); ok {
+			ttl := time.Until(time.Unix(int64(exp), 0))
+			if ttl <= 0 {
+				c.cache.Delete(key)
+				return map[string]any{"active": false}, nil
+			}
+			if ttl < c.introspectionTTL {
+				c.cache.Set(key, result, ttl)
+			}
+		}
+	}
+	return result, nil
+}
+
+// NewJWKSCache creates a new instance of JWKSCache caching key sets in cache.
+func NewJWKSCache(cache IResultCache, opts ...JWKSOption) *JWKSCache {
+	c := &JWKSCache{
+		cache:            cache,
+		client:           http.DefaultClient,
+		urls:             make(map[string]string),
+		discoverable:     make(map[string]struct{}),
+		ttl:              5 * time.Minute,
+		maxStale:         24 * time.Hour,
+		introspectionTTL: time.Minute,
+		lastRefresh:      make(map[string]time.Time),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
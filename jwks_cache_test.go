@@ -0,0 +1,81 @@
+package once_cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+func newJWKSServer(t *testing.T, requests *atomic.Int32) *httptest.Server {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			fmt.Fprintf(w, `{"jwks_uri": %q}`, srv.URL+"/keys")
+		case "/keys":
+			fmt.Fprint(w, `{"keys": [{"kty": "RSA", "kid": "k1"}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestJWKSCacheOnlyDiscoversAllowedIssuers(t *testing.T) {
+	var requests atomic.Int32
+	srv := newJWKSServer(t, &requests)
+	c := NewJWKSCache(NewOnceCache(&singleflight.Group{}, NewMemoryCache()), WithJWKSIssuers(srv.URL))
+
+	if _, err := c.Key(context.Background(), srv.URL, "k1"); err != nil {
+		t.Fatalf("Key of an allowed issuer = %v", err)
+	}
+	before := requests.Load()
+	if _, err := c.KeySet(context.Background(), srv.URL+"/other"); !errors.Is(err, ErrUnknownIssuer) {
+		t.Fatalf("KeySet of an unknown issuer = %v, want ErrUnknownIssuer", err)
+	}
+	if n := requests.Load(); n != before {
+		t.Fatalf("an unknown issuer caused %d requests, want none", n-before)
+	}
+}
+
+func TestJWKSCacheFetchesConfiguredURLsWithoutDiscovery(t *testing.T) {
+	var requests atomic.Int32
+	srv := newJWKSServer(t, &requests)
+	c := NewJWKSCache(NewOnceCache(&singleflight.Group{}, NewMemoryCache()), WithJWKSURL("https://issuer.example", srv.URL+"/keys"))
+	if _, err := c.Key(context.Background(), "https://issuer.example", "k1"); err != nil {
+		t.Fatal(err)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Fatalf("requests = %d, want only the key set", n)
+	}
+}
+
+func TestJWKSCacheIntrospectReportsExpiredTokensInactive(t *testing.T) {
+	c := NewJWKSCache(NewOnceCache(&singleflight.Group{}, NewMemoryCache()))
+	calls := 0
+	introspect := func(context.Context) (map[string]any, error) {
+		calls++
+		return map[string]any{"active": true, "exp": float64(time.Now().Add(-time.Minute).Unix())}, nil
+	}
+	for i := 0; i < 2; i++ {
+		result, err := c.Introspect(context.Background(), "https://issuer.example", "token", introspect)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result["active"] != false {
+			t.Fatalf("Introspect of an expired token = %v, want it inactive", result)
+		}
+	}
+	if calls != 2 {
+		t.Fatalf("introspect called %d times, want the expired result not cached", calls)
+	}
+}
@@ -0,0 +1,78 @@
+package once_cache
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// KafkaMessage is a Kafka record as seen by KafkaTransport.
+type KafkaMessage struct {
+	Topic     string
+	Partition int
+	Offset    int64
+	Key       []byte
+	Value     []byte
+}
+
+// KafkaWriter produces messages. It matches the shape of kafka-go's Writer.WriteMessages.
+type KafkaWriter interface {
+	WriteMessages(ctx context.Context, msgs ...KafkaMessage) error
+}
+
+// KafkaReader consumes messages as a member of a consumer group. It matches the shape of kafka-go's
+// Reader.FetchMessage and Reader.CommitMessages.
+type KafkaReader interface {
+	FetchMessage(ctx context.Context) (KafkaMessage, error)
+	CommitMessages(ctx context.Context, msgs ...KafkaMessage) error
+}
+
+// KafkaTransport is an InvalidationTransport over a Kafka topic, for durable fan-out of invalidations.
+//
+// Every cache instance must consume with its own consumer group ID (for example derived from the host name):
+// members of one group split the partitions between them, so a shared group would deliver each invalidation
+// to a single instance. Offsets are committed after a message is applied, so an instance that restarts
+// resumes where it stopped; redelivered messages are harmless because deletes are idempotent.
+type KafkaTransport struct {
+	writer KafkaWriter
+	reader KafkaReader
+}
+
+// Publish writes the invalidation as a JSON message.
+func (t *KafkaTransport) Publish(ctx context.Context, inv Invalidation) error {
+	value, err := json.Marshal(inv)
+	if err != nil {
+		return err
+	}
+	return t.writer.WriteMessages(ctx, KafkaMessage{Key: []byte(inv.ID), Value: value})
+}
+
+// Subscribe fetches messages, hands them to handler and commits them once handled.
+// Malformed messages are committed and skipped so they cannot block the partition.
+func (t *KafkaTransport) Subscribe(ctx context.Context, handler func(ctx context.Context, inv Invalidation) error) error {
+	for {
+		msg, err := t.reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		var inv Invalidation
+		if json.Unmarshal(msg.Value, &inv) == nil {
+			if err := handler(ctx, inv); err != nil {
+				return err
+			}
+		}
+		if err := t.reader.CommitMessages(ctx, msg); err != nil {
+			return err
+		}
+	}
+}
+
+// NewKafkaTransport creates a new instance of KafkaTransport publishing with writer and consuming with reader.
+func NewKafkaTransport(writer KafkaWriter, reader KafkaReader) InvalidationTransport {
+	return &KafkaTransport{
+		writer: writer,
+		reader: reader,
+	}
+}
@@ -0,0 +1,190 @@
+package once_cache
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+	"unsafe"
+)
+
+// Key builds a composite cache key from parts, such as Key(tenantID, userID, locale).
+// Each part is written with its type and length, so keys are canonical and two different
+// lists of parts never produce the same key, unlike keys joined with a separator.
+//
+// Parts may be strings, byte slices, booleans, integers, floats, time.Time values, and structs
+// and arrays of them, which are encoded field by field after the struct's package path and name,
+// so that structs of different types with the same field values have different keys. Key panics
+// on other types, such as pointers, maps and slices other than []byte, since they have no canonical
+// form; use TryKey for parts that are not known to be supported.
+func Key(parts ...any) string {
+	k, err := TryKey(parts...)
+	if err != nil {
+		panic(err.Error())
+	}
+	return k
+}
+
+// TryKey is Key returning an error instead of panicking on parts of unsupported types.
+func TryKey(parts ...any) (string, error) {
+	b := make([]byte, 0, 16*len(parts))
+	var err error
+	for _, p := range parts {
+		if b, err = appendKeyPart(b, keyPartValue(p)); err != nil {
+			return "", err
+		}
+	}
+	return string(b), nil
+}
+
+// KeyBuilder builds a composite key incrementally, see Key.
+type KeyBuilder struct {
+	b []byte
+}
+
+// NewKeyBuilder creates a new KeyBuilder, optionally starting with a namespace part.
+func NewKeyBuilder(namespace ...string) *KeyBuilder {
+	k := &KeyBuilder{}
+	for _, ns := range namespace {
+		k.String(ns)
+	}
+	return k
+}
+
+// String appends a string part.
+func (k *KeyBuilder) String(s string) *KeyBuilder {
+	k.b = appendKeyBytes(k.b, 's', s)
+	return k
+}
+
+// Int appends a signed integer part.
+func (k *KeyBuilder) Int(i int64) *KeyBuilder {
+	k.b = appendKeyBytes(k.b, 'i', strconv.FormatInt(i, 10))
+	return k
+}
+
+// Uint appends an unsigned integer part.
+func (k *KeyBuilder) Uint(u uint64) *KeyBuilder {
+	k.b = appendKeyBytes(k.b, 'u', strconv.FormatUint(u, 10))
+	return k
+}
+
+// Part appends any part supported by Key. Like Key, it panics on parts of unsupported types.
+func (k *KeyBuilder) Part(p any) *KeyBuilder {
+	b, err := appendKeyPart(k.b, keyPartValue(p))
+	if err != nil {
+		panic(err.Error())
+	}
+	k.b = b
+	return k
+}
+
+// Key returns the built key.
+func (k *KeyBuilder) Key() string {
+	return string(k.b)
+}
+
+// appendKeyBytes appends a part as its type tag, its length and its bytes.
+func appendKeyBytes(b []byte, tag byte, s string) []byte {
+	b = append(b, tag)
+	b = strconv.AppendInt(b, int64(len(s)), 10)
+	b = append(b, ':')
+	return append(b, s...)
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// keyPartValue returns the value of a part. Structs and arrays are copied to an addressable value, so that
+// their unexported time.Time fields, which reflect does not let Interface read, can be read from their address.
+func keyPartValue(p any) reflect.Value {
+	v := reflect.ValueOf(p)
+	if v.Kind() != reflect.Struct && v.Kind() != reflect.Array {
+		return v
+	}
+	addressable := reflect.New(v.Type()).Elem()
+	addressable.Set(v)
+	return addressable
+}
+
+func appendKeyPart(b []byte, v reflect.Value) ([]byte, error) {
+	if !v.IsValid() {
+		return append(b, 'n'), nil
+	}
+	if v.Type() == timeType {
+		var t time.Time
+		if v.CanInterface() {
+			t = v.Interface().(time.Time)
+		} else {
+			t = *(*time.Time)(unsafe.Pointer(v.UnsafeAddr()))
+		}
+		return appendKeyBytes(b, 't', t.UTC().Format(time.RFC3339Nano)), nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return appendKeyBytes(b, 's', v.String()), nil
+	case reflect.Bool:
+		return appendKeyBytes(b, 'b', strconv.FormatBool(v.Bool())), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return appendKeyBytes(b, 'i', strconv.FormatInt(v.Int(), 10)), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return appendKeyBytes(b, 'u', strconv.FormatUint(v.Uint(), 10)), nil
+	case reflect.Float32, reflect.Float64:
+		// Use the bits so that every float, including -0 and NaN payloads, has one encoding.
+		return appendKeyBytes(b, 'f', strconv.FormatUint(math.Float64bits(v.Float()), 16)), nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return appendKeyBytes(b, 'x', string(v.Bytes())), nil
+		}
+	case reflect.Array:
+		b = append(b, 'a')
+		b = strconv.AppendInt(b, int64(v.Len()), 10)
+		b = append(b, ':')
+		return appendKeyElems(b, v.Len(), v.Index)
+	case reflect.Struct:
+		b = appendKeyBytes(b, 'r', keyTypeName(v.Type()))
+		b = strconv.AppendInt(b, int64(v.NumField()), 10)
+		b = append(b, ':')
+		return appendKeyElems(b, v.NumField(), v.Field)
+	}
+	return nil, fmt.Errorf("once_cache: unsupported key part of type %s", v.Type())
+}
+
+// appendKeyElems appends the n elements or fields of an array or struct.
+func appendKeyElems(b []byte, n int, elem func(i int) reflect.Value) ([]byte, error) {
+	var err error
+	for i := 0; i < n; i++ {
+		if b, err = appendKeyPart(b, elem(i)); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// keyTypeName identifies a struct type: by its package path and name if it is named, and by its
+// definition, which lists its fields, otherwise.
+func keyTypeName(t reflect.Type) string {
+	if t.Name() == "" {
+		return t.String()
+	}
+	return t.PkgPath() + "." + t.Name()
+}
+
+// KeyEvery returns key suffixed with the current time bucket of length d, such as KeyEvery("leaderboard", 5*time.Minute),
+// so time-windowed data moves to a new key every d without explicit invalidation. Buckets are aligned to the
+// Unix epoch, so all processes agree on them. Store the values with KeyEveryTTL's duration to let old buckets age out.
+func KeyEvery(key string, d time.Duration) string {
+	k, _ := KeyEveryTTL(key, d)
+	return k
+}
+
+// KeyEveryTTL is KeyEvery also returning the time left until the bucket ends, to use as the entry's time to live.
+func KeyEveryTTL(key string, d time.Duration) (string, time.Duration) {
+	if d <= 0 {
+		return key, 0
+	}
+	now := time.Now().UnixNano()
+	bucket := now / int64(d)
+	end := (bucket + 1) * int64(d)
+	return key + "@" + strconv.FormatInt(bucket, 10), time.Duration(end - now)
+}
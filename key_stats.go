@@ -0,0 +1,90 @@
+package once_cache
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// WithKeyStats records hits, misses and loads of every key, queried with KeyStats. It costs memory for every
+// distinct key, so it is meant for investigations; maxKeys bounds the tracked keys, zero means unbounded.
+func WithKeyStats(maxKeys int) Option {
+	return func(o *OnceCache) {
+		o.keyStats = &keyStatsTable{max: int64(maxKeys)}
+	}
+}
+
+// keyStatsTable holds the counters of the tracked keys.
+type keyStatsTable struct {
+	keys  sync.Map
+	count atomic.Int64
+	max   int64
+}
+
+type keyCounters struct {
+	hits, misses, loads atomic.Uint64
+	lastLoad            atomic.Int64
+}
+
+// counters returns the counters of key, or nil if the table is full.
+func (t *keyStatsTable) counters(key string) *keyCounters {
+	if c, ok := t.keys.Load(key); ok {
+		return c.(*keyCounters)
+	}
+	if t.max > 0 && t.count.Load() >= t.max {
+		return nil
+	}
+	c, loaded := t.keys.LoadOrStore(key, new(keyCounters))
+	if !loaded {
+		t.count.Add(1)
+	}
+	return c.(*keyCounters)
+}
+
+func (o *OnceCache) recordHit(key string) {
+	o.recorder.record(key, true)
+	o.experiment.recordLookup(key, true)
+	o.alerts.recordLookup(true)
+	o.recordWarmLookup(key, true)
+	if o.keyStats != nil {
+		if c := o.keyStats.counters(key); c != nil {
+			c.hits.Add(1)
+		}
+	}
+}
+
+func (o *OnceCache) recordMiss(key string) {
+	o.recorder.record(key, false)
+	o.experiment.recordLookup(key, false)
+	o.alerts.recordLookup(false)
+	o.recordWarmLookup(key, false)
+	if o.keyStats != nil {
+		if c := o.keyStats.counters(key); c != nil {
+			c.misses.Add(1)
+		}
+	}
+}
+
+func (o *OnceCache) recordLoad(key string, d time.Duration, err error) {
+	o.experiment.recordLoad(key, d, err)
+	o.alerts.recordLoad(err)
+	if o.keyStats != nil {
+		if c := o.keyStats.counters(key); c != nil {
+			c.loads.Add(1)
+			c.lastLoad.Store(int64(d))
+		}
+	}
+}
+
+// KeyStats returns the counters of key recorded with WithKeyStats. ok is false if they are not recorded.
+func (o *OnceCache) KeyStats(key string) (hits, misses, loads uint64, lastLoadDuration time.Duration, ok bool) {
+	if o.keyStats == nil {
+		return 0, 0, 0, 0, false
+	}
+	v, ok := o.keyStats.keys.Load(key)
+	if !ok {
+		return 0, 0, 0, 0, false
+	}
+	c := v.(*keyCounters)
+	return c.hits.Load(), c.misses.Load(), c.loads.Load(), time.Duration(c.lastLoad.Load()), true
+}
@@ -0,0 +1,70 @@
+package once_cache
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKeyEncodesUnexportedFields(t *testing.T) {
+	type private struct {
+		at    time.Time
+		id    int
+		name  string
+		raw   []byte
+		times [2]time.Time
+	}
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	later := at.Add(time.Second)
+	p := private{at: at, id: 1, name: "a", raw: []byte("x"), times: [2]time.Time{at, later}}
+
+	got := Key(p)
+	if fields := Key(at, 1, "a", []byte("x"), [2]time.Time{at, later}); !strings.HasSuffix(got, ":"+fields) {
+		t.Fatalf("Key of unexported fields = %q, want it to end with the fields %q", got, fields)
+	}
+	if built := NewKeyBuilder().Part(p).Key(); built != got {
+		t.Fatalf("KeyBuilder.Part = %q, want %q", built, got)
+	}
+	inZone := p
+	inZone.at = at.In(time.FixedZone("UTC+2", 2*60*60))
+	if Key(inZone) != got {
+		t.Error("Key differs for the same instant in another zone")
+	}
+	moved := p
+	moved.at = later
+	if Key(moved) == got {
+		t.Error("Key is the same for different times")
+	}
+	if Key([1]private{p}) == Key([1]private{moved}) {
+		t.Error("Key of arrays of structs ignores unexported times")
+	}
+}
+
+type keyA struct{ ID int }
+
+type keyB struct{ ID int }
+
+func TestKeyDistinguishesStructTypes(t *testing.T) {
+	if Key(keyA{1}) == Key(keyB{1}) {
+		t.Fatal("Key is the same for structs of different types with the same fields")
+	}
+	if Key(keyA{1}) != Key(keyA{1}) {
+		t.Fatal("Key differs for equal structs")
+	}
+	if Key(struct{ ID int }{1}) == Key(struct{ Other int }{1}) {
+		t.Fatal("Key is the same for anonymous structs with different fields")
+	}
+}
+
+func TestTryKeyReportsUnsupportedParts(t *testing.T) {
+	if _, err := TryKey("a", map[string]int{}); err == nil {
+		t.Fatal("TryKey of a map succeeded")
+	}
+	if _, err := TryKey(keyA{1}, [1]struct{ P *int }{}); err == nil {
+		t.Fatal("TryKey of a struct with a pointer field succeeded")
+	}
+	k, err := TryKey("a", 1)
+	if err != nil || k != Key("a", 1) {
+		t.Fatalf("TryKey = %q, %v, want %q", k, err, Key("a", 1))
+	}
+}
@@ -0,0 +1,155 @@
+package once_cache
+
+import (
+	"context"
+	"sync"
+)
+
+// LoadBarrier pauses loads so that tests can observe and order concurrent access deterministically,
+// without sleeps. Every load of a key stops at the barrier until Release is called for that key,
+// and callers waiting for a load are counted, so a test can start N goroutines, wait until they
+// are all waiting with WaitCallers, release the load and check that it ran once with Loads.
+// It is meant for tests only.
+type LoadBarrier struct {
+	mu      sync.Mutex
+	keys    map[string]*barrierKey
+	changed chan struct{}
+	closed  bool
+}
+
+type barrierKey struct {
+	loads   int
+	paused  int
+	callers int
+	release chan struct{}
+}
+
+// NewLoadBarrier creates a new LoadBarrier pausing all loads.
+func NewLoadBarrier() *LoadBarrier {
+	return &LoadBarrier{
+		keys:    make(map[string]*barrierKey),
+		changed: make(chan struct{}),
+	}
+}
+
+// WithLoadBarrier makes loads of single keys, including refresh-ahead and prefetch loads,
+// stop at the barrier before calling the loader.
+func WithLoadBarrier(b *LoadBarrier) Option {
+	return func(o *OnceCache) {
+		o.barrier = b
+	}
+}
+
+// Release lets the loads of key currently paused at the barrier proceed. Later loads pause again.
+// It does nothing once the barrier is closed, since no load pauses then.
+func (b *LoadBarrier) Release(key string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	k := b.key(key)
+	close(k.release)
+	k.release = make(chan struct{})
+	k.paused = 0
+	b.broadcast()
+}
+
+// Close releases all paused loads and stops pausing new ones. Counting continues.
+func (b *LoadBarrier) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	b.closed = true
+	for _, k := range b.keys {
+		close(k.release)
+		k.paused = 0
+	}
+	b.broadcast()
+}
+
+// Loads returns how many loads of key have reached the barrier.
+func (b *LoadBarrier) Loads(key string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.key(key).loads
+}
+
+// WaitPaused blocks until n loads of key are paused at the barrier or ctx is done.
+func (b *LoadBarrier) WaitPaused(ctx context.Context, key string, n int) error {
+	return b.waitFor(ctx, func() bool { return b.key(key).paused >= n })
+}
+
+// WaitCallers blocks until n callers are waiting for a load of key or ctx is done.
+func (b *LoadBarrier) WaitCallers(ctx context.Context, key string, n int) error {
+	return b.waitFor(ctx, func() bool { return b.key(key).callers >= n })
+}
+
+// pause is called by a load of key and returns once the load is released.
+func (b *LoadBarrier) pause(key string) {
+	b.mu.Lock()
+	k := b.key(key)
+	k.loads++
+	if b.closed {
+		b.broadcast()
+		b.mu.Unlock()
+		return
+	}
+	k.paused++
+	release := k.release
+	b.broadcast()
+	b.mu.Unlock()
+	<-release
+}
+
+// arrive records a caller waiting for a load of key and returns a function recording its departure.
+func (b *LoadBarrier) arrive(key string) func() {
+	b.mu.Lock()
+	b.key(key).callers++
+	b.broadcast()
+	b.mu.Unlock()
+	return func() {
+		b.mu.Lock()
+		b.key(key).callers--
+		b.broadcast()
+		b.mu.Unlock()
+	}
+}
+
+func (b *LoadBarrier) waitFor(ctx context.Context, cond func() bool) error {
+	for {
+		b.mu.Lock()
+		if cond() {
+			b.mu.Unlock()
+			return nil
+		}
+		changed := b.changed
+		b.mu.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// key returns the state of key, creating it. b.mu must be held.
+func (b *LoadBarrier) key(key string) *barrierKey {
+	k, ok := b.keys[key]
+	if !ok {
+		k = &barrierKey{release: make(chan struct{})}
+		if b.closed {
+			close(k.release)
+		}
+		b.keys[key] = k
+	}
+	return k
+}
+
+// broadcast wakes up all waiters. b.mu must be held.
+func (b *LoadBarrier) broadcast() {
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
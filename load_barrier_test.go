@@ -0,0 +1,104 @@
+package once_cache
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+func TestLoadBarrierCoalescesWaitingCallers(t *testing.T) {
+	b := NewLoadBarrier()
+	defer b.Close()
+	c := NewOnceCache(&singleflight.Group{}, NewMemoryCache(), WithLoadBarrier(b))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	var calls int
+	var wg sync.WaitGroup
+	values := make([]any, 5)
+	for i := range values {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			values[i], _ = c.GetWithSingleFunc("k", func() (any, error) {
+				calls++
+				return "v", nil
+			}, time.Minute, nil)
+		}(i)
+	}
+	if err := b.WaitCallers(ctx, "k", len(values)); err != nil {
+		t.Fatalf("WaitCallers: %v", err)
+	}
+	if err := b.WaitPaused(ctx, "k", 1); err != nil {
+		t.Fatalf("WaitPaused: %v", err)
+	}
+	b.Release("k")
+	wg.Wait()
+	if calls != 1 || b.Loads("k") != 1 {
+		t.Fatalf("loader ran %d times, %d loads reached the barrier, want 1", calls, b.Loads("k"))
+	}
+	for i, v := range values {
+		if v != "v" {
+			t.Errorf("caller %d got %v, want v", i, v)
+		}
+	}
+}
+
+func TestLoadBarrierPausesAgainAfterRelease(t *testing.T) {
+	b := NewLoadBarrier()
+	defer b.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	for round := 1; round <= 2; round++ {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			b.pause("k")
+		}()
+		if err := b.WaitPaused(ctx, "k", 1); err != nil {
+			t.Fatalf("round %d: WaitPaused: %v", round, err)
+		}
+		b.Release("k")
+		<-done
+	}
+	if n := b.Loads("k"); n != 2 {
+		t.Fatalf("Loads = %d, want 2", n)
+	}
+}
+
+func TestLoadBarrierCloseReleasesAndStopsPausing(t *testing.T) {
+	b := NewLoadBarrier()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		b.pause("k")
+	}()
+	if err := b.WaitPaused(ctx, "k", 1); err != nil {
+		t.Fatal(err)
+	}
+	b.Close()
+	<-done
+	b.Close()
+	// Releasing keys paused before, or never seen, once closed must not panic.
+	b.Release("k")
+	b.Release("other")
+	b.pause("other")
+	if n := b.Loads("other"); n != 1 {
+		t.Fatalf("Loads after Close = %d, want 1", n)
+	}
+}
+
+func TestLoadBarrierWaitHonorsContext(t *testing.T) {
+	b := NewLoadBarrier()
+	defer b.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := b.WaitPaused(ctx, "k", 1); err != context.DeadlineExceeded {
+		t.Fatalf("WaitPaused = %v, want context.DeadlineExceeded", err)
+	}
+}
@@ -0,0 +1,77 @@
+package once_cache
+
+import "context"
+
+// WithContextSeed sets the function creating the context of loads that have no caller context: loads of
+// GetWithSingleFunc, GetWithOptions and GetResult, and the background refreshes they trigger. It lets
+// loaders and loader middleware find request-independent values, such as service credentials or a tenant
+// ID derived from the key, that context.Background lacks.
+func WithContextSeed(seed func(key string) context.Context) Option {
+	return func(o *OnceCache) {
+		o.contextSeed = seed
+	}
+}
+
+// GetWithContext is GetWithOptions for a loader taking the caller's context, so that it can use
+// request-scoped values such as credentials. The values are kept, but not the cancellation, for the load
+// and for the background refreshes the call triggers, which outlive the request: loads are shared with
+// other callers and refreshes run after the call returns. The first caller's context is used when several
+// callers share a load. With WithTenantCredentials, calls are scoped to the tenant named by ctx.
+func (o *OnceCache) GetWithContext(ctx context.Context, key string, f KeyedFunc, opts ...CallOption) (any, bool) {
+	c := acquireCallConfig(opts)
+	defer releaseCallConfig(c)
+	c.ctx = ctx
+	if o.credentials != nil {
+		scoped, loader, err := o.scopeToTenant(ctx, key, f)
+		if err != nil {
+			if handler := o.handler(c.errorHandler); handler != nil {
+				handler(o, key, err)
+			}
+			return nil, false
+		}
+		key, f = scoped, loader
+	}
+	res := o.get(key, loader{keyed: f}, c)
+	return res.Value, res.OK()
+}
+
+// loadContext returns the context of the loads of a call for key.
+func (o *OnceCache) loadContext(key string, c *callConfig) context.Context {
+	if c.ctx != nil {
+		return context.WithoutCancel(c.ctx)
+	}
+	if o.contextSeed != nil {
+		if ctx := o.contextSeed(key); ctx != nil {
+			return ctx
+		}
+	}
+	return context.Background()
+}
+
+// loader is the function of a call, either a KeyedFunc or a SingleFunc adapted to one when it is needed.
+type loader struct {
+	keyed  KeyedFunc
+	single SingleFunc
+}
+
+func (l loader) isNil() bool {
+	return l.keyed == nil && l.single == nil
+}
+
+// keyedFunc returns the function as a KeyedFunc, allocating the adapter of a SingleFunc.
+func (l loader) keyedFunc() KeyedFunc {
+	if l.keyed != nil {
+		return l.keyed
+	}
+	return singleKeyed(l.single)
+}
+
+// singleKeyed adapts a SingleFunc to the KeyedFunc the loads run.
+func singleKeyed(f SingleFunc) KeyedFunc {
+	if f == nil {
+		return nil
+	}
+	return func(context.Context, string) (any, error) {
+		return f()
+	}
+}
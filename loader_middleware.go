@@ -0,0 +1,103 @@
+package once_cache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// LoaderMiddleware wraps a loader, to add retries, timeouts, metrics or tracing around every load. It is
+// applied once, when the cache is created, and must call next with the context it received or one derived
+// from it.
+type LoaderMiddleware func(next KeyedFunc) KeyedFunc
+
+// WithLoaderMiddleware wraps every load of single keys, including refresh-ahead and prefetch loads,
+// with the middleware. The first middleware is the outermost. Each BatchFunc call is wrapped as one load,
+// whose key is the keys of the batch joined by commas and whose value is the map of the batch.
+func WithLoaderMiddleware(mw ...LoaderMiddleware) Option {
+	return func(o *OnceCache) {
+		o.loaderMiddleware = append(o.loaderMiddleware, mw...)
+	}
+}
+
+// RetryLoader retries failed loads up to attempts times in total, waiting backoff before the first retry
+// and doubling it after each one. It stops early when the context is done, and does not retry ErrRecordNotFound.
+func RetryLoader(attempts int, backoff time.Duration) LoaderMiddleware {
+	return func(next KeyedFunc) KeyedFunc {
+		return func(ctx context.Context, key string) (any, error) {
+			wait := backoff
+			for attempt := 1; ; attempt++ {
+				value, err := next(ctx, key)
+				if err == nil || attempt >= attempts || errors.Is(err, ErrRecordNotFound) {
+					return value, err
+				}
+				timer := time.NewTimer(wait)
+				select {
+				case <-timer.C:
+				case <-ctx.Done():
+					timer.Stop()
+					return nil, err
+				}
+				wait *= 2
+			}
+		}
+	}
+}
+
+// TimeoutLoader fails loads that take longer than d with ErrLoadTimeout, and loads whose context is done
+// first with the context's error. The loader receives a context cancelled after d; loaders ignoring it
+// keep running, but their result is discarded. A panic of the loader is raised again in the caller.
+func TimeoutLoader(d time.Duration) LoaderMiddleware {
+	return func(next KeyedFunc) KeyedFunc {
+		return func(parent context.Context, key string) (any, error) {
+			ctx, cancel := context.WithTimeout(parent, d)
+			defer cancel()
+			type result struct {
+				value    any
+				err      error
+				panicked any
+			}
+			done := make(chan result, 1)
+			go func() {
+				defer func() {
+					if r := recover(); r != nil {
+						done <- result{panicked: r}
+					}
+				}()
+				value, err := next(ctx, key)
+				done <- result{value: value, err: err}
+			}()
+			select {
+			case res := <-done:
+				if res.panicked != nil {
+					panic(res.panicked)
+				}
+				return res.value, res.err
+			case <-ctx.Done():
+				if err := parent.Err(); err != nil {
+					return nil, err
+				}
+				return nil, ErrLoadTimeout
+			}
+		}
+	}
+}
+
+// loaderContextKey is the context key under which loadKey passes the loader of a call to the middleware
+// chain, so that the chain is composed once rather than around every loader.
+type loaderContextKey struct{}
+
+// composeLoaderMiddleware returns the chain of mw around a loader calling the loader found in its context,
+// or nil without middleware.
+func composeLoaderMiddleware(mw []LoaderMiddleware) KeyedFunc {
+	if len(mw) == 0 {
+		return nil
+	}
+	f := KeyedFunc(func(ctx context.Context, key string) (any, error) {
+		return ctx.Value(loaderContextKey{}).(KeyedFunc)(ctx, key)
+	})
+	for i := len(mw) - 1; i >= 0; i-- {
+		f = mw[i](f)
+	}
+	return f
+}
@@ -0,0 +1,79 @@
+package once_cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+func TestTimeoutLoaderTellsTimeoutsFromCancellations(t *testing.T) {
+	blocked := func(ctx context.Context, key string) (any, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	load := TimeoutLoader(10 * time.Millisecond)(blocked)
+	if _, err := load(context.Background(), "k"); !errors.Is(err, ErrLoadTimeout) {
+		t.Fatalf("load past the timeout = %v, want ErrLoadTimeout", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	load = TimeoutLoader(time.Minute)(blocked)
+	if _, err := load(ctx, "k"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("load with a cancelled context = %v, want context.Canceled", err)
+	}
+}
+
+func TestTimeoutLoaderRaisesPanicsInTheCaller(t *testing.T) {
+	load := TimeoutLoader(time.Minute)(func(ctx context.Context, key string) (any, error) {
+		panic("boom")
+	})
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Fatalf("recovered %v, want the loader's panic", r)
+			}
+		}()
+		load(context.Background(), "k")
+	}()
+
+	// The flight of the panicking load is finished, so the next load of the key runs.
+	c := NewOnceCache(&singleflight.Group{}, NewMemoryCache(), WithLoaderMiddleware(TimeoutLoader(time.Minute)))
+	panicking := func(ctx context.Context, key string) (any, error) { panic("boom") }
+	if !recovered(func() { c.GetWithContext(context.Background(), "k", panicking) }) {
+		t.Fatal("the panic of the load was not raised in the caller")
+	}
+	returnsWithin(t, "the load after a panic", func() {
+		if v, ok := c.GetWithContext(context.Background(), "k", func(context.Context, string) (any, error) {
+			return 1, nil
+		}); !ok || v != 1 {
+			t.Errorf("load after a panic = %v, %v, want 1, true", v, ok)
+		}
+	})
+}
+
+func TestLoaderMiddlewareIsComposedOnce(t *testing.T) {
+	wraps := 0
+	mw := func(next KeyedFunc) KeyedFunc {
+		wraps++
+		return func(ctx context.Context, key string) (any, error) {
+			value, err := next(ctx, key)
+			return value.(int) * 10, err
+		}
+	}
+	c := NewOnceCache(&singleflight.Group{}, NewMemoryCache(), WithLoaderMiddleware(mw))
+	for i, key := range []string{"a", "b", "c"} {
+		v, ok := c.GetWithContext(context.Background(), key, func(context.Context, string) (any, error) {
+			return i, nil
+		})
+		if !ok || v != i*10 {
+			t.Fatalf("Get(%s) = %v, %v, want %d, true", key, v, ok, i*10)
+		}
+	}
+	if wraps != 1 {
+		t.Fatalf("middleware applied %d times, want once", wraps)
+	}
+}
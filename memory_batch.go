@@ -0,0 +1,83 @@
+package once_cache
+
+import (
+	"time"
+)
+
+// batchOp is a staged Set or Delete of a MemoryBatch.
+type batchOp struct {
+	key    string
+	entry  memoryEntry
+	delete bool
+	// value and ttl are the staged Set, turned into entry when the batch is applied, and data the
+	// encoding of value if the cache stores bytes.
+	value any
+	data  []byte
+	ttl   time.Duration
+}
+
+// MemoryBatch stages Set and Delete operations on a MemoryCache and applies them atomically,
+// so related entries never appear half-updated to readers.
+type MemoryBatch struct {
+	cache *MemoryCache
+	ops   []batchOp
+	err   error
+}
+
+// Batch returns a new MemoryBatch for the cache.
+func (c *MemoryCache) Batch() *MemoryBatch {
+	return &MemoryBatch{cache: c}
+}
+
+// Set stages storing the value with the specified time to live, counted from Apply.
+func (b *MemoryBatch) Set(key string, value any, d time.Duration) *MemoryBatch {
+	op := batchOp{key: key, value: value, ttl: d}
+	if b.cache.codec != nil {
+		data, err := b.cache.codec.Marshal(value)
+		if err != nil {
+			if b.err == nil {
+				b.err = err
+			}
+			return b
+		}
+		op.data = data
+	}
+	b.ops = append(b.ops, op)
+	return b
+}
+
+// Delete stages removing the key.
+func (b *MemoryBatch) Delete(key string) *MemoryBatch {
+	b.ops = append(b.ops, batchOp{key: key, delete: true})
+	return b
+}
+
+// Apply performs the staged operations in order, all at once. If a value could not be encoded,
+// nothing is applied and the encoding error is returned. The batch is empty afterwards.
+func (b *MemoryBatch) Apply() error {
+	ops, err := b.ops, b.err
+	b.ops, b.err = nil, nil
+	if err != nil {
+		return err
+	}
+	if len(ops) == 0 {
+		return nil
+	}
+	// Values are indexed and interned only once the batch is known to be applied, so that batches
+	// discarded or failing to encode leave nothing behind, but still before they are stored, as Set does.
+	for i := range ops {
+		op := &ops[i]
+		if op.delete {
+			continue
+		}
+		b.cache.indexValue(op.key, op.value)
+		value := op.value
+		if b.cache.codec != nil {
+			value = b.cache.intern(op.data)
+		}
+		op.entry = b.cache.newEntry(value, op.ttl, PriorityNormal)
+	}
+	b.cache.storage.apply(ops)
+	b.cache.enforceMaxEntries()
+	return nil
+}
@@ -0,0 +1,54 @@
+package once_cache
+
+import (
+	"testing"
+	"time"
+)
+
+func newIndexedInterningCache() *MemoryCache {
+	return NewMemoryCache(WithByteValues(JSONCodec{}), WithInterning(), WithIndex("kind", func(value any) []string {
+		if s, ok := value.(string); ok {
+			return []string{s}
+		}
+		return nil
+	}))
+}
+
+func TestMemoryBatchIndexesAndInternsOnApply(t *testing.T) {
+	c := newIndexedInterningCache()
+	defer c.Close()
+	b := c.Batch().Set("a", "flag", time.Minute).Set("b", "flag", time.Minute)
+	if refs := c.indexes["kind"].refs; refs != 0 {
+		t.Fatalf("staging indexed %d references, want none before Apply", refs)
+	}
+	if refs := c.interned.refs; refs != 0 {
+		t.Fatalf("staging interned %d references, want none before Apply", refs)
+	}
+	if err := b.Apply(); err != nil {
+		t.Fatal(err)
+	}
+	if values := c.GetByIndex("kind", "flag"); len(values) != 2 {
+		t.Fatalf("GetByIndex = %v, want a and b", values)
+	}
+	if stats := c.InternStats(); stats.Distinct != 1 || stats.Entries != 2 {
+		t.Fatalf("InternStats = %+v, want one payload shared by two entries", stats)
+	}
+}
+
+func TestMemoryBatchFailingToEncodeLeavesNothingBehind(t *testing.T) {
+	c := newIndexedInterningCache()
+	defer c.Close()
+	err := c.Batch().Set("a", "flag", time.Minute).Set("bad", make(chan int), time.Minute).Apply()
+	if err == nil {
+		t.Fatal("Apply of a value JSON cannot encode succeeded, want an error")
+	}
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("a failed batch stored a")
+	}
+	if refs := c.indexes["kind"].refs; refs != 0 {
+		t.Fatalf("failed batch indexed %d references, want none", refs)
+	}
+	if refs, n := c.interned.refs, len(c.interned.values); refs != 0 || n != 0 {
+		t.Fatalf("failed batch interned %d references to %d payloads, want none", refs, n)
+	}
+}
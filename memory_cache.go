@@ -0,0 +1,471 @@
+package once_cache
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultShards = 32
+	// evictionSampleSize is the minimum number of entries sampled to pick eviction victims.
+	evictionSampleSize = 64
+)
+
+// MemoryOption configures a MemoryCache.
+type MemoryOption func(*MemoryCache)
+
+// WithShards sets the number of mutex-guarded shards of the default storage. It defaults to 32.
+func WithShards(n int) MemoryOption {
+	return func(c *MemoryCache) {
+		c.shards = n
+	}
+}
+
+// WithHasher sets the hash and shard functions picking the shard of a key in the default storage,
+// FNVHasher and ModuloShard by default.
+func WithHasher(hasher Hasher, shardFn ShardFunc) MemoryOption {
+	return func(c *MemoryCache) {
+		c.hasher = hasher
+		c.shardFn = shardFn
+	}
+}
+
+// WithSupervisor runs the background expiry under s instead of DefaultSupervisor.
+func WithSupervisor(s *Supervisor) MemoryOption {
+	return func(c *MemoryCache) {
+		c.supervisor = s
+	}
+}
+
+// WithSyncMap selects a sync.Map based storage instead of the sharded one. Reads are nearly lock-free,
+// which suits read-dominated workloads with a stable key set, while frequent writes of new keys are slower.
+func WithSyncMap() MemoryOption {
+	return func(c *MemoryCache) {
+		c.useSyncMap = true
+	}
+}
+
+// WithCleanupInterval enables a background sweep removing expired entries every interval.
+// It is a shorthand for WithExpiryStrategy(PeriodicExpiry(interval)).
+func WithCleanupInterval(interval time.Duration) MemoryOption {
+	return WithExpiryStrategy(PeriodicExpiry(interval))
+}
+
+// WithByteValues stores values serialized with codec instead of as live objects, and decodes them on Get.
+// Byte slices contain no pointers, so caches with millions of entries cost the garbage collector far less
+// to scan, at the price of encoding on Set and decoding on every Get. Values the codec cannot encode are not stored.
+func WithByteValues(codec Codec) MemoryOption {
+	return func(c *MemoryCache) {
+		c.codec = codec
+	}
+}
+
+// WithMaxEntries bounds the number of entries. When a Set exceeds the bound, entries are evicted
+// in order of priority and then recency, see SetWithPriority. Zero means unbounded.
+func WithMaxEntries(n int) MemoryOption {
+	return func(c *MemoryCache) {
+		c.maxEntries.Store(int64(n))
+	}
+}
+
+// WithStaleRetention keeps expired entries for d after they expire. Retained entries are misses for Get
+// but can still be read with GetStale, which lets OnceCache serve them when a reload fails.
+func WithStaleRetention(d time.Duration) MemoryOption {
+	return func(c *MemoryCache) {
+		c.staleRetention = d
+	}
+}
+
+// MemoryCache is a struct that implements the ICache interface with an in-process map.
+type MemoryCache struct {
+	storage    memoryStorage
+	shards     int
+	hasher     Hasher
+	shardFn    ShardFunc
+	useSyncMap bool
+	supervisor *Supervisor
+	expiry     ExpiryStrategy
+	codec      Codec
+	maxEntries atomic.Int64
+	versions   atomic.Uint64
+	// staleRetention is how long expired entries are kept for GetStale.
+	staleRetention time.Duration
+	sliding        bool
+	maxAge         time.Duration
+	equal          func(stored, expected any) bool
+	random         Random
+
+	evictMu sync.RWMutex
+	onEvict []func(key string, reason EvictionReason)
+
+	tagMu    sync.Mutex
+	tagIndex map[string]map[string]struct{}
+	tagRefs  int
+
+	indexes  map[string]*valueIndex
+	interned *internPool
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// Set stores the value with the specified time to live and normal priority.
+// A non-positive duration never expires.
+func (c *MemoryCache) Set(key string, value any, d time.Duration) {
+	c.SetWithPriority(key, value, d, PriorityNormal)
+}
+
+// SetWithPriority stores the value with the specified time to live and eviction priority.
+// Under eviction pressure, lower priority entries are evicted before higher priority ones.
+func (c *MemoryCache) SetWithPriority(key string, value any, d time.Duration, priority Priority) {
+	c.indexValue(key, value)
+	if c.codec != nil {
+		data, err := c.codec.Marshal(value)
+		if err != nil {
+			// Do not leave a previous value behind for a key that could not be stored.
+			c.storage.delete(key)
+			return
+		}
+		value = c.intern(data)
+	}
+	c.storage.store(key, c.newEntry(value, d, priority))
+	c.enforceMaxEntries()
+}
+
+// SetOption configures a single write with SetWithOptions.
+type SetOption func(*memoryEntry)
+
+// WithGrace keeps the entry for grace after it expires. Within the grace period the entry is a miss for Get,
+// but is still served by GetStale, and so by OnceCache as configured with WithStalePolicy or WithStaleOK.
+// It extends the cache-wide WithStaleRetention for the entry.
+func WithGrace(grace time.Duration) SetOption {
+	return func(e *memoryEntry) {
+		e.grace = int64(max(0, grace))
+	}
+}
+
+// SetWithOptions stores the value with the specified time to live, configured with opts.
+func (c *MemoryCache) SetWithOptions(key string, value any, d time.Duration, opts ...SetOption) {
+	c.indexValue(key, value)
+	if c.codec != nil {
+		data, err := c.codec.Marshal(value)
+		if err != nil {
+			c.storage.delete(key)
+			return
+		}
+		value = c.intern(data)
+	}
+	e := c.newEntry(value, d, PriorityNormal)
+	for _, opt := range opts {
+		opt(&e)
+	}
+	c.storage.store(key, e)
+	c.enforceMaxEntries()
+}
+
+// newEntry creates an entry for an encoded value with the next version.
+func (c *MemoryCache) newEntry(value any, d time.Duration, priority Priority) memoryEntry {
+	now := time.Now().UnixNano()
+	e := memoryEntry{value: value, createdAt: now, lastAccess: now, version: c.versions.Add(1), priority: priority}
+	if d > 0 {
+		e.expiresAt = now + int64(d)
+		if c.sliding {
+			e.ttl = int64(d)
+		}
+	}
+	if c.maxAge > 0 {
+		e.capAge(now + int64(c.maxAge))
+	}
+	return e
+}
+
+// SetIfVersion stores the value only if the entry's current version, as reported by GetWithInfo, is version,
+// so that concurrent read-modify-write cycles do not overwrite each other. A zero version requires the key
+// to be missing or expired. It returns the new version and whether the value was stored.
+// Versions come from a counter shared by all keys, so a deleted and re-created key never reuses one.
+func (c *MemoryCache) SetIfVersion(key string, value any, version uint64, d time.Duration) (uint64, bool) {
+	c.indexValue(key, value)
+	if c.codec != nil {
+		data, err := c.codec.Marshal(value)
+		if err != nil {
+			return 0, false
+		}
+		value = c.intern(data)
+	}
+	e := c.newEntry(value, d, PriorityNormal)
+	if !c.storage.storeIf(key, e, func(old memoryEntry, ok bool) bool {
+		if !ok || old.expired(e.createdAt) {
+			return version == 0
+		}
+		return old.version == version
+	}) {
+		return 0, false
+	}
+	c.enforceMaxEntries()
+	return e.version, true
+}
+
+// Get retrieves the value for the key if it exists and has not expired.
+// Hits on a cache without byte values do not allocate.
+func (c *MemoryCache) Get(key string) (any, bool) {
+	now := time.Now().UnixNano()
+	e, ok := c.storage.load(key, now)
+	if !ok {
+		return nil, false
+	}
+	if e.expired(now) {
+		c.expire(key, now)
+		return nil, false
+	}
+	e = c.slide(key, e, now)
+	return c.decode(e)
+}
+
+// decode returns the value of an entry, decoding it when the cache stores byte values.
+func (c *MemoryCache) decode(e memoryEntry) (any, bool) {
+	if c.codec != nil {
+		value, err := c.codec.Unmarshal(e.value.([]byte))
+		if err != nil {
+			return nil, false
+		}
+		return value, true
+	}
+	return e.value, true
+}
+
+// Delete removes the key.
+func (c *MemoryCache) Delete(key string) {
+	c.storage.delete(key)
+}
+
+// Len returns the number of stored entries, including expired entries not yet removed.
+func (c *MemoryCache) Len() int {
+	return c.storage.len()
+}
+
+// EstimatedBytes estimates the memory held by the cache's keys and values. Sizes are measured exactly
+// from the encoded values when the cache stores byte values, and approximated by walking live objects
+// otherwise. At most sampleSize entries are measured and the result is extrapolated to the whole cache;
+// a non-positive sampleSize measures every entry.
+func (c *MemoryCache) EstimatedBytes(sampleSize int) int64 {
+	n := c.storage.len()
+	if n == 0 {
+		return 0
+	}
+	if sampleSize <= 0 || sampleSize > n {
+		sampleSize = n
+	}
+	var total int64
+	sampled := 0
+	c.storage.sample(sampleSize, func(key string, e memoryEntry) {
+		total += int64(len(key)) + int64(memoryEntryOverhead) + int64(estimateSize(e.value))
+		sampled++
+	})
+	if sampled == 0 {
+		return 0
+	}
+	return total * int64(n) / int64(sampled)
+}
+
+// SetMaxEntries changes the bound on the number of entries at runtime, evicting entries
+// right away if the cache is over the new bound. Zero means unbounded.
+func (c *MemoryCache) SetMaxEntries(n int) {
+	c.maxEntries.Store(int64(n))
+	c.enforceMaxEntries()
+}
+
+func (c *MemoryCache) enforceMaxEntries() {
+	if limit := int(c.maxEntries.Load()); limit > 0 {
+		if over := c.storage.len() - limit; over > 0 {
+			c.EvictCold(over)
+		}
+	}
+}
+
+// EvictCold removes up to n of the coldest entries and returns how many were removed: expired entries first,
+// then lower priority entries, then the least recently used. Recency is approximated from random samples
+// of entries, as in Redis' approximated LRU.
+func (c *MemoryCache) EvictCold(n int) int {
+	evicted := 0
+	for evicted < n {
+		remaining := n - evicted
+		candidates := c.coldest(remaining)
+		if len(candidates) == 0 {
+			break
+		}
+		removed := 0
+		for _, cand := range candidates {
+			if c.storage.deleteIf(cand.key, func(e memoryEntry) bool {
+				// Skip entries that were written or read since they were sampled.
+				return e.lastAccess == cand.lastAccess
+			}) {
+				removed++
+				if cand.expired {
+					c.notifyEvict(cand.key, EvictionExpired)
+				} else {
+					c.notifyEvict(cand.key, EvictionCapacity)
+				}
+			}
+		}
+		if removed == 0 {
+			break
+		}
+		evicted += removed
+	}
+	return evicted
+}
+
+// evictionCandidate is a sampled entry considered for eviction.
+type evictionCandidate struct {
+	key        string
+	lastAccess int64
+	priority   Priority
+	expired    bool
+}
+
+// coldest samples the storage and returns up to n candidates ordered from coldest to warmest.
+func (c *MemoryCache) coldest(n int) []evictionCandidate {
+	now := time.Now().UnixNano()
+	candidates := make([]evictionCandidate, 0, max(4*n, evictionSampleSize))
+	c.storage.sample(cap(candidates), func(key string, e memoryEntry) {
+		candidates = append(candidates, evictionCandidate{key: key, lastAccess: e.lastAccess, priority: e.priority, expired: e.expired(now)})
+	})
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.expired != b.expired {
+			return a.expired
+		}
+		if a.priority != b.priority {
+			return a.priority < b.priority
+		}
+		return a.lastAccess < b.lastAccess
+	})
+	if len(candidates) > n {
+		candidates = candidates[:n]
+	}
+	return candidates
+}
+
+// GetWithInfo retrieves the value for the key along with its metadata, if it exists and has not expired.
+func (c *MemoryCache) GetWithInfo(key string) (any, EntryInfo, bool) {
+	now := time.Now().UnixNano()
+	e, ok := c.storage.load(key, now)
+	if !ok {
+		return nil, EntryInfo{}, false
+	}
+	if e.expired(now) {
+		c.expire(key, now)
+		return nil, EntryInfo{}, false
+	}
+	e = c.slide(key, e, now)
+	value, ok := c.decode(e)
+	if !ok {
+		return nil, EntryInfo{}, false
+	}
+	return value, e.info(), true
+}
+
+// GetStale retrieves the value for the key even if it has expired, as long as it is still retained,
+// see WithStaleRetention and WithGrace. The returned time is the entry's expiry, zero if it never expires.
+func (c *MemoryCache) GetStale(key string) (any, time.Time, bool) {
+	now := time.Now().UnixNano()
+	e, ok := c.storage.load(key, now)
+	if !ok || e.removable(now, int64(c.staleRetention)) {
+		return nil, time.Time{}, false
+	}
+	value, ok := c.decode(e)
+	if !ok {
+		return nil, time.Time{}, false
+	}
+	var expiresAt time.Time
+	if e.expiresAt != 0 {
+		expiresAt = time.Unix(0, e.expiresAt)
+	}
+	return value, expiresAt, true
+}
+
+// OnEvict registers a function called for every entry removed because it expired or to make room.
+// Entries removed with Delete or overwritten with Set are not reported. f is called synchronously
+// from the operation that removed the entry and must not block.
+func (c *MemoryCache) OnEvict(f func(key string, reason EvictionReason)) {
+	c.evictMu.Lock()
+	c.onEvict = append(c.onEvict, f)
+	c.evictMu.Unlock()
+}
+
+func (c *MemoryCache) notifyEvict(key string, reason EvictionReason) {
+	c.evictMu.RLock()
+	defer c.evictMu.RUnlock()
+	for _, f := range c.onEvict {
+		f(key, reason)
+	}
+}
+
+// expire removes key if its entry is no longer retained at now and reports the removal.
+func (c *MemoryCache) expire(key string, now int64) {
+	if c.storage.deleteExpired(key, now, int64(c.staleRetention)) {
+		c.notifyEvict(key, EvictionExpired)
+	}
+}
+
+// DeleteExpired removes all expired entries that are no longer retained.
+func (c *MemoryCache) DeleteExpired() {
+	now := time.Now().UnixNano()
+	c.storage.rangeEntries(func(key string, e memoryEntry) bool {
+		if e.removable(now, int64(c.staleRetention)) {
+			c.expire(key, now)
+		}
+		return true
+	})
+}
+
+// Clear removes every entry in constant time, whatever the number of entries, by starting over with empty
+// maps rather than deleting entries one by one; the old entries are reclaimed by the garbage collector.
+// Removed entries are not reported to OnEvict.
+func (c *MemoryCache) Clear() {
+	c.tagMu.Lock()
+	c.storage.clear()
+	c.tagIndex, c.tagRefs = nil, 0
+	c.tagMu.Unlock()
+	for _, x := range c.indexes {
+		x.mu.Lock()
+		x.keys, x.refs = nil, 0
+		x.mu.Unlock()
+	}
+	if p := c.interned; p != nil {
+		p.mu.Lock()
+		p.values, p.refs = make(map[uint64][]*internedValue), 0
+		p.mu.Unlock()
+	}
+}
+
+// Close stops the background expiry, if any.
+func (c *MemoryCache) Close() {
+	c.stopOnce.Do(func() {
+		close(c.stop)
+	})
+}
+
+// NewMemoryCache creates a new instance of MemoryCache configured with the specified options.
+func NewMemoryCache(opts ...MemoryOption) *MemoryCache {
+	c := &MemoryCache{
+		shards:     defaultShards,
+		supervisor: DefaultSupervisor,
+		stop:       make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.random = randomOrDefault(c.random)
+	if c.useSyncMap {
+		c.storage = newSyncMapStorage()
+	} else {
+		c.storage = newShardedStorage(c.shards, c.hasher, c.shardFn)
+	}
+	if c.expiry.background() {
+		c.supervisor.Go("janitor", c.stop, c.janitor)
+	}
+	return c
+}
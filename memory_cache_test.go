@@ -0,0 +1,90 @@
+package once_cache
+
+import (
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// benchmarkKeys is the stable key set of the MemoryCache benchmarks.
+const benchmarkKeys = 1 << 12
+
+var memoryStorages = []struct {
+	name string
+	opts []MemoryOption
+}{
+	{"Sharded", nil},
+	{"SyncMap", []MemoryOption{WithSyncMap()}},
+}
+
+// newBenchmarkCache returns a cache holding the benchmark keys, and the keys with values boxed beforehand,
+// so that benchmarks measure the store rather than the boxing of values.
+func newBenchmarkCache(b *testing.B, opts []MemoryOption) (*MemoryCache, []string, []any) {
+	c := NewMemoryCache(opts...)
+	b.Cleanup(c.Close)
+	keys := make([]string, benchmarkKeys)
+	values := make([]any, benchmarkKeys)
+	for i := range keys {
+		keys[i], values[i] = "key:"+strconv.Itoa(i), i
+		c.Set(keys[i], values[i], time.Hour)
+	}
+	return c, keys, values
+}
+
+// runMemoryCacheParallel runs op over every storage from parallel goroutines, each walking the keys from
+// its own offset.
+func runMemoryCacheParallel(b *testing.B, op func(c *MemoryCache, key string, value any, i int)) {
+	for _, storage := range memoryStorages {
+		b.Run(storage.name, func(b *testing.B) {
+			c, keys, values := newBenchmarkCache(b, storage.opts)
+			var offset atomic.Int64
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := int(offset.Add(benchmarkKeys / 16))
+				for pb.Next() {
+					op(c, keys[i%benchmarkKeys], values[i%benchmarkKeys], i)
+					i++
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkMemoryCacheGet(b *testing.B) {
+	runMemoryCacheParallel(b, func(c *MemoryCache, key string, _ any, _ int) {
+		c.Get(key)
+	})
+}
+
+func BenchmarkMemoryCacheSet(b *testing.B) {
+	runMemoryCacheParallel(b, func(c *MemoryCache, key string, value any, _ int) {
+		c.Set(key, value, time.Hour)
+	})
+}
+
+// BenchmarkMemoryCacheReadMostly is the read-dominated workload sync.Map storage is meant for:
+// one Set for every 15 Gets over a stable key set.
+func BenchmarkMemoryCacheReadMostly(b *testing.B) {
+	runMemoryCacheParallel(b, func(c *MemoryCache, key string, value any, i int) {
+		if i%16 == 0 {
+			c.Set(key, value, time.Hour)
+		} else {
+			c.Get(key)
+		}
+	})
+}
+
+func BenchmarkMemoryCacheGetSerial(b *testing.B) {
+	for _, storage := range memoryStorages {
+		b.Run(storage.name, func(b *testing.B) {
+			c, keys, _ := newBenchmarkCache(b, storage.opts)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				c.Get(keys[i%benchmarkKeys])
+			}
+		})
+	}
+}
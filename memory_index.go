@@ -0,0 +1,105 @@
+package once_cache
+
+import "sync"
+
+// WithIndex maintains a secondary index called name over the attributes extract returns for every value
+// stored, so that entries cached by ID can also be found by other attributes with GetByIndex, such as users
+// by email, without caching them twice under keys that drift apart. extract receives every stored value,
+// including those of other types, and should return nil for values it does not index.
+func WithIndex(name string, extract func(value any) []string) MemoryOption {
+	return func(c *MemoryCache) {
+		if c.indexes == nil {
+			c.indexes = make(map[string]*valueIndex)
+		}
+		c.indexes[name] = &valueIndex{extract: extract}
+	}
+}
+
+// valueIndex maps attributes to the keys whose values had them when stored. References to removed or
+// overwritten entries are left behind and skipped by lookups, which check the current value, and the index
+// is rebuilt from the storage when they pile up, like the tag index.
+type valueIndex struct {
+	extract func(value any) []string
+
+	mu   sync.Mutex
+	keys map[string]map[string]struct{}
+	refs int
+}
+
+func (x *valueIndex) add(key string, attrs []string) {
+	if x.keys == nil {
+		x.keys = make(map[string]map[string]struct{})
+	}
+	for _, attr := range attrs {
+		keys, ok := x.keys[attr]
+		if !ok {
+			keys = make(map[string]struct{})
+			x.keys[attr] = keys
+		}
+		if _, ok := keys[key]; !ok {
+			keys[key] = struct{}{}
+			x.refs++
+		}
+	}
+}
+
+// has reports whether value currently has attr.
+func (x *valueIndex) has(value any, attr string) bool {
+	for _, a := range x.extract(value) {
+		if a == attr {
+			return true
+		}
+	}
+	return false
+}
+
+// indexValue records key under the attributes of value in every index. It runs before the value is stored,
+// so a lookup never misses a stored value; references to values that end up not stored are skipped.
+func (c *MemoryCache) indexValue(key string, value any) {
+	for _, x := range c.indexes {
+		attrs := x.extract(value)
+		if len(attrs) == 0 {
+			continue
+		}
+		x.mu.Lock()
+		// Rebuild before adding, since the storage does not hold value yet and a rebuild would drop it.
+		if x.refs >= 2*c.storage.len()+tagIndexSlack {
+			c.rebuildIndex(x)
+		}
+		x.add(key, attrs)
+		x.mu.Unlock()
+	}
+}
+
+// rebuildIndex recreates x from the stored entries. x.mu must be held.
+func (c *MemoryCache) rebuildIndex(x *valueIndex) {
+	x.keys, x.refs = nil, 0
+	c.storage.rangeEntries(func(key string, e memoryEntry) bool {
+		if value, ok := c.decode(e); ok {
+			x.add(key, x.extract(value))
+		}
+		return true
+	})
+}
+
+// GetByIndex returns the live entries whose current values have attr in the index called name,
+// see WithIndex. It returns nil if there is no such index.
+func (c *MemoryCache) GetByIndex(name, attr string) map[string]any {
+	x, ok := c.indexes[name]
+	if !ok {
+		return nil
+	}
+	x.mu.Lock()
+	keys := make([]string, 0, len(x.keys[attr]))
+	for key := range x.keys[attr] {
+		keys = append(keys, key)
+	}
+	x.mu.Unlock()
+	values := c.getAll(keys, func(string, memoryEntry) bool { return true })
+	for key, value := range values {
+		if !x.has(value, attr) {
+			delete(values, key)
+		}
+	}
+	return values
+}
@@ -0,0 +1,23 @@
+package once_cache
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestIndexKeepsOverwrittenKeyAcrossRebuilds(t *testing.T) {
+	c := NewMemoryCache(WithIndex("version", func(value any) []string {
+		if v, ok := value.(int); ok {
+			return []string{strconv.Itoa(v)}
+		}
+		return nil
+	}))
+	// Each overwrite leaves a stale reference behind, so the index is rebuilt several times.
+	for i := 0; i < 3*tagIndexSlack; i++ {
+		c.Set("key", i, 0)
+		got := c.GetByIndex("version", strconv.Itoa(i))
+		if _, ok := got["key"]; !ok {
+			t.Fatalf("overwrite %d: key missing from the index", i)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package once_cache
+
+import (
+	"bytes"
+	"hash/maphash"
+	"sync"
+	"unsafe"
+)
+
+// WithInterning stores identical encoded values once, shared by every key holding them, which saves memory
+// when many keys map to a few distinct payloads, such as feature flags or status records. Values are
+// recognized by a hash of their encoding, so it requires WithByteValues and has no effect otherwise.
+// Shared copies are reference counted and released once no entry holds them; counts are reconciled with
+// the stored entries lazily, when references to removed entries pile up.
+func WithInterning() MemoryOption {
+	return func(c *MemoryCache) {
+		c.interned = &internPool{seed: maphash.MakeSeed(), values: make(map[uint64][]*internedValue)}
+	}
+}
+
+// InternStats describes the values shared by WithInterning.
+type InternStats struct {
+	// Distinct is the number of distinct payloads stored.
+	Distinct int
+	// Entries is the number of entries holding one of them.
+	Entries int
+	// SavedBytes is the memory saved by sharing the payloads rather than storing a copy per entry.
+	SavedBytes int64
+}
+
+// internPool holds the shared copies of encoded values by hash.
+type internPool struct {
+	seed maphash.Seed
+
+	mu     sync.Mutex
+	values map[uint64][]*internedValue
+	// refs counts the references handed out, including those of entries removed since the last reconciliation.
+	refs int
+}
+
+type internedValue struct {
+	data []byte
+	refs int
+}
+
+// intern returns the shared copy of data, adding data to the pool if it is new.
+func (c *MemoryCache) intern(data []byte) []byte {
+	p := c.interned
+	if p == nil {
+		return data
+	}
+	h := maphash.Bytes(p.seed, data)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.refs > 2*c.storage.len()+tagIndexSlack {
+		c.reconcileInterned()
+	}
+	p.refs++
+	for _, v := range p.values[h] {
+		if bytes.Equal(v.data, data) {
+			v.refs++
+			return v.data
+		}
+	}
+	p.values[h] = append(p.values[h], &internedValue{data: data, refs: 1})
+	return data
+}
+
+// reconcileInterned recounts the references to the shared copies from the stored entries and releases
+// those no entry holds anymore. c.interned.mu must be held.
+func (c *MemoryCache) reconcileInterned() {
+	p := c.interned
+	held := make(map[*byte]int)
+	c.storage.rangeEntries(func(key string, e memoryEntry) bool {
+		if data, ok := e.value.([]byte); ok && len(data) > 0 {
+			held[unsafe.SliceData(data)]++
+		}
+		return true
+	})
+	p.refs = 0
+	for h, values := range p.values {
+		kept := values[:0]
+		for _, v := range values {
+			if v.refs = held[unsafe.SliceData(v.data)]; v.refs > 0 {
+				kept = append(kept, v)
+				p.refs += v.refs
+			}
+		}
+		if len(kept) == 0 {
+			delete(p.values, h)
+		} else {
+			p.values[h] = kept
+		}
+	}
+}
+
+// InternStats reconciles the values shared by WithInterning with the stored entries and describes them.
+func (c *MemoryCache) InternStats() InternStats {
+	p := c.interned
+	if p == nil {
+		return InternStats{}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	c.reconcileInterned()
+	var stats InternStats
+	for _, values := range p.values {
+		for _, v := range values {
+			stats.Distinct++
+			stats.Entries += v.refs
+			stats.SavedBytes += int64(v.refs-1) * int64(len(v.data))
+		}
+	}
+	return stats
+}
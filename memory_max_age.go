@@ -0,0 +1,92 @@
+package once_cache
+
+import "time"
+
+// WithSlidingExpiration makes reads extend the expiry of entries by their time to live, so that entries
+// expire only once they have not been read for that long. Entries that never expire are not affected.
+// Combine it with WithMaxEntryAge so that frequently read values are still reloaded eventually.
+func WithSlidingExpiration() MemoryOption {
+	return func(c *MemoryCache) {
+		c.sliding = true
+	}
+}
+
+// WithMaxEntryAge bounds how long any entry lives after it was written, however often it is read or
+// touched: neither WithSlidingExpiration nor Touch extends an entry past its max age, and entries that
+// would never expire expire at it. Use WithMaxAge to bound a single entry.
+func WithMaxEntryAge(d time.Duration) MemoryOption {
+	return func(c *MemoryCache) {
+		c.maxAge = d
+	}
+}
+
+// WithMaxAge bounds how long the entry lives after it is written, see WithMaxEntryAge.
+// It can only shorten the cache-wide max age.
+func WithMaxAge(d time.Duration) SetOption {
+	return func(e *memoryEntry) {
+		if d > 0 {
+			e.capAge(e.createdAt + int64(d))
+		}
+	}
+}
+
+// capAge bounds the entry's life to deadline.
+func (e *memoryEntry) capAge(deadline int64) {
+	if e.deadline == 0 || deadline < e.deadline {
+		e.deadline = deadline
+	}
+	if e.expiresAt == 0 || e.expiresAt > e.deadline {
+		e.expiresAt = e.deadline
+	}
+}
+
+// extendedExpiry returns the expiry of the entry extended to expiresAt, or to its max age if that comes first.
+// A zero expiresAt never expires.
+func (e *memoryEntry) extendedExpiry(expiresAt int64) int64 {
+	if e.deadline != 0 && (expiresAt == 0 || expiresAt > e.deadline) {
+		return e.deadline
+	}
+	return expiresAt
+}
+
+// Touch resets the time to live of the key to d, as if its value had just been written, without
+// changing its value or version. A non-positive duration never expires. The entry's max age still
+// applies, see WithMaxEntryAge. It reports whether the key exists and has not expired.
+func (c *MemoryCache) Touch(key string, d time.Duration) bool {
+	for {
+		now := time.Now().UnixNano()
+		e, ok := c.storage.load(key, now)
+		if !ok || e.expired(now) {
+			return false
+		}
+		var expiresAt int64
+		if d > 0 {
+			expiresAt = now + int64(d)
+		}
+		if c.extend(key, e, e.extendedExpiry(expiresAt)) {
+			return true
+		}
+		// The entry was rewritten concurrently; touch the new one.
+	}
+}
+
+// slide extends the expiry of an entry being read, see WithSlidingExpiration, and returns the entry as
+// extended. Extensions smaller than accessResolution are skipped, so hot keys are not rewritten on every read.
+func (c *MemoryCache) slide(key string, e memoryEntry, now int64) memoryEntry {
+	if e.ttl == 0 {
+		return e
+	}
+	if expiresAt := e.extendedExpiry(now + e.ttl); expiresAt-e.expiresAt >= accessResolution && c.extend(key, e, expiresAt) {
+		e.expiresAt = expiresAt
+	}
+	return e
+}
+
+// extend sets the expiry of the entry e read for key, reporting false if key was rewritten since.
+func (c *MemoryCache) extend(key string, e memoryEntry, expiresAt int64) bool {
+	version := e.version
+	e.expiresAt = expiresAt
+	return c.storage.storeIf(key, e, func(old memoryEntry, ok bool) bool {
+		return ok && old.version == version
+	})
+}
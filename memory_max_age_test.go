@@ -0,0 +1,45 @@
+package once_cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSlidingExpirationStopsAtTheMaxEntryAge(t *testing.T) {
+	// Reads extend entries by at least accessResolution, so the durations are well above it.
+	c := NewMemoryCache(WithSlidingExpiration(), WithMaxEntryAge(600*time.Millisecond))
+	defer c.Close()
+	c.Set("a", 1, 300*time.Millisecond)
+	// Reads keep the entry alive past its time to live.
+	for i := 0; i < 3; i++ {
+		time.Sleep(150 * time.Millisecond)
+		if _, ok := c.Get("a"); !ok {
+			t.Fatalf("read %d missed, want sliding expiration to keep the entry", i)
+		}
+	}
+	time.Sleep(200 * time.Millisecond)
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("entry outlived its max age")
+	}
+}
+
+func TestTouchResetsTheTTLWithinTheMaxAge(t *testing.T) {
+	c := NewMemoryCache()
+	defer c.Close()
+	if c.Touch("missing", time.Minute) {
+		t.Fatal("Touch of a missing key reported true")
+	}
+	c.SetWithOptions("a", 1, 10*time.Millisecond, WithMaxAge(40*time.Millisecond))
+	time.Sleep(5 * time.Millisecond)
+	if !c.Touch("a", time.Hour) {
+		t.Fatal("Touch of a live key reported false")
+	}
+	time.Sleep(15 * time.Millisecond)
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("Touch did not extend the entry")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("Touch extended the entry past its max age")
+	}
+}
@@ -0,0 +1,127 @@
+package once_cache
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// IColdEvicter is implemented by caches that can evict their least recently used entries, such as MemoryCache.
+type IColdEvicter interface {
+	// Len returns the number of entries in the cache
+	Len() int
+
+	// EvictCold removes up to n of the least recently used entries and returns how many were removed
+	EvictCold(n int) int
+}
+
+// MonitorOption configures a MemoryMonitor.
+type MonitorOption func(*MemoryMonitor)
+
+// WithMonitorInterval sets how often the monitor checks memory usage. It defaults to one second.
+func WithMonitorInterval(interval time.Duration) MonitorOption {
+	return func(m *MemoryMonitor) {
+		m.interval = interval
+	}
+}
+
+// WithEvictFraction sets the fraction of each registered cache evicted per check while memory usage
+// is above the threshold. It defaults to 0.1.
+func WithEvictFraction(fraction float64) MonitorOption {
+	return func(m *MemoryMonitor) {
+		m.fraction = fraction
+	}
+}
+
+// WithMemorySignal replaces the heap usage reading from runtime.MemStats with a user-provided signal,
+// such as the cgroup memory usage of the container.
+func WithMemorySignal(signal func() uint64) MonitorOption {
+	return func(m *MemoryMonitor) {
+		m.signal = signal
+	}
+}
+
+// MemoryMonitor watches memory usage and proactively evicts cold entries from the registered caches
+// when it crosses a threshold, so traffic spikes degrade hit ratios instead of getting the process killed.
+type MemoryMonitor struct {
+	threshold uint64
+	interval  time.Duration
+	fraction  float64
+	signal    func() uint64
+
+	mu     sync.Mutex
+	caches []IColdEvicter
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// Register adds a cache whose entries may be evicted under memory pressure.
+func (m *MemoryMonitor) Register(cache IColdEvicter) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.caches = append(m.caches, cache)
+}
+
+// Check reads the memory usage once and, if it is above the threshold, evicts cold entries from every
+// registered cache. It returns the number of entries evicted.
+func (m *MemoryMonitor) Check() int {
+	if m.signal() < m.threshold {
+		return 0
+	}
+	m.mu.Lock()
+	caches := append([]IColdEvicter(nil), m.caches...)
+	m.mu.Unlock()
+	evicted := 0
+	for _, cache := range caches {
+		n := int(float64(cache.Len())*m.fraction + 0.5)
+		if n > 0 {
+			evicted += cache.EvictCold(n)
+		}
+	}
+	return evicted
+}
+
+// Close stops the monitor.
+func (m *MemoryMonitor) Close() {
+	m.stopOnce.Do(func() {
+		close(m.stop)
+	})
+}
+
+func (m *MemoryMonitor) run() {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.Check()
+		case <-m.stop:
+			return
+		}
+	}
+}
+
+// heapInUse reads the heap usage from runtime.MemStats.
+func heapInUse() uint64 {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.HeapInuse
+}
+
+// NewMemoryMonitor creates and starts a MemoryMonitor evicting cold entries while memory usage,
+// the in-use heap by default, is at or above threshold bytes.
+func NewMemoryMonitor(threshold uint64, opts ...MonitorOption) *MemoryMonitor {
+	m := &MemoryMonitor{
+		threshold: threshold,
+		interval:  time.Second,
+		fraction:  0.1,
+		signal:    heapInUse,
+		stop:      make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	go m.run()
+	return m
+}
@@ -0,0 +1,587 @@
+package once_cache
+
+import (
+	"runtime"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+// accessResolution is the granularity of entry access times. Coarse access times spare hot keys
+// from writing to shared memory on every read.
+const accessResolution = int64(100 * time.Millisecond)
+
+// memoryEntry is a value stored in a MemoryCache.
+type memoryEntry struct {
+	value      any   // the value, or its encoding when the cache stores byte values
+	expiresAt  int64 // Unix nanoseconds, zero if the entry never expires
+	createdAt  int64 // Unix nanoseconds of the write
+	lastAccess int64 // Unix nanoseconds of the last read or write
+	version    uint64
+	tags       []string
+	// grace is how long the entry is retained after it expires, see WithGrace.
+	grace    int64
+	priority Priority
+	// ttl is the time to live reads extend the entry by, zero if they do not, see WithSlidingExpiration.
+	ttl int64
+	// deadline is the Unix nanoseconds past which the entry is never extended, zero if there is none,
+	// see WithMaxEntryAge.
+	deadline int64
+}
+
+// hasTag reports whether the entry was stored with tag.
+func (e *memoryEntry) hasTag(tag string) bool {
+	for _, t := range e.tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// info returns the entry's public metadata.
+func (e *memoryEntry) info() EntryInfo {
+	info := EntryInfo{
+		CreatedAt:  time.Unix(0, e.createdAt),
+		LastAccess: time.Unix(0, e.lastAccess),
+		Priority:   e.priority,
+		Version:    e.version,
+	}
+	if e.expiresAt != 0 {
+		info.ExpiresAt = time.Unix(0, e.expiresAt)
+	}
+	return info
+}
+
+// storedEntry is an entry as held by a storage. Its access time changes on reads,
+// so it is kept apart from the entry data and updated atomically.
+type storedEntry struct {
+	entry      memoryEntry
+	lastAccess atomic.Int64
+}
+
+func (p *storedEntry) set(e memoryEntry) {
+	p.entry = e
+	p.lastAccess.Store(e.lastAccess)
+}
+
+func (p *storedEntry) snapshot() memoryEntry {
+	e := p.entry
+	e.lastAccess = p.lastAccess.Load()
+	return e
+}
+
+// touch records a read at now.
+func (p *storedEntry) touch(now int64) {
+	if now-p.lastAccess.Load() >= accessResolution {
+		p.lastAccess.Store(now)
+	}
+}
+
+// memoryEntryOverhead approximates the per-entry bookkeeping cost: the entry itself, its pointer,
+// the key header and the map slot.
+const memoryEntryOverhead = int(unsafe.Sizeof(storedEntry{})) + 2*int(unsafe.Sizeof(uintptr(0))) + int(unsafe.Sizeof("")) + 8
+
+func (e *memoryEntry) expired(now int64) bool {
+	return e.expiresAt != 0 && now >= e.expiresAt
+}
+
+// removable reports whether the entry has expired and is no longer retained, for the longest of its
+// grace period and retention.
+func (e *memoryEntry) removable(now, retention int64) bool {
+	return e.expiresAt != 0 && now >= e.expiresAt+max(e.grace, retention)
+}
+
+// memoryStorage is the map implementation behind a MemoryCache.
+// Entries are passed by value so that storages are free to reuse their memory.
+type memoryStorage interface {
+	// load returns the entry for key, recording a read at now.
+	load(key string, now int64) (memoryEntry, bool)
+	store(key string, e memoryEntry)
+	// storeIf stores e if pred accepts the current entry, reporting whether it did.
+	// ok is false when there is no current entry.
+	storeIf(key string, e memoryEntry, pred func(old memoryEntry, ok bool) bool) bool
+	delete(key string)
+	// deleteExpired deletes key only if its current entry is removable at now with retention,
+	// so lazy expiry never removes a newer entry.
+	deleteExpired(key string, now, retention int64) bool
+	// deleteIf deletes key if its current entry satisfies pred, reporting whether it did.
+	deleteIf(key string, pred func(e memoryEntry) bool) bool
+	rangeEntries(f func(key string, e memoryEntry) bool)
+	// snapshot returns the entries matching match as of one point in time, among keys or among all
+	// entries if keys is nil. Entries written by batches are either all included or none of them.
+	snapshot(keys []string, match func(key string, e memoryEntry) bool) map[string]memoryEntry
+	// apply performs the operations in order, so that readers see either none or all of them.
+	apply(ops []batchOp)
+	// sample calls f for up to n entries without copying the storage. f must not modify the storage.
+	sample(n int, f func(key string, e memoryEntry))
+	len() int
+	// clear removes every entry at once by replacing the maps holding them, without visiting the entries,
+	// which are left to the garbage collector.
+	clear()
+}
+
+// entryPool recycles entries removed from a shardedStorage.
+var entryPool = sync.Pool{
+	New: func() any {
+		return new(storedEntry)
+	},
+}
+
+// shardedStorage spreads entries over mutex-guarded maps to reduce lock contention.
+// Readers copy entries under the shard lock, so entries are overwritten in place
+// and recycled through entryPool once deleted, which keeps Set free of allocations for existing keys.
+type shardedStorage struct {
+	shards  []storageShard
+	hasher  Hasher
+	shardFn ShardFunc
+	count   atomic.Int64
+	// sampled rotates the first shard of sample, so that small samples still reach every shard.
+	sampled atomic.Uint64
+}
+
+type storageShard struct {
+	mu    sync.RWMutex
+	items map[string]*storedEntry
+}
+
+func newShardedStorage(n int, hasher Hasher, shardFn ShardFunc) *shardedStorage {
+	if n < 1 {
+		n = 1
+	}
+	if hasher == nil {
+		hasher = FNVHasher{}
+	}
+	if shardFn == nil {
+		shardFn = ModuloShard
+	}
+	s := &shardedStorage{shards: make([]storageShard, n), hasher: hasher, shardFn: shardFn}
+	for i := range s.shards {
+		s.shards[i].items = make(map[string]*storedEntry)
+	}
+	return s
+}
+
+func (s *shardedStorage) shard(key string) *storageShard {
+	return &s.shards[s.index(key)]
+}
+
+func (s *shardedStorage) index(key string) int {
+	return s.shardFn(s.hasher.Sum64(key), len(s.shards))
+}
+
+func (s *shardedStorage) load(key string, now int64) (memoryEntry, bool) {
+	sh := s.shard(key)
+	sh.mu.RLock()
+	p, ok := sh.items[key]
+	var e memoryEntry
+	if ok {
+		p.touch(now)
+		e = p.snapshot()
+	}
+	sh.mu.RUnlock()
+	return e, ok
+}
+
+func (s *shardedStorage) store(key string, e memoryEntry) {
+	sh := s.shard(key)
+	sh.mu.Lock()
+	if p, ok := sh.items[key]; ok {
+		p.set(e)
+	} else {
+		p = entryPool.Get().(*storedEntry)
+		p.set(e)
+		sh.items[key] = p
+		s.count.Add(1)
+	}
+	sh.mu.Unlock()
+}
+
+func (s *shardedStorage) storeIf(key string, e memoryEntry, pred func(old memoryEntry, ok bool) bool) bool {
+	sh := s.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	p, ok := sh.items[key]
+	var old memoryEntry
+	if ok {
+		old = p.snapshot()
+	}
+	if !pred(old, ok) {
+		return false
+	}
+	if ok {
+		p.set(e)
+	} else {
+		p = entryPool.Get().(*storedEntry)
+		p.set(e)
+		sh.items[key] = p
+		s.count.Add(1)
+	}
+	return true
+}
+
+func (s *shardedStorage) apply(ops []batchOp) {
+	// Lock every involved shard in index order, so concurrent batches cannot deadlock.
+	keys := make([]string, len(ops))
+	for i, op := range ops {
+		keys[i] = op.key
+	}
+	idx := s.shardIndexes(keys)
+	for _, i := range idx {
+		s.shards[i].mu.Lock()
+	}
+	for _, op := range ops {
+		sh := s.shard(op.key)
+		if op.delete {
+			s.remove(sh, op.key)
+			continue
+		}
+		if p, ok := sh.items[op.key]; ok {
+			p.set(op.entry)
+		} else {
+			p = entryPool.Get().(*storedEntry)
+			p.set(op.entry)
+			sh.items[op.key] = p
+			s.count.Add(1)
+		}
+	}
+	for _, i := range idx {
+		s.shards[i].mu.Unlock()
+	}
+}
+
+func (s *shardedStorage) snapshot(keys []string, match func(key string, e memoryEntry) bool) map[string]memoryEntry {
+	var idx []int
+	if keys == nil {
+		idx = make([]int, len(s.shards))
+		for i := range idx {
+			idx[i] = i
+		}
+	} else {
+		idx = s.shardIndexes(keys)
+	}
+	// Hold every involved shard at once, in index order like apply.
+	for _, i := range idx {
+		s.shards[i].mu.RLock()
+	}
+	defer func() {
+		for _, i := range idx {
+			s.shards[i].mu.RUnlock()
+		}
+	}()
+	entries := make(map[string]memoryEntry)
+	if keys == nil {
+		for _, i := range idx {
+			for key, p := range s.shards[i].items {
+				if e := p.snapshot(); match(key, e) {
+					entries[key] = e
+				}
+			}
+		}
+		return entries
+	}
+	for _, key := range keys {
+		if p, ok := s.shard(key).items[key]; ok {
+			if e := p.snapshot(); match(key, e) {
+				entries[key] = e
+			}
+		}
+	}
+	return entries
+}
+
+// shardIndexes returns the sorted, deduplicated indexes of the shards holding keys.
+func (s *shardedStorage) shardIndexes(keys []string) []int {
+	idx := make([]int, 0, len(keys))
+	for _, key := range keys {
+		idx = append(idx, s.index(key))
+	}
+	sort.Ints(idx)
+	return slices.Compact(idx)
+}
+
+func (s *shardedStorage) delete(key string) {
+	sh := s.shard(key)
+	sh.mu.Lock()
+	s.remove(sh, key)
+	sh.mu.Unlock()
+}
+
+func (s *shardedStorage) deleteExpired(key string, now, retention int64) bool {
+	sh := s.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if p, ok := sh.items[key]; ok && p.entry.removable(now, retention) {
+		s.remove(sh, key)
+		return true
+	}
+	return false
+}
+
+func (s *shardedStorage) deleteIf(key string, pred func(e memoryEntry) bool) bool {
+	sh := s.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if p, ok := sh.items[key]; ok && pred(p.snapshot()) {
+		s.remove(sh, key)
+		return true
+	}
+	return false
+}
+
+func (s *shardedStorage) rangeEntries(f func(key string, e memoryEntry) bool) {
+	for i := range s.shards {
+		sh := &s.shards[i]
+		sh.mu.RLock()
+		// Copy the shard so f may modify the storage.
+		items := make(map[string]memoryEntry, len(sh.items))
+		for key, p := range sh.items {
+			items[key] = p.snapshot()
+		}
+		sh.mu.RUnlock()
+		for key, e := range items {
+			if !f(key, e) {
+				return
+			}
+		}
+	}
+}
+
+func (s *shardedStorage) sample(n int, f func(key string, e memoryEntry)) {
+	// Take an even share from as many shards as n allows, starting where the previous sample stopped;
+	// map iteration order makes each share random.
+	shards := len(s.shards)
+	visit := min(shards, n)
+	if visit <= 0 {
+		return
+	}
+	start := int((s.sampled.Add(uint64(visit)) - uint64(visit)) % uint64(shards))
+	for j := 0; j < visit && n > 0; j++ {
+		per := n / (visit - j)
+		sh := &s.shards[(start+j)%shards]
+		sh.mu.RLock()
+		taken := 0
+		for key, p := range sh.items {
+			if taken == per || n == 0 {
+				break
+			}
+			f(key, p.snapshot())
+			taken++
+			n--
+		}
+		sh.mu.RUnlock()
+	}
+}
+
+func (s *shardedStorage) len() int {
+	return int(s.count.Load())
+}
+
+func (s *shardedStorage) clear() {
+	// Hold every shard at once, in index order like apply, so no batch is cleared halfway.
+	for i := range s.shards {
+		s.shards[i].mu.Lock()
+	}
+	for i := range s.shards {
+		s.shards[i].items = make(map[string]*storedEntry)
+	}
+	s.count.Store(0)
+	for i := range s.shards {
+		s.shards[i].mu.Unlock()
+	}
+}
+
+// remove deletes key from the shard and recycles its entry. The shard must be locked for writing.
+func (s *shardedStorage) remove(sh *storageShard, key string) {
+	if p, ok := sh.items[key]; ok {
+		delete(sh.items, key)
+		s.count.Add(-1)
+		p.set(memoryEntry{})
+		entryPool.Put(p)
+	}
+}
+
+// syncMapStorage keeps entries in a sync.Map, making reads of existing keys nearly lock-free.
+// Readers hold no lock, so entry data is immutable and entries cannot be recycled.
+// Batches are made atomic with a sequence lock: seq is odd while a batch is being applied,
+// and readers retry when it changed during their read.
+type syncMapStorage struct {
+	gen     atomic.Pointer[syncMapGeneration]
+	batchMu sync.Mutex
+	seq     atomic.Uint64
+}
+
+// syncMapGeneration is the map of a syncMapStorage, replaced as a whole by clear. Writes racing with clear
+// land in the generation they loaded, which keeps its count exact.
+type syncMapGeneration struct {
+	items sync.Map
+	count atomic.Int64
+}
+
+func newSyncMapStorage() *syncMapStorage {
+	s := &syncMapStorage{}
+	s.gen.Store(new(syncMapGeneration))
+	return s
+}
+
+func (s *syncMapStorage) load(key string, now int64) (memoryEntry, bool) {
+	for {
+		seq := s.seq.Load()
+		if seq&1 == 1 {
+			runtime.Gosched()
+			continue
+		}
+		v, ok := s.gen.Load().items.Load(key)
+		if s.seq.Load() != seq {
+			continue
+		}
+		if !ok {
+			return memoryEntry{}, false
+		}
+		p := v.(*storedEntry)
+		p.touch(now)
+		return p.snapshot(), true
+	}
+}
+
+func (s *syncMapStorage) snapshot(keys []string, match func(key string, e memoryEntry) bool) map[string]memoryEntry {
+	// Keep batches out while reading, single-key writes are atomic anyway.
+	s.batchMu.Lock()
+	defer s.batchMu.Unlock()
+	g := s.gen.Load()
+	entries := make(map[string]memoryEntry)
+	if keys == nil {
+		g.items.Range(func(key, p any) bool {
+			if e := p.(*storedEntry).snapshot(); match(key.(string), e) {
+				entries[key.(string)] = e
+			}
+			return true
+		})
+		return entries
+	}
+	for _, key := range keys {
+		if p, ok := g.items.Load(key); ok {
+			if e := p.(*storedEntry).snapshot(); match(key, e) {
+				entries[key] = e
+			}
+		}
+	}
+	return entries
+}
+
+func (s *syncMapStorage) apply(ops []batchOp) {
+	s.batchMu.Lock()
+	defer s.batchMu.Unlock()
+	s.seq.Add(1)
+	defer s.seq.Add(1)
+	for _, op := range ops {
+		if op.delete {
+			s.delete(op.key)
+		} else {
+			s.store(op.key, op.entry)
+		}
+	}
+}
+
+func (s *syncMapStorage) store(key string, e memoryEntry) {
+	p := new(storedEntry)
+	p.set(e)
+	g := s.gen.Load()
+	if _, loaded := g.items.Swap(key, p); !loaded {
+		g.count.Add(1)
+	}
+}
+
+func (s *syncMapStorage) storeIf(key string, e memoryEntry, pred func(old memoryEntry, ok bool) bool) bool {
+	p := new(storedEntry)
+	p.set(e)
+	g := s.gen.Load()
+	for {
+		v, ok := g.items.Load(key)
+		var old memoryEntry
+		if ok {
+			old = v.(*storedEntry).snapshot()
+		}
+		if !pred(old, ok) {
+			return false
+		}
+		if !ok {
+			if _, loaded := g.items.LoadOrStore(key, p); !loaded {
+				g.count.Add(1)
+				return true
+			}
+		} else if g.items.CompareAndSwap(key, v, p) {
+			return true
+		}
+		// The entry changed concurrently; check again.
+	}
+}
+
+func (s *syncMapStorage) delete(key string) {
+	g := s.gen.Load()
+	if _, loaded := g.items.LoadAndDelete(key); loaded {
+		g.count.Add(-1)
+	}
+}
+
+func (s *syncMapStorage) deleteExpired(key string, now, retention int64) bool {
+	g := s.gen.Load()
+	p, ok := g.items.Load(key)
+	if ok && p.(*storedEntry).entry.removable(now, retention) && g.items.CompareAndDelete(key, p) {
+		g.count.Add(-1)
+		return true
+	}
+	return false
+}
+
+func (s *syncMapStorage) deleteIf(key string, pred func(e memoryEntry) bool) bool {
+	g := s.gen.Load()
+	p, ok := g.items.Load(key)
+	if ok && pred(p.(*storedEntry).snapshot()) && g.items.CompareAndDelete(key, p) {
+		g.count.Add(-1)
+		return true
+	}
+	return false
+}
+
+func (s *syncMapStorage) rangeEntries(f func(key string, e memoryEntry) bool) {
+	s.gen.Load().items.Range(func(key, p any) bool {
+		return f(key.(string), p.(*storedEntry).snapshot())
+	})
+}
+
+func (s *syncMapStorage) sample(n int, f func(key string, e memoryEntry)) {
+	if n <= 0 {
+		return
+	}
+	s.gen.Load().items.Range(func(key, p any) bool {
+		f(key.(string), p.(*storedEntry).snapshot())
+		n--
+		return n > 0
+	})
+}
+
+func (s *syncMapStorage) len() int {
+	return int(s.gen.Load().count.Load())
+}
+
+func (s *syncMapStorage) clear() {
+	s.batchMu.Lock()
+	defer s.batchMu.Unlock()
+	s.seq.Add(1)
+	defer s.seq.Add(1)
+	s.gen.Store(new(syncMapGeneration))
+}
+
+// fnv1a hashes a key with 64-bit FNV-1a without allocating.
+func fnv1a(key string) uint64 {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(key); i++ {
+		h ^= uint64(key[i])
+		h *= 1099511628211
+	}
+	return h
+}
@@ -0,0 +1,125 @@
+package once_cache
+
+import (
+	"strings"
+	"time"
+)
+
+// tagIndexSlack is how many stale references the tag index may hold beyond twice the number of entries
+// before it is rebuilt.
+const tagIndexSlack = 1024
+
+// SetWithTags stores the value with the specified time to live and tags, so that it can be removed
+// along with every other entry sharing one of the tags with DeleteTag.
+func (c *MemoryCache) SetWithTags(key string, value any, d time.Duration, tags ...string) {
+	c.indexValue(key, value)
+	if c.codec != nil {
+		data, err := c.codec.Marshal(value)
+		if err != nil {
+			c.storage.delete(key)
+			return
+		}
+		value = c.intern(data)
+	}
+	e := c.newEntry(value, d, PriorityNormal)
+	e.tags = append([]string(nil), tags...)
+	// Store and index together, so a concurrent DeleteTag either sees the entry or runs before it.
+	c.tagMu.Lock()
+	c.storage.store(key, e)
+	c.indexTags(key, e.tags)
+	c.tagMu.Unlock()
+	c.enforceMaxEntries()
+}
+
+// DeleteTag removes every entry stored with tag and returns how many were removed.
+func (c *MemoryCache) DeleteTag(tag string) int {
+	c.tagMu.Lock()
+	defer c.tagMu.Unlock()
+	keys := c.tagIndex[tag]
+	delete(c.tagIndex, tag)
+	c.tagRefs -= len(keys)
+	removed := 0
+	for key := range keys {
+		// The index is not updated when entries are overwritten, so check the current entry.
+		if c.storage.deleteIf(key, func(e memoryEntry) bool { return e.hasTag(tag) }) {
+			removed++
+		}
+	}
+	return removed
+}
+
+// indexTags records key under its tags. References to removed or overwritten entries are left behind
+// and skipped by DeleteTag; the index is rebuilt from the storage when they pile up. c.tagMu must be held.
+func (c *MemoryCache) indexTags(key string, tags []string) {
+	if len(tags) == 0 {
+		return
+	}
+	if c.tagIndex == nil {
+		c.tagIndex = make(map[string]map[string]struct{})
+	}
+	for _, tag := range tags {
+		keys, ok := c.tagIndex[tag]
+		if !ok {
+			keys = make(map[string]struct{})
+			c.tagIndex[tag] = keys
+		}
+		if _, ok := keys[key]; !ok {
+			keys[key] = struct{}{}
+			c.tagRefs++
+		}
+	}
+	if c.tagRefs > 2*c.storage.len()+tagIndexSlack {
+		c.rebuildTagIndex()
+	}
+}
+
+// rebuildTagIndex recreates the tag index from the stored entries. c.tagMu must be held.
+func (c *MemoryCache) rebuildTagIndex() {
+	index := make(map[string]map[string]struct{})
+	refs := 0
+	c.storage.rangeEntries(func(key string, e memoryEntry) bool {
+		for _, tag := range e.tags {
+			keys, ok := index[tag]
+			if !ok {
+				keys = make(map[string]struct{})
+				index[tag] = keys
+			}
+			keys[key] = struct{}{}
+			refs++
+		}
+		return true
+	})
+	c.tagIndex, c.tagRefs = index, refs
+}
+
+// GetAllByTag returns the live entries stored with tag, as of one point in time.
+func (c *MemoryCache) GetAllByTag(tag string) map[string]any {
+	c.tagMu.Lock()
+	keys := make([]string, 0, len(c.tagIndex[tag]))
+	for key := range c.tagIndex[tag] {
+		keys = append(keys, key)
+	}
+	c.tagMu.Unlock()
+	return c.getAll(keys, func(key string, e memoryEntry) bool { return e.hasTag(tag) })
+}
+
+// GetAllByPrefix returns the live entries whose keys start with prefix, as of one point in time.
+// It scans every entry.
+func (c *MemoryCache) GetAllByPrefix(prefix string) map[string]any {
+	return c.getAll(nil, func(key string, e memoryEntry) bool { return strings.HasPrefix(key, prefix) })
+}
+
+// getAll decodes a snapshot of the live entries matching match, among keys or all entries if keys is nil.
+func (c *MemoryCache) getAll(keys []string, match func(key string, e memoryEntry) bool) map[string]any {
+	now := time.Now().UnixNano()
+	entries := c.storage.snapshot(keys, func(key string, e memoryEntry) bool {
+		return !e.expired(now) && match(key, e)
+	})
+	values := make(map[string]any, len(entries))
+	for key, e := range entries {
+		if value, ok := c.decode(e); ok {
+			values[key] = value
+		}
+	}
+	return values
+}
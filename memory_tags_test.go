@@ -0,0 +1,45 @@
+package once_cache
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMemoryCacheDeleteTagSkipsOverwrittenEntries(t *testing.T) {
+	c := NewMemoryCache()
+	defer c.Close()
+	c.SetWithTags("a", 1, time.Minute, "t")
+	c.SetWithTags("b", 2, time.Minute, "t", "u")
+	c.SetWithTags("c", 3, time.Minute, "u")
+	// Overwritten without the tag, so DeleteTag must keep it.
+	c.Set("a", 10, time.Minute)
+
+	if got := c.GetAllByTag("t"); !reflect.DeepEqual(got, map[string]any{"b": 2}) {
+		t.Fatalf("GetAllByTag = %v, want only b", got)
+	}
+	if n := c.DeleteTag("t"); n != 1 {
+		t.Fatalf("DeleteTag = %d, want 1", n)
+	}
+	if v, ok := c.Get("a"); !ok || v != 10 {
+		t.Fatalf("Get(a) = %v, %v, want the overwritten value kept", v, ok)
+	}
+	if _, ok := c.Get("b"); ok {
+		t.Fatal("DeleteTag kept a tagged entry")
+	}
+	if got := c.GetAllByTag("u"); !reflect.DeepEqual(got, map[string]any{"c": 3}) {
+		t.Fatalf("GetAllByTag(u) = %v, want c", got)
+	}
+}
+
+func TestMemoryCacheGetAllByPrefix(t *testing.T) {
+	c := NewMemoryCache()
+	defer c.Close()
+	c.Set("user:1", 1, time.Minute)
+	c.Set("user:2", 2, time.Millisecond)
+	c.Set("order:1", 3, time.Minute)
+	time.Sleep(2 * time.Millisecond)
+	if got := c.GetAllByPrefix("user:"); !reflect.DeepEqual(got, map[string]any{"user:1": 1}) {
+		t.Fatalf("GetAllByPrefix = %v, want the live user entries", got)
+	}
+}
@@ -0,0 +1,148 @@
+package once_cache
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrRecordNotFound is returned by loaders to report that the key does not exist upstream.
+// With WithMissingFilter, such keys are remembered and later loads of them fail fast with it.
+var ErrRecordNotFound = errors.New("once_cache: record not found")
+
+// MissingFilter is a bloom filter of keys known not to exist upstream. Bloom filters have false positives,
+// so a small fraction of existing keys may be reported as missing; to bound how long a key stays marked,
+// including keys that are created upstream later, the filter is rebuilt every interval, keeping the keys
+// added during the previous interval.
+type MissingFilter struct {
+	bits   uint64
+	hashes int
+	every  time.Duration
+
+	mu       sync.RWMutex
+	current  []atomic.Uint64
+	previous []atomic.Uint64
+	rotateAt time.Time
+}
+
+// NewMissingFilter creates a new MissingFilter sized for expectedKeys keys per interval with the specified
+// false positive rate, rebuilt every interval. A non-positive interval never rebuilds the filter.
+func NewMissingFilter(expectedKeys int, falsePositiveRate float64, interval time.Duration) *MissingFilter {
+	n := float64(max(1, expectedKeys))
+	if falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+		falsePositiveRate = 0.01
+	}
+	m := math.Ceil(-n * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2))
+	words := uint64(math.Ceil(m / 64))
+	f := &MissingFilter{
+		bits:   words * 64,
+		hashes: max(1, int(math.Round(m/n*math.Ln2))),
+		every:  interval,
+	}
+	f.current = make([]atomic.Uint64, words)
+	f.previous = make([]atomic.Uint64, words)
+	if interval > 0 {
+		f.rotateAt = time.Now().Add(interval)
+	}
+	return f
+}
+
+// WithMissingFilter makes loads of keys in the filter fail with ErrRecordNotFound without calling
+// the loader, and adds keys whose loads return ErrRecordNotFound, or that a BatchFunc leaves out, to it.
+func WithMissingFilter(f *MissingFilter) Option {
+	return func(o *OnceCache) {
+		o.missingFilter = f
+	}
+}
+
+// Add records that key does not exist.
+func (f *MissingFilter) Add(key string) {
+	f.rotate()
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	h1, h2 := filterHashes(key)
+	for i := 0; i < f.hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % f.bits
+		word := &f.current[bit/64]
+		mask := uint64(1) << (bit % 64)
+		for {
+			old := word.Load()
+			if old&mask != 0 || word.CompareAndSwap(old, old|mask) {
+				break
+			}
+		}
+	}
+}
+
+// MayContain reports whether key may have been added. False means key was certainly not added
+// during the current or previous interval.
+func (f *MissingFilter) MayContain(key string) bool {
+	f.rotate()
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	h1, h2 := filterHashes(key)
+	return f.contains(f.current, h1, h2) || f.contains(f.previous, h1, h2)
+}
+
+func (f *MissingFilter) contains(words []atomic.Uint64, h1, h2 uint64) bool {
+	for i := 0; i < f.hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % f.bits
+		if words[bit/64].Load()&(uint64(1)<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Reset removes all keys.
+func (f *MissingFilter) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := range f.current {
+		f.current[i].Store(0)
+		f.previous[i].Store(0)
+	}
+}
+
+// rotate starts a new interval when the current one is over.
+func (f *MissingFilter) rotate() {
+	if f.every <= 0 {
+		return
+	}
+	now := time.Now()
+	f.mu.RLock()
+	due := !now.Before(f.rotateAt)
+	f.mu.RUnlock()
+	if !due {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if now.Before(f.rotateAt) {
+		return
+	}
+	f.previous, f.current = f.current, f.previous
+	for i := range f.current {
+		f.current[i].Store(0)
+	}
+	if now.Sub(f.rotateAt) >= f.every {
+		// More than a whole interval passed without use; the previous keys are stale too.
+		for i := range f.previous {
+			f.previous[i].Store(0)
+		}
+	}
+	f.rotateAt = now.Add(f.every)
+}
+
+// filterHashes returns the two hashes combined for double hashing.
+func filterHashes(key string) (uint64, uint64) {
+	h1 := fnv1a(key)
+	// Derive the second hash with a splitmix64 finalizer.
+	h2 := h1 + 0x9e3779b97f4a7c15
+	h2 = (h2 ^ (h2 >> 30)) * 0xbf58476d1ce4e5b9
+	h2 = (h2 ^ (h2 >> 27)) * 0x94d049bb133111eb
+	h2 ^= h2 >> 31
+	return h1, h2 | 1
+}
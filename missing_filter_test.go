@@ -0,0 +1,47 @@
+package once_cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+func TestMissingFilterFailsFastForMissingKeys(t *testing.T) {
+	f := NewMissingFilter(100, 0.01, time.Hour)
+	c := NewOnceCache(&singleflight.Group{}, NewMemoryCache(), WithMissingFilter(f))
+	loads := 0
+	load := func() (any, error) {
+		loads++
+		return nil, ErrRecordNotFound
+	}
+	for i := 0; i < 3; i++ {
+		if res := c.GetResult("ghost", load); !errors.Is(res.Err, ErrRecordNotFound) {
+			t.Fatalf("GetResult = %v, want ErrRecordNotFound", res.Err)
+		}
+	}
+	if loads != 1 {
+		t.Fatalf("loader ran %d times, want once", loads)
+	}
+	if !f.MayContain("ghost") {
+		t.Fatal("the filter does not hold the missing key")
+	}
+	f.Reset()
+	if f.MayContain("ghost") {
+		t.Fatal("the filter holds the key after Reset")
+	}
+}
+
+func TestMissingFilterForgetsKeysAfterTwoIntervals(t *testing.T) {
+	f := NewMissingFilter(100, 0.01, 20*time.Millisecond)
+	f.Add("a")
+	time.Sleep(25 * time.Millisecond)
+	if !f.MayContain("a") {
+		t.Fatal("the filter forgot a key added in the previous interval")
+	}
+	time.Sleep(45 * time.Millisecond)
+	if f.MayContain("a") {
+		t.Fatal("the filter still holds a key added two intervals ago")
+	}
+}
@@ -0,0 +1,39 @@
+package once_cache
+
+import "encoding/gob"
+
+func init() {
+	// Keep the name the sentinel had before it was exported, so that stored gob values still decode.
+	gob.RegisterName("once_cache.nilValue", NilValue{})
+}
+
+// NilValue is stored in place of a nil value, so that stores which cannot hold nil, such as those encoding
+// values, still cache it.
+//
+// A loaded nil is a value like any other: it is cached for its TTL, and OnceCache lookups return (nil, true)
+// for it, while a miss is (nil, false). Stores written by OnceCache may hold NilValue; OnceCache turns it
+// back into nil on every read, as well as a nil read from a store. Stores encoding values themselves must
+// keep it or encode it as their own nil: GobCodec registers it with gob, and it encodes as JSON null, so
+// that JSON stores read it back as nil.
+type NilValue struct{}
+
+// MarshalJSON encodes the sentinel as null.
+func (NilValue) MarshalJSON() ([]byte, error) {
+	return []byte("null"), nil
+}
+
+// wrapNil returns the value to store for a loaded value.
+func wrapNil(value any) any {
+	if value == nil {
+		return NilValue{}
+	}
+	return value
+}
+
+// unwrapNil returns the value wrapped by wrapNil.
+func unwrapNil(value any) any {
+	if _, ok := value.(NilValue); ok {
+		return nil
+	}
+	return value
+}
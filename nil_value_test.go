@@ -0,0 +1,66 @@
+package once_cache
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// jsonStore is an ICache keeping its values encoded with encoding/json, as a store written by users would.
+type jsonStore struct {
+	mu     sync.Mutex
+	values map[string][]byte
+}
+
+func (s *jsonStore) Set(key string, value any, d time.Duration) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[key] = data
+}
+
+func (s *jsonStore) Get(key string) (any, bool) {
+	s.mu.Lock()
+	data, ok := s.values[key]
+	s.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, false
+	}
+	return value, true
+}
+
+func (s *jsonStore) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.values, key)
+}
+
+func TestNilValuesRoundTripThroughJSONStores(t *testing.T) {
+	c := NewOnceCache(&singleflight.Group{}, &jsonStore{values: map[string][]byte{}})
+	loads := 0
+	load := func() (any, error) {
+		loads++
+		return nil, nil
+	}
+	for i := 0; i < 2; i++ {
+		if v, ok := c.GetWithOptions("k", load, WithTTL(time.Minute)); !ok || v != nil {
+			t.Fatalf("Get %d = %#v, %v, want nil, true", i, v, ok)
+		}
+	}
+	if loads != 1 {
+		t.Fatalf("loads = %d, want the cached nil to be served", loads)
+	}
+	if v, ok := c.Get("k"); !ok || v != nil {
+		t.Fatalf("lookup of a cached nil = %#v, %v, want nil, true", v, ok)
+	}
+}
@@ -1,6 +1,11 @@
 package once_cache
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/sync/singleflight"
@@ -8,53 +13,699 @@ import (
 
 type SingleFunc func() (any, error)
 
+// KeyedFunc loads the value for a key. It is used where one function serves many keys.
+type KeyedFunc func(ctx context.Context, key string) (any, error)
+
 type CatchErrorFunc func(cacheStore ICache, key string, err error) any
 
 // IOnceCache is an interface that extends the ICache interface with a method for getting values with a single function.
+// The other operations of OnceCache are methods of *OnceCache, which the constructors return.
 type IOnceCache interface {
 	ICache
 	GetWithSingleFunc(key string, f SingleFunc, d time.Duration, catchError *CatchErrorFunc) (any, bool)
+	Forget(key string)
+}
+
+// IResultCache is an IOnceCache describing how each value was obtained, as OnceCache does. It is what the
+// helpers built on a cache, such as HTTPCache and DNSCache, need of it.
+type IResultCache interface {
+	IOnceCache
+	GetResult(key string, f SingleFunc, opts ...CallOption) Result
+}
+
+var _ IResultCache = (*OnceCache)(nil)
+
+// Option configures an OnceCache.
+type Option func(*OnceCache)
+
+// WithSetErrorPolicy sets the policy applied when storing a freshly loaded value fails.
+func WithSetErrorPolicy(policy SetErrorPolicy) Option {
+	return func(o *OnceCache) {
+		o.onSetError = policy
+	}
+}
+
+// WithDefaultTTL sets the time to live used by loads that do not specify one.
+func WithDefaultTTL(d time.Duration) Option {
+	return func(o *OnceCache) {
+		o.defaultTTL.Store(int64(d))
+	}
+}
+
+// WithRefreshAhead reloads entries in the background when they are read within window of their expiry,
+// so hot keys are refreshed before they expire instead of making callers wait. It requires a store
+// implementing IEntryInfoGetter, such as MemoryCache.
+func WithRefreshAhead(window time.Duration) Option {
+	return func(o *OnceCache) {
+		o.refreshAhead.Store(int64(window))
+	}
+}
+
+// WithShouldRevalidate sets a function evaluated on every hit that turns the hit into a miss when it
+// returns true, so that entries can be revalidated on signals other than time, such as a configuration
+// epoch newer than info.CreatedAt, without deleting them. The entry is reloaded like any miss: the call
+// waits for the load, or is served the entry while it reloads with StaleWhileRevalidate. It requires a
+// store implementing IEntryInfoGetter, such as MemoryCache.
+func WithShouldRevalidate(shouldRevalidate func(info EntryInfo) bool) Option {
+	return func(o *OnceCache) {
+		o.shouldRevalidate = shouldRevalidate
+	}
+}
+
+// WithPrefetchConcurrency bounds how many prefetch loads may run at once. It defaults to 4.
+func WithPrefetchConcurrency(n int) Option {
+	return func(o *OnceCache) {
+		o.prefetchConcurrency = n
+	}
+}
+
+// WithResponseBudget races loads against a response-time budget: when a load is still running after d
+// and the store retains a stale value for the key, the stale value is returned right away and the load
+// finishes in the background. Without a stale value the call keeps waiting for the load. It requires a
+// store implementing IStaleGetter, such as MemoryCache with WithStaleRetention.
+func WithResponseBudget(d time.Duration) Option {
+	return func(o *OnceCache) {
+		o.responseBudget = d
+	}
+}
+
+// ErrInvalidValue is reported when a loaded value is rejected by the function given to WithValidate.
+var ErrInvalidValue = errors.New("once_cache: invalid value")
+
+// WithValidate checks loaded values with validate before they are stored. Rejected values are not stored
+// and the load fails with an error wrapping ErrInvalidValue and validate's error, which goes to the error
+// handler like any load error, so malformed upstream responses are never served for a full TTL.
+// Values rejected in a batch are left out of its result.
+func WithValidate(validate func(value any) error) Option {
+	return func(o *OnceCache) {
+		o.validate = validate
+	}
+}
+
+// WithTransform applies transform to loaded values before they are stored, after WithValidate, so the cache
+// stores their final shape, such as with fields stripped or derived indexes built. A transform error fails the load.
+// Values a transform fails on in a batch are left out of its result.
+func WithTransform(transform func(key string, value any) (any, error)) Option {
+	return func(o *OnceCache) {
+		o.transform = transform
+	}
+}
+
+// WithBackgroundErrorHandler sets a function called with the errors of loads no caller waits for:
+// refresh-ahead reloads, prefetches, and loads left to finish in the background by WithResponseBudget,
+// WithTimeout or WithWaitTimeout. Without it these failures are silent and leave stale data in place.
+// handler may be called concurrently and must not block.
+func WithBackgroundErrorHandler(handler func(key string, err error)) Option {
+	return func(o *OnceCache) {
+		o.onBackgroundError = handler
+	}
+}
+
+// WithDefaultErrorHandler sets the function called when a load fails, for calls that do not pass their own
+// with a catchError argument or WithErrorHandler, which take precedence.
+func WithDefaultErrorHandler(handler CatchErrorFunc) Option {
+	return func(o *OnceCache) {
+		o.errorHandler = handler
+	}
 }
 
 // OnceCache is a struct that implements the IOnceCache interface.
 type OnceCache struct {
 	group *singleflight.Group
 	ICache
+	store          ICacheWithError
+	multiGetter    IMultiGetter
+	multiSetter    IMultiSetter
+	prioritySetter IPrioritySetter
+	staleGetter    IStaleGetter
+	onSetError     SetErrorPolicy
+	infoGetter     IEntryInfoGetter
+	contextGetter  IContextGetter
+	defaultTTL     atomic.Int64
+	refreshAhead   atomic.Int64
+	refreshing     sync.Map
+
+	prefetchConcurrency int
+	prefetchSem         chan struct{}
+
+	events      chan CacheEvent
+	eventPolicy BackpressurePolicy
+
+	barrier        *LoadBarrier
+	limiter        *keyRateLimiter
+	responseBudget time.Duration
+	stalePolicy    StalePolicy
+	errorFallback  ErrorFallback
+	missingTTL     time.Duration
+	missingFilter  *MissingFilter
+
+	loaderMiddleware []LoaderMiddleware
+	// wrappedLoader is the middleware chain composed at construction, see composeLoaderMiddleware.
+	wrappedLoader    KeyedFunc
+	keyStats         *keyStatsTable
+	recorder         *AccessRecorder
+	aliasing         atomic.Bool
+	tombstones       sync.Map
+	dependencies     dependencyGraph
+	hasDependencies  atomic.Bool
+	tagInvalidator   ITagInvalidator
+	condDeleter      IConditionalDeleter
+	staggerer        IStaggeredInvalidator
+	validate         func(value any) error
+	transform        func(key string, value any) (any, error)
+	waiters          *waiterLimiter
+	flights          *flightRegistry
+	errorHandler     CatchErrorFunc
+	experiment       *Experiment
+	maxStaleness     time.Duration
+	staleRefusals    atomic.Uint64
+	decisions        *decisionLog
+	contextSeed      func(key string) context.Context
+	credentials      CredentialsProvider
+	shouldRevalidate func(info EntryInfo) bool
+	evictionNotifier IEvictionNotifier
+	label            string
+	auditSink        AuditSink
+	random           Random
+	jitter           Jitter
+	alerts           *alerter
+	backoff          *failureBackoff
+	refreshRetry     *refreshRetry
+	autoWarm         *autoWarmer
+	actors           actorSet
+	staggerWindow    time.Duration
+	flightMetrics    *flightTracker
+	strict           bool
+
+	onBackgroundError func(key string, err error)
 }
 
 // GetWithSingleFunc retrieves a value associated with a key using a single function to generate the value.
 // It ensures that the function is called only once for the same key within the specified time duration.
+// A zero duration uses the cache's default TTL, see WithDefaultTTL. A nil catchError uses the handler set
+// with WithDefaultErrorHandler; GetWithOptions takes the handler as a plain function with WithErrorHandler.
 func (o *OnceCache) GetWithSingleFunc(key string, f SingleFunc, d time.Duration, catchError *CatchErrorFunc) (any, bool) {
-	// Attempt to get the value from the cache
-	value, ok := o.Get(key)
-	if !ok {
-		// If not found in the cache, use the singleflight.Group to ensure the function is called only once
-		// for the same key, even if multiple goroutines request the same key simultaneously.
+	c := callConfig{ttl: d}
+	if catchError != nil {
+		c.errorHandler = *catchError
+	}
+	res := o.get(key, loader{single: f}, &c)
+	return res.Value, res.OK()
+}
+
+// GetWithOptions is GetWithSingleFunc configured with per-call options instead of positional parameters.
+func (o *OnceCache) GetWithOptions(key string, f SingleFunc, opts ...CallOption) (any, bool) {
+	c := acquireCallConfig(opts)
+	defer releaseCallConfig(c)
+	res := o.get(key, loader{single: f}, c)
+	return res.Value, res.OK()
+}
+
+// GetResult is GetWithOptions returning a Result that describes how the value was obtained.
+func (o *OnceCache) GetResult(key string, f SingleFunc, opts ...CallOption) Result {
+	c := acquireCallConfig(opts)
+	defer releaseCallConfig(c)
+	return o.get(key, loader{single: f}, c)
+}
+
+// get serves a call for key. Hits do not allocate: the config of calls with options is pooled and the
+// loader is only adapted to a KeyedFunc when the key is loaded.
+func (o *OnceCache) get(key string, l loader, c *callConfig) Result {
+	if o.aliasing.Load() {
+		key = o.canonical(key)
+	}
+	if o.strict {
+		if err := o.checkCall(key, o.keyTTL(key, c.ttl), !l.isNil()); err != nil {
+			if handler := o.handler(c.errorHandler); handler != nil {
+				handler(o, key, err)
+			}
+			return Result{Err: err}
+		}
+	}
+	if !c.forceRefresh && o.olderThanSession(key, c.session) {
+		c.forceRefresh = true
+	}
+	var timing Timing
+	if !c.forceRefresh {
+		// Attempt to get the value from the cache
+		start := time.Now()
+		value, ok := o.lookupAndRefresh(key, l, c)
+		timing.Lookup = time.Since(start)
+		if ok {
+			// Return the value from the cache.
+			o.emit(EventHit, key, nil, 0)
+			o.recordHit(key)
+			return Result{Value: value, Hit: true, Timing: timing}
+		}
+	}
+	o.emit(EventMiss, key, nil, 0)
+	o.recordMiss(key)
+	f := l.keyedFunc()
+	if c.dependsOn != nil {
+		o.DependsOn(key, c.dependsOn...)
+	}
+	if o.stalePolicy == StaleWhileRevalidate && !c.forceRefresh {
+		start := time.Now()
+		value, ok := o.stale(key)
+		timing.Lookup += time.Since(start)
+		if ok {
+			o.refreshInBackground(o.loadContext(key, c), key, f, o.keyTTL(key, c.ttl))
+			return Result{Value: value, Stale: true, Timing: timing}
+		}
+	}
+	if o.barrier != nil {
+		defer o.barrier.arrive(key)()
+	}
+	// If not found in the cache, use the singleflight.Group to ensure the function is called only once
+	// for the same key, even if multiple goroutines request the same key simultaneously.
+	budget := c.budget
+	if budget == 0 {
+		budget = o.responseBudget
+	}
+	timeout := c.timeout
+	if c.waitTimeout > 0 && (timeout <= 0 || c.waitTimeout < timeout) {
+		timeout = c.waitTimeout
+	}
+	start := time.Now()
+	res := o.wait(o.loadContext(key, c), key, f, o.keyTTL(key, c.ttl), timeout, budget)
+	res.Timing.Lookup, res.Timing.Wait = timing.Lookup, time.Since(start)
+	if res.Err != nil {
+		// If an error occurred while executing the function, handle the error and return false.
+		if handler := o.handler(c.errorHandler); handler != nil {
+			handler(o, key, res.Err)
+		}
+		if c.staleOK || o.stalePolicy == StaleOnError || o.shedsToStale(res.Err) || (c.waitTimeout > 0 && errors.Is(res.Err, ErrLoadTimeout)) {
+			if value, ok := o.stale(key); ok {
+				res.Value, res.Stale = value, true
+				return res
+			}
+		}
+		res.Value, res.Hit, res.Stale = o.fallback(key)
+	}
+	// If the function was successful, return the value that was set in the cache.
+	return res
+}
+
+// handler returns the error handler of a call, or the default one if the call has none.
+func (o *OnceCache) handler(handler CatchErrorFunc) CatchErrorFunc {
+	if handler == nil {
+		return o.errorHandler
+	}
+	return handler
+}
+
+// flightResult is the value shared by all callers of a flight.
+type flightResult struct {
+	value    any
+	duration time.Duration
+	timing   Timing
+}
+
+// shedsToStale reports whether err means the call was turned away rather than the load failing,
+// in which case a stale value is served even without WithStaleOK.
+func (o *OnceCache) shedsToStale(err error) bool {
+	return errors.Is(err, ErrLoadRateLimited) || errors.Is(err, ErrTooManyWaiters) || errors.Is(err, ErrLoadBackoff)
+}
+
+// wait is do counting the caller as a waiter of key, see WithMaxWaiters.
+func (o *OnceCache) wait(ctx context.Context, key string, f KeyedFunc, d, timeout, budget time.Duration) Result {
+	if o.waiters != nil {
+		if !o.waiters.acquire(key) {
+			return Result{Err: ErrTooManyWaiters}
+		}
+		defer o.waiters.release(key)
+	}
+	return o.do(ctx, key, f, d, timeout, budget)
+}
+
+// do runs the load for key through singleflight, waiting at most timeout if it is positive.
+// If budget is positive and the load outlasts it, a stale value is returned when there is one.
+// A load that is not waited for keeps running for other callers and still stores its result.
+func (o *OnceCache) do(ctx context.Context, key string, f KeyedFunc, d, timeout, budget time.Duration) Result {
+	defer o.flightMetrics.join(key)()
+	fn := func() (any, error) {
+		var timing Timing
+		start := time.Now()
+		value, err := o.loadWithPriority(ctx, key, f, d, PriorityNormal, &timing)
+		return flightResult{value: value, duration: time.Since(start), timing: timing}, err
+	}
+	if timeout <= 0 && (budget <= 0 || o.staleGetter == nil) {
 		defer o.group.Forget(key)
-		value, err, _ := o.group.Do(key, f)
+		v, err, shared := o.group.Do(key, fn)
+		return newFlightResult(v, err, shared)
+	}
+	var timeoutC, budgetC <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		timeoutC = timer.C
+	}
+	if budget > 0 && o.staleGetter != nil {
+		timer := time.NewTimer(budget)
+		defer timer.Stop()
+		budgetC = timer.C
+	}
+	ch := o.group.DoChan(key, fn)
+	for {
+		select {
+		case res := <-ch:
+			o.group.Forget(key)
+			return newFlightResult(res.Val, res.Err, res.Shared)
+		case <-budgetC:
+			if value, ok := o.stale(key); ok {
+				o.watchAbandoned(key, ch)
+				return Result{Value: value, Stale: true, LoadDuration: budget}
+			}
+			budgetC = nil
+		case <-timeoutC:
+			o.watchAbandoned(key, ch)
+			return Result{Err: ErrLoadTimeout, LoadDuration: timeout}
+		}
+	}
+}
+
+// watchAbandoned reports the error of a flight the caller stopped waiting for.
+func (o *OnceCache) watchAbandoned(key string, ch <-chan singleflight.Result) {
+	if o.onBackgroundError == nil {
+		return
+	}
+	go func() {
+		if res := <-ch; res.Err != nil {
+			o.backgroundError(key, res.Err)
+		}
+	}()
+}
+
+// backgroundError reports the error of a load no caller waits for.
+func (o *OnceCache) backgroundError(key string, err error) {
+	if o.onBackgroundError != nil {
+		o.onBackgroundError(key, err)
+	}
+}
 
+func newFlightResult(v any, err error, shared bool) Result {
+	fr, _ := v.(flightResult)
+	res := Result{Err: err, Shared: shared, LoadDuration: fr.duration, Timing: fr.timing}
+	if err == nil {
+		res.Value = fr.value
+	}
+	return res
+}
+
+// lookupAndRefresh is lookup that also starts a background reload of entries close to expiry
+// when refresh-ahead is enabled, and misses entries the WithShouldRevalidate function rejects.
+func (o *OnceCache) lookupAndRefresh(key string, l loader, c *callConfig) (any, bool) {
+	window := o.refreshWindow(key)
+	if (window <= 0 && o.shouldRevalidate == nil) || o.infoGetter == nil {
+		if c.ctx != nil && o.contextGetter != nil {
+			return o.lookupContext(c.ctx, key)
+		}
+		return o.lookup(key)
+	}
+	value, info, ok := o.infoGetter.GetWithInfo(key)
+	if internalValue(value) {
+		return nil, false
+	}
+	value = unwrapNil(value)
+	if ok && o.shouldRevalidate != nil && o.shouldRevalidate(info) {
+		return nil, false
+	}
+	if ok && !info.ExpiresAt.IsZero() && time.Until(info.ExpiresAt) < window {
+		o.refreshInBackground(o.loadContext(key, c), key, l.keyedFunc(), o.keyTTL(key, c.ttl))
+	}
+	return value, ok
+}
+
+// refreshInBackground reloads key in a new goroutine unless a background refresh of key is running.
+func (o *OnceCache) refreshInBackground(ctx context.Context, key string, f KeyedFunc, d time.Duration) {
+	if _, loaded := o.refreshing.LoadOrStore(key, struct{}{}); loaded {
+		return
+	}
+	o.decide(DecisionRefresh, key, "", nil, 0)
+	go func() {
+		defer o.refreshing.Delete(key)
+		defer o.recoverBackground(key)
+		if res := o.do(ctx, key, f, d, 0, 0); res.Err != nil {
+			o.backgroundError(key, res.Err)
+			o.retryRefresh(ctx, key, f, d, 1)
+		}
+	}()
+}
+
+// SetDefaultTTL changes the default time to live at runtime.
+func (o *OnceCache) SetDefaultTTL(d time.Duration) {
+	o.defaultTTL.Store(int64(d))
+}
+
+// DefaultTTL returns the time to live used by loads that do not specify one.
+func (o *OnceCache) DefaultTTL() time.Duration {
+	return time.Duration(o.defaultTTL.Load())
+}
+
+// SetRefreshAhead changes the refresh-ahead window at runtime, see WithRefreshAhead. Zero disables it.
+func (o *OnceCache) SetRefreshAhead(window time.Duration) {
+	o.refreshAhead.Store(int64(window))
+}
+
+// ttl returns d, or the default TTL if d is zero.
+func (o *OnceCache) ttl(d time.Duration) time.Duration {
+	if d == 0 {
+		return time.Duration(o.defaultTTL.Load())
+	}
+	return d
+}
+
+// stale returns an expired value retained by the store, if it supports it.
+func (o *OnceCache) stale(key string) (any, bool) {
+	if o.staleGetter == nil {
+		return nil, false
+	}
+	value, expiresAt, ok := o.staleGetter.GetStale(key)
+	if internalValue(value) {
+		return nil, false
+	}
+	if ok && o.maxStaleness > 0 && !expiresAt.IsZero() && time.Since(expiresAt) > o.maxStaleness {
+		o.staleRefusals.Add(1)
+		o.decide(DecisionStaleRefused, key, staleDetail(expiresAt), nil, 0)
+		return nil, false
+	}
+	if ok && o.decisions != nil {
+		o.decide(DecisionStale, key, staleDetail(expiresAt), nil, 0)
+	}
+	return unwrapNil(value), ok
+}
+
+// Get retrieves the value from the store, following aliases and hiding missing markers.
+// A cached nil value is returned as (nil, true), and a miss as (nil, false).
+func (o *OnceCache) Get(key string) (any, bool) {
+	value, ok := o.ICache.Get(key)
+	if p, alias := value.(aliasPointer); alias {
+		value, ok = o.ICache.Get(p.canonical)
+	}
+	if internalValue(value) {
+		return nil, false
+	}
+	return unwrapNil(value), ok
+}
+
+// DeleteTag removes every entry stored with tag when the store supports tags, and returns how many were removed.
+func (o *OnceCache) DeleteTag(tag string) int {
+	return o.DeleteTagWithReason(tag, "")
+}
+
+// unstored is returned by internal loaders in place of a value that must be neither stored nor shared.
+// The caller that ran the load keeps the value aside; callers that joined its flight receive the marker
+// and serve themselves.
+type unstored struct{}
+
+// internalValue reports whether value is a marker stored by the cache itself rather than a loaded value.
+func internalValue(value any) bool {
+	switch value.(type) {
+	case missingMarker, aliasPointer:
+		return true
+	}
+	return false
+}
+
+// lookup retrieves the value from the store, treating store errors as misses so that the loader still runs.
+func (o *OnceCache) lookup(key string) (any, bool) {
+	value, ok, err := o.store.Get(key)
+	if err != nil {
+		return nil, false
+	}
+	if internalValue(value) {
+		return nil, false
+	}
+	return unwrapNil(value), ok
+}
+
+// lookupContext is lookup bounded by ctx, for stores implementing IContextGetter.
+func (o *OnceCache) lookupContext(ctx context.Context, key string) (any, bool) {
+	value, ok := o.contextGetter.GetContext(ctx, key)
+	if internalValue(value) {
+		return nil, false
+	}
+	return unwrapNil(value), ok
+}
+
+// loadWithPriority is load storing the result with an eviction priority, for stores that support them.
+// If timing is not nil, the time spent in the loader and storing its result is recorded in it.
+func (o *OnceCache) loadWithPriority(ctx context.Context, key string, f KeyedFunc, d time.Duration, priority Priority, timing *Timing) (any, error) {
+	if timing == nil {
+		timing = new(Timing)
+	}
+	if o.flights != nil {
+		return o.flights.single(key, func() (any, error) {
+			return o.loadKey(ctx, key, f, d, priority, timing)
+		})
+	}
+	return o.loadKey(ctx, key, f, d, priority, timing)
+}
+
+// loadKey runs the function and stores its result, see loadWithPriority.
+func (o *OnceCache) loadKey(ctx context.Context, key string, f KeyedFunc, d time.Duration, priority Priority, timing *Timing) (any, error) {
+	if o.missingFilter != nil && o.missingFilter.MayContain(key) {
+		return nil, ErrRecordNotFound
+	}
+	if o.backoff != nil {
+		if err := o.backoff.allow(key); err != nil {
+			return nil, err
+		}
+	}
+	if o.limiter != nil && !o.limiter.allow(key) {
+		return nil, ErrLoadRateLimited
+	}
+	defer o.flightMetrics.start(key)()
+	if o.barrier != nil {
+		o.barrier.pause(key)
+	}
+	if o.wrappedLoader != nil {
+		ctx, f = context.WithValue(ctx, loaderContextKey{}, f), o.wrappedLoader
+	}
+	start := time.Now()
+	value, err := f(ctx, key)
+	elapsed := time.Since(start)
+	timing.Load = elapsed
+	o.emit(EventLoad, key, err, elapsed)
+	o.recordLoad(key, elapsed, err)
+	if o.backoff != nil {
 		if err != nil {
-			// If an error occurred while executing the function, handle the error and return false.
-			if catchError != nil {
-				catchErrorFunc := *catchError
-				catchErrorFunc(o, key, err)
-			}
-			// Even in case of an error, return the result from the cache if available.
-			return o.Get(key)
+			o.backoff.failure(key, err)
 		} else {
-			// If the function was successful, set the value in the cache and return true.
-			o.Set(key, value, d)
-			return value, true
+			o.backoff.success(key)
 		}
 	}
-	// Return the value from the cache.
-	return value, ok
+	if err != nil {
+		if o.missingFilter != nil && errors.Is(err, ErrRecordNotFound) {
+			o.missingFilter.Add(key)
+		}
+		return nil, err
+	}
+	if _, ok := value.(unstored); ok {
+		return value, nil
+	}
+	if o.validate != nil {
+		if err := o.validate(value); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidValue, err)
+		}
+	}
+	if o.transform != nil {
+		if value, err = o.transform(key, value); err != nil {
+			return nil, err
+		}
+	}
+	if o.tombstoned(key, start) {
+		return value, nil
+	}
+	setStart := time.Now()
+	if priority != PriorityNormal && o.prioritySetter != nil {
+		o.prioritySetter.SetWithPriority(key, wrapNil(value), d, priority)
+		timing.Set = time.Since(setStart)
+		o.emit(EventSet, key, nil, 0)
+		o.audit(AuditSet, key, "", "load", d)
+		return value, nil
+	}
+	err = o.store.Set(key, wrapNil(value), d)
+	timing.Set = time.Since(setStart)
+	if err != nil {
+		if o.onSetError != nil {
+			if err := o.onSetError(o.store, key, value, d, err); err != nil {
+				return nil, fmt.Errorf("%w: %w", ErrSetFailed, err)
+			}
+		}
+		return value, nil
+	}
+	o.emit(EventSet, key, nil, 0)
+	o.audit(AuditSet, key, "", "load", d)
+	return value, nil
 }
 
-// NewOnceCache creates a new instance of OnceCache with the specified singleflight.Group and ICache.
-func NewOnceCache(group *singleflight.Group, cacheStore ICache) IOnceCache {
-	return &OnceCache{
-		group:  group,
-		ICache: cacheStore,
+func newOnceCache(group *singleflight.Group, cacheStore ICache, store ICacheWithError, opts []Option) *OnceCache {
+	o := &OnceCache{
+		group:      group,
+		ICache:     cacheStore,
+		store:      store,
+		onSetError: IgnoreSetError,
+		flights:    &flightRegistry{},
+
+		prefetchConcurrency: 4,
 	}
+	// Use multi-key operations when either form of the store provides them.
+	for _, s := range []any{store, cacheStore} {
+		if g, ok := s.(IMultiGetter); ok && o.multiGetter == nil {
+			o.multiGetter = g
+		}
+		if m, ok := s.(IMultiSetter); ok && o.multiSetter == nil {
+			o.multiSetter = m
+		}
+		if p, ok := s.(IPrioritySetter); ok && o.prioritySetter == nil {
+			o.prioritySetter = p
+		}
+		if g, ok := s.(IStaleGetter); ok && o.staleGetter == nil {
+			o.staleGetter = g
+		}
+		if g, ok := s.(IEntryInfoGetter); ok && o.infoGetter == nil {
+			o.infoGetter = g
+		}
+		if g, ok := s.(IContextGetter); ok && o.contextGetter == nil {
+			o.contextGetter = g
+		}
+		if t, ok := s.(ITagInvalidator); ok && o.tagInvalidator == nil {
+			o.tagInvalidator = t
+		}
+		if t, ok := s.(IStaggeredInvalidator); ok && o.staggerer == nil {
+			o.staggerer = t
+		}
+		if d, ok := s.(IConditionalDeleter); ok && o.condDeleter == nil {
+			o.condDeleter = d
+		}
+		if n, ok := s.(IEvictionNotifier); ok && o.evictionNotifier == nil {
+			o.evictionNotifier = n
+		}
+	}
+	if p, ok := cacheStore.(*prefixedCache); ok {
+		p.adapt(o)
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	o.prefetchSem = make(chan struct{}, max(1, o.prefetchConcurrency))
+	o.wrappedLoader = composeLoaderMiddleware(o.loaderMiddleware)
+	if o.alerts != nil {
+		o.alerts.label = o.label
+	}
+	o.forwardEvictions()
+	return o
+}
+
+// NewOnceCache creates a new instance of OnceCache with the specified singleflight.Group and ICache.
+// If cacheStore wraps an ICacheWithError (see NewCacheIgnoringErrors), its errors are used.
+func NewOnceCache(group *singleflight.Group, cacheStore ICache, opts ...Option) *OnceCache {
+	return newOnceCache(group, cacheStore, asCacheWithError(cacheStore), opts)
+}
+
+// NewOnceCacheWithError creates a new instance of OnceCache over a store that reports errors,
+// so that failures to store loaded values can be handled with WithSetErrorPolicy.
+func NewOnceCacheWithError(group *singleflight.Group, store ICacheWithError, opts ...Option) *OnceCache {
+	return newOnceCache(group, NewCacheIgnoringErrors(store), store, opts)
 }
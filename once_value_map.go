@@ -0,0 +1,95 @@
+package once_cache
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// OnceValueMap gives sync.OnceValues semantics per key: the first Get of a key calls the function,
+// concurrent and later Gets of the key wait for and share its result, error included, until the result
+// expires or the key is Reset. It suits memoizing by dynamic key without a cache store.
+type OnceValueMap[K comparable, V any] struct {
+	f   func(K) (V, error)
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[K]*onceValue[V]
+}
+
+type onceValue[V any] struct {
+	get func() (V, error)
+	// expiresAt is when the result expires in Unix nanoseconds, zero while loading or if it never expires.
+	expiresAt atomic.Int64
+}
+
+// Get returns the result of the function for key, calling it if the key has no live result.
+func (m *OnceValueMap[K, V]) Get(key K) (V, error) {
+	m.mu.Lock()
+	e, ok := m.entries[key]
+	if !ok || e.expired(time.Now().UnixNano()) {
+		e = m.newValue(key)
+		m.entries[key] = e
+	}
+	m.mu.Unlock()
+	return e.get()
+}
+
+func (m *OnceValueMap[K, V]) newValue(key K) *onceValue[V] {
+	e := &onceValue[V]{}
+	e.get = sync.OnceValues(func() (V, error) {
+		defer func() {
+			if m.ttl > 0 {
+				e.expiresAt.Store(time.Now().Add(m.ttl).UnixNano())
+			}
+		}()
+		return m.f(key)
+	})
+	return e
+}
+
+func (e *onceValue[V]) expired(now int64) bool {
+	at := e.expiresAt.Load()
+	return at != 0 && now >= at
+}
+
+// Reset forgets the result for key, so the next Get calls the function again.
+// Callers already waiting for the current call still get its result.
+func (m *OnceValueMap[K, V]) Reset(key K) {
+	m.mu.Lock()
+	delete(m.entries, key)
+	m.mu.Unlock()
+}
+
+// ResetAll forgets every result.
+func (m *OnceValueMap[K, V]) ResetAll() {
+	m.mu.Lock()
+	clear(m.entries)
+	m.mu.Unlock()
+}
+
+// Purge forgets the expired results, which are otherwise only replaced on their next Get,
+// and returns how many were removed.
+func (m *OnceValueMap[K, V]) Purge() int {
+	now := time.Now().UnixNano()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for key, e := range m.entries {
+		if e.expired(now) {
+			delete(m.entries, key)
+			n++
+		}
+	}
+	return n
+}
+
+// NewOnceValueMap creates a new instance of OnceValueMap calling f for each key. Results expire ttl after
+// they are produced, or never if ttl is not positive.
+func NewOnceValueMap[K comparable, V any](f func(K) (V, error), ttl time.Duration) *OnceValueMap[K, V] {
+	return &OnceValueMap[K, V]{
+		f:       f,
+		ttl:     ttl,
+		entries: make(map[K]*onceValue[V]),
+	}
+}
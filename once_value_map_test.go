@@ -0,0 +1,51 @@
+package once_cache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestOnceValueMapSharesResultsUntilTheyExpire(t *testing.T) {
+	var calls atomic.Int32
+	failing := errors.New("failed")
+	m := NewOnceValueMap(func(key string) (int, error) {
+		n := int(calls.Add(1))
+		if key == "bad" {
+			return 0, failing
+		}
+		return n, nil
+	}, 20*time.Millisecond)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := m.Get("a"); err != nil || v != 1 {
+				t.Errorf("Get = %v, %v, want the first call's 1", v, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if _, err := m.Get("bad"); !errors.Is(err, failing) {
+		t.Fatalf("Get of a failing key = %v, want its error", err)
+	}
+	if _, err := m.Get("bad"); !errors.Is(err, failing) || calls.Load() != 2 {
+		t.Fatalf("Get of a failing key again = %v after %d calls, want the shared error", err, calls.Load())
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if n := m.Purge(); n != 2 {
+		t.Fatalf("Purge = %d, want both expired results removed", n)
+	}
+	if v, _ := m.Get("a"); v != 3 {
+		t.Fatalf("Get after expiry = %v, want a new call", v)
+	}
+	m.Reset("a")
+	if v, _ := m.Get("a"); v != 4 {
+		t.Fatalf("Get after Reset = %v, want a new call", v)
+	}
+}
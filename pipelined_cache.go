@@ -0,0 +1,231 @@
+package once_cache
+
+import (
+	"sync"
+	"time"
+)
+
+// IPipelineStore is a store that can write several entries in one round trip,
+// such as a Redis adapter implementing SetMulti with a pipeline.
+type IPipelineStore interface {
+	ICache
+	IMultiSetter
+}
+
+// PipelineStats describes the write batching done by a PipelinedCache, for tuning flush size and interval.
+type PipelineStats struct {
+	// Flushes is the number of batches written to the store.
+	Flushes uint64
+	// SizeFlushes is the number of flushes triggered by reaching the flush size.
+	SizeFlushes uint64
+	// IntervalFlushes is the number of flushes triggered by the flush interval.
+	IntervalFlushes uint64
+	// Entries is the number of entries written to the store.
+	Entries uint64
+	// Coalesced is the number of Sets that overwrote a pending entry before it was flushed.
+	Coalesced uint64
+	// MaxBatch is the largest batch written so far.
+	MaxBatch int
+	// Pending is the number of entries waiting to be flushed.
+	Pending int
+}
+
+// PipelineOption configures a PipelinedCache.
+type PipelineOption func(*PipelinedCache)
+
+// WithPipelineSupervisor runs the flush goroutine under s instead of DefaultSupervisor.
+func WithPipelineSupervisor(s *Supervisor) PipelineOption {
+	return func(c *PipelinedCache) {
+		c.supervisor = s
+	}
+}
+
+// PipelinedCache is a struct that implements the ICache interface by buffering Sets and writing them
+// to the underlying store in batches, either when flushSize entries are pending or every flushInterval.
+// Gets see pending writes, so callers read their own writes before they are flushed. Time to live runs
+// from the Set: entries are flushed with the time they have left, and those that expire before their flush
+// are deleted from the store instead, so that the older value they replaced is not served again.
+type PipelinedCache struct {
+	store         IPipelineStore
+	flushSize     int
+	flushInterval time.Duration
+	supervisor    *Supervisor
+
+	// flushMu serializes flushes and deletes so a delete is never overtaken by an older pending write.
+	flushMu  sync.Mutex
+	mu       sync.Mutex
+	pending  map[string]pipelinedEntry
+	flushing map[string]pipelinedEntry
+	stats    PipelineStats
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// pipelinedEntry is a buffered Set with its expiry, zero if it never expires.
+type pipelinedEntry struct {
+	value     any
+	expiresAt time.Time
+}
+
+func (e pipelinedEntry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
+
+// Set buffers the value until the next flush.
+func (c *PipelinedCache) Set(key string, value any, d time.Duration) {
+	entry := pipelinedEntry{value: value}
+	if d > 0 {
+		entry.expiresAt = time.Now().Add(d)
+	}
+	c.mu.Lock()
+	if _, ok := c.pending[key]; ok {
+		c.stats.Coalesced++
+	}
+	c.pending[key] = entry
+	full := len(c.pending) >= c.flushSize
+	c.mu.Unlock()
+	if full {
+		c.flush(&c.stats.SizeFlushes)
+	}
+}
+
+// SetMulti buffers all entries until the next flush.
+func (c *PipelinedCache) SetMulti(entries map[string]Entry) {
+	for key, entry := range entries {
+		c.Set(key, entry.Value, entry.TTL)
+	}
+}
+
+// Get retrieves a pending value if there is one, and reads from the store otherwise. An expired pending
+// value is a miss, since it replaces whatever the store holds.
+func (c *PipelinedCache) Get(key string) (any, bool) {
+	c.mu.Lock()
+	entry, ok := c.pending[key]
+	if !ok {
+		entry, ok = c.flushing[key]
+	}
+	c.mu.Unlock()
+	if ok {
+		if entry.expired(time.Now()) {
+			return nil, false
+		}
+		return entry.value, true
+	}
+	return c.store.Get(key)
+}
+
+// Delete drops any pending value and deletes the key from the store.
+func (c *PipelinedCache) Delete(key string) {
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+	c.mu.Lock()
+	delete(c.pending, key)
+	c.mu.Unlock()
+	c.store.Delete(key)
+}
+
+// Flush writes all pending entries to the store.
+func (c *PipelinedCache) Flush() {
+	c.flush(nil)
+}
+
+// Stats returns a snapshot of the pipeline statistics.
+func (c *PipelinedCache) Stats() PipelineStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Pending = len(c.pending)
+	return stats
+}
+
+// Close stops the flush loop and writes all pending entries to the store.
+func (c *PipelinedCache) Close() {
+	c.stopOnce.Do(func() {
+		close(c.stop)
+	})
+	<-c.done
+	c.Flush()
+}
+
+func (c *PipelinedCache) flush(reason *uint64) {
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+	c.mu.Lock()
+	if len(c.pending) == 0 {
+		c.mu.Unlock()
+		return
+	}
+	batch := c.pending
+	c.pending = make(map[string]pipelinedEntry, c.flushSize)
+	c.flushing = batch
+	c.stats.Flushes++
+	c.stats.Entries += uint64(len(batch))
+	c.stats.MaxBatch = max(c.stats.MaxBatch, len(batch))
+	if reason != nil {
+		*reason++
+	}
+	c.mu.Unlock()
+	now := time.Now()
+	entries := make(map[string]Entry, len(batch))
+	for key, entry := range batch {
+		switch {
+		case entry.expiresAt.IsZero():
+			entries[key] = Entry{Value: entry.value}
+		case entry.expired(now):
+			c.store.Delete(key)
+		default:
+			entries[key] = Entry{Value: entry.value, TTL: entry.expiresAt.Sub(now)}
+		}
+	}
+	if len(entries) > 0 {
+		c.store.SetMulti(entries)
+	}
+	c.mu.Lock()
+	c.flushing = nil
+	c.mu.Unlock()
+}
+
+func (c *PipelinedCache) run() {
+	if c.flushInterval <= 0 {
+		<-c.stop
+		return
+	}
+	ticker := time.NewTicker(c.flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.flush(&c.stats.IntervalFlushes)
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+// NewPipelinedCache creates a new instance of PipelinedCache over the store. Pending entries are flushed
+// when flushSize of them are buffered or every flushInterval, whichever happens first.
+// A flushInterval of zero disables time-based flushing. Call Close to flush remaining entries.
+func NewPipelinedCache(store IPipelineStore, flushSize int, flushInterval time.Duration, opts ...PipelineOption) *PipelinedCache {
+	if flushSize < 1 {
+		flushSize = 1
+	}
+	c := &PipelinedCache{
+		store:         store,
+		flushSize:     flushSize,
+		flushInterval: flushInterval,
+		supervisor:    DefaultSupervisor,
+		pending:       make(map[string]pipelinedEntry, flushSize),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	go func() {
+		defer close(c.done)
+		c.supervisor.Run("pipeline flush", c.stop, c.run)
+	}()
+	return c
+}
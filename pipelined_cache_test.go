@@ -0,0 +1,87 @@
+package once_cache
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingPipelineStore is an IPipelineStore recording the time to live of the entries it is sent.
+type recordingPipelineStore struct {
+	mu      sync.Mutex
+	values  map[string]any
+	ttls    map[string]time.Duration
+	deletes []string
+}
+
+func newRecordingPipelineStore() *recordingPipelineStore {
+	return &recordingPipelineStore{values: map[string]any{}, ttls: map[string]time.Duration{}}
+}
+
+func (s *recordingPipelineStore) Set(key string, value any, d time.Duration) {
+	s.SetMulti(map[string]Entry{key: {Value: value, TTL: d}})
+}
+
+func (s *recordingPipelineStore) SetMulti(entries map[string]Entry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, entry := range entries {
+		s.values[key] = entry.Value
+		s.ttls[key] = entry.TTL
+	}
+}
+
+func (s *recordingPipelineStore) Get(key string) (any, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.values[key]
+	return v, ok
+}
+
+func (s *recordingPipelineStore) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.values, key)
+	s.deletes = append(s.deletes, key)
+}
+
+func TestPipelinedCacheExpiresPendingEntries(t *testing.T) {
+	store := newRecordingPipelineStore()
+	store.Set("k", "old", 0)
+	c := NewPipelinedCache(store, 100, 0)
+	defer c.Close()
+
+	c.Set("k", "new", 20*time.Millisecond)
+	if v, ok := c.Get("k"); !ok || v != "new" {
+		t.Fatalf("Get of a pending entry = %v, %v, want new, true", v, ok)
+	}
+	time.Sleep(30 * time.Millisecond)
+	// The expired pending entry replaces the stored value, so neither is served.
+	if v, ok := c.Get("k"); ok {
+		t.Fatalf("Get of an expired pending entry = %v, want a miss", v)
+	}
+	c.Flush()
+	if v, ok := store.Get("k"); ok {
+		t.Fatalf("flush of an expired entry left %v in the store, want it deleted", v)
+	}
+	if len(store.deletes) != 1 {
+		t.Fatalf("store deletes = %v, want [k]", store.deletes)
+	}
+}
+
+func TestPipelinedCacheFlushesRemainingTTL(t *testing.T) {
+	store := newRecordingPipelineStore()
+	c := NewPipelinedCache(store, 100, 0)
+	defer c.Close()
+
+	c.Set("short", 1, 200*time.Millisecond)
+	c.Set("forever", 2, 0)
+	time.Sleep(50 * time.Millisecond)
+	c.Flush()
+	if d := store.ttls["short"]; d <= 0 || d > 150*time.Millisecond {
+		t.Errorf("flushed TTL %v, want what is left of 200ms after 50ms", d)
+	}
+	if d, ok := store.ttls["forever"]; !ok || d != 0 {
+		t.Errorf("flushed TTL of an entry without expiry = %v, %v, want 0, true", d, ok)
+	}
+}
@@ -0,0 +1,58 @@
+package once_cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Prefetch asynchronously warms keys that are likely to be needed soon, such as the next page of results.
+// Keys already cached are skipped, loads are shared with concurrent regular loads of the same key, and
+// at most WithPrefetchConcurrency loads run at once. Prefetched values are stored with PriorityLow when
+// the store supports priorities. Cancelling ctx stops prefetching keys that have not started loading.
+func (o *OnceCache) Prefetch(ctx context.Context, keys []string, f KeyedFunc, d time.Duration) {
+	go o.prefetchKeys(ctx, keys, f, d)
+}
+
+// prefetchKeys prefetches keys and waits for their loads.
+func (o *OnceCache) prefetchKeys(ctx context.Context, keys []string, f KeyedFunc, d time.Duration) {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for _, key := range keys {
+		select {
+		case o.prefetchSem <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		wg.Add(1)
+		go func(key string) {
+			defer wg.Done()
+			defer func() { <-o.prefetchSem }()
+			defer o.recoverBackground(key)
+			o.prefetch(ctx, key, f, d)
+		}(key)
+	}
+}
+
+func (o *OnceCache) prefetch(ctx context.Context, key string, f KeyedFunc, d time.Duration) {
+	if ctx.Err() != nil {
+		return
+	}
+	if o.strict {
+		if err := o.checkCall(key, o.keyTTL(key, d), f != nil); err != nil {
+			o.backgroundError(key, err)
+			return
+		}
+	}
+	if _, ok := o.lookup(key); ok {
+		return
+	}
+	defer o.group.Forget(key)
+	// A failed prefetch leaves the key to be loaded on demand; the error is only reported.
+	_, err, _ := o.group.Do(key, func() (any, error) {
+		return o.loadWithPriority(ctx, key, f, o.keyTTL(key, d), PriorityLow, nil)
+	})
+	if err != nil {
+		o.backgroundError(key, err)
+	}
+}
@@ -0,0 +1,142 @@
+package once_cache
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// ErrPurgeUnsupported is returned by PurgeWhere when a store cannot enumerate its entries.
+var ErrPurgeUnsupported = errors.New("once_cache: store does not support PurgeWhere")
+
+// purgeStore runs PurgeWhere on store, which must implement IPurger or IPurgerWithError, directly or
+// wrapped by NewCacheIgnoringErrors.
+func purgeStore(store any, predicate func(key string, meta EntryInfo) bool) ([]string, error) {
+	switch s := store.(type) {
+	case IPurgerWithError:
+		return s.PurgeWhere(predicate)
+	case IPurger:
+		return s.PurgeWhere(predicate), nil
+	case cacheWithErrorProvider:
+		return purgeStore(s.CacheWithError(), predicate)
+	case legacyCache:
+		return purgeStore(s.ICache, predicate)
+	}
+	return nil, ErrPurgeUnsupported
+}
+
+// PurgeWhere removes every entry for which predicate returns true, including expired entries retained for
+// stale reads, and returns the removed keys. Entries overwritten during the scan are checked again before
+// being removed.
+func (c *MemoryCache) PurgeWhere(predicate func(key string, meta EntryInfo) bool) []string {
+	var purged []string
+	c.storage.rangeEntries(func(key string, e memoryEntry) bool {
+		if !predicate(key, e.info()) {
+			return true
+		}
+		if c.storage.deleteIf(key, func(e memoryEntry) bool { return predicate(key, e.info()) }) {
+			purged = append(purged, key)
+		}
+		return true
+	})
+	return purged
+}
+
+// PurgeWhere removes the entries matching predicate from both levels, after applying queued write-back
+// writes so that they cannot bring purged entries back. Keys purged from L2 are also deleted from L1.
+// Both levels must support PurgeWhere, see IPurger.
+func (c *TieredCache) PurgeWhere(predicate func(key string, meta EntryInfo) bool) ([]string, error) {
+	c.Flush()
+	purged, err := purgeStore(c.l2, predicate)
+	if err != nil {
+		return purged, err
+	}
+	for _, key := range purged {
+		c.l1.Delete(key)
+	}
+	local, err := purgeStore(c.l1, predicate)
+	return mergeKeys(purged, local), err
+}
+
+// PurgeWhere removes the rows matching predicate, including expired rows not yet cleaned up. The predicate
+// sees the keys and expiry times of the rows; the other metadata is not stored.
+func (c *SQLCache) PurgeWhere(predicate func(key string, meta EntryInfo) bool) ([]string, error) {
+	rows, err := c.db.Query(c.scanQuery)
+	if err != nil {
+		return nil, err
+	}
+	var matched []string
+	for rows.Next() {
+		var key string
+		var expiresAt sql.NullTime
+		if err := rows.Scan(&key, &expiresAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if predicate(key, EntryInfo{ExpiresAt: expiresAt.Time}) {
+			matched = append(matched, key)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i, key := range matched {
+		if err := c.Delete(key); err != nil {
+			return matched[:i], err
+		}
+	}
+	return matched, nil
+}
+
+// PurgeWhere removes every entry of the store matching predicate, see IPurger, as if each were deleted
+// with Forget: loads in flight cannot store them again, entries depending on them are deleted, and each
+// removal is recorded by the audit sink with the reason "purge". It suits data deletion requests, such as
+// removing every cached record of a user. Stores that cannot enumerate their entries return
+// ErrPurgeUnsupported.
+func (o *OnceCache) PurgeWhere(predicate func(key string, meta EntryInfo) bool) ([]string, error) {
+	purged, err := purgeStore(o.ICache, predicate)
+	if errors.Is(err, ErrPurgeUnsupported) {
+		purged, err = purgeStore(o.store, predicate)
+	}
+	for _, key := range purged {
+		o.tombstone(key, forgetWindow)
+		o.audit(AuditInvalidate, key, "", "purge", 0)
+		o.deleteDependents(key)
+	}
+	return purged, err
+}
+
+// PurgeWhere removes the entries matching predicate from the local cache, see OnceCache.PurgeWhere, and
+// publishes the removed keys so that the other instances delete them too. Predicates cannot be sent to
+// other instances, so entries only they hold are missed unless the local cache includes every shared tier,
+// as a TieredCache over the shared store does. The invalidation carries reason for the audit logs.
+func (b *InvalidationBus) PurgeWhere(ctx context.Context, predicate func(key string, meta EntryInfo) bool, reason string) ([]string, error) {
+	purged, err := purgeStore(b.cache, predicate)
+	if len(purged) == 0 {
+		return purged, err
+	}
+	inv := Invalidation{ID: randomID(), Origin: b.origin, Keys: purged, Reason: reason}
+	b.markSeen(inv.ID)
+	if perr := b.transport.Publish(ctx, inv); err == nil {
+		err = perr
+	}
+	return purged, err
+}
+
+// mergeKeys appends the keys of b missing from a.
+func mergeKeys(a, b []string) []string {
+	if len(a) == 0 {
+		return b
+	}
+	seen := make(map[string]struct{}, len(a))
+	for _, key := range a {
+		seen[key] = struct{}{}
+	}
+	for _, key := range b {
+		if _, ok := seen[key]; !ok {
+			a = append(a, key)
+		}
+	}
+	return a
+}
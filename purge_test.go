@@ -0,0 +1,60 @@
+package once_cache
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+func TestPurgeWhereRemovesMatchingEntriesAndDependents(t *testing.T) {
+	c := NewOnceCache(&singleflight.Group{}, NewMemoryCache())
+	c.Set("user:1:profile", 1, time.Minute)
+	c.Set("user:1:orders", 2, time.Minute)
+	c.Set("user:2:profile", 3, time.Minute)
+	c.Set("feed", 4, time.Minute)
+	c.DependsOn("feed", "user:1:orders")
+
+	purged, err := c.PurgeWhere(func(key string, meta EntryInfo) bool { return strings.HasPrefix(key, "user:1:") })
+	if err != nil {
+		t.Fatalf("PurgeWhere = %v", err)
+	}
+	slices.Sort(purged)
+	if want := []string{"user:1:orders", "user:1:profile"}; !slices.Equal(purged, want) {
+		t.Fatalf("PurgeWhere = %v, want %v", purged, want)
+	}
+	for _, key := range []string{"user:1:profile", "user:1:orders", "feed"} {
+		if _, ok := c.Get(key); ok {
+			t.Errorf("Get(%s) hit after the purge", key)
+		}
+	}
+	if _, ok := c.Get("user:2:profile"); !ok {
+		t.Error("PurgeWhere removed an entry not matching")
+	}
+}
+
+func TestPurgeWhereOfTieredCachePurgesBothLevels(t *testing.T) {
+	l1, l2 := NewMemoryCache(), NewMemoryCache()
+	c := NewTieredCache(l1, l2)
+	defer c.Close()
+	c.Set("a", 1, time.Minute)
+	l1.Set("b", 2, time.Minute)
+	purged, err := c.PurgeWhere(func(string, EntryInfo) bool { return true })
+	slices.Sort(purged)
+	if err != nil || !slices.Equal(purged, []string{"a", "b"}) {
+		t.Fatalf("PurgeWhere = %v, %v, want a and b", purged, err)
+	}
+	if _, ok := l1.Get("a"); ok {
+		t.Fatal("PurgeWhere left the key in L1")
+	}
+}
+
+func TestPurgeWhereReportsUnsupportedStores(t *testing.T) {
+	c := NewOnceCache(&singleflight.Group{}, NewPipelinedCache(newRecordingPipelineStore(), 1, 0))
+	if _, err := c.PurgeWhere(func(string, EntryInfo) bool { return true }); !errors.Is(err, ErrPurgeUnsupported) {
+		t.Fatalf("PurgeWhere = %v, want ErrPurgeUnsupported", err)
+	}
+}
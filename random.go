@@ -0,0 +1,86 @@
+package once_cache
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Random is the source of randomness of jitter and sampling, such as Revalidate's jitter, WithReadRepair
+// and WithShadowSampleRate. Implementations must be safe for concurrent use.
+type Random interface {
+	// Float64 returns a number in [0, 1).
+	Float64() float64
+}
+
+// defaultRandom draws from the shared source of math/rand.
+type defaultRandom struct{}
+
+func (defaultRandom) Float64() float64 {
+	return rand.Float64()
+}
+
+// lockedRandom serializes access to a rand.Source, which is not safe for concurrent use.
+type lockedRandom struct {
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+func (r *lockedRandom) Float64() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rng.Float64()
+}
+
+// NewRandom returns a Random drawing from src, which may be a cryptographic or a seeded source.
+func NewRandom(src rand.Source) Random {
+	return &lockedRandom{rng: rand.New(src)}
+}
+
+// randomOrDefault returns r, or the shared source of math/rand if r is nil.
+func randomOrDefault(r Random) Random {
+	if r == nil {
+		return defaultRandom{}
+	}
+	return r
+}
+
+// Jitter randomizes a duration d by up to fraction of d, in (0, 1], drawing from r.
+type Jitter func(r Random, d time.Duration, fraction float64) time.Duration
+
+// SymmetricJitter spreads d evenly over [d - fraction*d, d + fraction*d). It is the default Jitter.
+func SymmetricJitter(r Random, d time.Duration, fraction float64) time.Duration {
+	return d + time.Duration((r.Float64()*2-1)*fraction*float64(d))
+}
+
+// PositiveJitter spreads d over [d, d + fraction*d), so that waits are never shorter than d.
+func PositiveJitter(r Random, d time.Duration, fraction float64) time.Duration {
+	return d + time.Duration(r.Float64()*fraction*float64(d))
+}
+
+// WithRandom sets the source of randomness of the cache's jitter. It defaults to the shared source of
+// math/rand; a seeded source, see the testutil package, makes the jitter reproducible.
+func WithRandom(r Random) Option {
+	return func(o *OnceCache) {
+		o.random = r
+	}
+}
+
+// WithJitter sets how the cache's jitter randomizes durations. It defaults to SymmetricJitter.
+func WithJitter(jitter Jitter) Option {
+	return func(o *OnceCache) {
+		o.jitter = jitter
+	}
+}
+
+// jittered returns d randomized by up to fraction of d with the cache's random source and jitter.
+func (o *OnceCache) jittered(d time.Duration, fraction float64) time.Duration {
+	if fraction <= 0 {
+		return d
+	}
+	jitter := o.jitter
+	if jitter == nil {
+		jitter = SymmetricJitter
+	}
+	return jitter(randomOrDefault(o.random), d, min(fraction, 1))
+}
@@ -0,0 +1,96 @@
+package once_cache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrLoadRateLimited is reported when a load is refused because the key's loader ran too often,
+// see WithLoadRateLimit.
+var ErrLoadRateLimited = errors.New("once_cache: load rate limited")
+
+// rateLimiterSweepEvery is how many checks pass between sweeps of idle buckets.
+const rateLimiterSweepEvery = 1024
+
+// WithLoadRateLimit limits how often the loader may run for each key with a token bucket holding burst
+// tokens and refilled with one token every interval, whether or not the entry expired in between.
+// Refused loads fail with ErrLoadRateLimited, and callers are served the stale value if the store retains one.
+// Batches take a token for each key they load and leave out the keys without one.
+func WithLoadRateLimit(interval time.Duration, burst int) Option {
+	return func(o *OnceCache) {
+		o.limiter = newKeyRateLimiter(interval, burst)
+	}
+}
+
+// keyRateLimiter is a token bucket per key.
+type keyRateLimiter struct {
+	interval time.Duration
+	burst    float64
+	buckets  sync.Map
+	checks   atomic.Int64
+}
+
+type tokenBucket struct {
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+	// dead is set when the bucket was swept, so that callers holding it use a new one.
+	dead bool
+}
+
+func newKeyRateLimiter(interval time.Duration, burst int) *keyRateLimiter {
+	return &keyRateLimiter{interval: interval, burst: float64(max(1, burst))}
+}
+
+// allow takes a token from the bucket of key, reporting whether one was available.
+func (l *keyRateLimiter) allow(key string) bool {
+	now := time.Now()
+	if l.checks.Add(1)%rateLimiterSweepEvery == 0 {
+		l.sweep(now)
+	}
+	for {
+		v, ok := l.buckets.Load(key)
+		if !ok {
+			v, _ = l.buckets.LoadOrStore(key, &tokenBucket{tokens: l.burst, last: now})
+		}
+		b := v.(*tokenBucket)
+		b.mu.Lock()
+		if b.dead {
+			b.mu.Unlock()
+			continue
+		}
+		b.refill(now, l)
+		allowed := b.tokens >= 1
+		if allowed {
+			b.tokens--
+		}
+		b.mu.Unlock()
+		return allowed
+	}
+}
+
+func (b *tokenBucket) refill(now time.Time, l *keyRateLimiter) {
+	if l.interval > 0 {
+		b.tokens = min(l.burst, b.tokens+float64(now.Sub(b.last))/float64(l.interval))
+	} else {
+		b.tokens = l.burst
+	}
+	b.last = now
+}
+
+// sweep drops buckets that have refilled completely, since they behave like new ones.
+func (l *keyRateLimiter) sweep(now time.Time) {
+	l.buckets.Range(func(key, v any) bool {
+		b := v.(*tokenBucket)
+		b.mu.Lock()
+		b.refill(now, l)
+		if b.tokens >= l.burst {
+			b.dead = true
+			l.buckets.CompareAndDelete(key, v)
+		}
+		b.mu.Unlock()
+		return true
+	})
+}
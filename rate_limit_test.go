@@ -0,0 +1,43 @@
+package once_cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+func TestLoadRateLimitRefusesLoadsBeyondTheBurst(t *testing.T) {
+	c := NewOnceCache(&singleflight.Group{}, NewMemoryCache(), WithLoadRateLimit(time.Hour, 2))
+	loads := 0
+	load := func() (any, error) {
+		loads++
+		return loads, nil
+	}
+	for i := 0; i < 2; i++ {
+		if res := c.GetResult("a", load, WithForceRefresh()); res.Err != nil {
+			t.Fatalf("load %d = %v, want it within the burst", i, res.Err)
+		}
+	}
+	if res := c.GetResult("a", load, WithForceRefresh()); !errors.Is(res.Err, ErrLoadRateLimited) {
+		t.Fatalf("load beyond the burst = %v, want ErrLoadRateLimited", res.Err)
+	}
+	if res := c.GetResult("b", load); res.Err != nil {
+		t.Fatalf("load of another key = %v, want its own bucket", res.Err)
+	}
+	if loads != 3 {
+		t.Fatalf("loader ran %d times, want 3", loads)
+	}
+}
+
+func TestKeyRateLimiterRefills(t *testing.T) {
+	l := newKeyRateLimiter(10*time.Millisecond, 1)
+	if !l.allow("a") || l.allow("a") {
+		t.Fatal("bucket of one token allowed two loads at once")
+	}
+	time.Sleep(15 * time.Millisecond)
+	if !l.allow("a") {
+		t.Fatal("bucket did not refill after the interval")
+	}
+}
@@ -0,0 +1,63 @@
+package once_cache
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// WithRefreshRetry retries a failed background refresh, started by refresh-ahead or StaleWhileRevalidate,
+// instead of waiting for the next access to the key, so hot keys become fresh again soon after the origin
+// recovers. The refresh is retried up to attempts times, waiting initial before the first retry and doubling
+// the wait up to maxDelay. Failed retries are reported to the handler of WithBackgroundErrorHandler.
+func WithRefreshRetry(initial, maxDelay time.Duration, attempts int) Option {
+	return func(o *OnceCache) {
+		o.refreshRetry = &refreshRetry{initial: initial, max: maxDelay, attempts: attempts}
+	}
+}
+
+// refreshRetry holds the keys whose failed refresh is waiting to be retried.
+type refreshRetry struct {
+	initial, max time.Duration
+	attempts     int
+	pending      sync.Map
+}
+
+// delay returns the wait before the given retry, counted from one.
+func (r *refreshRetry) delay(attempt int) time.Duration {
+	d := r.initial
+	for i := 1; i < attempt && (r.max <= 0 || d < r.max); i++ {
+		d *= 2
+	}
+	if r.max > 0 && d > r.max {
+		d = r.max
+	}
+	return d
+}
+
+// retryRefresh schedules the given retry of the refresh of key, unless retries are disabled or exhausted,
+// or one is already scheduled.
+func (o *OnceCache) retryRefresh(ctx context.Context, key string, f KeyedFunc, d time.Duration, attempt int) {
+	r := o.refreshRetry
+	if r == nil || attempt > r.attempts || ctx.Err() != nil {
+		return
+	}
+	if _, loaded := r.pending.LoadOrStore(key, struct{}{}); loaded {
+		return
+	}
+	time.AfterFunc(r.delay(attempt), func() {
+		r.pending.Delete(key)
+		// Leave the key to a refresh started meanwhile, which retries itself if it fails.
+		if _, loaded := o.refreshing.LoadOrStore(key, struct{}{}); loaded {
+			return
+		}
+		defer o.refreshing.Delete(key)
+		defer o.recoverBackground(key)
+		o.decide(DecisionRefresh, key, "retry "+strconv.Itoa(attempt), nil, 0)
+		if res := o.do(ctx, key, f, d, 0, 0); res.Err != nil {
+			o.backgroundError(key, res.Err)
+			o.retryRefresh(ctx, key, f, d, attempt+1)
+		}
+	})
+}
@@ -0,0 +1,55 @@
+package once_cache
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+func TestRefreshRetryRetriesFailedRefreshes(t *testing.T) {
+	var errs atomic.Int32
+	c := NewOnceCache(&singleflight.Group{}, NewMemoryCache(),
+		WithRefreshAhead(time.Hour),
+		WithRefreshRetry(5*time.Millisecond, 10*time.Millisecond, 3),
+		WithBackgroundErrorHandler(func(key string, err error) { errs.Add(1) }))
+	var loads atomic.Int32
+	load := func() (any, error) {
+		switch loads.Add(1) {
+		case 1:
+			return "first", nil
+		case 2:
+			return nil, errors.New("origin down")
+		}
+		return "refreshed", nil
+	}
+	c.GetResult("a", load, WithTTL(time.Minute))
+	// Within the refresh-ahead window, so the hit starts a background refresh, which fails.
+	if res := c.GetResult("a", load, WithTTL(time.Minute)); !res.Hit {
+		t.Fatalf("GetResult = %+v, want a hit", res)
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		if v, _ := c.Get("a"); v == "refreshed" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("the failed refresh was not retried, %d loads", loads.Load())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if errs.Load() != 1 {
+		t.Fatalf("%d background errors, want the failed refresh reported", errs.Load())
+	}
+}
+
+func TestRefreshRetryDelayDoublesUpToTheMaximum(t *testing.T) {
+	r := &refreshRetry{initial: time.Second, max: 3 * time.Second}
+	for attempt, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 3 * time.Second, 5: 3 * time.Second} {
+		if got := r.delay(attempt); got != want {
+			t.Errorf("delay of retry %d = %v, want %v", attempt, got, want)
+		}
+	}
+}
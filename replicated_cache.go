@@ -0,0 +1,177 @@
+package once_cache
+
+import (
+	"sync"
+	"time"
+)
+
+// defaultReplicationQueueSize is how many writes a ReplicatedCache buffers for the standby by default.
+const defaultReplicationQueueSize = 4096
+
+// ReplicationStats describes how far the standby of a ReplicatedCache trails the primary.
+type ReplicationStats struct {
+	// Replicated is the number of writes applied to the standby.
+	Replicated uint64
+	// Dropped is the number of writes not replicated because the queue was full.
+	Dropped uint64
+	// Pending is the number of writes waiting to be applied to the standby.
+	Pending int
+	// Lag is how long the last replicated write waited before being applied to the standby.
+	Lag time.Duration
+	// MaxLag is the longest such wait so far.
+	MaxLag time.Duration
+	// LastReplicated is when a write was last applied to the standby.
+	LastReplicated time.Time
+}
+
+// replicatedWrite is a Set or Delete waiting to be applied to the standby.
+type replicatedWrite struct {
+	levelWrite
+	at time.Time
+}
+
+// ReplicationOption configures a ReplicatedCache.
+type ReplicationOption func(*ReplicatedCache)
+
+// WithReplicationSupervisor runs the replication goroutine under s instead of DefaultSupervisor, reporting
+// the panics of the standby store to it.
+func WithReplicationSupervisor(s *Supervisor) ReplicationOption {
+	return func(c *ReplicatedCache) {
+		c.supervisor = s
+	}
+}
+
+// ReplicatedCache is a struct that implements the ICache interface over a primary store whose writes are
+// mirrored asynchronously to a standby store, such as one in another region, so that a failover to the
+// standby starts with a mostly warm cache. Reads are served by the primary alone and never wait for the
+// standby. When the standby falls behind by more than the queue size, writes are dropped rather than
+// slowing the primary down; Stats reports them along with the replication lag.
+type ReplicatedCache struct {
+	primary, standby ICache
+	queue            chan replicatedWrite
+	supervisor       *Supervisor
+
+	mu    sync.Mutex
+	idle  *sync.Cond
+	stats ReplicationStats
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// Set stores the value in the primary and queues it for the standby.
+func (c *ReplicatedCache) Set(key string, value any, d time.Duration) {
+	c.primary.Set(key, value, d)
+	c.replicate(levelWrite{key: key, value: value, d: d})
+}
+
+// Get retrieves the value from the primary.
+func (c *ReplicatedCache) Get(key string) (any, bool) {
+	return c.primary.Get(key)
+}
+
+// Delete removes the key from the primary and queues its removal from the standby.
+func (c *ReplicatedCache) Delete(key string) {
+	c.primary.Delete(key)
+	c.replicate(levelWrite{key: key, delete: true})
+}
+
+// Standby returns the standby store, to be served from on failover.
+func (c *ReplicatedCache) Standby() ICache {
+	return c.standby
+}
+
+// Stats returns a snapshot of the replication statistics.
+func (c *ReplicatedCache) Stats() ReplicationStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// Flush waits until the queued writes have been applied to the standby.
+func (c *ReplicatedCache) Flush() {
+	c.mu.Lock()
+	for c.stats.Pending > 0 {
+		c.idle.Wait()
+	}
+	c.mu.Unlock()
+}
+
+// Close applies the queued writes to the standby and stops replicating. The cache must not be written to
+// after Close.
+func (c *ReplicatedCache) Close() {
+	c.stopOnce.Do(func() {
+		c.Flush()
+		close(c.stop)
+	})
+	<-c.done
+}
+
+func (c *ReplicatedCache) replicate(op levelWrite) {
+	c.mu.Lock()
+	c.stats.Pending++
+	c.mu.Unlock()
+	select {
+	case c.queue <- replicatedWrite{levelWrite: op, at: time.Now()}:
+	default:
+		c.mu.Lock()
+		c.stats.Pending--
+		c.stats.Dropped++
+		c.signalIdle()
+		c.mu.Unlock()
+	}
+}
+
+func (c *ReplicatedCache) run() {
+	for {
+		select {
+		case op := <-c.queue:
+			c.supervisor.Protect("replication", func() { op.apply(c.standby) })
+			now := time.Now()
+			lag := now.Sub(op.at)
+			c.mu.Lock()
+			c.stats.Pending--
+			c.stats.Replicated++
+			c.stats.Lag = lag
+			c.stats.MaxLag = max(c.stats.MaxLag, lag)
+			c.stats.LastReplicated = now
+			c.signalIdle()
+			c.mu.Unlock()
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+// signalIdle wakes Flush once nothing is pending. c.mu must be held.
+func (c *ReplicatedCache) signalIdle() {
+	if c.stats.Pending == 0 {
+		c.idle.Broadcast()
+	}
+}
+
+// NewReplicatedCache creates a new instance of ReplicatedCache mirroring the writes of primary to standby,
+// buffering up to queueSize writes, or 4096 if queueSize is not positive. Call Close to stop replicating.
+func NewReplicatedCache(primary, standby ICache, queueSize int, opts ...ReplicationOption) *ReplicatedCache {
+	if queueSize <= 0 {
+		queueSize = defaultReplicationQueueSize
+	}
+	c := &ReplicatedCache{
+		primary:    primary,
+		standby:    standby,
+		queue:      make(chan replicatedWrite, queueSize),
+		supervisor: DefaultSupervisor,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.idle = sync.NewCond(&c.mu)
+	go func() {
+		defer close(c.done)
+		c.supervisor.Run("replication", c.stop, c.run)
+	}()
+	return c
+}
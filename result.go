@@ -0,0 +1,40 @@
+package once_cache
+
+import "time"
+
+// Result describes the outcome of a cache lookup, so that callers and middleware can act on
+// and log exactly what happened.
+type Result struct {
+	// Value is the returned value, if any.
+	Value any
+	// Err is the load error, if the value had to be loaded and the load failed.
+	Err error
+	// Hit reports whether Value was read from the cache.
+	Hit bool
+	// Stale reports whether Value is an expired value, served because the load failed or while it reloads.
+	Stale bool
+	// Shared reports whether the load was shared with other callers.
+	Shared bool
+	// LoadDuration is how long the load took, zero on a hit.
+	LoadDuration time.Duration
+	// Timing breaks down where the time of the call went.
+	Timing Timing
+}
+
+// Timing is the breakdown of the time of a call, telling slowness of the store apart from slowness of the loader.
+type Timing struct {
+	// Lookup is how long reading the store took, including the read of a stale value.
+	Lookup time.Duration
+	// Wait is how long the caller waited for the flight of the load, whether it ran the load or joined it.
+	// It includes Load and Set.
+	Wait time.Duration
+	// Load is how long the loader ran, zero if the flight was shared with another path, see WithCrossPathCoalescing.
+	Load time.Duration
+	// Set is how long storing the loaded value took.
+	Set time.Duration
+}
+
+// OK reports whether the result carries a value, either fresh, cached or stale.
+func (r Result) OK() bool {
+	return r.Err == nil || r.Hit || r.Stale
+}
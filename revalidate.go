@@ -0,0 +1,37 @@
+package once_cache
+
+import (
+	"context"
+	"time"
+)
+
+// Revalidate keeps key up to date by reloading it with f every interval, stored with NoExpiration, until ctx
+// is done or the returned stop function is called. It suits permanent entries, such as configuration, that
+// must still converge with their source. Each wait is randomized by up to jitter, a fraction of interval,
+// so that instances do not reload in lockstep, see WithRandom and WithJitter. Failed reloads keep the current value and are reported to
+// the handler of WithBackgroundErrorHandler.
+func (o *OnceCache) Revalidate(ctx context.Context, key string, f KeyedFunc, interval time.Duration, jitter float64) (stop func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		for {
+			timer := time.NewTimer(o.jittered(interval, jitter))
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			}
+			o.revalidate(ctx, key, f)
+		}
+	}()
+	return cancel
+}
+
+// revalidate reloads key once, reporting failures and panics as background errors.
+func (o *OnceCache) revalidate(ctx context.Context, key string, f KeyedFunc) {
+	defer o.recoverBackground(key)
+	res := o.do(ctx, key, f, NoExpiration, 0, 0)
+	if res.Err != nil && ctx.Err() == nil {
+		o.backgroundError(key, res.Err)
+	}
+}
@@ -0,0 +1,43 @@
+package once_cache
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+func TestRevalidateReloadsUntilStopped(t *testing.T) {
+	var errs atomic.Int32
+	c := NewOnceCache(&singleflight.Group{}, NewMemoryCache(),
+		WithBackgroundErrorHandler(func(key string, err error) { errs.Add(1) }))
+	var loads atomic.Int32
+	stop := c.Revalidate(context.Background(), "config", func(ctx context.Context, key string) (any, error) {
+		n := loads.Add(1)
+		if n == 2 {
+			return nil, errors.New("source down")
+		}
+		return n, nil
+	}, 5*time.Millisecond, 0)
+	time.Sleep(40 * time.Millisecond)
+	stop()
+	time.Sleep(10 * time.Millisecond)
+
+	n := loads.Load()
+	if n < 3 {
+		t.Fatalf("Revalidate loaded %d times, want it to keep reloading", n)
+	}
+	if errs.Load() != 1 {
+		t.Fatalf("%d background errors, want the failed reload reported once", errs.Load())
+	}
+	if v, ok := c.Get("config"); !ok || v.(int32) < 3 {
+		t.Fatalf("Get = %v, %v, want the latest reload", v, ok)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if loads.Load() != n {
+		t.Fatal("Revalidate kept reloading after stop")
+	}
+}
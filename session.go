@@ -0,0 +1,105 @@
+package once_cache
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrInvalidSessionToken is returned by ParseSessionToken for malformed tokens.
+var ErrInvalidSessionToken = errors.New("once_cache: invalid session token")
+
+// SessionToken marks a write made by a session, so that the session's later reads bypass cached values
+// older than the write, see WithSessionToken. The zero value marks no write.
+type SessionToken struct {
+	// Version is the entry version of the write, zero if the store does not track versions.
+	Version uint64
+	// Time is when the write happened.
+	Time time.Time
+}
+
+// IsZero reports whether the token marks no write.
+func (t SessionToken) IsZero() bool {
+	return t.Version == 0 && t.Time.IsZero()
+}
+
+// Merge returns a token covering both t and u, to be kept by a session making several writes.
+func (t SessionToken) Merge(u SessionToken) SessionToken {
+	if u.Version > t.Version {
+		t.Version = u.Version
+	}
+	if u.Time.After(t.Time) {
+		t.Time = u.Time
+	}
+	return t
+}
+
+// String encodes the token for a cookie or header, see ParseSessionToken.
+func (t SessionToken) String() string {
+	var nanos int64
+	if !t.Time.IsZero() {
+		nanos = t.Time.UnixNano()
+	}
+	return fmt.Sprintf("%d.%d", t.Version, nanos)
+}
+
+// predates reports whether an entry with info was written before the write the token marks.
+func (t SessionToken) predates(info EntryInfo) bool {
+	if t.Version != 0 && info.Version != 0 && info.Version < t.Version {
+		return true
+	}
+	return info.CreatedAt.Before(t.Time)
+}
+
+// ParseSessionToken decodes a token encoded with SessionToken.String.
+func ParseSessionToken(s string) (SessionToken, error) {
+	var version uint64
+	var nanos int64
+	if n, err := fmt.Sscanf(s, "%d.%d", &version, &nanos); err != nil || n != 2 {
+		return SessionToken{}, ErrInvalidSessionToken
+	}
+	t := SessionToken{Version: version}
+	if nanos != 0 {
+		t.Time = time.Unix(0, nanos)
+	}
+	return t, nil
+}
+
+// WithSessionToken gives read-your-writes consistency to a session that made the write marked by token:
+// a cached value written before it is skipped and loaded again. The store must implement IEntryInfoGetter,
+// as MemoryCache does; otherwise the token has no effect.
+func WithSessionToken(token SessionToken) CallOption {
+	return func(c *callConfig) {
+		c.session = token
+	}
+}
+
+// SetWithToken stores the value like Set and returns a token marking the write, see WithSessionToken.
+func (o *OnceCache) SetWithToken(key string, value any, d time.Duration) SessionToken {
+	o.Forget(key)
+	o.Set(key, value, d)
+	token := SessionToken{Time: time.Now()}
+	if o.infoGetter != nil {
+		if _, info, ok := o.infoGetter.GetWithInfo(key); ok {
+			token = SessionToken{Version: info.Version, Time: info.CreatedAt}
+		}
+	}
+	return token
+}
+
+// DeleteWithToken deletes the key like Forget and returns a token marking the deletion, for sessions that
+// changed the data behind the cache, see WithSessionToken. Reads carrying the token skip every value
+// cached before it, whatever its key.
+func (o *OnceCache) DeleteWithToken(key string) SessionToken {
+	o.Forget(key)
+	return SessionToken{Time: time.Now()}
+}
+
+// olderThanSession reports whether the entry cached for key predates the session's last write.
+func (o *OnceCache) olderThanSession(key string, token SessionToken) bool {
+	if token.IsZero() || o.infoGetter == nil {
+		return false
+	}
+	_, info, ok := o.infoGetter.GetWithInfo(key)
+	return ok && token.predates(info)
+}
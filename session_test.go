@@ -0,0 +1,58 @@
+package once_cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+func TestSessionTokenRoundTripsAndMerges(t *testing.T) {
+	token := SessionToken{Version: 7, Time: time.Unix(0, 1234)}
+	parsed, err := ParseSessionToken(token.String())
+	if err != nil || parsed.Version != 7 || !parsed.Time.Equal(token.Time) {
+		t.Fatalf("ParseSessionToken = %+v, %v, want %+v", parsed, err, token)
+	}
+	if _, err := ParseSessionToken("garbage"); !errors.Is(err, ErrInvalidSessionToken) {
+		t.Fatalf("ParseSessionToken of garbage = %v, want ErrInvalidSessionToken", err)
+	}
+	merged := token.Merge(SessionToken{Version: 3, Time: time.Unix(0, 5678)})
+	if merged.Version != 7 || merged.Time.UnixNano() != 5678 {
+		t.Fatalf("Merge = %+v, want the newest version and time", merged)
+	}
+	if !(SessionToken{}).IsZero() || token.IsZero() {
+		t.Fatal("IsZero is wrong")
+	}
+}
+
+func TestSessionTokenSkipsOlderCachedValues(t *testing.T) {
+	store := NewMemoryCache()
+	c := NewOnceCache(&singleflight.Group{}, store)
+	c.Set("a", "old", time.Minute)
+	// Another instance writes the source and the session gets a token, while this cache still holds "old".
+	token := SessionToken{Time: time.Now().Add(time.Millisecond)}
+	time.Sleep(2 * time.Millisecond)
+
+	load := func() (any, error) { return "new", nil }
+	if res := c.GetResult("a", load, WithTTL(time.Minute)); res.Value != "old" {
+		t.Fatalf("GetResult without the token = %v, want the cached value", res.Value)
+	}
+	if res := c.GetResult("a", load, WithTTL(time.Minute), WithSessionToken(token)); res.Hit || res.Value != "new" {
+		t.Fatalf("GetResult with the token = %+v, want a reload", res)
+	}
+	if res := c.GetResult("a", load, WithTTL(time.Minute), WithSessionToken(token)); !res.Hit {
+		t.Fatalf("GetResult with the token after the reload = %+v, want a hit", res)
+	}
+}
+
+func TestSetWithTokenMarksTheWrite(t *testing.T) {
+	c := NewOnceCache(&singleflight.Group{}, NewMemoryCache())
+	token := c.SetWithToken("a", 1, time.Minute)
+	if token.IsZero() {
+		t.Fatal("SetWithToken returned a zero token")
+	}
+	if res := c.GetResult("a", func() (any, error) { return 2, nil }, WithSessionToken(token)); !res.Hit || res.Value != 1 {
+		t.Fatalf("GetResult with the write's own token = %+v, want the written value", res)
+	}
+}
@@ -0,0 +1,62 @@
+package once_cache
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// ErrSetFailed is returned to callers when a loaded value could not be stored and
+// the SetErrorPolicy chose to surface the failure.
+var ErrSetFailed = errors.New("once_cache: set failed")
+
+// SetErrorPolicy handles a failed Set of a freshly loaded value.
+// Returning a non-nil error surfaces the failure to the caller as a load error.
+type SetErrorPolicy func(store ICacheWithError, key string, value any, d time.Duration, err error) error
+
+// IgnoreSetError is a SetErrorPolicy that silently drops Set failures. It is the default policy.
+func IgnoreSetError(store ICacheWithError, key string, value any, d time.Duration, err error) error {
+	return nil
+}
+
+// SurfaceSetError is a SetErrorPolicy that reports Set failures to the caller.
+func SurfaceSetError(store ICacheWithError, key string, value any, d time.Duration, err error) error {
+	return err
+}
+
+// LogSetError returns a SetErrorPolicy that logs Set failures and otherwise ignores them.
+// If logger is nil, slog.Default() is used.
+func LogSetError(logger *slog.Logger) SetErrorPolicy {
+	return func(store ICacheWithError, key string, value any, d time.Duration, err error) error {
+		l := logger
+		if l == nil {
+			l = slog.Default()
+		}
+		l.Warn("once_cache: set failed", "key", key, "error", err)
+		return nil
+	}
+}
+
+// RetrySetAsync returns a SetErrorPolicy that retries the Set in the background up to attempts
+// times, doubling the wait between attempts starting at backoff. The caller is not affected.
+func RetrySetAsync(attempts int, backoff time.Duration) SetErrorPolicy {
+	return RetrySetAsyncWithSupervisor(DefaultSupervisor, attempts, backoff)
+}
+
+// RetrySetAsyncWithSupervisor is RetrySetAsync running the retries under s, which a panicking store
+// is reported to.
+func RetrySetAsyncWithSupervisor(s *Supervisor, attempts int, backoff time.Duration) SetErrorPolicy {
+	return func(store ICacheWithError, key string, value any, d time.Duration, err error) error {
+		go s.Protect("set retry of "+key, func() {
+			wait := backoff
+			for i := 0; i < attempts; i++ {
+				time.Sleep(wait)
+				if store.Set(key, value, d) == nil {
+					return
+				}
+				wait *= 2
+			}
+		})
+		return nil
+	}
+}
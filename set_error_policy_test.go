@@ -0,0 +1,59 @@
+package once_cache
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+func TestSurfaceSetErrorFailsTheLoad(t *testing.T) {
+	store := &flakyStore{MemoryCache: NewMemoryCache(), down: true}
+	c := NewOnceCacheWithError(&singleflight.Group{}, store, WithSetErrorPolicy(SurfaceSetError))
+	if res := c.GetResult("a", func() (any, error) { return 1, nil }); !errors.Is(res.Err, errStoreDown) {
+		t.Fatalf("GetResult = %+v, want the Set error", res)
+	}
+	ignoring := NewOnceCacheWithError(&singleflight.Group{}, store)
+	if res := ignoring.GetResult("a", func() (any, error) { return 1, nil }); res.Err != nil || res.Value != 1 {
+		t.Fatalf("GetResult ignoring Set errors = %+v, want the loaded value", res)
+	}
+}
+
+// recoveringStore is an ICacheWithError over a MemoryCache failing the first failures Sets.
+type recoveringStore struct {
+	*MemoryCache
+	mu       sync.Mutex
+	failures int
+}
+
+func (s *recoveringStore) Set(key string, value any, d time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failures > 0 {
+		s.failures--
+		return errStoreDown
+	}
+	s.MemoryCache.Set(key, value, d)
+	return nil
+}
+
+func (s *recoveringStore) Get(key string) (any, bool, error) {
+	v, ok := s.MemoryCache.Get(key)
+	return v, ok, nil
+}
+
+func (s *recoveringStore) Delete(key string) error {
+	s.MemoryCache.Delete(key)
+	return nil
+}
+
+func TestRetrySetAsyncStoresTheValueOnceTheStoreRecovers(t *testing.T) {
+	store := &recoveringStore{MemoryCache: NewMemoryCache(), failures: 1}
+	policy := RetrySetAsync(3, time.Millisecond)
+	if err := policy(store, "a", 1, time.Minute, errStoreDown); err != nil {
+		t.Fatalf("policy = %v, want nil", err)
+	}
+	waitFor(t, "the retried Set", func() bool { _, ok := store.MemoryCache.Get("a"); return ok })
+}
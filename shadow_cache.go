@@ -0,0 +1,306 @@
+package once_cache
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultShadowQueueSize is how many operations a ShadowCache buffers for the shadow store by default.
+const defaultShadowQueueSize = 1024
+
+// ShadowStats describes how a shadow store diverges from the primary store of a ShadowCache.
+type ShadowStats struct {
+	// Writes is the number of Sets and Deletes mirrored to the shadow.
+	Writes uint64
+	// Compared is the number of reads compared with the shadow.
+	Compared uint64
+	// Matches is the number of compared reads where both stores agreed, on a value or on a miss.
+	Matches uint64
+	// Mismatches is the number of compared reads where both stores hit with different values.
+	Mismatches uint64
+	// ShadowMisses is the number of compared reads that hit the primary but missed the shadow.
+	ShadowMisses uint64
+	// ShadowOnlyHits is the number of compared reads that missed the primary but hit the shadow.
+	ShadowOnlyHits uint64
+	// Dropped is the number of writes not mirrored because the queue was full.
+	Dropped uint64
+	// DroppedCompares is the number of sampled reads not compared because the queue was full.
+	DroppedCompares uint64
+}
+
+// ShadowOption configures a ShadowCache.
+type ShadowOption func(*ShadowCache)
+
+// WithShadowSampleRate compares a fraction of the reads, in [0, 1], with the shadow. It defaults to 1.
+func WithShadowSampleRate(rate float64) ShadowOption {
+	return func(c *ShadowCache) {
+		c.sampleRate = rate
+	}
+}
+
+// WithShadowCompare sets the function deciding whether the values of both stores agree,
+// reflect.DeepEqual by default.
+func WithShadowCompare(equal func(primary, shadow any) bool) ShadowOption {
+	return func(c *ShadowCache) {
+		c.equal = equal
+	}
+}
+
+// WithShadowMismatchHandler sets a function called with every read on which the stores disagree, ok being
+// whether each store hit. It runs on the shadow goroutine and must not block.
+func WithShadowMismatchHandler(handler func(key string, primary, shadow any, primaryOK, shadowOK bool)) ShadowOption {
+	return func(c *ShadowCache) {
+		c.onMismatch = handler
+	}
+}
+
+// WithShadowQueueSize sets how many writes, and separately how many reads to compare, are buffered for the
+// shadow, 1024 by default.
+func WithShadowQueueSize(n int) ShadowOption {
+	return func(c *ShadowCache) {
+		c.queueSize = n
+	}
+}
+
+// WithShadowRandom sets the source of randomness of WithShadowSampleRate's sampling.
+func WithShadowRandom(r Random) ShadowOption {
+	return func(c *ShadowCache) {
+		c.random = r
+	}
+}
+
+// WithShadowSupervisor runs the shadow goroutine under s instead of DefaultSupervisor, reporting the panics
+// of the shadow store and of the mismatch handler to it.
+func WithShadowSupervisor(s *Supervisor) ShadowOption {
+	return func(c *ShadowCache) {
+		c.supervisor = s
+	}
+}
+
+// shadowOp is a mirrored write or a read to compare, applied to the shadow in order.
+type shadowOp struct {
+	write     levelWrite
+	compare   bool
+	value     any
+	primaryOK bool
+	// after is the number of writes queued before a read to compare, which must be applied first.
+	after uint64
+}
+
+// ShadowCache is a struct that implements the ICache interface over a primary store while mirroring its
+// traffic to a shadow store, such as a new backend or a cache with another eviction policy, so that it can
+// be validated in production before switching. Writes are duplicated to the shadow, and sampled reads are
+// repeated on the shadow and compared, with the divergence reported by Stats. Callers only ever see the
+// primary: the shadow is driven by a background goroutine in the order of the operations, and operations
+// are dropped rather than slowing the primary down when it falls behind. Reads to compare are queued apart
+// from writes, so that they never take the room of a write.
+type ShadowCache struct {
+	primary, shadow ICache
+	sampleRate      float64
+	random          Random
+	supervisor      *Supervisor
+	equal           func(primary, shadow any) bool
+	onMismatch      func(key string, primary, shadow any, primaryOK, shadowOK bool)
+	queueSize       int
+	writeQueue      chan shadowOp
+	compareQueue    chan shadowOp
+
+	// writeMu orders counting the queued writes with queueing them.
+	writeMu sync.Mutex
+	queued  atomic.Uint64
+	// applied is the number of writes applied, read and written by the shadow goroutine only.
+	applied uint64
+
+	writes, compared, matches, mismatches atomic.Uint64
+	shadowMisses, shadowOnlyHits, dropped atomic.Uint64
+	droppedCompares                       atomic.Uint64
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// Set stores the value in the primary and mirrors it to the shadow.
+func (c *ShadowCache) Set(key string, value any, d time.Duration) {
+	c.primary.Set(key, value, d)
+	c.enqueueWrite(shadowOp{write: levelWrite{key: key, value: value, d: d}})
+}
+
+// Get retrieves the value from the primary, comparing it with the shadow for sampled reads.
+func (c *ShadowCache) Get(key string) (any, bool) {
+	value, ok := c.primary.Get(key)
+	if c.sampleRate >= 1 || (c.sampleRate > 0 && c.random.Float64() < c.sampleRate) {
+		c.enqueueCompare(shadowOp{write: levelWrite{key: key}, compare: true, value: value, primaryOK: ok})
+	}
+	return value, ok
+}
+
+// Delete removes the key from the primary and from the shadow.
+func (c *ShadowCache) Delete(key string) {
+	c.primary.Delete(key)
+	c.enqueueWrite(shadowOp{write: levelWrite{key: key, delete: true}})
+}
+
+// Stats returns a snapshot of the divergence statistics.
+func (c *ShadowCache) Stats() ShadowStats {
+	return ShadowStats{
+		Writes:         c.writes.Load(),
+		Compared:       c.compared.Load(),
+		Matches:        c.matches.Load(),
+		Mismatches:     c.mismatches.Load(),
+		ShadowMisses:   c.shadowMisses.Load(),
+		ShadowOnlyHits: c.shadowOnlyHits.Load(),
+		Dropped:        c.dropped.Load(),
+
+		DroppedCompares: c.droppedCompares.Load(),
+	}
+}
+
+// Close stops mirroring after applying the queued operations. The cache must not be used after Close.
+func (c *ShadowCache) Close() {
+	c.stopOnce.Do(func() {
+		close(c.stop)
+	})
+	<-c.done
+}
+
+func (c *ShadowCache) enqueueWrite(op shadowOp) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	select {
+	case c.writeQueue <- op:
+		c.queued.Add(1)
+	default:
+		c.dropped.Add(1)
+	}
+}
+
+func (c *ShadowCache) enqueueCompare(op shadowOp) {
+	op.after = c.queued.Load()
+	select {
+	case c.compareQueue <- op:
+	default:
+		c.droppedCompares.Add(1)
+	}
+}
+
+// run applies the queued operations, holding each read to compare until the writes queued before it
+// are applied.
+func (c *ShadowCache) run() {
+	var pending *shadowOp
+	for {
+		if pending == nil {
+			// Take a queued read before any further write, which it may have to precede.
+			select {
+			case op := <-c.compareQueue:
+				pending = &op
+			default:
+			}
+		}
+		if pending != nil && c.applied >= pending.after {
+			c.protect(*pending)
+			pending = nil
+			continue
+		}
+		compares := c.compareQueue
+		if pending != nil {
+			compares = nil
+		}
+		select {
+		case op := <-c.writeQueue:
+			c.protect(op)
+			c.applied++
+		case op := <-compares:
+			pending = &op
+		case <-c.stop:
+			c.drain(pending)
+			return
+		}
+	}
+}
+
+// drain applies the operations left in the queues once the cache is closed.
+func (c *ShadowCache) drain(pending *shadowOp) {
+	for {
+		if pending == nil {
+			select {
+			case op := <-c.compareQueue:
+				pending = &op
+			default:
+			}
+		}
+		if pending != nil && c.applied >= pending.after {
+			c.protect(*pending)
+			pending = nil
+			continue
+		}
+		select {
+		case op := <-c.writeQueue:
+			c.protect(op)
+			c.applied++
+		default:
+			if pending == nil {
+				return
+			}
+			// Every queued write is applied, so the read may be compared.
+			c.protect(*pending)
+			pending = nil
+		}
+	}
+}
+
+func (c *ShadowCache) protect(op shadowOp) {
+	c.supervisor.Protect("shadow", func() { c.apply(op) })
+}
+
+func (c *ShadowCache) apply(op shadowOp) {
+	if !op.compare {
+		op.write.apply(c.shadow)
+		c.writes.Add(1)
+		return
+	}
+	value, ok := c.shadow.Get(op.write.key)
+	c.compared.Add(1)
+	switch {
+	case op.primaryOK && ok && c.equal(op.value, value), !op.primaryOK && !ok:
+		c.matches.Add(1)
+		return
+	case op.primaryOK && ok:
+		c.mismatches.Add(1)
+	case op.primaryOK:
+		c.shadowMisses.Add(1)
+	default:
+		c.shadowOnlyHits.Add(1)
+	}
+	if c.onMismatch != nil {
+		c.onMismatch(op.write.key, op.value, value, op.primaryOK, ok)
+	}
+}
+
+// NewShadowCache creates a new instance of ShadowCache serving from primary and mirroring to shadow.
+// Call Close to stop mirroring.
+func NewShadowCache(primary, shadow ICache, opts ...ShadowOption) *ShadowCache {
+	c := &ShadowCache{
+		primary:    primary,
+		shadow:     shadow,
+		sampleRate: 1,
+		equal:      reflect.DeepEqual,
+		queueSize:  defaultShadowQueueSize,
+		supervisor: DefaultSupervisor,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.random = randomOrDefault(c.random)
+	c.writeQueue = make(chan shadowOp, max(1, c.queueSize))
+	c.compareQueue = make(chan shadowOp, max(1, c.queueSize))
+	go func() {
+		defer close(c.done)
+		c.supervisor.Run("shadow", c.stop, c.run)
+	}()
+	return c
+}
@@ -0,0 +1,62 @@
+package once_cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestShadowCacheComparesDoNotCrowdOutWrites(t *testing.T) {
+	gate := make(chan struct{})
+	// The shadow stalls on its first write, so that everything else stays queued.
+	shadow := &hookedCache{ICache: NewMemoryCache(), onSet: func(key string) {
+		if key == "gate" {
+			<-gate
+		}
+	}}
+	c := NewShadowCache(NewMemoryCache(), shadow, WithShadowQueueSize(4))
+	c.Set("gate", 0, time.Minute)
+	time.Sleep(10 * time.Millisecond)
+	for i := 0; i < 10; i++ {
+		c.Get("gate")
+	}
+	for _, key := range []string{"a", "b", "c", "d"} {
+		c.Set(key, key, time.Minute)
+	}
+	close(gate)
+	c.Close()
+
+	stats := c.Stats()
+	if stats.Dropped != 0 || stats.Writes != 5 {
+		t.Fatalf("Stats = %+v, want every write mirrored", stats)
+	}
+	if stats.DroppedCompares != 6 || stats.Compared != 4 {
+		t.Fatalf("Stats = %+v, want the compares beyond the queue dropped", stats)
+	}
+	for _, key := range []string{"a", "b", "c", "d"} {
+		if _, ok := shadow.ICache.Get(key); !ok {
+			t.Errorf("the shadow misses %s", key)
+		}
+	}
+}
+
+func TestShadowCacheComparesAfterEarlierWrites(t *testing.T) {
+	gate := make(chan struct{})
+	shadow := &hookedCache{ICache: NewMemoryCache(), onSet: func(key string) {
+		if key == "gate" {
+			<-gate
+		}
+	}}
+	c := NewShadowCache(NewMemoryCache(), shadow)
+	c.Set("gate", 0, time.Minute)
+	time.Sleep(10 * time.Millisecond)
+	c.Set("k", 1, time.Minute)
+	c.Get("k")
+	c.Set("k", 2, time.Minute)
+	c.Get("k")
+	close(gate)
+	c.Close()
+
+	if stats := c.Stats(); stats.Compared != 2 || stats.Matches != 2 {
+		t.Fatalf("Stats = %+v, want both reads compared after the writes before them", stats)
+	}
+}
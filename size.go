@@ -0,0 +1,94 @@
+package once_cache
+
+import (
+	"reflect"
+	"unsafe"
+)
+
+// maxSizeDepth bounds how deep estimateSize follows pointers and containers.
+const maxSizeDepth = 8
+
+// estimateSize returns an approximation of the memory retained by v, following pointers,
+// slices, maps and struct fields. Shared or cyclic references are counted once.
+func estimateSize(v any) int {
+	if v == nil {
+		return 0
+	}
+	switch v := v.(type) {
+	case []byte:
+		return cap(v) + int(unsafe.Sizeof(v))
+	case string:
+		return len(v) + int(unsafe.Sizeof(v))
+	}
+	seen := make(map[uintptr]struct{})
+	return sizeOf(reflect.ValueOf(v), seen, 0)
+}
+
+func sizeOf(v reflect.Value, seen map[uintptr]struct{}, depth int) int {
+	if !v.IsValid() {
+		return 0
+	}
+	size := int(v.Type().Size())
+	if depth >= maxSizeDepth {
+		return size
+	}
+	return size + indirectSize(v, seen, depth)
+}
+
+// indirectSize returns the memory v references beyond its own inline size.
+func indirectSize(v reflect.Value, seen map[uintptr]struct{}, depth int) int {
+	switch v.Kind() {
+	case reflect.String:
+		return v.Len()
+	case reflect.Pointer:
+		if v.IsNil() || visited(v.Pointer(), seen) {
+			return 0
+		}
+		return sizeOf(v.Elem(), seen, depth+1)
+	case reflect.Interface:
+		if v.IsNil() {
+			return 0
+		}
+		return sizeOf(v.Elem(), seen, depth+1)
+	case reflect.Slice:
+		if v.IsNil() || visited(v.Pointer(), seen) {
+			return 0
+		}
+		size := v.Cap() * int(v.Type().Elem().Size())
+		for i := 0; i < v.Len(); i++ {
+			size += indirectSize(v.Index(i), seen, depth+1)
+		}
+		return size
+	case reflect.Array:
+		size := 0
+		for i := 0; i < v.Len(); i++ {
+			size += indirectSize(v.Index(i), seen, depth+1)
+		}
+		return size
+	case reflect.Map:
+		if v.IsNil() || visited(v.Pointer(), seen) {
+			return 0
+		}
+		size := 0
+		iter := v.MapRange()
+		for iter.Next() {
+			size += sizeOf(iter.Key(), seen, depth+1) + sizeOf(iter.Value(), seen, depth+1)
+		}
+		return size
+	case reflect.Struct:
+		size := 0
+		for i := 0; i < v.NumField(); i++ {
+			size += indirectSize(v.Field(i), seen, depth+1)
+		}
+		return size
+	}
+	return 0
+}
+
+func visited(p uintptr, seen map[uintptr]struct{}) bool {
+	if _, ok := seen[p]; ok {
+		return true
+	}
+	seen[p] = struct{}{}
+	return false
+}
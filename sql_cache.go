@@ -0,0 +1,145 @@
+package once_cache
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+var sqlIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// SQLCache is a struct that implements the ICacheWithError interface on top of a Postgres table
+// with the columns (key, value bytea, expires_at). Expired rows are never returned and are
+// removed by Cleanup, which StartCleanup runs periodically.
+type SQLCache struct {
+	db    *sql.DB
+	codec Codec
+
+	createQuery  string
+	setQuery     string
+	getQuery     string
+	deleteQuery  string
+	cleanupQuery string
+	scanQuery    string
+}
+
+// CreateTable creates the cache table if it does not exist yet.
+func (c *SQLCache) CreateTable(ctx context.Context) error {
+	_, err := c.db.ExecContext(ctx, c.createQuery)
+	return err
+}
+
+// Set upserts the value with the specified time to live. A non-positive duration never expires.
+func (c *SQLCache) Set(key string, value any, d time.Duration) error {
+	data, err := marshalValue(c.codec, value, d)
+	if err != nil {
+		return err
+	}
+	return c.SetBytes(key, data, d)
+}
+
+// SetBytes upserts the raw value, bypassing the codec.
+func (c *SQLCache) SetBytes(key string, data []byte, d time.Duration) error {
+	var expiresAt sql.NullTime
+	if d > 0 {
+		expiresAt = sql.NullTime{Time: time.Now().Add(d), Valid: true}
+	}
+	_, err := c.db.Exec(c.setQuery, key, data, expiresAt)
+	return err
+}
+
+// Get retrieves the value if the row exists and has not expired.
+func (c *SQLCache) Get(key string) (any, bool, error) {
+	data, ok, err := c.GetBytes(key)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	value, err := c.codec.Unmarshal(data)
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// GetBytes retrieves the raw value, bypassing the codec.
+func (c *SQLCache) GetBytes(key string) ([]byte, bool, error) {
+	var data []byte
+	err := c.db.QueryRow(c.getQuery, key, time.Now()).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+// Delete removes the row for the key.
+func (c *SQLCache) Delete(key string) error {
+	_, err := c.db.Exec(c.deleteQuery, key)
+	return err
+}
+
+// Cleanup deletes all expired rows and returns how many were removed.
+func (c *SQLCache) Cleanup(ctx context.Context) (int64, error) {
+	res, err := c.db.ExecContext(ctx, c.cleanupQuery, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// StartCleanup runs Cleanup every interval until the returned stop function is called.
+// Cleanup errors are passed to onError if it is not nil.
+func (c *SQLCache) StartCleanup(interval time.Duration, onError func(error)) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := c.Cleanup(ctx); err != nil && onError != nil && ctx.Err() == nil {
+					onError(err)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// NewSQLCache creates a new instance of SQLCache storing entries in the specified Postgres table.
+// Values are serialized with codec, or GobCodec wrapped in an EnvelopeCodec if codec is nil.
+func NewSQLCache(db *sql.DB, table string, codec Codec) (*SQLCache, error) {
+	if !sqlIdentifier.MatchString(table) {
+		return nil, fmt.Errorf("once_cache: invalid table name %q", table)
+	}
+	if codec == nil {
+		codec = defaultCodec()
+	}
+	return &SQLCache{
+		db:    db,
+		codec: codec,
+		createQuery: `CREATE TABLE IF NOT EXISTS ` + table + ` (
+	key TEXT PRIMARY KEY,
+	value BYTEA NOT NULL,
+	expires_at TIMESTAMPTZ
+)`,
+		setQuery: `INSERT INTO ` + table + ` (key, value, expires_at) VALUES ($1, $2, $3)
+ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, expires_at = EXCLUDED.expires_at`,
+		getQuery:     `SELECT value FROM ` + table + ` WHERE key = $1 AND (expires_at IS NULL OR expires_at > $2)`,
+		deleteQuery:  `DELETE FROM ` + table + ` WHERE key = $1`,
+		cleanupQuery: `DELETE FROM ` + table + ` WHERE expires_at IS NOT NULL AND expires_at <= $1`,
+		scanQuery:    `SELECT key, expires_at FROM ` + table,
+	}, nil
+}
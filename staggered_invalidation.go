@@ -0,0 +1,87 @@
+package once_cache
+
+import (
+	"strings"
+	"time"
+)
+
+// WithStaggeredInvalidation makes DeleteTag expire the entries of the tag at random times spread over
+// window instead of deleting them all at once, so that invalidating a large tag does not send every
+// reader of its keys to the origin in the same instant. Entries written after the invalidation are not
+// affected. It requires a store implementing IStaggeredInvalidator, such as MemoryCache; otherwise
+// DeleteTag deletes the entries as usual.
+func WithStaggeredInvalidation(window time.Duration) Option {
+	return func(o *OnceCache) {
+		o.staggerWindow = window
+	}
+}
+
+// WithMemoryRandom sets the source of randomness of the expiry times drawn by ExpireTag and ExpirePrefix.
+// It defaults to the shared source of math/rand.
+func WithMemoryRandom(r Random) MemoryOption {
+	return func(c *MemoryCache) {
+		c.random = r
+	}
+}
+
+// ExpireTag schedules every entry stored with tag to expire at a random time within window from now,
+// unless it expires sooner anyway, and returns how many entries were scheduled. Scheduled entries are no
+// longer extended by WithSlidingExpiration or Touch, and entries written after the call are not affected.
+func (c *MemoryCache) ExpireTag(tag string, window time.Duration) int {
+	c.tagMu.Lock()
+	keys := make([]string, 0, len(c.tagIndex[tag]))
+	for key := range c.tagIndex[tag] {
+		keys = append(keys, key)
+	}
+	c.tagMu.Unlock()
+	scheduled := 0
+	for _, key := range keys {
+		if c.stagger(key, window, func(e memoryEntry) bool { return e.hasTag(tag) }) {
+			scheduled++
+		}
+	}
+	return scheduled
+}
+
+// ExpirePrefix is ExpireTag for the entries whose keys start with prefix. It scans every entry.
+func (c *MemoryCache) ExpirePrefix(prefix string, window time.Duration) int {
+	var keys []string
+	c.storage.rangeEntries(func(key string, e memoryEntry) bool {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return true
+	})
+	scheduled := 0
+	for _, key := range keys {
+		if c.stagger(key, window, func(memoryEntry) bool { return true }) {
+			scheduled++
+		}
+	}
+	return scheduled
+}
+
+// stagger schedules the live entry of key to expire within window if match accepts it.
+func (c *MemoryCache) stagger(key string, window time.Duration, match func(e memoryEntry) bool) bool {
+	now := time.Now().UnixNano()
+	e, ok := c.storage.load(key, now)
+	if !ok || e.expired(now) || !match(e) {
+		return false
+	}
+	version := e.version
+	e.ttl = 0
+	e.capAge(now + int64(c.random.Float64()*float64(window)))
+	// A rewritten entry holds data newer than the invalidation and is left alone.
+	return c.storage.storeIf(key, e, func(old memoryEntry, ok bool) bool {
+		return ok && old.version == version
+	})
+}
+
+// deleteTagStaggered expires the entries of tag over the window of WithStaggeredInvalidation,
+// reporting false if the store cannot.
+func (o *OnceCache) deleteTagStaggered(tag string) (int, bool) {
+	if o.staggerWindow <= 0 || o.staggerer == nil {
+		return 0, false
+	}
+	return o.staggerer.ExpireTag(tag, o.staggerWindow), true
+}
@@ -0,0 +1,31 @@
+package once_cache
+
+import (
+	"testing"
+	"time"
+)
+
+// fixedRandom is a Random always drawing the same number.
+type fixedRandom float64
+
+func (r fixedRandom) Float64() float64 {
+	return float64(r)
+}
+
+func TestExpireTagDrawsFromTheCachesRandom(t *testing.T) {
+	c := NewMemoryCache(WithMemoryRandom(fixedRandom(0.5)))
+	defer c.Close()
+	c.SetWithTags("k", 1, time.Hour, "t")
+
+	start := time.Now()
+	if n := c.ExpireTag("t", time.Hour); n != 1 {
+		t.Fatalf("ExpireTag = %d, want 1", n)
+	}
+	_, info, ok := c.GetWithInfo("k")
+	if !ok {
+		t.Fatal("ExpireTag deleted the entry, want it scheduled")
+	}
+	if got := info.ExpiresAt.Sub(start); got < 29*time.Minute || got > 31*time.Minute {
+		t.Fatalf("entry expires in %v, want half the window", got)
+	}
+}
@@ -0,0 +1,75 @@
+package once_cache
+
+import "time"
+
+// StalePolicy decides when OnceCache serves expired values retained by the store, such as entries
+// within their grace period, see WithGrace and WithStaleRetention.
+type StalePolicy int
+
+const (
+	// StaleNever serves retained values only to calls made with WithStaleOK, and when the load is shed.
+	StaleNever StalePolicy = iota
+	// StaleOnError serves a retained value whenever the load fails.
+	StaleOnError
+	// StaleWhileRevalidate serves a retained value right away and reloads it in the background.
+	StaleWhileRevalidate
+)
+
+// ErrorFallback decides what a call returns besides the error when its load fails.
+type ErrorFallback int
+
+const (
+	// FallbackRecheck returns the value found in the store after the failure, which may have been written
+	// meanwhile by another flight, such as a Set or a load started after this one. It is the default.
+	FallbackRecheck ErrorFallback = iota
+	// FallbackError returns no value, only the error.
+	FallbackError
+	// FallbackStale returns the expired value retained by the store, if any, flagged as stale.
+	// The store must implement IStaleGetter.
+	FallbackStale
+)
+
+// WithErrorFallback sets what calls return when their load fails, after any stale value served
+// because of WithStalePolicy or WithStaleOK. It defaults to FallbackRecheck.
+func WithErrorFallback(fallback ErrorFallback) Option {
+	return func(o *OnceCache) {
+		o.errorFallback = fallback
+	}
+}
+
+// WithMaxStaleness bounds how long after expiry any value may be served, whatever serves it:
+// WithStalePolicy, WithStaleOK, WithGrace, WithResponseBudget, FallbackStale and shed loads. A call that
+// would be served an older value waits for the load instead, or fails with its error; StaleRefusals counts
+// them. Zero leaves staleness unbounded, up to what the store retains.
+func WithMaxStaleness(d time.Duration) Option {
+	return func(o *OnceCache) {
+		o.maxStaleness = d
+	}
+}
+
+// StaleRefusals returns how many times a retained value was not served because it was older than the
+// bound set with WithMaxStaleness.
+func (o *OnceCache) StaleRefusals() uint64 {
+	return o.staleRefusals.Load()
+}
+
+// fallback returns the value of a call whose load failed, as configured with WithErrorFallback.
+func (o *OnceCache) fallback(key string) (value any, hit, stale bool) {
+	switch o.errorFallback {
+	case FallbackError:
+		return nil, false, false
+	case FallbackStale:
+		value, ok := o.stale(key)
+		return value, false, ok
+	}
+	value, hit = o.lookup(key)
+	return value, hit, false
+}
+
+// WithStalePolicy sets when retained expired values are served. It defaults to StaleNever.
+// The store must implement IStaleGetter, as MemoryCache does.
+func WithStalePolicy(policy StalePolicy) Option {
+	return func(o *OnceCache) {
+		o.stalePolicy = policy
+	}
+}
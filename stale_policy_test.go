@@ -0,0 +1,50 @@
+package once_cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+func TestStaleOnErrorServesRetainedValues(t *testing.T) {
+	c := NewOnceCache(&singleflight.Group{}, NewMemoryCache(WithStaleRetention(time.Hour)),
+		WithStalePolicy(StaleOnError))
+	c.Set("a", "old", time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+
+	res := c.GetResult("a", func() (any, error) { return nil, errors.New("down") })
+	if !res.Stale || res.Value != "old" || !res.OK() {
+		t.Fatalf("GetResult = %+v, want the stale value", res)
+	}
+}
+
+func TestMaxStalenessRefusesOlderValues(t *testing.T) {
+	c := NewOnceCache(&singleflight.Group{}, NewMemoryCache(WithStaleRetention(time.Hour)),
+		WithStalePolicy(StaleOnError), WithMaxStaleness(5*time.Millisecond))
+	c.Set("a", "old", time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+
+	failing := errors.New("down")
+	res := c.GetResult("a", func() (any, error) { return nil, failing })
+	if res.Stale || !errors.Is(res.Err, failing) {
+		t.Fatalf("GetResult = %+v, want the load error", res)
+	}
+	if n := c.StaleRefusals(); n == 0 {
+		t.Fatal("StaleRefusals = 0, want the refused value counted")
+	}
+}
+
+func TestFallbackErrorReturnsNoValue(t *testing.T) {
+	store := NewMemoryCache()
+	c := NewOnceCache(&singleflight.Group{}, store, WithErrorFallback(FallbackError))
+	res := c.GetResult("a", func() (any, error) {
+		// Another writer stores the key while the load fails.
+		store.Set("a", "written", time.Minute)
+		return nil, errors.New("down")
+	})
+	if res.OK() || res.Value != nil {
+		t.Fatalf("GetResult = %+v, want only the error", res)
+	}
+}
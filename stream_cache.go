@@ -0,0 +1,83 @@
+package once_cache
+
+import (
+	"io"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// IStreamStore is a store that streams values, implemented by FileCache and BlobCache, so that large
+// payloads are never held in memory whole.
+type IStreamStore interface {
+	// GetReader opens the value for key for reading
+	GetReader(key string) (io.ReadCloser, bool, error)
+
+	// SetReader stores the value read from r for key with the specified time to live
+	SetReader(key string, r io.Reader, d time.Duration) error
+}
+
+// StreamFunc opens the source of a value to cache, such as an upstream response body.
+type StreamFunc func() (io.ReadCloser, error)
+
+// OnceStreamCache caches large payloads in an IStreamStore. On a miss, one caller streams the source into
+// the store while concurrent callers for the same key wait, and then every caller reads from the store.
+type OnceStreamCache struct {
+	group *singleflight.Group
+	store IStreamStore
+	ttl   time.Duration
+}
+
+// GetReader opens the cached value for key. Store errors are reported as misses.
+// The caller must close the reader.
+func (c *OnceStreamCache) GetReader(key string) (io.ReadCloser, bool) {
+	r, ok, err := c.store.GetReader(key)
+	if err != nil || !ok {
+		return nil, false
+	}
+	return r, true
+}
+
+// SetReader stores the value read from r for key with the specified time to live, or the default TTL
+// if d is zero.
+func (c *OnceStreamCache) SetReader(key string, r io.Reader, d time.Duration) error {
+	if d == 0 {
+		d = c.ttl
+	}
+	return c.store.SetReader(key, r, d)
+}
+
+// GetReaderWithSingleFunc opens the cached value for key, first streaming it from the source opened by f
+// into the store if it is missing. Concurrent calls for the same key open the source once.
+// The caller must close the reader.
+func (c *OnceStreamCache) GetReaderWithSingleFunc(key string, f StreamFunc, d time.Duration) (io.ReadCloser, error) {
+	if r, ok := c.GetReader(key); ok {
+		return r, nil
+	}
+	_, err, _ := c.group.Do(key, func() (any, error) {
+		src, err := f()
+		if err != nil {
+			return nil, err
+		}
+		defer src.Close()
+		return nil, c.SetReader(key, src, d)
+	})
+	if err != nil {
+		return nil, err
+	}
+	r, ok, err := c.store.GetReader(key)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		// The value was stored with a time to live too short to be read back.
+		return nil, io.ErrUnexpectedEOF
+	}
+	return r, nil
+}
+
+// NewOnceStreamCache creates a new instance of OnceStreamCache over store, storing values for defaultTTL
+// unless the call specifies a time to live.
+func NewOnceStreamCache(group *singleflight.Group, store IStreamStore, defaultTTL time.Duration) *OnceStreamCache {
+	return &OnceStreamCache{group: group, store: store, ttl: defaultTTL}
+}
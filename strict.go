@@ -0,0 +1,76 @@
+package once_cache
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// strictMinTTL and strictMaxTTL bound the times to live accepted by ValidationStrict. Durations outside
+	// them are nearly always unit mistakes, such as a number of seconds passed as a time.Duration.
+	strictMinTTL = time.Millisecond
+	strictMaxTTL = 365 * 24 * time.Hour
+)
+
+var (
+	// ErrEmptyKey is reported by ValidationStrict for calls with an empty key.
+	ErrEmptyKey = errors.New("once_cache: empty key")
+	// ErrInvalidTTL is reported by ValidationStrict for calls whose time to live is zero, negative other
+	// than NoExpiration, below a millisecond or above a year.
+	ErrInvalidTTL = errors.New("once_cache: invalid ttl")
+	// ErrNilLoader is reported by ValidationStrict for loads without a loader.
+	ErrNilLoader = errors.New("once_cache: nil loader")
+)
+
+// ValidationMode decides how an OnceCache treats invalid calls, see WithValidation.
+type ValidationMode int
+
+const (
+	// ValidationPermissive accepts every call as earlier versions did: an empty key is a key like any
+	// other, a zero time to live without a default one never expires, and a nil loader panics when it
+	// is needed. It is the default for now.
+	ValidationPermissive ValidationMode = iota
+	// ValidationStrict rejects loads with an empty key, an invalid time to live after applying the default
+	// one, or a nil loader, reporting ErrEmptyKey, ErrInvalidTTL or ErrNilLoader to the error handler and
+	// to GetResult without calling the loader or serving stale values. It will be the default in the next
+	// major version. Invalid writes with Set are reported to the default error handler and dropped.
+	ValidationStrict
+)
+
+// WithValidation sets how invalid calls are treated. It defaults to ValidationPermissive.
+func WithValidation(mode ValidationMode) Option {
+	return func(o *OnceCache) {
+		o.strict = mode == ValidationStrict
+	}
+}
+
+// checkCall returns the error of an invalid call in strict mode. d is the time to live after applying the
+// default one.
+func (o *OnceCache) checkCall(key string, d time.Duration, hasLoader bool) error {
+	if key == "" {
+		return ErrEmptyKey
+	}
+	if d != NoExpiration && (d < strictMinTTL || d > strictMaxTTL) {
+		return fmt.Errorf("%w: %v", ErrInvalidTTL, d)
+	}
+	if !hasLoader {
+		return ErrNilLoader
+	}
+	return nil
+}
+
+// checkBatch reports the invalid keys of a batch to handler and returns the others.
+func (o *OnceCache) checkBatch(keys []string, d time.Duration, hasLoader bool, handler CatchErrorFunc) []string {
+	valid := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if err := o.checkCall(key, d, hasLoader); err != nil {
+			if handler != nil {
+				handler(o, key, err)
+			}
+			continue
+		}
+		valid = append(valid, key)
+	}
+	return valid
+}
@@ -0,0 +1,45 @@
+package once_cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+func TestStrictSetValidatesTheDefaultTTL(t *testing.T) {
+	var errs []error
+	var records []AuditRecord
+	cache := NewOnceCache(&singleflight.Group{}, NewMemoryCache(),
+		WithValidation(ValidationStrict),
+		WithDefaultTTL(time.Minute),
+		WithDefaultErrorHandler(func(_ ICache, _ string, err error) any {
+			errs = append(errs, err)
+			return nil
+		}),
+		WithAuditSink(AuditSinkFunc(func(rec AuditRecord) { records = append(records, rec) })),
+	)
+
+	before := time.Now()
+	cache.SetWithReason("k", "v", 0, "seed")
+	if len(errs) != 0 {
+		t.Fatalf("Set without a TTL reported %v, want the default TTL to apply", errs)
+	}
+	_, info, ok := cache.infoGetter.GetWithInfo("k")
+	if !ok || info.ExpiresAt.Before(before.Add(time.Minute)) || info.ExpiresAt.After(time.Now().Add(time.Minute)) {
+		t.Fatalf("k stored until %v, %v, want a minute from the Set", info.ExpiresAt, ok)
+	}
+	if len(records) != 1 || records[0].TTL != time.Minute {
+		t.Fatalf("audit records %+v, want one with the default TTL", records)
+	}
+
+	cache.SetDefaultTTL(0)
+	cache.Set("other", "v", 0)
+	if len(errs) != 1 || !errors.Is(errs[0], ErrInvalidTTL) {
+		t.Fatalf("Set without any TTL reported %v, want ErrInvalidTTL", errs)
+	}
+	if _, ok := cache.Get("other"); ok {
+		t.Fatal("invalid Set was stored")
+	}
+}
@@ -0,0 +1,120 @@
+package once_cache
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+)
+
+// PanicError is reported when a background goroutine panics.
+type PanicError struct {
+	// Name identifies the goroutine, such as "janitor".
+	Name  string
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("once_cache: %s panicked: %v", e.Name, e.Value)
+}
+
+// Supervisor runs background goroutines, recovering their panics, reporting them and restarting
+// long-running loops with exponential backoff, so that a bug in a store or in a callback cannot
+// silently stop expiration or write-back for good.
+type Supervisor struct {
+	onCrash    atomic.Pointer[func(err error)]
+	crashes    atomic.Uint64
+	minBackoff time.Duration
+	maxBackoff time.Duration
+}
+
+// DefaultSupervisor supervises the background goroutines of caches not given their own Supervisor.
+// It reports nothing until OnCrash is called.
+var DefaultSupervisor = NewSupervisor(nil, 100*time.Millisecond, 10*time.Second)
+
+// OnCrash sets the function receiving a *PanicError for every recovered panic.
+func (s *Supervisor) OnCrash(f func(err error)) {
+	if f == nil {
+		s.onCrash.Store(nil)
+		return
+	}
+	s.onCrash.Store(&f)
+}
+
+// Crashes returns how many panics were recovered.
+func (s *Supervisor) Crashes() uint64 {
+	return s.crashes.Load()
+}
+
+// Go runs f in a new goroutine, restarting it after a backoff whenever it panics, until it returns
+// or stop is closed.
+func (s *Supervisor) Go(name string, stop <-chan struct{}, f func()) {
+	go s.Run(name, stop, f)
+}
+
+// Run is Go running f in the calling goroutine.
+func (s *Supervisor) Run(name string, stop <-chan struct{}, f func()) {
+	backoff := s.minBackoff
+	for {
+		start := time.Now()
+		if s.Protect(name, f) {
+			return
+		}
+		if time.Since(start) > s.maxBackoff {
+			// It ran fine for a while, so this is not a crash loop.
+			backoff = s.minBackoff
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-stop:
+			timer.Stop()
+			return
+		}
+		backoff = min(2*backoff, s.maxBackoff)
+	}
+}
+
+// Protect runs f, recovering and reporting a panic, and reports whether f returned normally.
+func (s *Supervisor) Protect(name string, f func()) (ok bool) {
+	defer func() {
+		if v := recover(); v != nil {
+			s.report(&PanicError{Name: name, Value: v, Stack: debug.Stack()})
+		}
+	}()
+	f()
+	return true
+}
+
+func (s *Supervisor) report(err *PanicError) {
+	s.crashes.Add(1)
+	if f := s.onCrash.Load(); f != nil {
+		(*f)(err)
+	}
+}
+
+// NewSupervisor creates a new instance of Supervisor reporting panics to onCrash, if not nil, and
+// restarting crashed loops after a backoff doubling from minBackoff up to maxBackoff.
+func NewSupervisor(onCrash func(err error), minBackoff, maxBackoff time.Duration) *Supervisor {
+	s := &Supervisor{minBackoff: minBackoff, maxBackoff: max(minBackoff, maxBackoff)}
+	s.OnCrash(onCrash)
+	return s
+}
+
+// recoverBackground reports a panic of a background load of key to the background error handler, or to
+// DefaultSupervisor without one, instead of letting it crash the process. It must be deferred.
+func (o *OnceCache) recoverBackground(key string) {
+	if v := recover(); v != nil {
+		o.crashed(key, &PanicError{Name: "background load of " + key, Value: v, Stack: debug.Stack()})
+	}
+}
+
+// crashed reports a panic of a background goroutine of the cache.
+func (o *OnceCache) crashed(key string, err *PanicError) {
+	if o.onBackgroundError == nil {
+		DefaultSupervisor.report(err)
+		return
+	}
+	o.backgroundError(key, err)
+}
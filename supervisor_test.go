@@ -0,0 +1,127 @@
+package once_cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// panickingStore is an IPipelineStore whose writes panic.
+type panickingStore struct {
+	*MemoryCache
+}
+
+func (s panickingStore) Set(key string, value any, d time.Duration) {
+	panic("set " + key)
+}
+
+func (s panickingStore) SetMulti(entries map[string]Entry) {
+	panic("set multi")
+}
+
+// crashes records the panics reported to a Supervisor.
+type crashes struct {
+	mu   sync.Mutex
+	errs []*PanicError
+}
+
+func (c *crashes) supervisor() *Supervisor {
+	return NewSupervisor(func(err error) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.errs = append(c.errs, err.(*PanicError))
+	}, time.Millisecond, time.Millisecond)
+}
+
+// wait returns the first reported panic, failing the test if none is reported in time.
+func (c *crashes) wait(t *testing.T) *PanicError {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		c.mu.Lock()
+		if len(c.errs) > 0 {
+			err := c.errs[0]
+			c.mu.Unlock()
+			return err
+		}
+		c.mu.Unlock()
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("no panic reported to the supervisor")
+	return nil
+}
+
+func TestCachesReportPanicsToTheirSupervisor(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(s *Supervisor) (closeCache func())
+	}{
+		{"tiered write-back", func(s *Supervisor) func() {
+			c := NewTieredCache(NewMemoryCache(), panickingStore{NewMemoryCache()},
+				WithL2WritePolicy(WriteBack), WithTieredSupervisor(s))
+			c.Set("a", 1, time.Minute)
+			return c.Close
+		}},
+		{"shadow", func(s *Supervisor) func() {
+			c := NewShadowCache(NewMemoryCache(), panickingStore{NewMemoryCache()}, WithShadowSupervisor(s))
+			c.Set("a", 1, time.Minute)
+			return c.Close
+		}},
+		{"replication", func(s *Supervisor) func() {
+			c := NewReplicatedCache(NewMemoryCache(), panickingStore{NewMemoryCache()}, 0, WithReplicationSupervisor(s))
+			c.Set("a", 1, time.Minute)
+			return c.Close
+		}},
+		{"pipeline flush", func(s *Supervisor) func() {
+			c := NewPipelinedCache(panickingStore{NewMemoryCache()}, 100, time.Millisecond, WithPipelineSupervisor(s))
+			c.Set("a", 1, time.Minute)
+			return func() {}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := DefaultSupervisor.Crashes()
+			var got crashes
+			closeCache := tt.write(got.supervisor())
+			err := got.wait(t)
+			closeCache()
+			if err.Value == nil {
+				t.Fatalf("reported %v without the panic value", err)
+			}
+			if n := DefaultSupervisor.Crashes(); n != before {
+				t.Fatalf("DefaultSupervisor recorded %d crashes, want none", n-before)
+			}
+		})
+	}
+}
+
+func TestRetrySetAsyncWithSupervisorReportsPanics(t *testing.T) {
+	var got crashes
+	policy := RetrySetAsyncWithSupervisor(got.supervisor(), 1, time.Millisecond)
+	store := NewCacheWithError(panickingStore{NewMemoryCache()})
+	if err := policy(store, "a", 1, time.Minute, errors.New("set failed")); err != nil {
+		t.Fatalf("policy = %v, want nil", err)
+	}
+	if err := got.wait(t); err.Name != "set retry of a" {
+		t.Fatalf("reported %q, want the retry of a", err.Name)
+	}
+}
+
+func TestOnceCacheReportsBackgroundPanicsToDefaultSupervisorWithoutHandler(t *testing.T) {
+	before := DefaultSupervisor.Crashes()
+	c := NewOnceCache(&singleflight.Group{}, NewMemoryCache())
+	c.Prefetch(context.Background(), []string{"a"}, func(ctx context.Context, key string) (any, error) {
+		panic("loading " + key)
+	}, time.Minute)
+	deadline := time.Now().Add(time.Second)
+	for DefaultSupervisor.Crashes() == before {
+		if time.Now().After(deadline) {
+			t.Fatal("the panic of a prefetch was not reported to DefaultSupervisor")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,254 @@
+package once_cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// TenantQuota bounds the entries a tenant may keep in a TenantCache. Zero fields are unbounded.
+type TenantQuota struct {
+	MaxEntries int
+	// MaxCost bounds the total cost of the tenant's entries, see WithTenantCost.
+	MaxCost int64
+}
+
+// TenantStats are the counters of one tenant of a TenantCache.
+type TenantStats struct {
+	Hits      uint64
+	Misses    uint64
+	Sets      uint64
+	Evictions uint64
+	Entries   int
+	Cost      int64
+}
+
+// TenantOption configures a TenantCache.
+type TenantOption func(*TenantCache)
+
+// WithTenantQuota overrides the default quota for one tenant.
+func WithTenantQuota(tenant string, quota TenantQuota) TenantOption {
+	return func(c *TenantCache) {
+		c.quotas[tenant] = quota
+	}
+}
+
+// WithTenantCost sets the function computing the cost of a value counted against MaxCost.
+// It defaults to an estimate of the value's size in bytes.
+func WithTenantCost(cost func(value any) int64) TenantOption {
+	return func(c *TenantCache) {
+		c.cost = cost
+	}
+}
+
+// TenantCache partitions a shared store between tenants. Each tenant's keys are namespaced, and when
+// a tenant exceeds its quota its own least recently used entries are evicted, so one noisy tenant cannot
+// push out everyone else's entries.
+type TenantCache struct {
+	store        ICache
+	defaultQuota TenantQuota
+	quotas       map[string]TenantQuota
+	cost         func(value any) int64
+
+	mu      sync.Mutex
+	tenants map[string]*tenant
+}
+
+// tenant tracks the entries of one tenant in least recently used order.
+type tenant struct {
+	quota   TenantQuota
+	entries map[string]*list.Element
+	lru     *list.List
+	cost    int64
+	stats   TenantStats
+}
+
+type tenantEntry struct {
+	key       string
+	cost      int64
+	expiresAt time.Time
+}
+
+// Tenant returns a view of the cache holding the entries of one tenant.
+func (c *TenantCache) Tenant(id string) ICache {
+	return &tenantView{cache: c, id: id}
+}
+
+// Stats returns the counters of a tenant.
+func (c *TenantCache) Stats(id string) TenantStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t, ok := c.tenants[id]
+	if !ok {
+		return TenantStats{}
+	}
+	stats := t.stats
+	stats.Entries = len(t.entries)
+	stats.Cost = t.cost
+	return stats
+}
+
+// Tenants returns the tenants that stored entries, sorted.
+func (c *TenantCache) Tenants() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return sortedKeys(c.tenants)
+}
+
+// tenant returns the state of a tenant, creating it. c.mu must be held.
+func (c *TenantCache) tenant(id string) *tenant {
+	t, ok := c.tenants[id]
+	if !ok {
+		quota, ok := c.quotas[id]
+		if !ok {
+			quota = c.defaultQuota
+		}
+		t = &tenant{quota: quota, entries: make(map[string]*list.Element), lru: list.New()}
+		c.tenants[id] = t
+	}
+	return t
+}
+
+func (c *TenantCache) set(id, key string, value any, d time.Duration) {
+	e := tenantEntry{key: key, cost: c.cost(value)}
+	if d > 0 {
+		e.expiresAt = time.Now().Add(d)
+	}
+	c.mu.Lock()
+	t := c.tenant(id)
+	if t.quota.MaxCost > 0 && e.cost > t.quota.MaxCost {
+		// The value alone is over quota; do not evict everything else for it.
+		t.forget(key)
+		c.mu.Unlock()
+		c.store.Delete(Key(id, key))
+		return
+	}
+	if el, ok := t.entries[key]; ok {
+		t.cost -= el.Value.(tenantEntry).cost
+		el.Value = e
+		t.lru.MoveToFront(el)
+	} else {
+		t.entries[key] = t.lru.PushFront(e)
+	}
+	t.cost += e.cost
+	t.stats.Sets++
+	evicted := c.enforceQuota(t)
+	c.mu.Unlock()
+	c.store.Set(Key(id, key), value, d)
+	for _, k := range evicted {
+		c.store.Delete(Key(id, k))
+	}
+}
+
+// enforceQuota removes the tenant's least recently used entries until it is within its quota,
+// expired entries first, and returns their keys. c.mu must be held.
+func (c *TenantCache) enforceQuota(t *tenant) []string {
+	var evicted []string
+	over := func() bool {
+		return (t.quota.MaxEntries > 0 && len(t.entries) > t.quota.MaxEntries) ||
+			(t.quota.MaxCost > 0 && t.cost > t.quota.MaxCost)
+	}
+	if !over() {
+		return nil
+	}
+	now := time.Now()
+	for el := t.lru.Back(); el != nil && over(); {
+		prev := el.Prev()
+		if e := el.Value.(tenantEntry); !e.expiresAt.IsZero() && now.After(e.expiresAt) {
+			t.remove(el)
+			evicted = append(evicted, e.key)
+		}
+		el = prev
+	}
+	for over() {
+		el := t.lru.Back()
+		t.remove(el)
+		t.stats.Evictions++
+		evicted = append(evicted, el.Value.(tenantEntry).key)
+	}
+	return evicted
+}
+
+func (t *tenant) remove(el *list.Element) {
+	e := el.Value.(tenantEntry)
+	t.lru.Remove(el)
+	delete(t.entries, e.key)
+	t.cost -= e.cost
+}
+
+// get reads key of the tenant. Tenants that never stored an entry are not tracked, so reading one only
+// reads the store.
+func (c *TenantCache) get(id, key string) (any, bool) {
+	value, ok := c.store.Get(Key(id, key))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t, known := c.tenants[id]
+	if !known {
+		return value, ok
+	}
+	el, tracked := t.entries[key]
+	if !ok {
+		t.stats.Misses++
+		if tracked {
+			// The store expired or evicted the entry on its own.
+			t.remove(el)
+		}
+		return nil, false
+	}
+	t.stats.Hits++
+	if tracked {
+		t.lru.MoveToFront(el)
+	}
+	return value, true
+}
+
+// delete removes key from the tenant and then from the store, outside c.mu like the other store calls.
+func (c *TenantCache) delete(id, key string) {
+	c.mu.Lock()
+	if t, ok := c.tenants[id]; ok {
+		t.forget(key)
+	}
+	c.mu.Unlock()
+	c.store.Delete(Key(id, key))
+}
+
+// forget stops tracking key, if it is tracked. c.mu must be held.
+func (t *tenant) forget(key string) {
+	if el, ok := t.entries[key]; ok {
+		t.remove(el)
+	}
+}
+
+// tenantView is a struct that implements the ICache interface for one tenant of a TenantCache.
+type tenantView struct {
+	cache *TenantCache
+	id    string
+}
+
+func (v *tenantView) Set(key string, value any, d time.Duration) {
+	v.cache.set(v.id, key, value, d)
+}
+
+func (v *tenantView) Get(key string) (any, bool) {
+	return v.cache.get(v.id, key)
+}
+
+func (v *tenantView) Delete(key string) {
+	v.cache.delete(v.id, key)
+}
+
+// NewTenantCache creates a new instance of TenantCache over store, applying defaultQuota to every tenant
+// without an override from WithTenantQuota.
+func NewTenantCache(store ICache, defaultQuota TenantQuota, opts ...TenantOption) *TenantCache {
+	c := &TenantCache{
+		store:        store,
+		defaultQuota: defaultQuota,
+		quotas:       make(map[string]TenantQuota),
+		cost:         func(value any) int64 { return int64(estimateSize(value)) },
+		tenants:      make(map[string]*tenant),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
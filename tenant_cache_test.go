@@ -0,0 +1,41 @@
+package once_cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTenantCacheReadsDoNotCreateTenants(t *testing.T) {
+	c := NewTenantCache(NewMemoryCache(), TenantQuota{MaxEntries: 10})
+	for _, id := range []string{"a", "b", "c"} {
+		c.Tenant(id).Get("k")
+		c.Tenant(id).Delete("k")
+	}
+	if tenants := c.Tenants(); len(tenants) != 0 {
+		t.Fatalf("Tenants = %v after reads and deletes only, want none", tenants)
+	}
+	c.Tenant("a").Set("k", 1, time.Minute)
+	if v, ok := c.Tenant("a").Get("k"); !ok || v != 1 {
+		t.Fatalf("Get = %v, %v, want 1, true", v, ok)
+	}
+	if stats := c.Stats("a"); stats.Sets != 1 || stats.Hits != 1 || stats.Entries != 1 {
+		t.Fatalf("Stats = %+v, want one set, one hit and one entry", stats)
+	}
+}
+
+func TestTenantCacheDeletesFromTheStoreOutsideItsLock(t *testing.T) {
+	var c *TenantCache
+	// A store calling back into the cache while deleting would deadlock if the lock were held.
+	store := &hookedCache{ICache: NewMemoryCache(), onDelete: func(string) { c.Stats("a") }}
+	c = NewTenantCache(store, TenantQuota{MaxCost: 1}, WithTenantCost(func(value any) int64 { return int64(value.(int)) }))
+	returnsWithin(t, "Delete", func() {
+		c.Tenant("a").Set("k", 1, time.Minute)
+		c.Tenant("a").Delete("k")
+	})
+	returnsWithin(t, "Set of a value over quota", func() {
+		c.Tenant("a").Set("big", 2, time.Minute)
+	})
+	if stats := c.Stats("a"); stats.Entries != 0 {
+		t.Fatalf("Stats = %+v, want no entries", stats)
+	}
+}
@@ -0,0 +1,56 @@
+package once_cache
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNoTenant is reported by GetWithContext when tenant credentials are configured but the context names
+// no tenant, see WithTenantCredentials.
+var ErrNoTenant = errors.New("once_cache: no tenant in context")
+
+type tenantContextKey struct{}
+
+// ContextWithTenant returns a copy of ctx naming the tenant its cache calls act for.
+func ContextWithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenant)
+}
+
+// TenantFromContext returns the tenant named by ctx, see ContextWithTenant.
+func TenantFromContext(ctx context.Context) (string, bool) {
+	tenant, ok := ctx.Value(tenantContextKey{}).(string)
+	return tenant, ok && tenant != ""
+}
+
+// CredentialsProvider returns the context a load for tenant runs with, carrying the tenant's credentials
+// for the origin, such as a token cached per tenant.
+type CredentialsProvider func(ctx context.Context, tenant string) (context.Context, error)
+
+// WithTenantCredentials scopes the calls of GetWithContext to the tenant named by their context, see
+// ContextWithTenant, so that one tenant's data is never served to another: the key of the entry and of its
+// flight become Key(tenant, key), the same layout as a TenantCache, so concurrent calls of two tenants
+// for the same key run separate loads. Each load runs with the context returned by creds, and sees the key
+// without the tenant. Calls whose context names no tenant fail with ErrNoTenant.
+// To delete a tenant's entry, delete Key(tenant, key).
+func WithTenantCredentials(creds CredentialsProvider) Option {
+	return func(o *OnceCache) {
+		o.credentials = creds
+	}
+}
+
+// scopeToTenant returns the key and loader of a call of GetWithContext made for the tenant named by ctx,
+// see WithTenantCredentials.
+func (o *OnceCache) scopeToTenant(ctx context.Context, key string, f KeyedFunc) (string, KeyedFunc, error) {
+	tenant, ok := TenantFromContext(ctx)
+	if !ok {
+		return "", nil, ErrNoTenant
+	}
+	creds := o.credentials
+	return Key(tenant, key), func(ctx context.Context, _ string) (any, error) {
+		ctx, err := creds(ctx, tenant)
+		if err != nil {
+			return nil, err
+		}
+		return f(ctx, key)
+	}, nil
+}
@@ -0,0 +1,41 @@
+// Package testutil provides deterministic stand-ins for the sources of randomness of once_cache, so that
+// tests of jitter and sampling are reproducible.
+package testutil
+
+import (
+	"math/rand"
+	"sync"
+
+	once_cache "github.com/phongthien99/once-cache"
+)
+
+// NewSeededRandom returns a once_cache.Random producing the same numbers for the same seed. Draws are
+// reproducible as long as the code under test draws in a deterministic order.
+func NewSeededRandom(seed int64) once_cache.Random {
+	return once_cache.NewRandom(rand.NewSource(seed))
+}
+
+// Sequence is a once_cache.Random returning its values in order, starting over after the last one,
+// so that a test can pin each draw exactly.
+type Sequence struct {
+	mu     sync.Mutex
+	values []float64
+	next   int
+}
+
+// Float64 returns the next value of the sequence, or 0 if it is empty.
+func (s *Sequence) Float64() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.values) == 0 {
+		return 0
+	}
+	v := s.values[s.next]
+	s.next = (s.next + 1) % len(s.values)
+	return v
+}
+
+// NewSequence creates a new instance of Sequence over values, each in [0, 1).
+func NewSequence(values ...float64) *Sequence {
+	return &Sequence{values: values}
+}
@@ -0,0 +1,458 @@
+package once_cache
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// promotionCounterSlots is the number of hashed hit counters used by PromoteOnHit.
+	promotionCounterSlots = 1 << 14
+	// promotionGuardSlots is the number of hashed write generations promotions are checked against.
+	promotionGuardSlots = 256
+	// promotionQueueSize bounds the asynchronous promotions waiting to be written to L1.
+	promotionQueueSize = 1024
+	// writeBackQueueSize bounds the write-back operations waiting for a level; writers block beyond it.
+	writeBackQueueSize  = 1024
+	defaultPromotionTTL = time.Minute
+)
+
+// PromotionPolicy decides when an entry found in L2 is copied into L1.
+type PromotionPolicy struct {
+	hits int
+}
+
+// PromoteAlways copies every L2 hit into L1. It is the default policy.
+func PromoteAlways() PromotionPolicy {
+	return PromotionPolicy{hits: 1}
+}
+
+// PromoteOnHit copies an entry into L1 on its nth L2 hit, so keys read once by scans do not evict hot ones.
+// Hits are counted in a fixed table of hashed counters, so unrelated keys may occasionally share a count.
+func PromoteOnHit(n int) PromotionPolicy {
+	return PromotionPolicy{hits: max(1, n)}
+}
+
+// PromoteNever never copies L2 hits into L1; L1 only holds values written through the TieredCache.
+func PromoteNever() PromotionPolicy {
+	return PromotionPolicy{}
+}
+
+// WritePolicy decides how writes reach a level of a TieredCache.
+type WritePolicy int
+
+const (
+	// WriteThrough writes to the level before Set or Delete returns. It is the default.
+	WriteThrough WritePolicy = iota
+	// WriteBack queues writes to the level and applies them in order from a background goroutine,
+	// so Set and Delete do not wait for a slow level. Flush waits for queued writes.
+	WriteBack
+)
+
+// TieredOption configures a TieredCache.
+type TieredOption func(*TieredCache)
+
+// WithPromotion sets the promotion policy.
+func WithPromotion(policy PromotionPolicy) TieredOption {
+	return func(c *TieredCache) {
+		c.promotion = policy
+	}
+}
+
+// WithAsyncPromotion writes promotions to L1 from a background goroutine instead of during Get.
+// Promotions are dropped while the queue is full. Close stops the goroutine.
+func WithAsyncPromotion() TieredOption {
+	return func(c *TieredCache) {
+		c.promotions = make(chan promotion, promotionQueueSize)
+	}
+}
+
+// WithPromotionTTL sets the time to live of promoted entries when L2 cannot tell how long they have left.
+// It defaults to one minute.
+func WithPromotionTTL(d time.Duration) TieredOption {
+	return func(c *TieredCache) {
+		c.promotionTTL = d
+	}
+}
+
+// WithL1TTL replaces the time to live of values written to L1, typically with a much shorter one than L2's
+// since local copies should not lag far behind the shared copy. L1 never keeps a value longer than L2.
+func WithL1TTL(d time.Duration) TieredOption {
+	return func(c *TieredCache) {
+		c.l1TTL = d
+	}
+}
+
+// WithL2TTL replaces the time to live of values written to L2.
+func WithL2TTL(d time.Duration) TieredOption {
+	return func(c *TieredCache) {
+		c.l2TTL = d
+	}
+}
+
+// WithL1WritePolicy sets the write policy of L1.
+func WithL1WritePolicy(policy WritePolicy) TieredOption {
+	return func(c *TieredCache) {
+		c.l1Policy = policy
+	}
+}
+
+// WithL2WritePolicy sets the write policy of L2.
+func WithL2WritePolicy(policy WritePolicy) TieredOption {
+	return func(c *TieredCache) {
+		c.l2Policy = policy
+	}
+}
+
+// WithReadRepair compares a fraction of L1 hits with L2, in [0, 1], and replaces the L1 entry when L2 holds
+// one written after it, so replicas converge even when invalidations are missed. Both levels must implement
+// IEntryInfoGetter, as MemoryCache does; otherwise it has no effect.
+func WithReadRepair(fraction float64) TieredOption {
+	return func(c *TieredCache) {
+		c.readRepair = fraction
+	}
+}
+
+// WithTieredSupervisor runs the asynchronous promotions and write-backs under s instead of DefaultSupervisor.
+func WithTieredSupervisor(s *Supervisor) TieredOption {
+	return func(c *TieredCache) {
+		c.supervisor = s
+	}
+}
+
+// WithTieredRandom sets the source of randomness of WithReadRepair's sampling.
+func WithTieredRandom(r Random) TieredOption {
+	return func(c *TieredCache) {
+		c.random = r
+	}
+}
+
+// TieredCache is a struct that implements the ICache interface over a small, fast L1 cache in front of
+// a larger L2 cache, such as a MemoryCache in front of a shared remote store.
+type TieredCache struct {
+	l1, l2             ICache
+	l2Info             IEntryInfoGetter
+	promotion          PromotionPolicy
+	promotionTTL       time.Duration
+	hits               []atomic.Uint32
+	guards             []promotionGuard
+	l1TTL, l2TTL       time.Duration
+	l1Policy, l2Policy WritePolicy
+	l1Writer, l2Writer *levelWriter
+	l1Info             IEntryInfoGetter
+	readRepair         float64
+	random             Random
+	supervisor         *Supervisor
+	l1Ratio, l2Ratio   float64
+
+	promotions chan promotion
+	stop       chan struct{}
+	stopOnce   sync.Once
+}
+
+type promotion struct {
+	key   string
+	value any
+	d     time.Duration
+	// gen is the write generation of key when the value was read from L2.
+	gen uint64
+}
+
+// promotionGuard counts the writes of the keys hashed to it. A promotion is only written to L1 if no Set or
+// Delete of its key happened since it read L2, so that it never replaces a newer value or revives a deleted
+// one. Unrelated keys sharing a guard only cost a skipped promotion.
+type promotionGuard struct {
+	mu  sync.Mutex
+	gen uint64
+}
+
+func (c *TieredCache) guard(key string) *promotionGuard {
+	return &c.guards[fnv1a(key)%promotionGuardSlots]
+}
+
+// generation returns the write generation of key, to read before L2.
+func (c *TieredCache) generation(key string) uint64 {
+	g := c.guard(key)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.gen
+}
+
+// invalidatePromotions makes the promotions of key that read L2 before now skip their write. Writes call
+// it after writing L2 and before writing L1.
+func (c *TieredCache) invalidatePromotions(key string) {
+	g := c.guard(key)
+	g.mu.Lock()
+	g.gen++
+	g.mu.Unlock()
+}
+
+// Set stores the value in both levels, with the time to live of each level.
+func (c *TieredCache) Set(key string, value any, d time.Duration) {
+	d2 := d
+	if c.l2TTL != 0 {
+		d2 = c.l2TTL
+	}
+	c.write(c.l2, c.l2Writer, levelWrite{key: key, value: value, d: d2})
+	c.invalidatePromotions(key)
+	c.write(c.l1, c.l1Writer, levelWrite{key: key, value: value, d: c.capL1TTL(d2)})
+}
+
+// capL1TTL returns the time to live of an L1 write for a value living d in L2.
+func (c *TieredCache) capL1TTL(d time.Duration) time.Duration {
+	if c.l1TTL > 0 && (d <= 0 || c.l1TTL < d) {
+		return c.l1TTL
+	}
+	return d
+}
+
+func (c *TieredCache) write(level ICache, w *levelWriter, op levelWrite) {
+	if w != nil {
+		w.enqueue(op)
+		return
+	}
+	op.apply(level)
+}
+
+// Flush waits until all queued write-back operations have been applied.
+func (c *TieredCache) Flush() {
+	if c.l2Writer != nil {
+		c.l2Writer.flush()
+	}
+	if c.l1Writer != nil {
+		c.l1Writer.flush()
+	}
+}
+
+// Get retrieves the value from L1, or from L2 and then promotes it according to the promotion policy.
+func (c *TieredCache) Get(key string) (any, bool) {
+	if value, ok := c.getL1(key); ok {
+		return value, true
+	}
+	gen := c.generation(key)
+	value, d, ok := c.getL2(key)
+	if !ok {
+		return nil, false
+	}
+	if c.shouldPromote(key) {
+		c.promote(promotion{key: key, value: value, d: d, gen: gen})
+	}
+	return value, true
+}
+
+// getL1 retrieves the value from L1, repairing a sample of the reads, see WithReadRepair.
+func (c *TieredCache) getL1(key string) (any, bool) {
+	if c.readRepair > 0 && c.l1Info != nil && c.l2Info != nil && c.random.Float64() < c.readRepair {
+		return c.getRepaired(key)
+	}
+	return c.l1.Get(key)
+}
+
+// getRepaired retrieves the value from L1, replacing it with L2's entry when that one was written later.
+func (c *TieredCache) getRepaired(key string) (any, bool) {
+	gen := c.generation(key)
+	value, info, ok := c.l1Info.GetWithInfo(key)
+	if !ok {
+		return nil, false
+	}
+	newer, newerInfo, ok := c.l2Info.GetWithInfo(key)
+	if !ok || !newerInfo.CreatedAt.After(info.CreatedAt) {
+		return value, true
+	}
+	d := c.promotionTTL
+	if !newerInfo.ExpiresAt.IsZero() {
+		d = time.Until(newerInfo.ExpiresAt)
+	}
+	c.writePromotion(promotion{key: key, value: newer, d: c.capL1TTL(d), gen: gen})
+	return newer, true
+}
+
+// getL2 retrieves the value from L2 along with the time to live to give it in L1.
+func (c *TieredCache) getL2(key string) (any, time.Duration, bool) {
+	if c.l2Info == nil {
+		value, ok := c.l2.Get(key)
+		return value, c.capL1TTL(c.promotionTTL), ok
+	}
+	value, info, ok := c.l2Info.GetWithInfo(key)
+	if !ok {
+		return nil, 0, false
+	}
+	if info.ExpiresAt.IsZero() {
+		return value, c.capL1TTL(c.promotionTTL), true
+	}
+	d := time.Until(info.ExpiresAt)
+	if d <= 0 {
+		return nil, 0, false
+	}
+	return value, c.capL1TTL(d), true
+}
+
+// shouldPromote counts an L2 hit of key and reports whether it should be promoted.
+func (c *TieredCache) shouldPromote(key string) bool {
+	switch c.promotion.hits {
+	case 0:
+		return false
+	case 1:
+		return true
+	}
+	counter := &c.hits[fnv1a(key)%promotionCounterSlots]
+	if int(counter.Add(1)) < c.promotion.hits {
+		return false
+	}
+	counter.Store(0)
+	return true
+}
+
+func (c *TieredCache) promote(p promotion) {
+	if c.promotions == nil {
+		c.writePromotion(p)
+		return
+	}
+	select {
+	case c.promotions <- p:
+	default:
+	}
+}
+
+func (c *TieredCache) promoter() {
+	for {
+		select {
+		case p := <-c.promotions:
+			c.writePromotion(p)
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+// writePromotion writes a promotion to L1 unless its key was written since the promotion read L2.
+func (c *TieredCache) writePromotion(p promotion) {
+	g := c.guard(p.key)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.gen == p.gen {
+		c.write(c.l1, c.l1Writer, levelWrite{key: p.key, value: p.value, d: p.d})
+	}
+}
+
+// Delete removes the key from both levels. Promotions of values read from L2 before the Delete, queued or
+// running, are skipped, so that they do not bring the value back into L1.
+func (c *TieredCache) Delete(key string) {
+	c.write(c.l2, c.l2Writer, levelWrite{key: key, delete: true})
+	c.invalidatePromotions(key)
+	c.write(c.l1, c.l1Writer, levelWrite{key: key, delete: true})
+}
+
+// Close stops the asynchronous promotion, if any, and stops write-back after applying queued writes.
+// The cache must not be written to after Close.
+func (c *TieredCache) Close() {
+	c.stopOnce.Do(func() {
+		close(c.stop)
+		c.Flush()
+		if c.l1Writer != nil {
+			c.l1Writer.close()
+		}
+		if c.l2Writer != nil {
+			c.l2Writer.close()
+		}
+	})
+}
+
+// levelWrite is a Set or Delete of one level.
+type levelWrite struct {
+	key    string
+	value  any
+	d      time.Duration
+	delete bool
+}
+
+func (w levelWrite) apply(level ICache) {
+	if w.delete {
+		level.Delete(w.key)
+	} else {
+		level.Set(w.key, w.value, w.d)
+	}
+}
+
+// levelWriter applies the writes of a write-back level in order.
+type levelWriter struct {
+	level      ICache
+	supervisor *Supervisor
+	queue      chan levelWrite
+
+	mu      sync.Mutex
+	pending int
+	idle    *sync.Cond
+}
+
+func newLevelWriter(level ICache, supervisor *Supervisor) *levelWriter {
+	w := &levelWriter{level: level, supervisor: supervisor, queue: make(chan levelWrite, writeBackQueueSize)}
+	w.idle = sync.NewCond(&w.mu)
+	go w.run()
+	return w
+}
+
+func (w *levelWriter) enqueue(op levelWrite) {
+	w.mu.Lock()
+	w.pending++
+	w.mu.Unlock()
+	w.queue <- op
+}
+
+func (w *levelWriter) run() {
+	for op := range w.queue {
+		// A panicking store must neither stop the writer nor leave Flush waiting for the write.
+		w.supervisor.Protect("write-back", func() { op.apply(w.level) })
+		w.mu.Lock()
+		w.pending--
+		if w.pending == 0 {
+			w.idle.Broadcast()
+		}
+		w.mu.Unlock()
+	}
+}
+
+func (w *levelWriter) flush() {
+	w.mu.Lock()
+	for w.pending > 0 {
+		w.idle.Wait()
+	}
+	w.mu.Unlock()
+}
+
+func (w *levelWriter) close() {
+	close(w.queue)
+}
+
+// NewTieredCache creates a new instance of TieredCache over l1 and l2.
+func NewTieredCache(l1, l2 ICache, opts ...TieredOption) *TieredCache {
+	c := &TieredCache{
+		l1:           l1,
+		l2:           l2,
+		promotion:    PromoteAlways(),
+		promotionTTL: defaultPromotionTTL,
+		guards:       make([]promotionGuard, promotionGuardSlots),
+		supervisor:   DefaultSupervisor,
+		stop:         make(chan struct{}),
+	}
+	c.l1Info, _ = l1.(IEntryInfoGetter)
+	c.l2Info, _ = l2.(IEntryInfoGetter)
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.random = randomOrDefault(c.random)
+	if c.promotion.hits > 1 {
+		c.hits = make([]atomic.Uint32, promotionCounterSlots)
+	}
+	if c.l1Policy == WriteBack {
+		c.l1Writer = newLevelWriter(l1, c.supervisor)
+	}
+	if c.l2Policy == WriteBack {
+		c.l2Writer = newLevelWriter(l2, c.supervisor)
+	}
+	if c.promotions != nil {
+		c.supervisor.Go("promoter", c.stop, c.promoter)
+	}
+	return c
+}
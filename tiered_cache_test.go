@@ -0,0 +1,163 @@
+package once_cache
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// hookedCache is an ICache calling its hooks, when set, after each Get and before each Set and Delete.
+type hookedCache struct {
+	ICache
+	onGet    func(key string)
+	onSet    func(key string)
+	onDelete func(key string)
+}
+
+func (c *hookedCache) Get(key string) (any, bool) {
+	value, ok := c.ICache.Get(key)
+	if c.onGet != nil {
+		c.onGet(key)
+	}
+	return value, ok
+}
+
+func (c *hookedCache) Set(key string, value any, d time.Duration) {
+	if c.onSet != nil {
+		c.onSet(key)
+	}
+	c.ICache.Set(key, value, d)
+}
+
+func (c *hookedCache) Delete(key string) {
+	if c.onDelete != nil {
+		c.onDelete(key)
+	}
+	c.ICache.Delete(key)
+}
+
+func TestTieredCacheDeleteRacingGetDoesNotResurrect(t *testing.T) {
+	l1 := NewMemoryCache()
+	var c *TieredCache
+	var reads []any
+	l2 := &hookedCache{ICache: NewMemoryCache(), onDelete: func(key string) {
+		// A Get from another goroutine runs while L2 is being deleted from.
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, _ := c.Get(key)
+			reads = append(reads, v)
+		}()
+		wg.Wait()
+	}}
+	c = NewTieredCache(l1, l2)
+	defer c.Close()
+
+	c.Set("k", "v", time.Minute)
+	c.Delete("k")
+	if len(reads) != 1 {
+		t.Fatalf("racing reads = %v, want one", reads)
+	}
+	if v, ok := c.Get("k"); ok {
+		t.Fatalf("Get after Delete = %v, want a miss", v)
+	}
+	if v, ok := l1.Get("k"); ok {
+		t.Fatalf("L1 holds %v after Delete, want nothing", v)
+	}
+}
+
+func TestTieredCacheConcurrentGetAndDelete(t *testing.T) {
+	c := NewTieredCache(NewMemoryCache(), NewMemoryCache())
+	defer c.Close()
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					c.Get("k")
+				}
+			}
+		}()
+	}
+	for i := 0; i < 1000; i++ {
+		c.Set("k", i, time.Minute)
+		c.Delete("k")
+	}
+	close(stop)
+	wg.Wait()
+	if v, ok := c.Get("k"); ok {
+		t.Fatalf("Get after the last Delete = %v, want a miss", v)
+	}
+}
+
+func TestTieredCacheDeleteAfterL2ReadDoesNotResurrect(t *testing.T) {
+	l1 := NewMemoryCache()
+	var c *TieredCache
+	deleted := false
+	// The Delete runs once the Get has read L2 and before it promotes the value.
+	l2 := &hookedCache{ICache: NewMemoryCache(), onGet: func(key string) {
+		if !deleted {
+			deleted = true
+			c.Delete(key)
+		}
+	}}
+	c = NewTieredCache(l1, l2)
+	defer c.Close()
+
+	l2.ICache.Set("k", "v", time.Minute)
+	if v, ok := c.Get("k"); !ok || v != "v" {
+		t.Fatalf("Get racing the Delete = %v, %v, want v, true", v, ok)
+	}
+	if v, ok := c.Get("k"); ok {
+		t.Fatalf("Get after Delete = %v, want a miss", v)
+	}
+}
+
+func TestTieredCacheDeleteDropsQueuedPromotions(t *testing.T) {
+	gate := make(chan struct{})
+	// The promoter stalls on the promotion of "gate", so that later promotions stay queued.
+	l1 := &hookedCache{ICache: NewMemoryCache(), onSet: func(key string) {
+		if key == "gate" {
+			<-gate
+		}
+	}}
+	l2 := NewMemoryCache()
+	c := NewTieredCache(l1, l2, WithAsyncPromotion())
+	defer c.Close()
+
+	for _, key := range []string{"gate", "k", "sentinel"} {
+		l2.Set(key, key, time.Minute)
+	}
+	c.Get("gate")
+	if v, ok := c.Get("k"); !ok || v != "k" {
+		t.Fatalf("Get = %v, %v, want k, true", v, ok)
+	}
+	c.Delete("k")
+	c.Get("sentinel")
+	close(gate)
+
+	// Promotions are written in order, so k's is done once the sentinel's is.
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, ok := l1.ICache.Get("sentinel"); ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the sentinel was never promoted")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if v, ok := l1.ICache.Get("k"); ok {
+		t.Fatalf("L1 holds %v after Delete, want nothing", v)
+	}
+	if v, ok := c.Get("k"); ok {
+		t.Fatalf("Get after Delete = %v, want a miss", v)
+	}
+}
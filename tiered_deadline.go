@@ -0,0 +1,82 @@
+package once_cache
+
+import (
+	"context"
+	"time"
+)
+
+// WithDeadlineRatios divides the deadline of the context given to GetContext between the levels: L1 lookups
+// may take the l1 fraction of the time left, and L2 lookups the l2 fraction, in [0, 1]. A lookup overrunning
+// its share is a miss, so a slow remote level cannot spend the whole budget, and the rest is left to the
+// caller's loader, such as OnceCache.GetWithContext's. A zero ratio leaves the level unbounded.
+func WithDeadlineRatios(l1, l2 float64) TieredOption {
+	return func(c *TieredCache) {
+		c.l1Ratio, c.l2Ratio = l1, l2
+	}
+}
+
+// GetContext is Get bounded by the deadline of ctx as divided by WithDeadlineRatios. A lookup given up on
+// keeps running in the background, since levels do not take a context. Without a deadline or ratios it is Get.
+func (c *TieredCache) GetContext(ctx context.Context, key string) (any, bool) {
+	deadline, ok := ctx.Deadline()
+	if !ok || (c.l1Ratio <= 0 && c.l2Ratio <= 0) {
+		return c.Get(key)
+	}
+	left := time.Until(deadline)
+	if left <= 0 {
+		return nil, false
+	}
+	l1, ok := within(share(left, c.l1Ratio), func() levelRead {
+		value, ok := c.getL1(key)
+		return levelRead{value: value, ok: ok}
+	})
+	if ok && l1.ok {
+		return l1.value, true
+	}
+	gen := c.generation(key)
+	l2, ok := within(share(left, c.l2Ratio), func() levelRead {
+		value, d, ok := c.getL2(key)
+		return levelRead{value: value, d: d, ok: ok}
+	})
+	if !ok || !l2.ok {
+		return nil, false
+	}
+	if c.shouldPromote(key) {
+		c.promote(promotion{key: key, value: l2.value, d: l2.d, gen: gen})
+	}
+	return l2.value, true
+}
+
+// levelRead is the result of a lookup of one level.
+type levelRead struct {
+	value any
+	d     time.Duration
+	ok    bool
+}
+
+// share returns the ratio of d, zero for no bound.
+func share(d time.Duration, ratio float64) time.Duration {
+	if ratio <= 0 {
+		return 0
+	}
+	return max(1, time.Duration(float64(d)*min(ratio, 1)))
+}
+
+// within runs f, giving up after d if it is positive and reporting whether f finished in time.
+func within(d time.Duration, f func() levelRead) (levelRead, bool) {
+	if d <= 0 {
+		return f(), true
+	}
+	ch := make(chan levelRead, 1)
+	go func() {
+		ch <- f()
+	}()
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case r := <-ch:
+		return r, true
+	case <-timer.C:
+		return levelRead{}, false
+	}
+}
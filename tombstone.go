@@ -0,0 +1,38 @@
+package once_cache
+
+import (
+	"time"
+)
+
+// forgetWindow is how long Forget keeps loads started before it from storing their results.
+const forgetWindow = time.Minute
+
+// tombstone records a deletion that loads started earlier must not undo.
+type tombstone struct {
+	deletedAt time.Time
+}
+
+// DeleteWithTombstone removes the key and, for window, prevents loads that started before the deletion
+// from storing their results, which fixes the race where a slow load repopulates a key with data read
+// before the write that invalidated it. Callers waiting for such a load still receive its value, while
+// calls made after the deletion start a new load.
+func (o *OnceCache) DeleteWithTombstone(key string, window time.Duration) {
+	// New callers must not join a load that started before the deletion.
+	o.tombstone(key, window)
+	o.ICache.Delete(key)
+	o.audit(AuditDelete, key, "", "", 0)
+	o.deleteDependents(key)
+}
+
+// Forget deletes the cached entry and forgets the in-flight load of key, if any, so that after a known
+// mutation the next reader is guaranteed a fresh load. A load in progress still completes for the callers
+// already waiting for it, but its result is not stored unless it takes longer than a minute.
+func (o *OnceCache) Forget(key string) {
+	o.DeleteWithTombstone(key, forgetWindow)
+}
+
+// tombstoned reports whether a load of key that started at start must not store its result.
+func (o *OnceCache) tombstoned(key string, start time.Time) bool {
+	v, ok := o.tombstones.Load(key)
+	return ok && !start.After(v.(*tombstone).deletedAt)
+}
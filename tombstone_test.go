@@ -0,0 +1,35 @@
+package once_cache
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+func TestForgetKeepsEarlierLoadsFromStoring(t *testing.T) {
+	c := NewOnceCache(&singleflight.Group{}, NewMemoryCache())
+	started, release := make(chan struct{}), make(chan struct{})
+	done := make(chan Result)
+	go func() {
+		done <- c.GetResult("a", func() (any, error) {
+			close(started)
+			<-release
+			return "read before the write", nil
+		}, WithTTL(time.Minute))
+	}()
+	<-started
+	c.Forget("a")
+
+	// A call after Forget does not join the earlier load.
+	if res := c.GetResult("a", func() (any, error) { return "fresh", nil }, WithTTL(time.Minute)); res.Value != "fresh" {
+		t.Fatalf("GetResult after Forget = %v, want fresh", res.Value)
+	}
+	close(release)
+	if res := <-done; res.Value != "read before the write" {
+		t.Fatalf("the earlier caller got %v, want its own load", res.Value)
+	}
+	if v, ok := c.Get("a"); !ok || v != "fresh" {
+		t.Fatalf("Get = %v, %v, want the load made after Forget", v, ok)
+	}
+}
@@ -0,0 +1,93 @@
+package once_cache
+
+import (
+	"encoding/gob"
+	"time"
+)
+
+func init() {
+	gob.Register(ttlEnvelope{})
+}
+
+// ttlEnvelope is a value stored by TTLCache along with its expiry, in Unix nanoseconds, zero if it never
+// expires. Its fields are exported so that codecs can encode it.
+type ttlEnvelope struct {
+	Value     any   `json:"value"`
+	ExpiresAt int64 `json:"expires_at"`
+}
+
+// TTLCache is a struct that implements the ICacheWithError interface by emulating expiration on top of a
+// store without native TTLs, such as a plain map or an object store. Each value is stored in an envelope
+// holding its expiry, and expired values are misses. The store only sees entries that never expire, so
+// expired entries stay in it until they are overwritten or deleted; they are served by GetStale meanwhile.
+type TTLCache struct {
+	store ICacheWithError
+}
+
+// Set stores the value with the specified time to live. A non-positive duration never expires.
+func (c *TTLCache) Set(key string, value any, d time.Duration) error {
+	env := ttlEnvelope{Value: value}
+	if d > 0 {
+		env.ExpiresAt = time.Now().Add(d).UnixNano()
+	}
+	return c.store.Set(key, env, NoExpiration)
+}
+
+// Get retrieves the value if it exists and has not expired.
+func (c *TTLCache) Get(key string) (any, bool, error) {
+	env, ok, err := c.get(key)
+	if err != nil || !ok || env.expired(time.Now().UnixNano()) {
+		return nil, false, err
+	}
+	return env.Value, true, nil
+}
+
+// GetStale retrieves the value even if it has expired, along with its expiry time.
+func (c *TTLCache) GetStale(key string) (any, time.Time, bool) {
+	env, ok, err := c.get(key)
+	if err != nil || !ok {
+		return nil, time.Time{}, false
+	}
+	var expiresAt time.Time
+	if env.ExpiresAt != 0 {
+		expiresAt = time.Unix(0, env.ExpiresAt)
+	}
+	return env.Value, expiresAt, true
+}
+
+// Delete removes the key from the store.
+func (c *TTLCache) Delete(key string) error {
+	return c.store.Delete(key)
+}
+
+// get reads the envelope of key. Values stored without an envelope never expire, and envelopes decoded by
+// JSONCodec into maps are converted back.
+func (c *TTLCache) get(key string) (ttlEnvelope, bool, error) {
+	value, ok, err := c.store.Get(key)
+	if err != nil || !ok {
+		return ttlEnvelope{}, false, err
+	}
+	switch v := value.(type) {
+	case ttlEnvelope:
+		return v, true, nil
+	case map[string]any:
+		if exp, ok := v["expires_at"].(float64); ok && len(v) == 2 {
+			return ttlEnvelope{Value: v["value"], ExpiresAt: int64(exp)}, true, nil
+		}
+	}
+	return ttlEnvelope{Value: value}, true, nil
+}
+
+func (e ttlEnvelope) expired(now int64) bool {
+	return e.ExpiresAt != 0 && now >= e.ExpiresAt
+}
+
+// NewTTLCacheWithError creates a new instance of TTLCache emulating expiration on top of store.
+func NewTTLCacheWithError(store ICacheWithError) *TTLCache {
+	return &TTLCache{store: store}
+}
+
+// NewTTLCache creates a TTLCache emulating expiration on top of a store that does not report errors.
+func NewTTLCache(store ICache) ICache {
+	return NewCacheIgnoringErrors(NewTTLCacheWithError(NewCacheWithError(store)))
+}
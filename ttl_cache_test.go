@@ -0,0 +1,43 @@
+package once_cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTTLCacheEmulatesExpiry(t *testing.T) {
+	store := NewMemoryCache()
+	c := NewTTLCacheWithError(NewCacheWithError(store))
+	c.Set("a", 1, time.Millisecond)
+	c.Set("b", 2, 0)
+	if v, ok, err := c.Get("a"); err != nil || !ok || v != 1 {
+		t.Fatalf("Get = %v, %v, %v, want 1", v, ok, err)
+	}
+	time.Sleep(2 * time.Millisecond)
+	if v, ok, _ := c.Get("a"); ok {
+		t.Fatalf("Get of an expired entry = %v, want a miss", v)
+	}
+	if v, expiresAt, ok := c.GetStale("a"); !ok || v != 1 || expiresAt.IsZero() {
+		t.Fatalf("GetStale = %v, %v, %v, want the expired value and its expiry", v, expiresAt, ok)
+	}
+	if v, ok, _ := c.Get("b"); !ok || v != 2 {
+		t.Fatalf("Get of an entry without expiry = %v, %v, want 2", v, ok)
+	}
+	if _, ok := store.Get("a"); !ok {
+		t.Fatal("the store expired the entry itself, want it stored without expiry")
+	}
+}
+
+func TestTTLCacheReadsEnvelopesDecodedAsMaps(t *testing.T) {
+	store := NewMemoryCache()
+	c := NewTTLCacheWithError(NewCacheWithError(store))
+	past := float64(time.Now().Add(-time.Second).UnixNano())
+	store.Set("expired", map[string]any{"value": "v", "expires_at": past}, 0)
+	store.Set("raw", "v", 0)
+	if v, ok, _ := c.Get("expired"); ok {
+		t.Fatalf("Get of an expired JSON envelope = %v, want a miss", v)
+	}
+	if v, ok, _ := c.Get("raw"); !ok || v != "v" {
+		t.Fatalf("Get of a value without envelope = %v, %v, want v", v, ok)
+	}
+}
@@ -0,0 +1,62 @@
+package once_cache
+
+import (
+	"sort"
+	"time"
+)
+
+// defaultTTLBounds are the histogram bounds used when TTLHistogram is given none.
+var defaultTTLBounds = []time.Duration{time.Second, 10 * time.Second, time.Minute, 10 * time.Minute, time.Hour}
+
+// TTLHistogram counts entries by remaining time to live.
+type TTLHistogram struct {
+	// Bounds are the sorted upper bounds of the buckets.
+	Bounds []time.Duration
+	// Counts[i] is the number of entries expiring within Bounds[i] but not within Bounds[i-1].
+	// The last count is for entries expiring after the last bound.
+	Counts []int
+	// NoExpiry is the number of entries that never expire.
+	NoExpiry int
+	// Expired is the number of expired entries not yet removed.
+	Expired int
+}
+
+// TTLHistogram returns a histogram of the remaining time to live of the entries, with the specified bucket
+// bounds or 1s, 10s, 1m, 10m and 1h. It helps predicting refresh storms, such as after bulk warm-ups.
+// It scans every entry.
+func (c *MemoryCache) TTLHistogram(bounds ...time.Duration) TTLHistogram {
+	if len(bounds) == 0 {
+		bounds = defaultTTLBounds
+	}
+	bounds = append([]time.Duration(nil), bounds...)
+	sort.Slice(bounds, func(i, j int) bool { return bounds[i] < bounds[j] })
+	h := TTLHistogram{Bounds: bounds, Counts: make([]int, len(bounds)+1)}
+	now := time.Now().UnixNano()
+	c.storage.rangeEntries(func(key string, e memoryEntry) bool {
+		switch {
+		case e.expiresAt == 0:
+			h.NoExpiry++
+		case e.expired(now):
+			h.Expired++
+		default:
+			remaining := time.Duration(e.expiresAt - now)
+			h.Counts[sort.Search(len(bounds), func(i int) bool { return remaining <= bounds[i] })]++
+		}
+		return true
+	})
+	return h
+}
+
+// ExpiringWithin returns how many live entries expire within d.
+func (c *MemoryCache) ExpiringWithin(d time.Duration) int {
+	now := time.Now().UnixNano()
+	deadline := now + int64(d)
+	n := 0
+	c.storage.rangeEntries(func(key string, e memoryEntry) bool {
+		if e.expiresAt != 0 && !e.expired(now) && e.expiresAt <= deadline {
+			n++
+		}
+		return true
+	})
+	return n
+}
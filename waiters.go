@@ -0,0 +1,64 @@
+package once_cache
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrTooManyWaiters is reported to callers turned away because too many are already waiting for the
+// load of the same key, see WithMaxWaiters.
+var ErrTooManyWaiters = errors.New("once_cache: too many waiters")
+
+// waiterShards is the number of mutex-guarded shards counting waiters.
+const waiterShards = 32
+
+// WithMaxWaiters bounds how many callers may wait for the load of one key, including the caller running it.
+// Callers beyond the bound are served the stale value if the store retains one, and fail right away with
+// ErrTooManyWaiters otherwise, so that one slow key cannot pin thousands of goroutines.
+func WithMaxWaiters(n int) Option {
+	return func(o *OnceCache) {
+		o.waiters = newWaiterLimiter(n)
+	}
+}
+
+// waiterLimiter counts the callers waiting for each key.
+type waiterLimiter struct {
+	max    int
+	shards [waiterShards]waiterShard
+}
+
+type waiterShard struct {
+	mu    sync.Mutex
+	count map[string]int
+}
+
+func newWaiterLimiter(n int) *waiterLimiter {
+	l := &waiterLimiter{max: n}
+	for i := range l.shards {
+		l.shards[i].count = make(map[string]int)
+	}
+	return l
+}
+
+// acquire records a waiter for key, reporting false if there are too many already.
+func (l *waiterLimiter) acquire(key string) bool {
+	sh := &l.shards[fnv1a(key)%waiterShards]
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if sh.count[key] >= l.max {
+		return false
+	}
+	sh.count[key]++
+	return true
+}
+
+func (l *waiterLimiter) release(key string) {
+	sh := &l.shards[fnv1a(key)%waiterShards]
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if sh.count[key] <= 1 {
+		delete(sh.count, key)
+	} else {
+		sh.count[key]--
+	}
+}
@@ -0,0 +1,36 @@
+package once_cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+func TestMaxWaitersTurnsAwayExtraCallers(t *testing.T) {
+	c := NewOnceCache(&singleflight.Group{}, NewMemoryCache(), WithMaxWaiters(1))
+	started, release := make(chan struct{}), make(chan struct{})
+	done := make(chan Result)
+	go func() {
+		done <- c.GetResult("a", func() (any, error) {
+			close(started)
+			<-release
+			return "v", nil
+		}, WithTTL(time.Minute))
+	}()
+	<-started
+	if res := c.GetResult("a", func() (any, error) { return "other", nil }); !errors.Is(res.Err, ErrTooManyWaiters) {
+		t.Fatalf("GetResult beyond the bound = %+v, want ErrTooManyWaiters", res)
+	}
+	if res := c.GetResult("b", func() (any, error) { return "b", nil }); res.Err != nil {
+		t.Fatalf("GetResult of another key = %v, want its own bound", res.Err)
+	}
+	close(release)
+	if res := <-done; res.Value != "v" {
+		t.Fatalf("the waiting caller got %+v, want v", res)
+	}
+	if res := c.GetResult("a", func() (any, error) { return "other", nil }, WithForceRefresh()); res.Err != nil {
+		t.Fatalf("GetResult after the load = %v, want the waiter released", res.Err)
+	}
+}